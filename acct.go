@@ -0,0 +1,79 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// Acct represents parsed byte accounting of a transaction, as found in
+// ReqAcct and BereqAcct records. Received and transmitted bytes are from the
+// point of view of Varnish.
+type Acct struct {
+	HeaderRx int64 // Header bytes received.
+	BodyRx   int64 // Body bytes received.
+	TotalRx  int64 // Total bytes received.
+	HeaderTx int64 // Header bytes transmitted.
+	BodyTx   int64 // Body bytes transmitted.
+	TotalTx  int64 // Total bytes transmitted.
+}
+
+// parseInts parses the n white-space separated integers of the record with
+// the given tag and value.
+func parseInts(tag, v string, n int) ([]int64, error) {
+	fs := strings.Fields(v)
+	if len(fs) != n {
+		return nil, errors.Errorf("%s record %q is malformed", tag, v)
+	}
+	is := make([]int64, n)
+	for i, f := range fs {
+		var err error
+		if is[i], err = strconv.ParseInt(f, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s record %q", tag, v)
+		}
+	}
+	return is, nil
+}
+
+// ReqAcct parses and returns the ReqAcct record of the log entry, which holds
+// the bytes received from and transmitted to the client.
+func (e *Entry) ReqAcct() (*Acct, error) {
+	fs, err := e.Field("ReqAcct")
+	if err != nil {
+		return nil, err
+	}
+	is, err := parseInts("ReqAcct", fs[0], 6)
+	if err != nil {
+		return nil, err
+	}
+	return &Acct{
+		HeaderRx: is[0],
+		BodyRx:   is[1],
+		TotalRx:  is[2],
+		HeaderTx: is[3],
+		BodyTx:   is[4],
+		TotalTx:  is[5],
+	}, nil
+}
+
+// BereqAcct parses and returns the BereqAcct record of the log entry, which
+// holds the bytes transmitted to and received from the backend. Unlike
+// ReqAcct, the record lists the transmitted bytes first.
+func (e *Entry) BereqAcct() (*Acct, error) {
+	fs, err := e.Field("BereqAcct")
+	if err != nil {
+		return nil, err
+	}
+	is, err := parseInts("BereqAcct", fs[0], 6)
+	if err != nil {
+		return nil, err
+	}
+	return &Acct{
+		HeaderTx: is[0],
+		BodyTx:   is[1],
+		TotalTx:  is[2],
+		HeaderRx: is[3],
+		BodyRx:   is[4],
+		TotalRx:  is[5],
+	}, nil
+}
@@ -0,0 +1,49 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAcct tests that ReqAcct and BereqAcct records are parsed correctly and
+// that malformed ones produce errors.
+func TestAcct(t *testing.T) {
+	want := &Acct{HeaderRx: 24, TotalRx: 24, HeaderTx: 233, BodyTx: 2, TotalTx: 235}
+	got, err := example().ReqAcct()
+	if err != nil {
+		t.Fatalf("parsing ReqAcct record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing ReqAcct record should give %v, got %v", want, got)
+	}
+
+	e, err := Parse(stringScanner("* << BeReq >> 3\n- BereqAcct 180 0 180 320 4294967296 4294967616\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want = &Acct{HeaderTx: 180, TotalTx: 180, HeaderRx: 320, BodyRx: 4294967296, TotalRx: 4294967616}
+	if got, err = e.BereqAcct(); err != nil {
+		t.Fatalf("parsing BereqAcct record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing BereqAcct record should give %v, got %v", want, got)
+	}
+	if _, err := e.ReqAcct(); err == nil {
+		t.Errorf("parsing missing ReqAcct record should fail")
+	}
+
+	bad := []string{
+		"",
+		"24 0 24 233 2",
+		"24 0 24 233 2 235 0",
+		"24 0 24 233 two 235",
+	}
+	for _, v := range bad {
+		e := &Entry{Fields: Fields{"ReqAcct": []string{v}}}
+		if _, err := e.ReqAcct(); err == nil {
+			t.Errorf("parsing ReqAcct record %q should fail", v)
+		} else {
+			t.Logf("parsing ReqAcct record %q gives: %v", v, err)
+		}
+	}
+}
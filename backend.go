@@ -0,0 +1,147 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// BackendOpen represents a parsed BackendOpen record, which reports a
+// connection to a backend, e.g.:
+//
+//	17 boot.default 127.0.0.1 8080 127.0.0.1 41234 connect
+//
+// Varnish before 6.0 does not report whether the connection was opened or
+// reused, in which case Reason is empty.
+type BackendOpen struct {
+	FD         int        // File descriptor of the connection.
+	Name       string     // Name of the backend, e.g. "boot.default".
+	RemoteAddr netip.Addr // Address of the backend.
+	RemotePort uint16     // Port of the backend.
+	LocalAddr  netip.Addr // Local address of the connection.
+	LocalPort  uint16     // Local port of the connection.
+	Reason     string     // "connect" or "reuse".
+}
+
+// parseBackendOpen parses the value of a BackendOpen record.
+func parseBackendOpen(v string) (*BackendOpen, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 6 && len(fs) != 7 {
+		return nil, errors.Errorf("BackendOpen record %q is malformed", v)
+	}
+	b := &BackendOpen{Name: fs[1]}
+	var err error
+	if b.FD, err = strconv.Atoi(fs[0]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse BackendOpen record %q", v)
+	}
+	if b.RemoteAddr, b.RemotePort, err = parseAddrPort(fs[2], fs[3]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse BackendOpen record %q", v)
+	}
+	if b.LocalAddr, b.LocalPort, err = parseAddrPort(fs[4], fs[5]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse BackendOpen record %q", v)
+	}
+	if len(fs) == 7 {
+		b.Reason = fs[6]
+	}
+	return b, nil
+}
+
+// BackendOpen parses and returns the BackendOpen record of the log entry.
+func (e *Entry) BackendOpen() (*BackendOpen, error) {
+	fs, err := e.Field("BackendOpen")
+	if err != nil {
+		return nil, err
+	}
+	return parseBackendOpen(fs[0])
+}
+
+// BackendStart represents a parsed BackendStart record, which reports the
+// endpoint of the backend a request is sent to, e.g. "127.0.0.1 8080".
+type BackendStart struct {
+	Addr netip.Addr // Address of the backend.
+	Port uint16     // Port of the backend.
+}
+
+// BackendStart parses and returns the BackendStart record of the log entry.
+func (e *Entry) BackendStart() (*BackendStart, error) {
+	fs, err := e.Field("BackendStart")
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(fs[0])
+	if len(parts) != 2 {
+		return nil, errors.Errorf("BackendStart record %q is malformed", fs[0])
+	}
+	b := &BackendStart{}
+	if b.Addr, b.Port, err = parseAddrPort(parts[0], parts[1]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse BackendStart record %q", fs[0])
+	}
+	return b, nil
+}
+
+// parseBackendConn parses the file descriptor, the backend name and the
+// optional reason of a BackendClose or BackendReuse record with the given
+// tag and value.
+func parseBackendConn(tag, v string) (int, string, string, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 2 && len(fs) != 3 {
+		return 0, "", "", errors.Errorf("%s record %q is malformed", tag, v)
+	}
+	fd, err := strconv.Atoi(fs[0])
+	if err != nil {
+		return 0, "", "", errors.Wrapf(err, "cannot parse %s record %q", tag, v)
+	}
+	reason := ""
+	if len(fs) == 3 {
+		reason = fs[2]
+	}
+	return fd, fs[1], reason, nil
+}
+
+// BackendClose represents a parsed BackendClose record, which reports the end
+// of the use of a backend connection by a transaction, e.g.
+// "17 boot.default recycle". Varnish before 5.0 does not report the reason,
+// in which case Reason is empty.
+type BackendClose struct {
+	FD     int    // File descriptor of the connection.
+	Name   string // Name of the backend, e.g. "boot.default".
+	Reason string // "close" if the connection was closed, "recycle" if kept.
+}
+
+// BackendClose parses and returns the BackendClose record of the log entry.
+func (e *Entry) BackendClose() (*BackendClose, error) {
+	fs, err := e.Field("BackendClose")
+	if err != nil {
+		return nil, err
+	}
+	b := &BackendClose{}
+	if b.FD, b.Name, b.Reason, err = parseBackendConn("BackendClose", fs[0]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// BackendReuse represents a parsed BackendReuse record, which reports that a
+// backend connection is returned to the pool for reuse, e.g.
+// "17 boot.default". Only Varnish before 5.0 logs it.
+type BackendReuse struct {
+	FD   int    // File descriptor of the connection.
+	Name string // Name of the backend, e.g. "boot.default".
+}
+
+// BackendReuse parses and returns the BackendReuse record of the log entry.
+func (e *Entry) BackendReuse() (*BackendReuse, error) {
+	fs, err := e.Field("BackendReuse")
+	if err != nil {
+		return nil, err
+	}
+	fd, name, reason, err := parseBackendConn("BackendReuse", fs[0])
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, errors.Errorf("BackendReuse record %q is malformed", fs[0])
+	}
+	return &BackendReuse{FD: fd, Name: name}, nil
+}
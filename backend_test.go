@@ -0,0 +1,103 @@
+package vslparser
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// TestBackendOpen tests that BackendOpen records are parsed correctly and
+// that malformed ones produce errors.
+func TestBackendOpen(t *testing.T) {
+	samples := map[string]*BackendOpen{
+		"17 boot.default 127.0.0.1 8080 127.0.0.1 41234 connect": &BackendOpen{
+			FD:         17,
+			Name:       "boot.default",
+			RemoteAddr: netip.MustParseAddr("127.0.0.1"),
+			RemotePort: 8080,
+			LocalAddr:  netip.MustParseAddr("127.0.0.1"),
+			LocalPort:  41234,
+			Reason:     "connect",
+		},
+		"23 origin 2001:db8::10 443 2001:db8::1 50122": &BackendOpen{
+			FD:         23,
+			Name:       "origin",
+			RemoteAddr: netip.MustParseAddr("2001:db8::10"),
+			RemotePort: 443,
+			LocalAddr:  netip.MustParseAddr("2001:db8::1"),
+			LocalPort:  50122,
+		},
+	}
+	for v, want := range samples {
+		got, err := parseBackendOpen(v)
+		if err != nil {
+			t.Errorf("parsing BackendOpen record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing BackendOpen record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"17 boot.default 127.0.0.1 8080",
+		"fd boot.default 127.0.0.1 8080 127.0.0.1 41234",
+		"17 boot.default backend 8080 127.0.0.1 41234",
+		"17 boot.default 127.0.0.1 8080 127.0.0.1 local",
+	}
+	for _, v := range bad {
+		if _, err := parseBackendOpen(v); err == nil {
+			t.Errorf("parsing BackendOpen record %q should fail", v)
+		} else {
+			t.Logf("parsing BackendOpen record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestBackendConn tests that BackendStart, BackendClose and BackendReuse
+// records are parsed correctly and that malformed ones produce errors.
+func TestBackendConn(t *testing.T) {
+	e, err := Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BackendOpen 17 boot.default 127.0.0.1 8080 127.0.0.1 41234 connect\n" +
+		"- BackendStart 127.0.0.1 8080\n" +
+		"- BackendClose 17 boot.default recycle\n" +
+		"- BackendReuse 17 boot.default\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if o, err := e.BackendOpen(); err != nil || o.FD != 17 {
+		t.Errorf("parsing BackendOpen record should give fd 17, got %v (%v)", o, err)
+	}
+	wantStart := &BackendStart{Addr: netip.MustParseAddr("127.0.0.1"), Port: 8080}
+	if s, err := e.BackendStart(); err != nil || !reflect.DeepEqual(wantStart, s) {
+		t.Errorf("parsing BackendStart record should give %v, got %v (%v)", wantStart, s, err)
+	}
+	wantClose := &BackendClose{FD: 17, Name: "boot.default", Reason: "recycle"}
+	if c, err := e.BackendClose(); err != nil || !reflect.DeepEqual(wantClose, c) {
+		t.Errorf("parsing BackendClose record should give %v, got %v (%v)", wantClose, c, err)
+	}
+	wantReuse := &BackendReuse{FD: 17, Name: "boot.default"}
+	if r, err := e.BackendReuse(); err != nil || !reflect.DeepEqual(wantReuse, r) {
+		t.Errorf("parsing BackendReuse record should give %v, got %v (%v)", wantReuse, r, err)
+	}
+
+	bad := &Entry{Fields: Fields{
+		"BackendStart": []string{"127.0.0.1"},
+		"BackendClose": []string{"fd boot.default"},
+		"BackendReuse": []string{"17 boot.default recycle"},
+	}}
+	if _, err := bad.BackendStart(); err == nil {
+		t.Errorf("parsing malformed BackendStart record should fail")
+	}
+	if _, err := bad.BackendClose(); err == nil {
+		t.Errorf("parsing malformed BackendClose record should fail")
+	}
+	if _, err := bad.BackendReuse(); err == nil {
+		t.Errorf("parsing malformed BackendReuse record should fail")
+	}
+	if _, err := example().BackendOpen(); err == nil {
+		t.Errorf("parsing missing BackendOpen record should fail")
+	}
+}
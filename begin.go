@@ -0,0 +1,98 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// Begin represents a parsed Begin record, which opens every transaction and
+// ties it to its parent, e.g. "req 32769 rxreq".
+type Begin struct {
+	Kind   Kind   // Kind of the transaction, Unknown if its Type is unknown.
+	Type   string // Type of the transaction, e.g. "sess", "req" or "bereq".
+	Parent uint64 // VXID of the parent transaction, zero for sessions.
+	Reason string // Reason of the transaction, e.g. "rxreq", "esi" or "fetch".
+}
+
+// parseBegin parses the value of a Begin record.
+func parseBegin(v string) (*Begin, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 3 {
+		return nil, errors.Errorf("Begin record %q is malformed", v)
+	}
+	parent, err := strconv.ParseUint(fs[1], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse parent VXID of Begin record %q", v)
+	}
+	return &Begin{
+		Kind:   beginKinds[fs[0]],
+		Type:   fs[0],
+		Parent: parent,
+		Reason: fs[2],
+	}, nil
+}
+
+// Begin parses and returns the Begin record of the log entry.
+func (e *Entry) Begin() (*Begin, error) {
+	fs, err := e.Field("Begin")
+	if err != nil {
+		return nil, err
+	}
+	return parseBegin(fs[0])
+}
+
+// Parent returns the VXID of the parent transaction of the log entry and the
+// reason of the entry, as reported by its Begin record, e.g. 32769 and
+// "rxreq" for a client request received over session 32769. ok is false if
+// the entry has no parent, i.e. it is a session, or no valid Begin record.
+func (e *Entry) Parent() (vxid uint64, reason string, ok bool) {
+	b, err := e.Begin()
+	if err != nil || b.Parent == 0 {
+		return 0, "", false
+	}
+	return b.Parent, b.Reason, true
+}
+
+// Link represents a parsed Link record, which ties a transaction to one of its
+// children, e.g. "bereq 32771 fetch".
+type Link struct {
+	Kind   Kind   // Kind of the child, Unknown if its Type is unknown.
+	Type   string // Type of the child, e.g. "req" or "bereq".
+	Child  uint64 // VXID of the child transaction.
+	Reason string // Reason of the child, e.g. "rxreq", "esi" or "fetch".
+}
+
+// parseLink parses the value of a Link record. Fields following the reason,
+// which some versions of Varnish append, are ignored.
+func parseLink(v string) (*Link, error) {
+	fs := strings.Fields(v)
+	if len(fs) < 3 {
+		return nil, errors.Errorf("Link record %q is malformed", v)
+	}
+	child, err := strconv.ParseUint(fs[1], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse child VXID of Link record %q", v)
+	}
+	return &Link{
+		Kind:   beginKinds[fs[0]],
+		Type:   fs[0],
+		Child:  child,
+		Reason: fs[2],
+	}, nil
+}
+
+// Links parses and returns all Link records of the log entry, in the order in
+// which they appear. An entry without children has no links.
+func (e *Entry) Links() ([]*Link, error) {
+	fs := e.Fields["Link"]
+	links := make([]*Link, 0, len(fs))
+	for _, v := range fs {
+		l, err := parseLink(v)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
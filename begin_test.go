@@ -0,0 +1,112 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBegin tests that Begin records are parsed correctly and that malformed
+// ones produce errors.
+func TestBegin(t *testing.T) {
+	want := &Begin{Kind: Request, Type: "req", Parent: 29236595, Reason: "rxreq"}
+	got, err := example().Begin()
+	if err != nil {
+		t.Fatalf("parsing Begin record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing Begin record should give %v, got %v", want, got)
+	}
+
+	samples := map[string]*Begin{
+		"sess 0 HTTP/1":         &Begin{Kind: Session, Type: "sess", Parent: 0, Reason: "HTTP/1"},
+		"bereq 32770 fetch":     &Begin{Kind: BeReq, Type: "bereq", Parent: 32770, Reason: "fetch"},
+		"req 32770 esi":         &Begin{Kind: Request, Type: "req", Parent: 32770, Reason: "esi"},
+		"quic 1 rxreq":          &Begin{Kind: Unknown, Type: "quic", Parent: 1, Reason: "rxreq"},
+		"req 4294967296 rxreq":  &Begin{Kind: Request, Type: "req", Parent: 4294967296, Reason: "rxreq"},
+		"bereq  32770   retry ": &Begin{Kind: BeReq, Type: "bereq", Parent: 32770, Reason: "retry"},
+	}
+	for v, want := range samples {
+		got, err := parseBegin(v)
+		if err != nil {
+			t.Errorf("parsing Begin record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing Begin record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"req 1",
+		"req foo rxreq",
+		"req -1 rxreq",
+		"req 1 rxreq extra",
+	}
+	for _, v := range bad {
+		if _, err := parseBegin(v); err == nil {
+			t.Errorf("parsing Begin record %q should fail", v)
+		} else {
+			t.Logf("parsing Begin record %q gives: %v", v, err)
+		}
+	}
+	if _, err := newEntry().Begin(); err == nil {
+		t.Errorf("parsing missing Begin record should fail")
+	}
+}
+
+// TestParent tests that parents are taken from Begin records and that
+// sessions and entries without a valid Begin record have none.
+func TestParent(t *testing.T) {
+	if vxid, reason, ok := example().Parent(); !ok || vxid != 29236595 || reason != "rxreq" {
+		t.Errorf("parent of example should be 29236595 with rxreq, got %d with %q (%v)", vxid, reason, ok)
+	}
+	bad := []*Entry{
+		NewEntry(Session, 1).Add("Begin", "sess 0 HTTP/1"),
+		NewEntry(Request, 2).Add("Begin", "req x rxreq"),
+		NewEntry(Request, 2),
+	}
+	for _, e := range bad {
+		if vxid, reason, ok := e.Parent(); ok || vxid != 0 || reason != "" {
+			t.Errorf("%v should have no parent, got %d with %q", e, vxid, reason)
+		}
+	}
+}
+
+// TestLinks tests that Link records are parsed correctly and that malformed
+// ones produce errors.
+func TestLinks(t *testing.T) {
+	e, err := Parse(stringScanner("* << Request >> 2\n- Link bereq 3 fetch\n- Link req 4 esi\n- Link req 5 esi 1\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := []*Link{
+		&Link{Kind: BeReq, Type: "bereq", Child: 3, Reason: "fetch"},
+		&Link{Kind: Request, Type: "req", Child: 4, Reason: "esi"},
+		&Link{Kind: Request, Type: "req", Child: 5, Reason: "esi"},
+	}
+	got, err := e.Links()
+	if err != nil {
+		t.Fatalf("parsing Link records should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing Link records should give %v, got %v", want, got)
+	}
+
+	if links, err := example().Links(); err != nil || len(links) != 0 {
+		t.Errorf("entry without Link records should have no links, got %v (%v)", links, err)
+	}
+
+	bad := []string{
+		"",
+		"bereq 3",
+		"bereq foo fetch",
+	}
+	for _, v := range bad {
+		if _, err := parseLink(v); err == nil {
+			t.Errorf("parsing Link record %q should fail", v)
+		} else {
+			t.Logf("parsing Link record %q gives: %v", v, err)
+		}
+	}
+}
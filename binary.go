@@ -0,0 +1,164 @@
+package vslparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// binaryFileID is the header of binary log files written by varnishlog -w.
+const binaryFileID = "VSL\x00"
+
+// Layout of the binary log records, see vapi/vsl_int.h in Varnish sources.
+// Each record starts with a word holding the tag, format version and length
+// of the record. Version 2 records follow with a word holding the marker bits
+// and a 30-bit VXID, version 3 records with two words holding the marker bits
+// and a 62-bit VXID. The NUL-terminated content padded to whole words comes
+// last.
+const (
+	binaryClientMarker  = 1 << 30
+	binaryBackendMarker = 1 << 31
+	binaryIdentMask     = binaryClientMarker - 1
+	binaryVersion3      = 1
+)
+
+// BinaryReader reads log records from the binary format written by
+// varnishlog -w, so that such captures can be processed without rendering
+// them with varnishlog -r first.
+type BinaryReader struct {
+	// Tags maps the numeric tags of records to their names. It defaults to
+	// the tags of DefaultTagVersion, and has to be replaced, e.g. by the
+	// result of TagNames, when reading files written by a version of
+	// Varnish which numbers tags differently.
+	Tags []string
+	// ByteOrder is the byte order in which the file was written. It defaults
+	// to the native byte order of the host.
+	ByteOrder binary.ByteOrder
+
+	r       *bufio.Reader
+	buf     []byte
+	grouper vxidGrouper
+}
+
+// NewBinaryReader returns a new BinaryReader reading the binary log from r.
+// An error is returned if r does not start with the header of the format.
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+	br := &BinaryReader{
+		Tags:      tagTable,
+		ByteOrder: binary.NativeEndian,
+		r:         bufio.NewReader(r),
+	}
+	head := make([]byte, len(binaryFileID))
+	if _, err := io.ReadFull(br.r, head); err != nil {
+		return nil, errors.Wrap(err, "cannot read binary log file header")
+	}
+	if string(head) != binaryFileID {
+		return nil, errors.New("not a binary log file")
+	}
+	return br, nil
+}
+
+// word reads a single word of the binary log.
+func (br *BinaryReader) word() (uint32, error) {
+	var w [4]byte
+	if _, err := io.ReadFull(br.r, w[:]); err != nil {
+		return 0, err
+	}
+	return br.ByteOrder.Uint32(w[:]), nil
+}
+
+// tagName returns the name of the numeric tag t according to the table tags.
+func tagName(tags []string, t uint32) string {
+	if int(t) < len(tags) && tags[t] != "" {
+		return tags[t]
+	}
+	return "Tag" + strconv.Itoa(int(t))
+}
+
+// binaryHeader returns a RawRecord initialized from the first two header
+// words of a binary record, and whether it is a version 3 record, which has
+// the lower 32 bits of its VXID in the following word.
+func binaryHeader(tags []string, head, id uint32) (*RawRecord, bool) {
+	r := &RawRecord{
+		VXID: uint64(id & binaryIdentMask),
+		Tag:  tagName(tags, head>>24),
+		Type: '-',
+	}
+	switch {
+	case id&binaryClientMarker != 0:
+		r.Type = 'c'
+	case id&binaryBackendMarker != 0:
+		r.Type = 'b'
+	}
+	return r, (head>>16)&0x03 == binaryVersion3
+}
+
+// binaryLength returns the length of the content of a binary record, and the
+// number of words it occupies.
+func binaryLength(head uint32) (int, int) {
+	n := int(head & 0xffff)
+	return n, (n + 3) / 4
+}
+
+// binaryValue returns the value of a record given its NUL-terminated content.
+func binaryValue(data []byte) string {
+	return strings.TrimRight(string(data), "\x00")
+}
+
+// Next reads the next record of the binary log. io.EOF is returned at the end
+// of the log.
+func (br *BinaryReader) Next() (*RawRecord, error) {
+	head, err := br.word()
+	if err != nil {
+		return nil, err
+	}
+	unexpected := func(err error) error {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return errors.Wrap(err, "cannot read binary log record")
+	}
+	id, err := br.word()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	r, v3 := binaryHeader(br.Tags, head, id)
+	if v3 {
+		lo, err := br.word()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		r.VXID = r.VXID<<32 | uint64(lo)
+	}
+	n, words := binaryLength(head)
+	if cap(br.buf) < 4*words {
+		br.buf = make([]byte, 4*words)
+	}
+	buf := br.buf[:4*words]
+	if _, err := io.ReadFull(br.r, buf); err != nil {
+		return nil, unexpected(err)
+	}
+	r.Value = binaryValue(buf[:n])
+	return r, nil
+}
+
+// NextEntry reads records of the binary log until a transaction is complete,
+// and returns its entry, grouping the records the same way varnishlog does in
+// its default VXID grouping. Records which belong to no transaction are
+// skipped. Entries of transactions which began before the log was written
+// have Incomplete set. io.EOF is returned at the end of the log.
+func (br *BinaryReader) NextEntry() (*Entry, error) {
+	for {
+		if e := br.grouper.next(); e != nil {
+			return e, nil
+		}
+		r, err := br.Next()
+		if err != nil {
+			return nil, err
+		}
+		br.grouper.add(r)
+	}
+}
@@ -0,0 +1,127 @@
+package vslparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// binaryTag returns the numeric value of the tag with the given name.
+func binaryTag(t *testing.T, name string) uint32 {
+	for i, n := range tagTable {
+		if n == name {
+			return uint32(i)
+		}
+	}
+	t.Fatalf("unknown tag %q", name)
+	return 0
+}
+
+// binaryRecord appends a binary log record to buf.
+func binaryRecord(t *testing.T, buf *bytes.Buffer, tag string, vxid uint32, marker uint32, v string) {
+	data := append([]byte(v), 0)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	binary.Write(buf, binary.LittleEndian, binaryTag(t, tag)<<24|uint32(len(v)+1))
+	binary.Write(buf, binary.LittleEndian, marker|vxid)
+	buf.Write(data)
+}
+
+// TestBinaryReader tests that records and entries are decoded from a binary
+// log.
+func TestBinaryReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(binaryFileID)
+	binaryRecord(t, buf, "Begin", 2, binaryClientMarker, "req 1 rxreq")
+	binaryRecord(t, buf, "Begin", 3, binaryBackendMarker, "bereq 2 fetch")
+	binaryRecord(t, buf, "CLI", 0, 0, "Rd ping")
+	binaryRecord(t, buf, "ReqURL", 2, binaryClientMarker, "/foo")
+	binaryRecord(t, buf, "End", 3, binaryBackendMarker, "")
+	binaryRecord(t, buf, "End", 2, binaryClientMarker, "")
+
+	br, err := NewBinaryReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to open binary log: %v", err)
+	}
+	br.ByteOrder = binary.LittleEndian
+	r, err := br.Next()
+	if err != nil {
+		t.Fatalf("failed to read binary record: %v", err)
+	}
+	want := &RawRecord{VXID: 2, Tag: "Begin", Type: 'c', Value: "req 1 rxreq"}
+	if !reflect.DeepEqual(want, r) {
+		t.Errorf("reading binary record should give %v, got %v", want, r)
+	}
+
+	br, _ = NewBinaryReader(bytes.NewReader(buf.Bytes()))
+	br.ByteOrder = binary.LittleEndian
+	entries := []*Entry{
+		&Entry{
+			Kind:    BeReq,
+			VXID:    3,
+			Level:   1,
+			Fields:  Fields{"Begin": []string{"bereq 2 fetch"}},
+			Records: Records{{"Begin", "bereq 2 fetch"}},
+		},
+		&Entry{
+			Kind:  Request,
+			VXID:  2,
+			Level: 1,
+			Fields: Fields{
+				"Begin":  []string{"req 1 rxreq"},
+				"ReqURL": []string{"/foo"},
+			},
+			Records: Records{{"Begin", "req 1 rxreq"}, {"ReqURL", "/foo"}},
+		},
+	}
+	for _, e := range entries {
+		got, err := br.NextEntry()
+		if err != nil {
+			t.Fatalf("failed to read entry from binary log: %v", err)
+		}
+		if !reflect.DeepEqual(e, got) {
+			t.Errorf("reading entry should give %v, got %v", e, got)
+		}
+	}
+	if _, err := br.NextEntry(); err != io.EOF {
+		t.Errorf("reading past the end of binary log should give EOF, got: %v", err)
+	}
+
+	if _, err := NewBinaryReader(bytes.NewReader([]byte("* << Request >> 1\n"))); err == nil {
+		t.Errorf("opening a text log as binary log should fail")
+	}
+	br, _ = NewBinaryReader(bytes.NewReader(buf.Bytes()[:len(buf.Bytes())-2]))
+	for err == nil {
+		_, err = br.Next()
+	}
+	if err == io.EOF {
+		t.Errorf("reading a truncated binary log should not give a clean EOF")
+	}
+}
+
+// TestBinaryVXID64 tests that 64-bit VXIDs of version 3 records are decoded.
+func TestBinaryVXID64(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(binaryFileID)
+	binary.Write(buf, binary.LittleEndian, binaryTag(t, "ReqURL")<<24|binaryVersion3<<16|2)
+	binary.Write(buf, binary.LittleEndian, uint32(binaryClientMarker|0x12345))
+	binary.Write(buf, binary.LittleEndian, uint32(0xfffffffe))
+	buf.WriteString("/\x00\x00\x00")
+
+	br, err := NewBinaryReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to open binary log: %v", err)
+	}
+	br.ByteOrder = binary.LittleEndian
+	r, err := br.Next()
+	if err != nil {
+		t.Fatalf("failed to read binary record: %v", err)
+	}
+	want := &RawRecord{VXID: 0x12345fffffffe, Tag: "ReqURL", Type: 'c', Value: "/"}
+	if !reflect.DeepEqual(want, r) {
+		t.Errorf("reading binary record should give %v, got %v", want, r)
+	}
+}
@@ -0,0 +1,160 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcome is the outcome of the cache lookup of a client request.
+type Outcome int
+
+const (
+	// OutcomeUnknown is the outcome of transactions without a cache
+	// lookup, e.g. of backend requests.
+	OutcomeUnknown Outcome = iota
+	// OutcomeHit is a cache hit, possibly of an object in grace.
+	OutcomeHit
+	// OutcomeHitMiss is a hit of a hit-for-miss object.
+	OutcomeHitMiss
+	// OutcomeHitPass is a hit of a hit-for-pass object.
+	OutcomeHitPass
+	// OutcomeMiss is a cache miss.
+	OutcomeMiss
+	// OutcomePass is a request passed to the backend from VCL.
+	OutcomePass
+	// OutcomePipe is a request piped to the backend.
+	OutcomePipe
+	// OutcomeSynth is a synthetic response produced without a lookup.
+	OutcomeSynth
+)
+
+// outcomeNames maps the outcomes to their names.
+var outcomeNames = [...]string{
+	OutcomeUnknown: "unknown",
+	OutcomeHit:     "hit",
+	OutcomeHitMiss: "hitmiss",
+	OutcomeHitPass: "hitpass",
+	OutcomeMiss:    "miss",
+	OutcomePass:    "pass",
+	OutcomePipe:    "pipe",
+	OutcomeSynth:   "synth",
+}
+
+// String returns the name of the outcome, e.g. "hit".
+func (o Outcome) String() string {
+	if o < 0 || int(o) >= len(outcomeNames) {
+		return "unknown"
+	}
+	return outcomeNames[o]
+}
+
+// ParseOutcome returns the outcome of the given name, e.g. "hit". Unknown
+// names give an error.
+func ParseOutcome(s string) (Outcome, error) {
+	for o, n := range outcomeNames {
+		if n == s {
+			return Outcome(o), nil
+		}
+	}
+	return OutcomeUnknown, errors.Errorf("unknown cache outcome %q", s)
+}
+
+// CacheOutcome represents the outcome of the cache lookup of a client
+// request, as reported by the Hit, HitMiss and HitPass records or, failing
+// these, by the VCL subroutines called.
+//
+// Since Varnish 6.0, the hit records also report the remaining TTL, grace and
+// keep of the object hit, e.g. "Hit 32769 119.998 10.000 0.000". A hit of an
+// object in grace has a negative TTL. The remaining periods are zero for
+// older versions, and for records which do not report them.
+type CacheOutcome struct {
+	Outcome Outcome       // Outcome of the lookup.
+	ObjVXID uint64        // VXID of the object hit, zero if none.
+	TTL     time.Duration // Remaining TTL of the object hit.
+	Grace   time.Duration // Remaining grace of the object hit.
+	Keep    time.Duration // Remaining keep of the object hit.
+}
+
+// outcomeTags lists the tags of hit records along with their outcomes, in
+// the order in which they are looked for.
+var outcomeTags = []struct {
+	tag     string
+	outcome Outcome
+}{
+	{"Hit", OutcomeHit},
+	{"HitMiss", OutcomeHitMiss},
+	{"HitPass", OutcomeHitPass},
+}
+
+// outcomeCalls maps the VCL subroutines to the outcomes of lookups they
+// indicate.
+var outcomeCalls = map[string]Outcome{
+	"MISS":  OutcomeMiss,
+	"PASS":  OutcomePass,
+	"PIPE":  OutcomePipe,
+	"SYNTH": OutcomeSynth,
+}
+
+// parseHit parses the value of a Hit, HitMiss or HitPass record with the given
+// tag into c.
+func parseHit(c *CacheOutcome, tag, v string) error {
+	fs := strings.Fields(v)
+	if len(fs) < 1 || len(fs) > 4 {
+		return errors.Errorf("%s record %q is malformed", tag, v)
+	}
+	var err error
+	if c.ObjVXID, err = strconv.ParseUint(fs[0], 10, 64); err != nil {
+		return errors.Wrapf(err, "cannot parse %s record %q", tag, v)
+	}
+	durations := []*time.Duration{&c.TTL, &c.Grace, &c.Keep}
+	for i, f := range fs[1:] {
+		if *durations[i], err = parseDuration(f); err != nil {
+			return errors.Wrapf(err, "cannot parse %s record %q", tag, v)
+		}
+	}
+	return nil
+}
+
+// CacheOutcome determines the outcome of the cache lookup of the log entry.
+// OutcomeUnknown is returned for entries without a lookup.
+func (e *Entry) CacheOutcome() (*CacheOutcome, error) {
+	c := &CacheOutcome{}
+	for _, t := range outcomeTags {
+		if fs, ok := e.Fields[t.tag]; ok {
+			c.Outcome = t.outcome
+			if err := parseHit(c, t.tag, fs[0]); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+	for _, call := range e.Fields["VCL_call"] {
+		if o, ok := outcomeCalls[call]; ok {
+			c.Outcome = o
+			break
+		}
+	}
+	return c, nil
+}
+
+// Storage represents a parsed Storage record, which reports the storage
+// backend an object is stored in, e.g. "malloc s0" or "mse mse.store1".
+type Storage struct {
+	Type string // Type of the storage, e.g. "malloc", "file" or "mse".
+	Name string // Name of the storage, e.g. "s0" or "Transient".
+}
+
+// Storage parses and returns the Storage record of the log entry.
+func (e *Entry) Storage() (*Storage, error) {
+	fs, err := e.Field("Storage")
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(fs[0])
+	if len(parts) != 2 {
+		return nil, errors.Errorf("Storage record %q is malformed", fs[0])
+	}
+	return &Storage{Type: parts[0], Name: parts[1]}, nil
+}
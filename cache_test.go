@@ -0,0 +1,117 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestCacheOutcome tests that the outcome of cache lookups is determined
+// from hit records of all versions, and from the VCL subroutines called.
+func TestCacheOutcome(t *testing.T) {
+	samples := map[string]*CacheOutcome{
+		"- VCL_call HIT\n- Hit 32769 119.998 10.000 0.000\n": &CacheOutcome{
+			Outcome: OutcomeHit,
+			ObjVXID: 32769,
+			TTL:     119998 * time.Millisecond,
+			Grace:   10 * time.Second,
+		},
+		"- Hit 32769 -2.500 7.500 0.000\n": &CacheOutcome{
+			Outcome: OutcomeHit,
+			ObjVXID: 32769,
+			TTL:     -2500 * time.Millisecond,
+			Grace:   7500 * time.Millisecond,
+		},
+		"- Hit 32769\n- VCL_call HIT\n": &CacheOutcome{
+			Outcome: OutcomeHit,
+			ObjVXID: 32769,
+		},
+		"- HitMiss 32771 119.998\n- VCL_call MISS\n": &CacheOutcome{
+			Outcome: OutcomeHitMiss,
+			ObjVXID: 32771,
+			TTL:     119998 * time.Millisecond,
+		},
+		"- HitPass 32771\n- VCL_call PASS\n": &CacheOutcome{
+			Outcome: OutcomeHitPass,
+			ObjVXID: 32771,
+		},
+		"- VCL_call RECV\n- VCL_call HASH\n- VCL_call MISS\n- VCL_call SYNTH\n": &CacheOutcome{
+			Outcome: OutcomeMiss,
+		},
+		"- VCL_call RECV\n- VCL_call HASH\n- VCL_call PASS\n": &CacheOutcome{
+			Outcome: OutcomePass,
+		},
+		"- VCL_call RECV\n- VCL_call PIPE\n": &CacheOutcome{
+			Outcome: OutcomePipe,
+		},
+		"- VCL_call BACKEND_FETCH\n": &CacheOutcome{
+			Outcome: OutcomeUnknown,
+		},
+	}
+	for records, want := range samples {
+		s := "* << Request >> 2\n" + records + "- End"
+		e, err := Parse(stringScanner(s))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", s, err)
+		}
+		got, err := e.CacheOutcome()
+		if err != nil {
+			t.Errorf("determining outcome of %q should not fail, got: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("determining outcome of %q should give %v, got %v", s, want, got)
+		}
+	}
+
+	if c, err := example().CacheOutcome(); err != nil || c.Outcome != OutcomeSynth {
+		t.Errorf("outcome of synthetic response should be synth, got %v (%v)", c, err)
+	}
+	for _, v := range []string{"", "foo", "32769 ttl", "32769 1 2 3 4"} {
+		e := &Entry{Fields: Fields{"Hit": []string{v}}}
+		if _, err := e.CacheOutcome(); err == nil {
+			t.Errorf("determining outcome of Hit record %q should fail", v)
+		} else {
+			t.Logf("determining outcome of Hit record %q gives: %v", v, err)
+		}
+	}
+	if s := OutcomeHitPass.String(); s != "hitpass" {
+		t.Errorf("OutcomeHitPass should be named hitpass, got %q", s)
+	}
+	if o, err := ParseOutcome("hitpass"); err != nil || o != OutcomeHitPass {
+		t.Errorf("parsing hitpass should give OutcomeHitPass, got %v (%v)", o, err)
+	}
+	if _, err := ParseOutcome("HIT"); err == nil {
+		t.Errorf("parsing unknown outcome should fail")
+	}
+}
+
+// TestStorage tests that Storage records are parsed correctly and that
+// malformed ones produce errors.
+func TestStorage(t *testing.T) {
+	want := &Storage{Type: "malloc", Name: "Transient"}
+	got, err := example().Storage()
+	if err != nil {
+		t.Fatalf("parsing Storage record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing Storage record should give %v, got %v", want, got)
+	}
+	e := &Entry{Fields: Fields{"Storage": []string{"mse mse.store1"}}}
+	want = &Storage{Type: "mse", Name: "mse.store1"}
+	if got, err := e.Storage(); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing Storage record should give %v, got %v (%v)", want, got, err)
+	}
+
+	for _, v := range []string{"", "malloc", "malloc s0 extra"} {
+		e := &Entry{Fields: Fields{"Storage": []string{v}}}
+		if _, err := e.Storage(); err == nil {
+			t.Errorf("parsing Storage record %q should fail", v)
+		} else {
+			t.Logf("parsing Storage record %q gives: %v", v, err)
+		}
+	}
+	if _, err := newEntry().Storage(); err == nil {
+		t.Errorf("parsing missing Storage record should fail")
+	}
+}
@@ -0,0 +1,172 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/netip"
+	"strings"
+)
+
+// AddrSource is a record the address of the client is taken from, see
+// CIDRFilter.
+type AddrSource int
+
+const (
+	// AddrReqStart is the peer address of client requests from ReqStart.
+	AddrReqStart AddrSource = iota
+	// AddrSessOpen is the peer address of sessions from SessOpen.
+	AddrSessOpen
+	// AddrProxy is the address of the original client of connections
+	// received through the PROXY protocol from Proxy.
+	AddrProxy
+	// AddrForwardedFor is the address of the client in the X-Forwarded-For
+	// header of client requests, see CIDRFilter.TrustedHops.
+	AddrForwardedFor
+)
+
+// DefaultAddrSources are the sources of addresses of clients used by a
+// CIDRFilter without Sources.
+var DefaultAddrSources = []AddrSource{AddrReqStart, AddrSessOpen}
+
+// CIDRFilter is a Filter selecting entries by the address of their client,
+// e.g. to investigate abuse. The address is taken from the first of the
+// configured sources found in an entry and matched against a set of
+// prefixes, e.g. 192.0.2.0/24 or 2001:db8::/32. IPv4-mapped IPv6 addresses
+// are treated as IPv4 addresses. Entries without an address, e.g. backend
+// requests or requests received over a Unix domain socket, are not selected.
+type CIDRFilter struct {
+	// Sources are the records to take the address from, in order of
+	// preference. If empty, DefaultAddrSources are used.
+	Sources []AddrSource
+	// TrustedHops is the number of trusted proxies in front of Varnish which
+	// append the address of their peer to X-Forwarded-For. Varnish appends
+	// the address of its own peer, so the address of the client is the one
+	// TrustedHops positions from the end of the header as seen by VCL, see
+	// ReplayHeaders, or its first address if there are fewer. Addresses
+	// left of those of the trusted proxies may be forged by clients.
+	TrustedHops int
+
+	// prefixes holds the masked prefixes by their lengths.
+	prefixes map[int]map[netip.Prefix]bool
+}
+
+// NewCIDRFilter returns a filter selecting the entries of clients within the
+// given prefixes, e.g. "192.0.2.0/24", or of the given addresses.
+func NewCIDRFilter(cidrs ...string) (*CIDRFilter, error) {
+	f := &CIDRFilter{prefixes: map[int]map[netip.Prefix]bool{}}
+	for _, s := range cidrs {
+		var p netip.Prefix
+		var err error
+		if strings.Contains(s, "/") {
+			p, err = netip.ParsePrefix(s)
+		} else {
+			var a netip.Addr
+			if a, err = netip.ParseAddr(s); err == nil {
+				p = netip.PrefixFrom(a, a.BitLen())
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse prefix %q", s)
+		}
+		f.Add(p)
+	}
+	return f, nil
+}
+
+// Add adds the prefix p to the set of matched prefixes.
+func (f *CIDRFilter) Add(p netip.Prefix) {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	p = p.Masked()
+	if f.prefixes == nil {
+		f.prefixes = map[int]map[netip.Prefix]bool{}
+	}
+	set, ok := f.prefixes[p.Bits()]
+	if !ok {
+		set = map[netip.Prefix]bool{}
+		f.prefixes[p.Bits()] = set
+	}
+	set[p] = true
+}
+
+// Contains returns whether the address a is within any of the prefixes. The
+// cost of a lookup depends on the number of distinct prefix lengths only.
+func (f *CIDRFilter) Contains(a netip.Addr) bool {
+	a = a.Unmap().WithZone("")
+	for bits, set := range f.prefixes {
+		if p, err := a.Prefix(bits); err == nil && set[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// Addr returns the address of the client of the entry e taken from the first
+// source found in it, or false if there is none.
+func (f *CIDRFilter) Addr(e *Entry) (netip.Addr, bool) {
+	sources := f.Sources
+	if len(sources) == 0 {
+		sources = DefaultAddrSources
+	}
+	for _, s := range sources {
+		var a netip.Addr
+		switch s {
+		case AddrReqStart:
+			if _, ok := e.Fields["ReqStart"]; ok {
+				if r, err := e.ReqStart(); err == nil {
+					a = r.Addr
+				}
+			}
+		case AddrSessOpen:
+			if _, ok := e.Fields["SessOpen"]; ok {
+				if o, err := e.SessOpen(); err == nil {
+					a = o.RemoteAddr
+				}
+			}
+		case AddrProxy:
+			if _, ok := e.Fields["Proxy"]; ok {
+				if p, err := e.Proxy(); err == nil {
+					a = p.ClientAddr
+				}
+			}
+		case AddrForwardedFor:
+			a = f.forwardedFor(e)
+		}
+		if a.IsValid() {
+			return a, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// forwardedFor returns the address of the client in the X-Forwarded-For
+// header of the client request e, see TrustedHops, or the zero address.
+func (f *CIDRFilter) forwardedFor(e *Entry) netip.Addr {
+	if e.Kind != Request {
+		return netip.Addr{}
+	}
+	r, err := e.ReplayHeaders("Req")
+	if err != nil {
+		return netip.Addr{}
+	}
+	var addrs []string
+	for _, v := range r.Received.Values("X-Forwarded-For") {
+		addrs = append(addrs, strings.Split(v, ",")...)
+	}
+	if len(addrs) == 0 {
+		return netip.Addr{}
+	}
+	s := strings.TrimSpace(addrs[max(len(addrs)-1-f.TrustedHops, 0)])
+	a, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return a
+}
+
+// Match returns whether the address of the client of the entry e is within
+// any of the prefixes.
+func (f *CIDRFilter) Match(e *Entry) bool {
+	a, ok := f.Addr(e)
+	return ok && f.Contains(a)
+}
@@ -0,0 +1,85 @@
+package vslparser
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestCIDRFilter tests that entries are selected by the addresses of their
+// clients from the configured sources, for IPv4 and IPv6 prefixes.
+func TestCIDRFilter(t *testing.T) {
+	f, err := NewCIDRFilter("192.0.2.0/24", "2001:db8::/32", "198.51.100.7", "::ffff:203.0.113.0/120")
+	if err != nil {
+		t.Fatalf("creating CIDR filter should not fail, got: %v", err)
+	}
+	addrs := map[string]bool{
+		"192.0.2.1":          true,
+		"192.0.3.1":          false,
+		"::ffff:192.0.2.200": true,
+		"2001:db8:1::1":      true,
+		"2001:db9::1":        false,
+		"198.51.100.7":       true,
+		"198.51.100.8":       false,
+		"203.0.113.9":        true,
+		"fe80::1%eth0":       false,
+	}
+	for s, want := range addrs {
+		if got := f.Contains(netip.MustParseAddr(s)); got != want {
+			t.Errorf("CIDR filter should give %v for %s, got %v", want, s, got)
+		}
+	}
+
+	req := NewEntry(Request, 2).
+		Add("ReqStart", "10.0.0.1 1234 a0").
+		Add("ReqHeader", "X-Forwarded-For: 192.0.2.1, 2001:db8::1").
+		Add("ReqUnset", "X-Forwarded-For: 192.0.2.1, 2001:db8::1").
+		Add("ReqHeader", "X-Forwarded-For: 192.0.2.1, 2001:db8::1, 10.0.0.1").
+		Add("VCL_call", "RECV")
+	sess := NewEntry(Session, 1).
+		Add("SessOpen", "2001:db8::2 44876 a0 ::1 6081 1545037998.267700 17").
+		Add("Proxy", "2 198.51.100.9 51234 198.51.100.1 443")
+	samples := []struct {
+		Sources []AddrSource
+		Hops    int
+		Req     string
+		Sess    string
+	}{
+		{nil, 0, "10.0.0.1", "2001:db8::2"},
+		{[]AddrSource{AddrProxy, AddrSessOpen}, 0, "", "198.51.100.9"},
+		{[]AddrSource{AddrForwardedFor}, 0, "10.0.0.1", ""},
+		{[]AddrSource{AddrForwardedFor}, 1, "2001:db8::1", ""},
+		{[]AddrSource{AddrForwardedFor}, 2, "192.0.2.1", ""},
+		{[]AddrSource{AddrForwardedFor}, 5, "192.0.2.1", ""},
+		{[]AddrSource{AddrProxy, AddrReqStart}, 0, "10.0.0.1", "198.51.100.9"},
+	}
+	for _, s := range samples {
+		f.Sources, f.TrustedHops = s.Sources, s.Hops
+		for _, c := range []struct {
+			Entry *Entry
+			Want  string
+		}{{req, s.Req}, {sess, s.Sess}} {
+			got := ""
+			if a, ok := f.Addr(c.Entry); ok {
+				got = a.String()
+			}
+			if got != c.Want {
+				t.Errorf("sources %v with %d hops should give %q for entry %d, got %q", s.Sources, s.Hops, c.Want, c.Entry.VXID, got)
+			}
+			if want := c.Want != "" && f.Contains(netip.MustParseAddr(c.Want)); f.Match(c.Entry) != want {
+				t.Errorf("sources %v with %d hops should give %v for entry %d", s.Sources, s.Hops, want, c.Entry.VXID)
+			}
+		}
+	}
+	f.Sources = nil
+	if f.Match(NewEntry(Request, 3).Add("ReqStart", "- - a0")) || f.Match(NewEntry(BeReq, 4)) {
+		t.Errorf("entries without client address should not be selected")
+	}
+
+	for _, s := range []string{"192.0.2.0/33", "foo", "2001:db8::/"} {
+		if _, err := NewCIDRFilter(s); err == nil {
+			t.Errorf("creating CIDR filter of %q should fail", s)
+		} else {
+			t.Logf("creating CIDR filter of %q gives: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package vslparser
+
+import (
+	"time"
+)
+
+// ClientSession summarizes a client session and the requests received over
+// it. Sessions whose entry is missing, e.g. because it was not logged when
+// the input ended, have no Entry, Open or Close.
+type ClientSession struct {
+	VXID     uint64        // VXID of the session.
+	Entry    *Entry        // Entry of the session, nil if it is missing.
+	Open     *SessOpen     // Parsed SessOpen record, nil if none was logged.
+	Close    *SessClose    // Parsed SessClose record, nil if none was logged.
+	Requests []*Entry      // Client requests, with their nested transactions.
+	Duration time.Duration // Duration of the session, see SessClose.
+	Reason   string        // Reason of the close of the session, e.g. "REM_CLOSE".
+}
+
+// newClientSession summarizes the session e, whose requests are nested in it.
+func newClientSession(e *Entry) (*ClientSession, error) {
+	s := &ClientSession{VXID: e.VXID, Entry: e}
+	var err error
+	if _, ok := e.Fields["SessOpen"]; ok {
+		if s.Open, err = e.SessOpen(); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := e.Fields["SessClose"]; ok {
+		if s.Close, err = e.SessClose(); err != nil {
+			return nil, err
+		}
+		s.Duration, s.Reason = s.Close.Duration, s.Close.Reason
+	}
+	for _, c := range e.Children {
+		if c.Kind == Request {
+			s.Requests = append(s.Requests, c)
+		}
+	}
+	return s, nil
+}
+
+// SessionAggregator collects the client requests of sessions from entries of
+// transactions parsed separately, i.e. in the default VXID grouping, and
+// returns each session once it ended, see TreeBuilder. Sessions already
+// grouped with their requests, i.e. parsed in session grouping, are
+// summarized as they are added.
+type SessionAggregator struct {
+	trees *TreeBuilder
+}
+
+// NewSessionAggregator returns a new empty aggregator.
+func NewSessionAggregator() *SessionAggregator {
+	return &SessionAggregator{trees: NewTreeBuilder(GroupSession)}
+}
+
+// Add adds the entry e to the aggregator and returns the sessions which ended
+// with it, if any. An error is returned if the records tying e to other
+// transactions or the SessOpen or SessClose records of an ended session
+// cannot be parsed.
+func (a *SessionAggregator) Add(e *Entry) ([]*ClientSession, error) {
+	var trees []*Entry
+	if e.Kind == Session && len(e.Children) > 0 {
+		trees = []*Entry{e}
+	} else {
+		var err error
+		if trees, err = a.trees.Add(e); err != nil {
+			return nil, err
+		}
+	}
+	var sessions []*ClientSession
+	for _, t := range trees {
+		if t.Kind != Session {
+			continue
+		}
+		s, err := newClientSession(t)
+		if err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Flush returns the sessions held by the aggregator, which have not ended or
+// whose requests were not all logged, and empties the aggregator. Requests
+// whose session is missing are collected into sessions without an Entry;
+// backend requests whose client request is missing are dropped. Flush is
+// meant to be called at the end of the input.
+func (a *SessionAggregator) Flush() ([]*ClientSession, error) {
+	var sessions []*ClientSession
+	orphans := make(map[uint64]*ClientSession)
+	for _, t := range a.trees.Flush() {
+		if t.Kind == Session {
+			s, err := newClientSession(t)
+			if err != nil {
+				return sessions, err
+			}
+			sessions = append(sessions, s)
+			continue
+		}
+		// Held entries were added with a valid Begin record.
+		b, err := t.Begin()
+		if err != nil || t.Kind != Request {
+			continue
+		}
+		s := orphans[b.Parent]
+		if s == nil {
+			s = &ClientSession{VXID: b.Parent}
+			orphans[b.Parent] = s
+			sessions = append(sessions, s)
+		}
+		s.Requests = append(s.Requests, t)
+	}
+	return sessions, nil
+}
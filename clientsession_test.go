@@ -0,0 +1,91 @@
+package vslparser
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessionAggregator tests that sessions are returned with their requests
+// once they end, both from separately logged and from grouped transactions,
+// and that requests without a session are flushed.
+func TestSessionAggregator(t *testing.T) {
+	s := `
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   End
+*   << Request  >> 4
+-   Begin          req 1 rxreq
+-   End
+*   << Request  >> 7
+-   Begin          req 6 rxreq
+-   End
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   SessOpen       127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 17
+-   Link           req 2 rxreq
+-   Link           req 4 rxreq
+-   SessClose      REM_CLOSE 0.008
+-   End
+`
+	entries, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("failed to parse entries: %v", err)
+	}
+	a := NewSessionAggregator()
+	var sessions []*ClientSession
+	for _, e := range entries {
+		ss, err := a.Add(e)
+		if err != nil {
+			t.Fatalf("adding entry %d should not fail, got: %v", e.VXID, err)
+		}
+		sessions = append(sessions, ss...)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("aggregating should give 1 session, got %d", len(sessions))
+	}
+	got := sessions[0]
+	if got.VXID != 1 || got.Entry != entries[4] || got.Reason != "REM_CLOSE" ||
+		got.Duration != 8*time.Millisecond || got.Close == nil {
+		t.Errorf("aggregating should give session 1 closed with REM_CLOSE after 8ms, got %+v", got)
+	}
+	if got.Open == nil || got.Open.RemoteAddr != netip.MustParseAddr("127.0.0.1") {
+		t.Errorf("session 1 should be opened from 127.0.0.1, got %+v", got.Open)
+	}
+	if len(got.Requests) != 2 || got.Requests[0].VXID != 2 || got.Requests[1].VXID != 4 ||
+		len(got.Requests[0].Children) != 1 {
+		t.Errorf("session 1 should have requests 2 and 4, got %v", got.Requests)
+	}
+
+	flushed, err := a.Flush()
+	if err != nil {
+		t.Fatalf("flushing should not fail, got: %v", err)
+	}
+	if len(flushed) != 1 || flushed[0].VXID != 6 || flushed[0].Entry != nil ||
+		len(flushed[0].Requests) != 1 || flushed[0].Requests[0].VXID != 7 {
+		t.Errorf("flushing should give missing session 6 with request 7, got %v", flushed)
+	}
+
+	// Sessions parsed in session grouping are summarized as they are.
+	p := NewParser(strings.NewReader(session))
+	p.Grouping = GroupSession
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse session group: %v", err)
+	}
+	if ss, err := NewSessionAggregator().Add(e); err != nil || len(ss) != 1 || len(ss[0].Requests) != 2 {
+		t.Errorf("adding session group should give session with 2 requests, got %v (%v)", ss, err)
+	}
+
+	bad := NewEntry(Session, 1).Add("Begin", "sess 0 HTTP/1").Add("SessClose", "REM_CLOSE")
+	if _, err := NewSessionAggregator().Add(bad); err == nil {
+		t.Errorf("adding session with malformed SessClose should fail")
+	} else {
+		t.Logf("adding session with malformed SessClose gives: %v", err)
+	}
+}
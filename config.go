@@ -0,0 +1,275 @@
+package vslparser
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FilterConfig declares a filter in a configuration file, see FilterConfigs.
+// The filter selects the entries matching all the criteria set, so that an
+// empty filter selects all entries.
+type FilterConfig struct {
+	Query       string          `json:"query,omitempty"`        // Query, see CompileQuery.
+	Kinds       []string        `json:"kinds,omitempty"`        // Kinds of entries, e.g. "Request".
+	Status      []int           `json:"status,omitempty"`       // Status codes, see HasStatus.
+	StatusClass []int           `json:"status_class,omitempty"` // Classes of status codes, see HasStatusClass.
+	Outcome     []string        `json:"outcome,omitempty"`      // Cache outcomes, e.g. "miss", see HasOutcome.
+	FetchFailed bool            `json:"fetch_failed,omitempty"` // Whether a fetch failed, see FetchFailed.
+	Slow        []SlowConfig    `json:"slow,omitempty"`         // Thresholds any of which is exceeded, see Slow.
+	Clients     *ClientConfig   `json:"clients,omitempty"`      // Addresses of clients, see CIDRFilter.
+	Headers     []HeaderConfig  `json:"headers,omitempty"`      // Headers all of which match, see HeaderFilter.
+	From        time.Time       `json:"from,omitzero"`          // Start of the time window, see TimeWindow.
+	To          time.Time       `json:"to,omitzero"`            // End of the time window, see TimeWindow.
+	Filters     []string        `json:"filters,omitempty"`      // Names of other filters all of which match.
+	Any         []*FilterConfig `json:"any,omitempty"`          // Filters any of which matches.
+	Not         *FilterConfig   `json:"not,omitempty"`          // Filter which does not match.
+}
+
+// SlowConfig declares a threshold of a Slow filter.
+type SlowConfig struct {
+	Event     string `json:"event"`                // Event of the timestamp, e.g. "Resp".
+	Max       string `json:"max"`                  // Time the event may take, e.g. "200ms".
+	SinceLast bool   `json:"since_last,omitempty"` // Whether the time since the previous timestamp is limited.
+}
+
+// ClientConfig declares a CIDRFilter.
+type ClientConfig struct {
+	CIDRs       []string `json:"cidrs"`                  // Prefixes or addresses, e.g. "192.0.2.0/24".
+	Sources     []string `json:"sources,omitempty"`      // "ReqStart", "SessOpen", "Proxy" or "X-Forwarded-For".
+	TrustedHops int      `json:"trusted_hops,omitempty"` // See CIDRFilter.TrustedHops.
+}
+
+// addrSourceNames maps the names of sources of addresses in configuration
+// files to the sources.
+var addrSourceNames = map[string]AddrSource{
+	"ReqStart":        AddrReqStart,
+	"SessOpen":        AddrSessOpen,
+	"Proxy":           AddrProxy,
+	"X-Forwarded-For": AddrForwardedFor,
+}
+
+// HeaderConfig declares a HeaderFilter.
+type HeaderConfig struct {
+	Family   string `json:"family"`             // Family of the header, e.g. "Req".
+	Name     string `json:"name"`               // Name of the header.
+	Match    string `json:"match,omitempty"`    // Regular expression matched against the values, any value if empty.
+	Received bool   `json:"received,omitempty"` // Whether the headers as received are matched.
+}
+
+// FilterConfigs is a configuration file declaring named filters and the
+// records to be kept by the parser, e.g.
+//
+//	{
+//		"records": ["-i Begin,Link,ReqURL,RespStatus,Timestamp", "-I ReqHeader:^Host:"],
+//		"filters": {
+//			"errors": {"kinds": ["Request"], "status_class": [5]},
+//			"slow": {"slow": [{"event": "Resp", "max": "1s"}]},
+//			"bad": {"any": [{"filters": ["errors"]}, {"filters": ["slow"]}]},
+//			"staff": {"clients": {"cidrs": ["192.0.2.0/24"], "sources": ["X-Forwarded-For"], "trusted_hops": 1}},
+//			"shop": {"query": "ReqHeader:Host ~ '^shop\\.'", "not": {"filters": ["staff"]}}
+//		}
+//	}
+//
+// Records are options of varnishlog, see RecordFilter.Option, and filters may
+// refer to other filters by their names. Configurations are read in JSON by
+// LoadFilters; those in other formats, e.g. YAML, can be decoded into
+// FilterConfigs by other means and compiled by Compile.
+type FilterConfigs struct {
+	Records []string                 `json:"records,omitempty"`
+	Filters map[string]*FilterConfig `json:"filters,omitempty"`
+}
+
+// FilterSet holds the filters compiled from FilterConfigs.
+type FilterSet struct {
+	Filters map[string]Filter // Filters by their names.
+	Records *RecordFilter     // Records to be kept, nil if not configured, see Options.Records.
+}
+
+// LoadFilters reads FilterConfigs in JSON from r and compiles them. Unknown
+// fields are errors, so that misspelled criteria are not ignored.
+//
+// Configurations in YAML have to be converted to JSON first, e.g. by
+// sigs.k8s.io/yaml, or decoded into FilterConfigs by a YAML decoder using
+// the JSON names of the fields, and compiled by FilterConfigs.Compile.
+func LoadFilters(r io.Reader) (*FilterSet, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	var c FilterConfigs
+	if err := d.Decode(&c); err != nil {
+		return nil, errors.Wrap(err, "cannot decode filter configs")
+	}
+	return c.Compile()
+}
+
+// Compile compiles the configured filters and records.
+func (c *FilterConfigs) Compile() (*FilterSet, error) {
+	s := &FilterSet{Filters: map[string]Filter{}}
+	if len(c.Records) > 0 {
+		s.Records = &RecordFilter{}
+		for _, arg := range c.Records {
+			opt, v, _ := strings.Cut(strings.TrimSpace(arg), " ")
+			if len(opt) != 2 || opt[0] != '-' {
+				return nil, errors.Errorf("cannot parse record option %q", arg)
+			}
+			if err := s.Records.Option(opt[1], strings.TrimSpace(v)); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse record option %q", arg)
+			}
+		}
+	}
+	b := &filterCompiler{configs: c.Filters, set: s}
+	for name := range c.Filters {
+		if _, err := b.named(name); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// filterCompiler compiles named filters, resolving references to other
+// filters.
+type filterCompiler struct {
+	configs map[string]*FilterConfig
+	set     *FilterSet
+	path    []string // Names of the filters being compiled, to detect cycles.
+}
+
+// named returns the filter of the given name, compiling it if needed.
+func (b *filterCompiler) named(name string) (Filter, error) {
+	if f, ok := b.set.Filters[name]; ok {
+		return f, nil
+	}
+	c, ok := b.configs[name]
+	if !ok || c == nil {
+		return nil, errors.Errorf("unknown filter %q", name)
+	}
+	if i := slices.Index(b.path, name); i != -1 {
+		cycle := append(slices.Clone(b.path[i:]), name)
+		return nil, errors.Errorf("filters refer to each other in a cycle: %s", strings.Join(cycle, " -> "))
+	}
+	b.path = append(b.path, name)
+	f, err := b.compile(c)
+	b.path = b.path[:len(b.path)-1]
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot compile filter %q", name)
+	}
+	b.set.Filters[name] = f
+	return f, nil
+}
+
+// compile compiles the filter c.
+func (b *filterCompiler) compile(c *FilterConfig) (Filter, error) {
+	var all []Filter
+	if len(c.Kinds) > 0 {
+		kinds := make([]Kind, len(c.Kinds))
+		for i, s := range c.Kinds {
+			var err error
+			if kinds[i], err = ParseKind(s); err != nil {
+				return nil, err
+			}
+		}
+		all = append(all, FilterFunc(func(e *Entry) bool {
+			return slices.Contains(kinds, e.Kind)
+		}))
+	}
+	if c.Query != "" {
+		q, err := CompileQuery(c.Query)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, q)
+	}
+	if len(c.Status) > 0 {
+		all = append(all, HasStatus(c.Status...))
+	}
+	if len(c.StatusClass) > 0 {
+		all = append(all, HasStatusClass(c.StatusClass...))
+	}
+	if len(c.Outcome) > 0 {
+		outcomes := make([]Outcome, len(c.Outcome))
+		for i, s := range c.Outcome {
+			var err error
+			if outcomes[i], err = ParseOutcome(s); err != nil {
+				return nil, err
+			}
+		}
+		all = append(all, HasOutcome(outcomes...))
+	}
+	if c.FetchFailed {
+		all = append(all, FetchFailed())
+	}
+	if len(c.Slow) > 0 {
+		thresholds := make([]SlowThreshold, len(c.Slow))
+		for i, s := range c.Slow {
+			d, err := time.ParseDuration(s.Max)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse threshold of %s", s.Event)
+			}
+			thresholds[i] = SlowThreshold{Event: s.Event, Max: d, SinceLast: s.SinceLast}
+		}
+		all = append(all, Slow(thresholds...))
+	}
+	if c.Clients != nil {
+		f, err := NewCIDRFilter(c.Clients.CIDRs...)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range c.Clients.Sources {
+			src, ok := addrSourceNames[s]
+			if !ok {
+				return nil, errors.Errorf("unknown source of addresses %q", s)
+			}
+			f.Sources = append(f.Sources, src)
+		}
+		f.TrustedHops = c.Clients.TrustedHops
+		all = append(all, f)
+	}
+	for _, h := range c.Headers {
+		f := &HeaderFilter{Family: h.Family, Name: h.Name, Received: h.Received}
+		if h.Match != "" {
+			var err error
+			if f.Re, err = regexp.Compile(h.Match); err != nil {
+				return nil, errors.Wrapf(err, "cannot compile match of header %s", h.Name)
+			}
+		}
+		all = append(all, f)
+	}
+	if !c.From.IsZero() || !c.To.IsZero() {
+		all = append(all, TimeWindow{From: c.From, To: c.To})
+	}
+	for _, name := range c.Filters {
+		f, err := b.named(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, f)
+	}
+	if len(c.Any) > 0 {
+		anyOf := make([]Filter, len(c.Any))
+		for i, a := range c.Any {
+			if a == nil {
+				return nil, errors.New("filter of any is empty")
+			}
+			var err error
+			if anyOf[i], err = b.compile(a); err != nil {
+				return nil, err
+			}
+		}
+		all = append(all, Or(anyOf...))
+	}
+	if c.Not != nil {
+		f, err := b.compile(c.Not)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, Not(f))
+	}
+	if len(all) == 1 {
+		return all[0], nil
+	}
+	return And(all...), nil
+}
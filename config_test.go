@@ -0,0 +1,114 @@
+package vslparser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLoadFilters tests that named filters are compiled from their configs,
+// including references to other filters, and that the configured records are
+// selected.
+func TestLoadFilters(t *testing.T) {
+	config := `{
+	"records": ["-i Begin,ReqURL,RespStatus,Timestamp", "-I ReqHeader:^Host:"],
+	"filters": {
+		"errors": {"kinds": ["Request"], "status_class": [5]},
+		"slow": {"slow": [{"event": "Resp", "max": "1s"}]},
+		"bad": {"any": [{"filters": ["errors"]}, {"filters": ["slow"]}]},
+		"staff": {"clients": {"cidrs": ["192.0.2.0/24"], "sources": ["X-Forwarded-For"], "trusted_hops": 1}},
+		"shop": {"query": "ReqHeader:Host ~ '^shop\\.'", "not": {"filters": ["staff"]}},
+		"misses": {"outcome": ["miss", "pass"], "headers": [{"family": "Req", "name": "Host", "match": "^shop"}]},
+		"december": {"from": "2018-12-01T00:00:00Z", "to": "2019-01-01T00:00:00Z"},
+		"all": {}
+	}
+}`
+	s, err := LoadFilters(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("loading filters should not fail, got: %v", err)
+	}
+	shop := NewEntry(Request, 2).
+		Add("ReqStart", "10.0.0.1 1234 a0").
+		Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000").
+		Add("ReqHeader", "Host: shop.example.com").
+		Add("ReqHeader", "X-Forwarded-For: 198.51.100.1, 10.0.0.1").
+		Add("VCL_call", "RECV").
+		Add("VCL_call", "MISS").
+		Add("Timestamp", "Resp: 1545037999.500000 1.500000 1.500000").
+		Add("RespStatus", "200")
+	staff := NewEntry(Request, 3).
+		Add("Timestamp", "Start: 1543622400.000000 0.000000 0.000000").
+		Add("ReqHeader", "Host: shop.example.com").
+		Add("ReqHeader", "X-Forwarded-For: 192.0.2.1, 10.0.0.1").
+		Add("VCL_call", "RECV").
+		Add("RespStatus", "503")
+	bereq := NewEntry(BeReq, 4).Add("BerespStatus", "503")
+	want := map[string][]bool{
+		"errors":   {false, true, false},
+		"slow":     {true, false, false},
+		"bad":      {true, true, false},
+		"staff":    {false, true, false},
+		"shop":     {true, false, false},
+		"misses":   {true, false, false},
+		"december": {true, true, false},
+		"all":      {true, true, true},
+	}
+	if len(s.Filters) != len(want) {
+		t.Errorf("loading filters should give %d filters, got %d", len(want), len(s.Filters))
+	}
+	for name, w := range want {
+		f := s.Filters[name]
+		if f == nil {
+			t.Errorf("loading filters should give filter %q", name)
+			continue
+		}
+		for i, e := range []*Entry{shop, staff, bereq} {
+			if got := f.Match(e); got != w[i] {
+				t.Errorf("filter %q should give %v for entry %d, got %v", name, w[i], e.VXID, got)
+			}
+		}
+	}
+	if s.Records == nil || !s.Records.Keep("ReqURL", "/") || !s.Records.Keep("ReqHeader", "Host: a") || s.Records.Keep("ReqHeader", "Cookie: a") || s.Records.Keep("Link", "") {
+		t.Errorf("loading filters should select records by the configured options")
+	}
+	if s, err := LoadFilters(strings.NewReader(`{}`)); err != nil || s.Records != nil || len(s.Filters) != 0 {
+		t.Errorf("loading empty config should give no filters, got %v (%v)", s, err)
+	}
+
+	bad := []string{
+		`{"filters": {"a": {"query": "NoSuchTag"}}}`,
+		`{"filters": {"a": {"kinds": ["Req"]}}}`,
+		`{"filters": {"a": {"outcome": ["HIT"]}}}`,
+		`{"filters": {"a": {"slow": [{"event": "Resp", "max": "1"}]}}}`,
+		`{"filters": {"a": {"clients": {"cidrs": ["foo"]}}}}`,
+		`{"filters": {"a": {"clients": {"cidrs": ["::/0"], "sources": ["XFF"]}}}}`,
+		`{"filters": {"a": {"headers": [{"family": "Req", "name": "Host", "match": "("}]}}}`,
+		`{"filters": {"a": {"filters": ["b"]}}}`,
+		`{"filters": {"a": {"filters": ["b"]}, "b": {"not": {"filters": ["a"]}}}}`,
+		`{"filters": {"a": {"any": [null]}}}`,
+		`{"filters": {"a": {"status_classes": [5]}}}`,
+		`{"records": ["-i NoSuchTag"]}`,
+		`{"records": ["ReqURL"]}`,
+		`{"filters": []}`,
+	}
+	for _, c := range bad {
+		if _, err := LoadFilters(strings.NewReader(c)); err == nil {
+			t.Errorf("loading %s should fail", c)
+		} else {
+			t.Logf("loading %s gives: %v", c, err)
+		}
+	}
+
+	cycles := map[string][]string{
+		`{"filters": {"a": {"filters": ["a"]}}}`:                                   {"a -> a"},
+		`{"filters": {"a": {"filters": ["b"]}, "b": {"not": {"filters": ["a"]}}}}`: {"a -> b -> a", "b -> a -> b"},
+	}
+	for c, want := range cycles {
+		_, err := LoadFilters(strings.NewReader(c))
+		if err == nil || !slices.ContainsFunc(want, func(cycle string) bool {
+			return strings.HasSuffix(err.Error(), "cycle: "+cycle)
+		}) {
+			t.Errorf("loading %s should fail naming the cycle %q, got: %v", c, want[0], err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package vslparser
+
+import (
+	"context"
+	"io"
+)
+
+// readResult holds the outcome of a single Read call on the underlying reader
+// of a contextReader.
+type readResult struct {
+	n   int
+	err error
+}
+
+// contextReader is an io.Reader which stops waiting for the underlying reader
+// once its context is done. Reads are performed in a separate goroutine into
+// a private buffer, so that an abandoned read never writes into the buffer of
+// the caller.
+type contextReader struct {
+	ctx     context.Context
+	r       io.Reader
+	buf     []byte
+	res     chan readResult
+	pending bool
+}
+
+// newContextReader returns a new contextReader reading from r until ctx is
+// done.
+func newContextReader(ctx context.Context, r io.Reader) *contextReader {
+	return &contextReader{
+		ctx: ctx,
+		r:   r,
+		res: make(chan readResult, 1),
+	}
+}
+
+// Read implements io.Reader. Once the context is done, its error is returned
+// from all subsequent calls.
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if !cr.pending {
+		if cap(cr.buf) < len(p) {
+			cr.buf = make([]byte, len(p))
+		}
+		buf := cr.buf[:len(p)]
+		cr.pending = true
+		go func() {
+			n, err := cr.r.Read(buf)
+			cr.res <- readResult{n: n, err: err}
+		}()
+	}
+	select {
+	case <-cr.ctx.Done():
+		return 0, cr.ctx.Err()
+	case res := <-cr.res:
+		cr.pending = false
+		return copy(p, cr.buf[:res.n]), res.err
+	}
+}
+
+// NewParserContext returns a new Parser reading varnishlog output from r, just
+// like NewParser. When ctx is done, a Next call blocked waiting for input
+// returns the error of the context, and so do all subsequent calls. Any read
+// still in progress on r is abandoned, but not interrupted.
+func NewParserContext(ctx context.Context, r io.Reader) *Parser {
+	return NewParser(newContextReader(ctx, r))
+}
@@ -0,0 +1,31 @@
+package vslparser
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestParserContext tests that a Parser blocked on input which never comes
+// returns once its context is cancelled.
+func TestParserContext(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewParserContext(ctx, r)
+	go func() {
+		w.Write([]byte("* << BeReq >> 1\n- End\n\n"))
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := p.Next(); err != nil {
+		t.Fatalf("p.Next() should not fail before cancellation, got: %v", err)
+	}
+	if _, err := p.Next(); err != context.Canceled {
+		t.Errorf("p.Next() should fail with context.Canceled, got: %v", err)
+	}
+	if _, err := p.Next(); err != context.Canceled {
+		t.Errorf("p.Next() should keep failing after cancellation, got: %v", err)
+	}
+}
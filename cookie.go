@@ -0,0 +1,51 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// Cookies parses and returns the cookies of the final Cookie headers of a
+// client or backend request, see ReplayHeaders.
+func (e *Entry) Cookies() ([]*http.Cookie, error) {
+	fam, ok := headerFamilies[e.Kind]
+	if !ok {
+		return nil, errors.Errorf("%v entry has no request cookies", e.Kind)
+	}
+	h, err := e.ReplayHeaders(fam[0])
+	if err != nil {
+		return nil, err
+	}
+	var cookies []*http.Cookie
+	for _, v := range h.Final.Values("Cookie") {
+		cs, err := http.ParseCookie(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse Cookie header %q", v)
+		}
+		cookies = append(cookies, cs...)
+	}
+	return cookies, nil
+}
+
+// SetCookies parses and returns the cookies of the final Set-Cookie headers
+// of the response to a client or backend request, in the order in which they
+// were logged, see ReplayHeaders.
+func (e *Entry) SetCookies() ([]*http.Cookie, error) {
+	fam, ok := headerFamilies[e.Kind]
+	if !ok {
+		return nil, errors.Errorf("%v entry has no response cookies", e.Kind)
+	}
+	h, err := e.ReplayHeaders(fam[1])
+	if err != nil {
+		return nil, err
+	}
+	var cookies []*http.Cookie
+	for _, v := range h.Final.Values("Set-Cookie") {
+		c, err := http.ParseSetCookie(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse Set-Cookie header %q", v)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, nil
+}
@@ -0,0 +1,48 @@
+package vslparser
+
+import (
+	"testing"
+)
+
+// TestCookies tests that cookies are parsed from the final Cookie and
+// Set-Cookie headers.
+func TestCookies(t *testing.T) {
+	e, err := Parse(stringScanner("* << Request >> 2\n" +
+		"- ReqHeader Cookie: a=1; b=2\n- ReqHeader Cookie: c=3\n" +
+		"- VCL_call RECV\n- ReqUnset Cookie: c=3\n" +
+		"- RespHeader Set-Cookie: sid=abc; Path=/; HttpOnly\n" +
+		"- RespHeader Set-Cookie: lang=en; Max-Age=3600\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	cs, err := e.Cookies()
+	if err != nil {
+		t.Fatalf("parsing cookies should not fail, got: %v", err)
+	}
+	if len(cs) != 2 || cs[0].Name != "a" || cs[0].Value != "1" || cs[1].Name != "b" {
+		t.Errorf("parsing cookies should give a=1 and b=2, got %v", cs)
+	}
+	cs, err = e.SetCookies()
+	if err != nil {
+		t.Fatalf("parsing set cookies should not fail, got: %v", err)
+	}
+	if len(cs) != 2 || cs[0].Name != "sid" || cs[0].Path != "/" || !cs[0].HttpOnly || cs[1].MaxAge != 3600 {
+		t.Errorf("parsing set cookies should give sid and lang, got %v", cs)
+	}
+
+	bad := []*Entry{
+		&Entry{Kind: Session},
+		&Entry{Kind: BeReq, Records: Records{{"BereqHeader", "Cookie: ="}}},
+		&Entry{Kind: BeReq, Records: Records{{"BerespHeader", "Set-Cookie: ;"}}},
+	}
+	for _, e := range bad {
+		_, err1 := e.Cookies()
+		_, err2 := e.SetCookies()
+		if err1 == nil && err2 == nil {
+			t.Errorf("parsing cookies of %v should fail", e)
+		} else {
+			t.Logf("parsing cookies of %v gives: %v, %v", e, err1, err2)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package vslparser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// debugPatterns lists the well-known messages of Debug records along with
+// their labels, in the order in which they are looked for. The first
+// subexpression of each pattern captures the value.
+var debugPatterns = []struct {
+	label   string
+	pattern *regexp.Regexp
+}{
+	// Response mode chosen for delivery, e.g. "RES_MODE 2".
+	{"RES_MODE", regexp.MustCompile(`^RES_MODE (\S+)$`)},
+	// Workspace operations logged with the workspace debug flag, e.g.
+	// "WS_Reserve(0x7f2a8c0a4020, 0/4096) = 4096".
+	{"workspace", regexp.MustCompile(`^WS_\w+\((0x[0-9a-fA-F]+)`)},
+	// HPACK dynamic table size updates of HTTP/2 sessions.
+	{"hpack_table_update", regexp.MustCompile(`(?i)hpack.*table size(?: update)?(?: to)?:? (\d+)`)},
+	// Failures to write to the client, e.g.
+	// "Write error, retval = -1, len = 4242, errno = Broken pipe".
+	{"write_error", regexp.MustCompile(`^Write error, retval = -?\d+, len = \d+, errno = (.+)$`)},
+}
+
+// Debug represents a Debug record. Well-known messages are recognized and
+// labelled, with their value extracted, e.g. "RES_MODE 2" gets the label
+// "RES_MODE" and the value "2". Other messages have an empty label.
+type Debug struct {
+	Message string // Message of the record, without enclosing quotes.
+	Label   string // Label of a well-known message.
+	Value   string // Value extracted from a well-known message.
+}
+
+// parseDebug parses the value of a Debug record.
+func parseDebug(v string) *Debug {
+	// Varnish before 5.0 logs some messages in quotes.
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+	d := &Debug{Message: v}
+	for _, p := range debugPatterns {
+		if m := p.pattern.FindStringSubmatch(v); m != nil {
+			d.Label = p.label
+			d.Value = strings.TrimSpace(m[1])
+			break
+		}
+	}
+	return d
+}
+
+// Debugs returns the Debug records of the log entry, in the order in which
+// they appear.
+func (e *Entry) Debugs() []*Debug {
+	fs := e.Fields["Debug"]
+	ds := make([]*Debug, 0, len(fs))
+	for _, v := range fs {
+		ds = append(ds, parseDebug(v))
+	}
+	return ds
+}
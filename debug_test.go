@@ -0,0 +1,41 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDebugs tests that well-known Debug messages are recognized and that
+// others are returned as is.
+func TestDebugs(t *testing.T) {
+	want := []*Debug{&Debug{Message: "RES_MODE 2", Label: "RES_MODE", Value: "2"}}
+	if got := example().Debugs(); !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing Debug records of example should give %v, got %v", want, got)
+	}
+
+	samples := map[string]*Debug{
+		"RES_MODE 8": &Debug{Message: "RES_MODE 8", Label: "RES_MODE", Value: "8"},
+		"WS_Reserve(0x7f2a8c0a4020, 0/4096) = 4096": &Debug{
+			Message: "WS_Reserve(0x7f2a8c0a4020, 0/4096) = 4096",
+			Label:   "workspace",
+			Value:   "0x7f2a8c0a4020",
+		},
+		"H2: HPACK dynamic table size update to 4096": &Debug{
+			Message: "H2: HPACK dynamic table size update to 4096",
+			Label:   "hpack_table_update",
+			Value:   "4096",
+		},
+		"Write error, retval = -1, len = 4242, errno = Broken pipe": &Debug{
+			Message: "Write error, retval = -1, len = 4242, errno = Broken pipe",
+			Label:   "write_error",
+			Value:   "Broken pipe",
+		},
+		"XXX something else": &Debug{Message: "XXX something else"},
+		`"`:                  &Debug{Message: `"`},
+	}
+	for v, want := range samples {
+		if got := parseDebug(v); !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing Debug record %q should give %v, got %v", v, want, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package vslparser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// DiffOp is the kind of a difference between two log entries.
+type DiffOp int
+
+const (
+	// DiffChanged is the kind of values which differ between the entries.
+	DiffChanged DiffOp = iota
+	// DiffRemoved is the kind of values present only in the first entry.
+	DiffRemoved
+	// DiffAdded is the kind of values present only in the second entry.
+	DiffAdded
+)
+
+// Difference represents a value of a log field which differs between two log
+// entries. Indexes are positions among the values of the field, -1 in the
+// entry the value is not present in.
+type Difference struct {
+	Op     DiffOp // Kind of the difference.
+	Tag    string // Tag of the field.
+	AIndex int    // Index of the value in the first entry.
+	BIndex int    // Index of the value in the second entry.
+	A      string // Value in the first entry, empty if added.
+	B      string // Value in the second entry, empty if removed.
+}
+
+// String formats the difference in the style of unified diffs, e.g.
+// `-ReqHeader[2] "Cookie: a=b"` or `~ReqURL[0] "/a" -> "/b"`.
+func (d Difference) String() string {
+	switch d.Op {
+	case DiffRemoved:
+		return fmt.Sprintf("-%s[%d] %q", d.Tag, d.AIndex, d.A)
+	case DiffAdded:
+		return fmt.Sprintf("+%s[%d] %q", d.Tag, d.BIndex, d.B)
+	}
+	return fmt.Sprintf("~%s[%d] %q -> %q", d.Tag, d.AIndex, d.A, d.B)
+}
+
+// Diff compares the log fields of two entries and returns their differences,
+// ordered by tag and then by position. The values of each field are aligned
+// by their longest common subsequence, so that e.g. a header added in VCL is
+// reported as a single addition; removals directly followed by additions are
+// reported as changes. Only records are compared, not the kinds, VXIDs or
+// children of the entries; an empty result means the entries logged the same
+// records, possibly in a different order across tags.
+func Diff(a, b *Entry) []Difference {
+	tags := maps.Clone(a.Fields)
+	if tags == nil {
+		tags = Fields{}
+	}
+	maps.Copy(tags, b.Fields)
+	var diffs []Difference
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		diffs = append(diffs, diffValues(tag, a.Fields[tag], b.Fields[tag])...)
+	}
+	return diffs
+}
+
+// diffValues returns the differences between the values of the field with the
+// given tag, aligned by their longest common subsequence.
+func diffValues(tag string, as, bs []string) []Difference {
+	// lcs[i][j] is the length of the longest common subsequence of as[i:]
+	// and bs[j:].
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	// Removals are paired with the following additions in order, pending
+	// is the index of the next removal to be paired.
+	var diffs []Difference
+	i, j, pending := 0, 0, -1
+	for i < len(as) || j < len(bs) {
+		switch {
+		case i < len(as) && j < len(bs) && as[i] == bs[j]:
+			i, j, pending = i+1, j+1, -1
+		case j == len(bs) || i < len(as) && lcs[i+1][j] >= lcs[i][j+1]:
+			diffs = append(diffs, Difference{Op: DiffRemoved, Tag: tag, AIndex: i, BIndex: -1, A: as[i]})
+			if pending < 0 {
+				pending = len(diffs) - 1
+			}
+			i++
+		default:
+			if pending >= 0 && pending < len(diffs) && diffs[pending].Op == DiffRemoved {
+				diffs[pending].Op, diffs[pending].BIndex, diffs[pending].B = DiffChanged, j, bs[j]
+				pending++
+			} else {
+				diffs = append(diffs, Difference{Op: DiffAdded, Tag: tag, AIndex: -1, BIndex: j, B: bs[j]})
+			}
+			j++
+		}
+	}
+	return diffs
+}
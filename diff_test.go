@@ -0,0 +1,58 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiff tests that the values of fields are aligned, so that insertions,
+// removals and changes are reported as such, ordered by tag.
+func TestDiff(t *testing.T) {
+	a := NewEntry(Request, 2).
+		Add("ReqURL", "/a").
+		Add("ReqHeader", "Host: example.com").
+		Add("ReqHeader", "Cookie: a=b").
+		Add("ReqHeader", "Accept: */*").
+		Add("RespStatus", "200").
+		Add("VCL_call", "RECV").
+		Add("VCL_call", "HASH")
+	b := NewEntry(Request, 5).
+		Add("ReqURL", "/b").
+		Add("ReqHeader", "Host: example.com").
+		Add("ReqHeader", "Accept: */*").
+		Add("ReqHeader", "X-Forwarded-For: 127.0.0.1").
+		Add("VCL_call", "RECV").
+		Add("VCL_call", "PASS").
+		Add("VCL_call", "MISS").
+		Add("Length", "2")
+	want := []Difference{
+		{Op: DiffAdded, Tag: "Length", AIndex: -1, BIndex: 0, B: "2"},
+		{Op: DiffRemoved, Tag: "ReqHeader", AIndex: 1, BIndex: -1, A: "Cookie: a=b"},
+		{Op: DiffAdded, Tag: "ReqHeader", AIndex: -1, BIndex: 2, B: "X-Forwarded-For: 127.0.0.1"},
+		{Op: DiffChanged, Tag: "ReqURL", AIndex: 0, BIndex: 0, A: "/a", B: "/b"},
+		{Op: DiffRemoved, Tag: "RespStatus", AIndex: 0, BIndex: -1, A: "200"},
+		{Op: DiffChanged, Tag: "VCL_call", AIndex: 1, BIndex: 1, A: "HASH", B: "PASS"},
+		{Op: DiffAdded, Tag: "VCL_call", AIndex: -1, BIndex: 2, B: "MISS"},
+	}
+	got := Diff(a, b)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("diff should give %v, got %v", want, got)
+	}
+	if d := Diff(a, a.Clone()); len(d) != 0 {
+		t.Errorf("diff of equal entries should be empty, got %v", d)
+	}
+	if d := Diff(&Entry{}, &Entry{}); len(d) != 0 {
+		t.Errorf("diff of empty entries should be empty, got %v", d)
+	}
+
+	strs := map[string]Difference{
+		`-ReqHeader[1] "Cookie: a=b"`: want[1],
+		`+Length[0] "2"`:              want[0],
+		`~ReqURL[0] "/a" -> "/b"`:     want[3],
+	}
+	for s, d := range strs {
+		if got := d.String(); got != s {
+			t.Errorf("formatting %#v should give %q, got %q", d, s, got)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package vslparser
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"sync"
+)
+
+// DefaultQueueSize is the default capacity of the queue of each handler of a
+// Dispatcher.
+const DefaultQueueSize = 64
+
+// ErrDispatcherClosed is returned by Dispatch once the dispatcher is closed.
+var ErrDispatcherClosed = errors.New("dispatcher is closed")
+
+// route is a handler registered with a Dispatcher along with its queue.
+type route struct {
+	kind   Kind
+	filter Filter
+	fn     func(*Entry) error
+	queue  chan *Entry
+}
+
+// Dispatcher routes entries to handlers registered for their kinds. Each
+// handler runs in a goroutine of its own, fed by a bounded queue, so that
+// handlers run concurrently, while a slow handler stalls the dispatch once
+// its queue fills up instead of letting entries pile up in memory.
+//
+// Entries are shared by all handlers they are routed to, which must not
+// modify them; a handler which needs to should work on a Clone. Since
+// entries outlive the dispatch, they must not be reused for parsing, see
+// ParseFunc.
+//
+// Handlers must be registered before the first entry is dispatched, Close
+// must be called once all entries were dispatched. Close may be called more
+// than once, e.g. deferred after Run, which closes the dispatcher itself.
+type Dispatcher struct {
+	// QueueSize is the capacity of the queue of each handler. If zero,
+	// DefaultQueueSize is used.
+	QueueSize int
+
+	routes []*route
+	start  sync.Once
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    error
+
+	// state guards closed, which is set by Close, against dispatches in
+	// progress, so that no entry is sent to a closed queue.
+	state  sync.RWMutex
+	closed bool
+}
+
+// Handle registers fn to handle the entries of the given kind.
+func (d *Dispatcher) Handle(k Kind, fn func(*Entry) error) {
+	d.HandleFilter(k, nil, fn)
+}
+
+// HandleFilter registers fn to handle the entries of the given kind which
+// match f. If f is nil, all entries of the kind are handled.
+func (d *Dispatcher) HandleFilter(k Kind, f Filter, fn func(*Entry) error) {
+	d.routes = append(d.routes, &route{kind: k, filter: f, fn: fn})
+}
+
+// run starts the goroutines of the handlers.
+func (d *Dispatcher) run() {
+	size := d.QueueSize
+	if size == 0 {
+		size = DefaultQueueSize
+	}
+	for _, r := range d.routes {
+		r.queue = make(chan *Entry, size)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			failed := false
+			for e := range r.queue {
+				if failed {
+					continue
+				}
+				if err := r.fn(e); err != nil {
+					failed = true
+					d.fail(errors.Wrapf(err, "cannot handle %v entry %d", e.Kind, e.VXID))
+				}
+			}
+		}()
+	}
+}
+
+// fail records the error of a handler, keeping the first one.
+func (d *Dispatcher) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Err returns the first error returned by a handler, if any.
+func (d *Dispatcher) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// Dispatch queues the entry e for the handlers registered for its kind whose
+// filters match it, blocking while their queues are full. A handler which
+// returned an error is passed no more entries; the first such error is
+// returned by Dispatch and Close, so that the caller can stop early. Once the
+// dispatcher is closed, ErrDispatcherClosed is returned.
+func (d *Dispatcher) Dispatch(e *Entry) error {
+	return d.DispatchContext(context.Background(), e)
+}
+
+// DispatchContext is like Dispatch, except that it stops waiting for full
+// queues once ctx is done, returning the error of the context.
+func (d *Dispatcher) DispatchContext(ctx context.Context, e *Entry) error {
+	d.start.Do(d.run)
+	d.state.RLock()
+	defer d.state.RUnlock()
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	for _, r := range d.routes {
+		if r.kind != e.Kind || r.filter != nil && !r.filter.Match(e) {
+			continue
+		}
+		select {
+		case r.queue <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return d.Err()
+}
+
+// Close waits until the handlers processed all queued entries and returns the
+// first error returned by a handler, if any. Entries dispatched after Close
+// are rejected with ErrDispatcherClosed.
+func (d *Dispatcher) Close() error {
+	d.start.Do(d.run)
+	d.state.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, r := range d.routes {
+			close(r.queue)
+		}
+	}
+	d.state.Unlock()
+	d.wg.Wait()
+	return d.Err()
+}
+
+// Run dispatches the entries parsed by p until the end of its input and
+// closes the dispatcher. It stops early if parsing fails, if a handler
+// returns an error or if ctx is done, and returns the error.
+func (d *Dispatcher) Run(ctx context.Context, p *Parser) error {
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = d.DispatchContext(ctx, e)
+		}
+		if err != nil {
+			d.Close()
+			return err
+		}
+	}
+	return d.Close()
+}
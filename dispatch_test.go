@@ -0,0 +1,116 @@
+package vslparser
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestDispatcher tests that entries are routed to the handlers of their kinds
+// whose filters match them, and that errors of handlers stop the dispatch.
+func TestDispatcher(t *testing.T) {
+	s := `
+*   << Request  >> 2
+-   ReqURL         /a
+-   End
+*   << BeReq    >> 3
+-   BereqURL       /a
+-   End
+*   << Request  >> 4
+-   ReqURL         /b
+-   End
+*   << Session  >> 1
+-   End
+`
+	var mu sync.Mutex
+	got := map[string][]uint64{}
+	handler := func(name string) func(*Entry) error {
+		return func(e *Entry) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[name] = append(got[name], e.VXID)
+			return nil
+		}
+	}
+	d := &Dispatcher{QueueSize: 1}
+	d.Handle(Request, handler("requests"))
+	d.Handle(BeReq, handler("bereqs"))
+	d.HandleFilter(Request, FilterFunc(func(e *Entry) bool {
+		return e.TryField("ReqURL") == "/b"
+	}), handler("b"))
+	d.Handle(Request, handler("requests2"))
+	if err := d.Run(context.Background(), NewParser(strings.NewReader(s))); err != nil {
+		t.Fatalf("dispatching should not fail, got: %v", err)
+	}
+	want := map[string][]uint64{
+		"requests":  {2, 4},
+		"requests2": {2, 4},
+		"bereqs":    {3},
+		"b":         {4},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("dispatching should give %v, got %v", want, got)
+	}
+
+	// A failing handler gets no more entries, and its error is returned.
+	var handled []uint64
+	fail := errors.New("sink is down")
+	d = &Dispatcher{}
+	d.Handle(Request, func(e *Entry) error {
+		handled = append(handled, e.VXID)
+		return fail
+	})
+	for _, e := range []*Entry{NewEntry(Request, 2), NewEntry(Request, 4)} {
+		if err := d.Dispatch(e); err != nil && !errors.Is(err, fail) {
+			t.Errorf("dispatching should give the error of the handler, got: %v", err)
+		}
+	}
+	if err := d.Close(); !errors.Is(err, fail) {
+		t.Errorf("closing should give the error of the handler, got: %v", err)
+	} else {
+		t.Logf("closing gives: %v", err)
+	}
+	if !slices.Equal(handled, []uint64{2}) {
+		t.Errorf("failed handler should only handle entry 2, got %v", handled)
+	}
+
+	// Dispatching stops waiting for a full queue once the context is done.
+	block := make(chan struct{})
+	d = &Dispatcher{QueueSize: 1}
+	d.Handle(Request, func(*Entry) error {
+		<-block
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = d.DispatchContext(ctx, NewEntry(Request, 2))
+	}
+	if err != context.Canceled {
+		t.Errorf("dispatching to a full queue should be canceled, got: %v", err)
+	}
+	close(block)
+	d.Close()
+
+	// Closing twice, e.g. deferred after Run, and dispatching to a closed
+	// dispatcher do not panic.
+	d = &Dispatcher{}
+	d.Handle(Request, handler("closed"))
+	if err := d.Run(context.Background(), NewParser(strings.NewReader(s))); err != nil {
+		t.Fatalf("dispatching should not fail, got: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("closing twice should not fail, got: %v", err)
+	}
+	if err := d.Dispatch(NewEntry(Request, 5)); err != ErrDispatcherClosed {
+		t.Errorf("dispatching after Close should give ErrDispatcherClosed, got: %v", err)
+	}
+	if got := got["closed"]; !slices.Equal(got, []uint64{2, 4}) {
+		t.Errorf("closed dispatcher should handle entries [2 4], got %v", got)
+	}
+}
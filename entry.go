@@ -2,8 +2,10 @@ package vslparser
 
 import (
 	"github.com/pkg/errors"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -11,9 +13,12 @@ import (
 
 // Timestamp represents a parsed time-stamp.
 type Timestamp struct {
-	AbsTime     time.Time // Absolute time component.
-	UsSinceUnit int       // Number of microseconds since the start of work unit.
-	UsSincePrev int       // Number of microseconds since previous stamp.
+	Event       string        // Name of the event, e.g. "Start" or "Resp".
+	AbsTime     time.Time     // Absolute time component.
+	SinceStart  time.Duration // Time since the start of work unit.
+	SinceLast   time.Duration // Time since previous stamp.
+	UsSinceUnit int           // Number of microseconds since the start of work unit.
+	UsSincePrev int           // Number of microseconds since previous stamp.
 }
 
 // Fields are a collection of log fields. A single log field consists of a key
@@ -28,12 +33,117 @@ type Timestamp struct {
 // "Bar" with an empty string as a sole value.
 type Fields map[string][]string
 
+// Get returns the first value of the field with the given key, or an empty
+// string if there is no such field. Use Lookup to tell a missing field from
+// an empty value.
+func (fs Fields) Get(key string) string {
+	v, _ := fs.Lookup(key)
+	return v
+}
+
+// Lookup returns the first value of the field with the given key and whether
+// there is such a field.
+func (fs Fields) Lookup(key string) (string, bool) {
+	vs := fs[key]
+	if len(vs) == 0 {
+		return "", false
+	}
+	return vs[0], true
+}
+
+// GetFold is like Get, except that the key is compared case-insensitively,
+// so that e.g. "reqheader" finds the ReqHeader field. It is meant for
+// interactive tools; tags are case-sensitive and Get is faster.
+func (fs Fields) GetFold(key string) string {
+	v, _ := fs.LookupFold(key)
+	return v
+}
+
+// LookupFold is like Lookup, except that the key is compared
+// case-insensitively, see GetFold. An exact match is preferred over other
+// matches.
+func (fs Fields) LookupFold(key string) (string, bool) {
+	if v, ok := fs.Lookup(key); ok {
+		return v, true
+	}
+	keys := slices.Sorted(maps.Keys(fs))
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			if v, ok := fs.Lookup(k); ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// First returns the first value of a field which may appear multiple times,
+// e.g. ReqURL as received before it was changed in VCL or the request was
+// restarted, and whether there is such a field. It is the same as Lookup.
+func (fs Fields) First(key string) (string, bool) {
+	return fs.Lookup(key)
+}
+
+// Last returns the last value of a field which may appear multiple times,
+// e.g. the final ReqURL after it was changed in VCL, and whether there is
+// such a field.
+func (fs Fields) Last(key string) (string, bool) {
+	vs := fs[key]
+	if len(vs) == 0 {
+		return "", false
+	}
+	return vs[len(vs)-1], true
+}
+
+// Record is a single log record, i.e. a tag and its value.
+type Record struct {
+	Tag   string
+	Value string
+}
+
+// Records are log records in the order in which they were logged.
+type Records []Record
+
+// Fields returns the fields made of the records, i.e. the values of records
+// of each tag in the order in which they appear.
+func (rs Records) Fields() Fields {
+	fs := Fields{}
+	for _, r := range rs {
+		fs[r.Tag] = append(fs[r.Tag], r.Value)
+	}
+	return fs
+}
+
 // Entry holds a single log entry. An entry consists mostly of a collection
-// of log fields.
+// of log fields. Records holds the same records in the order in which they
+// were logged, which matters e.g. for headers changed in VCL, and from which
+// Fields can be derived by Records.Fields. Entries parsed by this package
+// have both; accessors which depend on the order of records of different
+// tags fall back to Fields for entries without Records.
+//
+// When transactions are grouped (see Grouping), Level is the nesting level of
+// the transaction reported by varnishlog, starting with 1 for the top-level
+// transaction, and Children holds the transactions nested in this one.
+//
+// RawKind is the kind of a transaction of a type unknown to this package as
+// reported by Varnish, in which case Kind is Unknown.
+//
+// Truncated is set if the input ended before the "End" record of the entry,
+// which is only accepted if the parser allows truncated entries. Incomplete
+// is set on the top-level entry of a tree assembled by a TreeBuilder if some
+// transactions of the tree are missing, see FlushPolicy, and on entries read
+// from binary or shared memory logs whose first records are missing, see
+// VSMReader.NextEntry.
 type Entry struct {
-	Kind   string
-	VXID   int
-	Fields Fields
+	Kind       Kind
+	RawKind    string
+	VXID       uint64
+	Level      int
+	Fields     Fields
+	Records    Records
+	Children   []*Entry
+	Truncated  bool
+	Incomplete bool
 }
 
 // newEntry returns a new empty log entry.
@@ -43,23 +153,151 @@ func newEntry() *Entry {
 	}
 }
 
+// NewEntry returns a new top-level entry of the given kind and VXID, to which
+// records and nested transactions can be added in code, e.g. in tests:
+//
+//	e := vslparser.NewEntry(vslparser.Request, 2).
+//		Add("ReqMethod", "GET").
+//		Add("ReqURL", "/foo").
+//		AddChild(vslparser.NewEntry(vslparser.BeReq, 3))
+func NewEntry(kind Kind, vxid uint64) *Entry {
+	e := newEntry()
+	e.Kind = kind
+	e.VXID = vxid
+	e.Level = 1
+	return e
+}
+
+// Add appends a record with the given tag and value to the entry, like the
+// parser does, and returns the entry.
+func (e *Entry) Add(tag, value string) *Entry {
+	if e.Fields == nil {
+		e.Fields = Fields{}
+	}
+	e.add(tag, value)
+	return e
+}
+
+// AddChild attaches the transaction c nested in the entry, adjusting the
+// levels of c and the transactions nested in it, and returns the entry.
+func (e *Entry) AddChild(c *Entry) *Entry {
+	d := e.Level + 1 - c.Level
+	c.Walk(func(c *Entry) bool {
+		c.Level += d
+		return true
+	})
+	e.Children = append(e.Children, c)
+	return e
+}
+
+// reset empties the entry so that it can be reused for parsing of another
+// entry, keeping the memory allocated for its fields.
+func (e *Entry) reset() {
+	e.Kind = Unknown
+	e.RawKind = ""
+	e.VXID = 0
+	e.Level = 0
+	clear(e.Fields)
+	e.Records = e.Records[:0]
+	e.Children = nil
+	e.Truncated = false
+	e.Incomplete = false
+}
+
+// Clone returns a deep copy of the entry, including its fields, records and
+// nested transactions, which can be modified without affecting the original.
+func (e *Entry) Clone() *Entry {
+	c := *e
+	if e.Fields != nil {
+		c.Fields = make(Fields, len(e.Fields))
+		for k, vs := range e.Fields {
+			c.Fields[k] = slices.Clone(vs)
+		}
+	}
+	c.Records = slices.Clone(e.Records)
+	if e.Children != nil {
+		c.Children = make([]*Entry, len(e.Children))
+		for i, ch := range e.Children {
+			c.Children[i] = ch.Clone()
+		}
+	}
+	return &c
+}
+
+// add appends a record with the given tag and value to the entry.
+func (e *Entry) add(tag, value string) {
+	e.Records = append(e.Records, Record{Tag: tag, Value: value})
+	e.Fields[tag] = append(e.Fields[tag], value)
+}
+
+// Walk calls fn for the entry and all transactions nested in it, depth-first,
+// parents before their children. Walking stops as soon as fn returns false.
+// Walk returns false if it was stopped, true otherwise.
+func (e *Entry) Walk(fn func(*Entry) bool) bool {
+	if !fn(e) {
+		return false
+	}
+	for _, c := range e.Children {
+		if !c.Walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDecimal parses the given decimal number of seconds, e.g.
+// "1545037998.267746", exactly into whole seconds and nanoseconds, avoiding
+// the rounding errors of floating point numbers. Digits beyond nanoseconds are
+// truncated.
+func parseDecimal(s string) (int64, int64, error) {
+	i, f, frac := strings.Cut(s, ".")
+	neg := strings.HasPrefix(i, "-")
+	if neg {
+		i = i[1:]
+	}
+	if !digits(i) || frac && !digits(f) {
+		return 0, 0, errors.Errorf("invalid decimal number %q", s)
+	}
+	sec, err := strconv.ParseInt(i, 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid decimal number %q", s)
+	}
+	if len(f) > 9 {
+		f = f[:9]
+	}
+	nsec, _ := strconv.ParseInt(f+strings.Repeat("0", 9-len(f)), 10, 64)
+	if neg {
+		sec, nsec = -sec, -nsec
+	}
+	return sec, nsec, nil
+}
+
+// parseDuration returns the duration encoded in the given decimal number of
+// seconds.
+func parseDuration(s string) (time.Duration, error) {
+	sec, nsec, err := parseDecimal(s)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot parse duration")
+	}
+	return time.Duration(sec)*time.Second + time.Duration(nsec), nil
+}
+
 // parseUs returns the number of microseconds encoded in the given string.
 func parseUs(s string) (int, error) {
-	sec, err := strconv.ParseFloat(s, 64)
+	d, err := parseDuration(s)
 	if err != nil {
-		return 0, errors.Wrap(err, "cannot parse float component")
+		return 0, err
 	}
-	us := int(1e6 * sec)
-	return us, nil
+	return int(d / time.Microsecond), nil
 }
 
 // parseAbsTime returns a time.Time object parsed from the given string.
 func parseAbsTime(s string) (time.Time, error) {
-	us, err := parseUs(s)
+	sec, nsec, err := parseDecimal(s)
 	if err != nil {
 		return time.Time{}, errors.Wrap(err, "cannot parse absolute time")
 	}
-	return time.Unix(0, 1e3*int64(us)).UTC(), nil
+	return time.Unix(sec, nsec).UTC(), nil
 }
 
 // Field returns a fields of the log field with the given key. For example, in
@@ -117,7 +355,7 @@ func (e *Entry) URLField(key string) (*url.URL, error) {
 func (e *Entry) HeadersField(key string) (http.Header, error) {
 	h := http.Header{}
 	for _, f := range e.Fields[key] {
-		name, val, err := rfc7230Split(f)
+		name, val, err := splitHeader(f)
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot parse field %q as HTTP headers", key)
 		}
@@ -126,6 +364,30 @@ func (e *Entry) HeadersField(key string) (http.Header, error) {
 	return h, nil
 }
 
+// RequestHeaders returns the headers of the client request logged in ReqHeader
+// records, in the order in which they appear.
+func (e *Entry) RequestHeaders() (http.Header, error) {
+	return e.HeadersField("ReqHeader")
+}
+
+// ResponseHeaders returns the headers of the client response logged in
+// RespHeader records, in the order in which they appear.
+func (e *Entry) ResponseHeaders() (http.Header, error) {
+	return e.HeadersField("RespHeader")
+}
+
+// BereqHeaders returns the headers of the backend request logged in
+// BereqHeader records, in the order in which they appear.
+func (e *Entry) BereqHeaders() (http.Header, error) {
+	return e.HeadersField("BereqHeader")
+}
+
+// BerespHeaders returns the headers of the backend response logged in
+// BerespHeader records, in the order in which they appear.
+func (e *Entry) BerespHeaders() (http.Header, error) {
+	return e.HeadersField("BerespHeader")
+}
+
 // rfc7230Split splits a key-value pair according to the grammar for HTTP
 // header fields described by RFC 7230 into a key and a value component. Please
 // note that only single-line headers may be parsed in this way. (Which is OK,
@@ -160,6 +422,16 @@ func rfc7230Split(v string) (string, string, error) {
 	return fn, fv, nil
 }
 
+// splitHeader splits a header record into its name and value like
+// rfc7230Split, except that the name must not be empty.
+func splitHeader(v string) (string, string, error) {
+	name, val, err := rfc7230Split(v)
+	if err == nil && name == "" {
+		err = errors.Errorf("empty header name in %q", v)
+	}
+	return name, val, err
+}
+
 // NamedField returns a structured field with the given key, whose name
 // component is the given name, if it exists. Names are compared
 // case-insensitive to accommodate for HTTP headers.
@@ -199,18 +471,158 @@ func (e *Entry) Timestamp(name string) (*Timestamp, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "entry has no timestamp %q", name)
 	}
+	return parseTimestamp(name, stamp)
+}
+
+// Timestamps parses and returns all Timestamp records of the log entry, in the
+// order in which they appear.
+func (e *Entry) Timestamps() ([]*Timestamp, error) {
+	fs := e.Fields["Timestamp"]
+	stamps := make([]*Timestamp, 0, len(fs))
+	for _, v := range fs {
+		name, fv, err := rfc7230Split(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "timestamp %q is malformed", v)
+		}
+		ts, err := parseTimestamp(name, strings.Fields(fv))
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, ts)
+	}
+	return stamps, nil
+}
+
+// parseTimestamp parses the components of the timestamp with the given name.
+func parseTimestamp(name string, stamp []string) (*Timestamp, error) {
 	if len(stamp) != 3 {
 		return nil, errors.Errorf("timestamp %q is malformed", name)
 	}
-	ts := &Timestamp{}
+	var err error
+	ts := &Timestamp{Event: name}
 	if ts.AbsTime, err = parseAbsTime(stamp[0]); err != nil {
 		return nil, errors.Wrap(err, "cannot parse absolute time")
 	}
-	if ts.UsSinceUnit, err = parseUs(stamp[1]); err != nil {
+	if ts.SinceStart, err = parseDuration(stamp[1]); err != nil {
 		return nil, errors.Wrap(err, "cannot parse time since work unit start")
 	}
-	if ts.UsSincePrev, err = parseUs(stamp[2]); err != nil {
+	if ts.SinceLast, err = parseDuration(stamp[2]); err != nil {
 		return nil, errors.Wrap(err, "cannot parse time since previous timestamp")
 	}
+	ts.UsSinceUnit = int(ts.SinceStart / time.Microsecond)
+	ts.UsSincePrev = int(ts.SinceLast / time.Microsecond)
 	return ts, nil
 }
+
+// lastField returns the last value of the log field with the given key, which
+// for fields changed in VCL is the final one.
+func (e *Entry) lastField(key string) (string, error) {
+	fs, err := e.Field(key)
+	if err != nil {
+		return "", err
+	}
+	return fs[len(fs)-1], nil
+}
+
+// Occurrence selects which of the values of a log field, which appears
+// multiple times in an entry, is returned.
+type Occurrence int
+
+const (
+	// Final selects the last value of the field, e.g. the URL after it was
+	// rewritten in VCL or the request was restarted.
+	Final Occurrence = iota
+	// Initial selects the first value of the field, e.g. the URL as received
+	// from the client.
+	Initial
+)
+
+// occurrenceField returns the value of the log field with the given key
+// selected by o.
+func (e *Entry) occurrenceField(key string, o Occurrence) (string, error) {
+	if o == Initial {
+		fs, err := e.Field(key)
+		if err != nil {
+			return "", err
+		}
+		return fs[0], nil
+	}
+	return e.lastField(key)
+}
+
+// kindField returns the last value of the log field with the key given for
+// the kind of the entry, clientKey for client requests and backendKey for
+// backend requests.
+func (e *Entry) kindField(clientKey, backendKey string) (string, string, error) {
+	return e.kindOccurrenceField(clientKey, backendKey, Final)
+}
+
+// kindOccurrenceField is like kindField, except that the value of the field
+// is selected by o.
+func (e *Entry) kindOccurrenceField(clientKey, backendKey string, o Occurrence) (string, string, error) {
+	switch e.Kind {
+	case Request:
+		v, err := e.occurrenceField(clientKey, o)
+		return clientKey, v, err
+	case BeReq:
+		v, err := e.occurrenceField(backendKey, o)
+		return backendKey, v, err
+	}
+	return "", "", errors.Errorf("%v entry has no %s or %s field", e.Kind, clientKey, backendKey)
+}
+
+// Method returns the method of the request, i.e. ReqMethod of client requests
+// and BereqMethod of backend requests, selected by o.
+func (e *Entry) Method(o Occurrence) (string, error) {
+	_, v, err := e.kindOccurrenceField("ReqMethod", "BereqMethod", o)
+	return v, err
+}
+
+// URL returns the URL of the request, i.e. ReqURL of client requests and
+// BereqURL of backend requests, selected by o.
+func (e *Entry) URL(o Occurrence) (string, error) {
+	_, v, err := e.kindOccurrenceField("ReqURL", "BereqURL", o)
+	return v, err
+}
+
+// Proto returns the protocol of the request, i.e. ReqProtocol of client
+// requests and BereqProtocol of backend requests, selected by o.
+func (e *Entry) Proto(o Occurrence) (string, error) {
+	_, v, err := e.kindOccurrenceField("ReqProtocol", "BereqProtocol", o)
+	return v, err
+}
+
+// ObjectLength returns the length of the object body as reported by the
+// Length record.
+func (e *Entry) ObjectLength() (int64, error) {
+	v, err := e.lastField("Length")
+	if err != nil {
+		return 0, err
+	}
+	l, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot convert field \"Length\" to an int")
+	}
+	return l, nil
+}
+
+// Status returns the final status code of the response, i.e. RespStatus of
+// client requests and BerespStatus of backend requests.
+func (e *Entry) Status() (int, error) {
+	key, v, err := e.kindField("RespStatus", "BerespStatus")
+	if err != nil {
+		return 0, err
+	}
+	s, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot convert field %q to an int", key)
+	}
+	return s, nil
+}
+
+// Reason returns the final reason phrase of the response, i.e. RespReason of
+// client requests and BerespReason of backend requests.
+func (e *Entry) Reason() (string, error) {
+	_, v, err := e.kindField("RespReason", "BerespReason")
+	return v, err
+}
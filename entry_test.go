@@ -2,6 +2,7 @@ package vslparser
 
 import (
 	"bufio"
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
@@ -100,6 +101,75 @@ func TestTimestamp(t *testing.T) {
 	}
 }
 
+// TestTimestamps tests that all Timestamp records are parsed exactly, in
+// order, and that malformed ones produce errors.
+func TestTimestamps(t *testing.T) {
+	e, err := Parse(stringScanner("* << Request >> 2\n" +
+		"- Timestamp Start: 1545037998.267746 0.000000 0.000000\n" +
+		"- Timestamp Resp: 1545037998.267831 0.000085 0.000047\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := []*Timestamp{
+		&Timestamp{
+			Event:   "Start",
+			AbsTime: time.Unix(1545037998, 267746000).UTC(),
+		},
+		&Timestamp{
+			Event:       "Resp",
+			AbsTime:     time.Unix(1545037998, 267831000).UTC(),
+			SinceStart:  85 * time.Microsecond,
+			SinceLast:   47 * time.Microsecond,
+			UsSinceUnit: 85,
+			UsSincePrev: 47,
+		},
+	}
+	got, err := e.Timestamps()
+	if err != nil {
+		t.Fatalf("parsing timestamps should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing timestamps should give %v, got %v", want, got)
+	}
+
+	if _, err := example().Timestamps(); err == nil {
+		t.Errorf("parsing malformed timestamps should fail")
+	} else {
+		t.Logf("parsing malformed timestamps gives: %v", err)
+	}
+}
+
+// TestParseDecimal tests that decimal numbers of seconds are parsed exactly.
+func TestParseDecimal(t *testing.T) {
+	samples := map[string][2]int64{
+		"1545037998.267746": {1545037998, 267746000},
+		"0.000001":          {0, 1000},
+		"12":                {12, 0},
+		"1.5":               {1, 500000000},
+		"1.1234567891":      {1, 123456789},
+		"-0.25":             {0, -250000000},
+		"1700000000.999999": {1700000000, 999999000},
+	}
+	for s, want := range samples {
+		sec, nsec, err := parseDecimal(s)
+		if err != nil {
+			t.Errorf("parsing %q should not fail, got: %v", s, err)
+			continue
+		}
+		if sec != want[0] || nsec != want[1] {
+			t.Errorf("parsing %q should give %v, got [%d %d]", s, want, sec, nsec)
+		}
+	}
+	for _, s := range []string{"", ".5", "1.", "1e3", "foo", "1.2.3", "--1"} {
+		if _, _, err := parseDecimal(s); err == nil {
+			t.Errorf("parsing %q should fail", s)
+		} else {
+			t.Logf("parsing %q gives: %v", s, err)
+		}
+	}
+}
+
 func TestNamedField(t *testing.T) {
 	e := example()
 	respHeaders := map[string]string{
@@ -207,3 +277,258 @@ func TestIntField(t *testing.T) {
 		}
 	}
 }
+
+// TestStatus tests that the status, reason and length are looked up by the
+// kind of the entry, using the final values.
+func TestStatus(t *testing.T) {
+	e := example()
+	if s, err := e.Status(); err != nil || s != 200 {
+		t.Errorf("status of example should be 200, got %d (%v)", s, err)
+	}
+	if r, err := e.Reason(); err != nil || r != "OK" {
+		t.Errorf("reason of example should be OK, got %q (%v)", r, err)
+	}
+	if _, err := e.ObjectLength(); err == nil {
+		t.Errorf("length of example without Length record should fail")
+	}
+
+	e, err := Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BerespStatus 200\n- BerespReason OK\n" +
+		"- BerespStatus 503\n- BerespReason Service Unavailable\n" +
+		"- RespStatus 404\n- Length 1234\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if s, err := e.Status(); err != nil || s != 503 {
+		t.Errorf("status of backend request should be 503, got %d (%v)", s, err)
+	}
+	if r, err := e.Reason(); err != nil || r != "Service Unavailable" {
+		t.Errorf("reason of backend request should be \"Service Unavailable\", got %q (%v)", r, err)
+	}
+	if l, err := e.ObjectLength(); err != nil || l != 1234 {
+		t.Errorf("length of backend request should be 1234, got %d (%v)", l, err)
+	}
+
+	bad := []*Entry{
+		&Entry{Kind: Session, Fields: Fields{"RespStatus": []string{"200"}}},
+		&Entry{Kind: Request, Fields: Fields{"RespStatus": []string{"OK"}, "Length": []string{"-1x"}}},
+		&Entry{Kind: Request, Fields: Fields{}},
+	}
+	for _, e := range bad {
+		if _, err := e.Status(); err == nil {
+			t.Errorf("status of %v should fail", e)
+		} else {
+			t.Logf("status of %v gives: %v", e, err)
+		}
+	}
+	if _, err := bad[1].ObjectLength(); err == nil {
+		t.Errorf("malformed length should fail")
+	}
+	if _, err := bad[0].Reason(); err == nil {
+		t.Errorf("reason of session should fail")
+	}
+}
+
+// TestRequestLine tests that the method, URL and protocol of requests are
+// taken from the records of the kind of the entry, first or final.
+func TestRequestLine(t *testing.T) {
+	e := example()
+	if m, err := e.Method(Final); err != nil || m != "GET" {
+		t.Errorf("method of example should be GET, got %q (%v)", m, err)
+	}
+	if u, err := e.URL(Initial); err != nil || u != "/health" {
+		t.Errorf("URL of example should be /health, got %q (%v)", u, err)
+	}
+	if p, err := e.Proto(Final); err != nil || p != "HTTP/1.0" {
+		t.Errorf("protocol of example should be HTTP/1.0, got %q (%v)", p, err)
+	}
+
+	e, err := Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BereqMethod GET\n- BereqURL /foo\n- BereqProtocol HTTP/1.1\n" +
+		"- ReqURL /bar\n- BereqURL /foo?bar\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if u, err := e.URL(Initial); err != nil || u != "/foo" {
+		t.Errorf("initial URL of backend request should be /foo, got %q (%v)", u, err)
+	}
+	if u, err := e.URL(Final); err != nil || u != "/foo?bar" {
+		t.Errorf("final URL of backend request should be /foo?bar, got %q (%v)", u, err)
+	}
+	if m, err := e.Method(Initial); err != nil || m != "GET" {
+		t.Errorf("method of backend request should be GET, got %q (%v)", m, err)
+	}
+
+	for _, e := range []*Entry{
+		&Entry{Kind: Session, Fields: Fields{"ReqURL": []string{"/"}}},
+		&Entry{Kind: Request, Fields: Fields{"BereqURL": []string{"/"}}},
+	} {
+		if _, err := e.URL(Initial); err == nil {
+			t.Errorf("URL of %v should fail", e)
+		} else {
+			t.Logf("URL of %v gives: %v", e, err)
+		}
+	}
+}
+
+// TestHeaders tests that header records are split at the first colon into
+// http.Header.
+func TestHeaders(t *testing.T) {
+	e, err := Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BereqHeader Host: example.com:8080\n" +
+		"- BereqHeader X-Foo: a\n- BereqHeader x-foo:b\n" +
+		"- BerespHeader Date: Mon, 17 Dec 2018 09:13:18 GMT\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := http.Header{"Host": []string{"example.com:8080"}, "X-Foo": []string{"a", "b"}}
+	if h, err := e.BereqHeaders(); err != nil || !reflect.DeepEqual(want, h) {
+		t.Errorf("backend request headers should be %v, got %v (%v)", want, h, err)
+	}
+	want = http.Header{"Date": []string{"Mon, 17 Dec 2018 09:13:18 GMT"}}
+	if h, err := e.BerespHeaders(); err != nil || !reflect.DeepEqual(want, h) {
+		t.Errorf("backend response headers should be %v, got %v (%v)", want, h, err)
+	}
+	if h, err := e.RequestHeaders(); err != nil || len(h) != 0 {
+		t.Errorf("request headers of backend request should be empty, got %v (%v)", h, err)
+	}
+	if h, err := example().ResponseHeaders(); err != nil || h.Get("Server") != "Varnish" {
+		t.Errorf("response headers of example should contain Server, got %v (%v)", h, err)
+	}
+
+	for _, v := range []string{"NoColon", ": empty"} {
+		e := &Entry{Fields: Fields{"ReqHeader": []string{v}}}
+		if _, err := e.RequestHeaders(); err == nil {
+			t.Errorf("parsing header %q should fail", v)
+		} else {
+			t.Logf("parsing header %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestRecordsFields tests that fields are derived from records.
+func TestRecordsFields(t *testing.T) {
+	e := example()
+	if got := e.Records.Fields(); !reflect.DeepEqual(e.Fields, got) {
+		t.Errorf("fields of records of example should be %v, got %v", e.Fields, got)
+	}
+	if got := Records(nil).Fields(); len(got) != 0 {
+		t.Errorf("fields of no records should be empty, got %v", got)
+	}
+}
+
+// TestFieldsGet tests that the first value of a field is returned and that
+// missing fields are told from empty values.
+func TestFieldsGet(t *testing.T) {
+	fs := Fields{"ReqURL": []string{"/foo", "/bar"}, "Empty": []string{""}, "None": []string{}}
+	samples := map[string]struct {
+		v  string
+		ok bool
+	}{
+		"ReqURL":  {"/foo", true},
+		"Empty":   {"", true},
+		"None":    {"", false},
+		"Missing": {"", false},
+	}
+	for key, want := range samples {
+		if got := fs.Get(key); got != want.v {
+			t.Errorf("getting field %q should give %q, got %q", key, want.v, got)
+		}
+		if got, ok := fs.Lookup(key); got != want.v || ok != want.ok {
+			t.Errorf("looking up field %q should give %q, %t, got %q, %t", key, want.v, want.ok, got, ok)
+		}
+	}
+}
+
+// TestFieldsFirstLast tests that the first and last values of repeated fields
+// are returned.
+func TestFieldsFirstLast(t *testing.T) {
+	fs := Fields{"ReqURL": []string{"/foo", "/bar", "/baz"}, "None": []string{}}
+	if v, ok := fs.First("ReqURL"); !ok || v != "/foo" {
+		t.Errorf("first ReqURL should be /foo, got %q, %t", v, ok)
+	}
+	if v, ok := fs.Last("ReqURL"); !ok || v != "/baz" {
+		t.Errorf("last ReqURL should be /baz, got %q, %t", v, ok)
+	}
+	for _, key := range []string{"None", "Missing"} {
+		if v, ok := fs.First(key); ok || v != "" {
+			t.Errorf("first %s should be missing, got %q, %t", key, v, ok)
+		}
+		if v, ok := fs.Last(key); ok || v != "" {
+			t.Errorf("last %s should be missing, got %q, %t", key, v, ok)
+		}
+	}
+}
+
+// TestFieldsGetFold tests that fields are looked up case-insensitively,
+// preferring exact matches.
+func TestFieldsGetFold(t *testing.T) {
+	fs := Fields{"ReqHeader": []string{"Host: example.com"}, "requrl": []string{"/foo"}, "ReqURL": []string{"/bar"}}
+	samples := map[string]string{
+		"reqheader": "Host: example.com",
+		"REQHEADER": "Host: example.com",
+		"ReqURL":    "/bar",
+		"requrl":    "/foo",
+		"REQURL":    "/bar",
+		"Missing":   "",
+	}
+	for key, want := range samples {
+		if got := fs.GetFold(key); got != want {
+			t.Errorf("getting field %q case-insensitively should give %q, got %q", key, want, got)
+		}
+	}
+	if _, ok := fs.LookupFold("missing"); ok {
+		t.Errorf("looking up missing field case-insensitively should fail")
+	}
+}
+
+// TestClone tests that cloned entries are equal to the original and share no
+// memory with it.
+func TestClone(t *testing.T) {
+	e := example()
+	e.Children = []*Entry{&Entry{Kind: BeReq, VXID: 3, Fields: Fields{"BereqURL": []string{"/"}}}}
+	c := e.Clone()
+	if !reflect.DeepEqual(e, c) {
+		t.Fatalf("clone should be equal to the original %v, got %v", e, c)
+	}
+	c.Fields["ReqURL"][0] = "/changed"
+	c.Fields["New"] = []string{"x"}
+	c.Records[0].Value = "changed"
+	c.Children[0].Fields["BereqURL"][0] = "/changed"
+	c.Children = append(c.Children, &Entry{})
+	if e.Fields.Get("ReqURL") != "/health" || e.Fields["New"] != nil || e.Records[0].Value == "changed" {
+		t.Errorf("modifying the clone should not modify the original, got %v", e)
+	}
+	if e.Children[0].Fields.Get("BereqURL") != "/" || len(e.Children) != 1 {
+		t.Errorf("modifying the clone should not modify children of the original, got %v", e.Children)
+	}
+	if c := (&Entry{}).Clone(); !reflect.DeepEqual(&Entry{}, c) {
+		t.Errorf("clone of an empty entry should be empty, got %v", c)
+	}
+}
+
+// TestNewEntry tests that entries built in code are equal to parsed ones.
+func TestNewEntry(t *testing.T) {
+	s := "* << Request >> 2\n- Begin req 1 rxreq\n- ReqURL /foo\n- ReqURL /bar\n- End\n" +
+		"** << Request >> 3\n-- Begin req 2 esi\n-- End\n" +
+		"*** << BeReq >> 4\n--- End\n"
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	want, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", s, err)
+	}
+	got := NewEntry(Request, 2).
+		Add("Begin", "req 1 rxreq").
+		Add("ReqURL", "/foo").
+		Add("ReqURL", "/bar").
+		AddChild(NewEntry(Request, 3).
+			Add("Begin", "req 2 esi").
+			AddChild(NewEntry(BeReq, 4)))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("built entry should be %v, got %v", want, got)
+	}
+	if e := (&Entry{}).Add("ReqURL", "/"); e.Fields.Get("ReqURL") != "/" {
+		t.Errorf("adding to an entry without fields should give the field, got %v", e)
+	}
+}
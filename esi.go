@@ -0,0 +1,117 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// ESIError represents a parsed ESI_xmlerror record, which reports a problem
+// found by the ESI parser in an object body, e.g.:
+//
+//	ERR after 1234 ESI 1.0 <esi:include> lacks src attr
+type ESIError struct {
+	VXID     uint64 // VXID of the transaction which logged the record.
+	Severity string // "ERR" for errors, "WARN" for warnings.
+	Offset   int64  // Offset in the body after which the problem was found.
+	Message  string // Description of the problem.
+}
+
+// parseESIError parses the value of an ESI_xmlerror record.
+func parseESIError(v string) (*ESIError, error) {
+	fs := strings.SplitN(v, " ", 4)
+	if len(fs) != 4 || fs[1] != "after" {
+		return nil, errors.Errorf("ESI_xmlerror record %q is malformed", v)
+	}
+	off, err := strconv.ParseInt(fs[2], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse ESI_xmlerror record %q", v)
+	}
+	return &ESIError{Severity: fs[0], Offset: off, Message: fs[3]}, nil
+}
+
+// ESIErrors parses and returns the ESI_xmlerror records of the log entry and
+// of the transactions nested in it, depth-first, since the ESI parser runs
+// in the backend requests fetching the objects of a client request.
+func (e *Entry) ESIErrors() ([]*ESIError, error) {
+	var errs []*ESIError
+	var err error
+	e.Walk(func(t *Entry) bool {
+		for _, v := range t.Fields["ESI_xmlerror"] {
+			var ee *ESIError
+			if ee, err = parseESIError(v); err != nil {
+				return false
+			}
+			ee.VXID = t.VXID
+			errs = append(errs, ee)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// ESIInclude represents an ESI subrequest, which Varnish issues for each
+// <esi:include> of an object delivered to a client, along with the
+// subrequests issued for the objects it includes in turn.
+type ESIInclude struct {
+	VXID     uint64        // VXID of the subrequest.
+	Level    int           // Include level, 1 for includes of the top-level request.
+	Entry    *Entry        // Entry of the subrequest, nil if it is missing.
+	Includes []*ESIInclude // Subrequests of the objects included by this one.
+}
+
+// IsESI returns whether the log entry is an ESI subrequest, i.e. its Begin
+// record has the "esi" reason.
+func (e *Entry) IsESI() bool {
+	b, err := e.Begin()
+	return err == nil && e.Kind == Request && b.Reason == "esi"
+}
+
+// ESIIncludes returns the tree of ESI subrequests of the log entry, which
+// must have its nested transactions attached, see Grouping and TreeBuilder.
+// Subrequests are ordered as they are linked from their parents by Link
+// records with the "esi" reason; linked subrequests which are not attached
+// are reported without an Entry, and attached ESI subrequests which are not
+// linked follow the linked ones.
+func (e *Entry) ESIIncludes() ([]*ESIInclude, error) {
+	return esiIncludes(e, 1)
+}
+
+// esiIncludes returns the ESI subrequests of e at the given include level.
+func esiIncludes(e *Entry, level int) ([]*ESIInclude, error) {
+	links, err := e.Links()
+	if err != nil {
+		return nil, err
+	}
+	var incs []*ESIInclude
+	linked := make(map[uint64]bool)
+	for _, l := range links {
+		if l.Kind == Request && l.Reason == "esi" {
+			incs = append(incs, &ESIInclude{VXID: l.Child, Level: level})
+			linked[l.Child] = true
+		}
+	}
+	for _, c := range e.Children {
+		if !linked[c.VXID] && c.IsESI() {
+			incs = append(incs, &ESIInclude{VXID: c.VXID, Level: level})
+		}
+	}
+	for _, inc := range incs {
+		for _, c := range e.Children {
+			if c.VXID == inc.VXID && c.Kind == Request {
+				inc.Entry = c
+				break
+			}
+		}
+		if inc.Entry == nil {
+			continue
+		}
+		if inc.Includes, err = esiIncludes(inc.Entry, level+1); err != nil {
+			return nil, err
+		}
+	}
+	return incs, nil
+}
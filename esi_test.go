@@ -0,0 +1,117 @@
+package vslparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestESIErrors tests that ESI_xmlerror records are gathered from nested
+// transactions and that malformed ones produce errors.
+func TestESIErrors(t *testing.T) {
+	s := `*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   End
+**  << BeReq    >> 3
+--  Begin          bereq 2 fetch
+--  ESI_xmlerror   ERR after 1234 ESI 1.0 <esi:include> lacks src attr
+--  ESI_xmlerror   WARN after 2048 ESI 1.0 <esi:comment> element nested
+--  End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse request group: %v", err)
+	}
+	want := []*ESIError{
+		&ESIError{VXID: 3, Severity: "ERR", Offset: 1234, Message: "ESI 1.0 <esi:include> lacks src attr"},
+		&ESIError{VXID: 3, Severity: "WARN", Offset: 2048, Message: "ESI 1.0 <esi:comment> element nested"},
+	}
+	got, err := e.ESIErrors()
+	if err != nil {
+		t.Fatalf("gathering ESI errors should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("gathering ESI errors should give %v, got %v", want, got)
+	}
+
+	for _, v := range []string{"", "ERR 1234 lacks src attr", "ERR after x ESI 1.0", "ERR after 12"} {
+		e := &Entry{Fields: Fields{"ESI_xmlerror": []string{v}}}
+		if _, err := e.ESIErrors(); err == nil {
+			t.Errorf("parsing ESI_xmlerror record %q should fail", v)
+		} else {
+			t.Logf("parsing ESI_xmlerror record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestESIIncludes tests that ESI subrequests are gathered into a tree with
+// their include levels, in the order of their links, and that missing ones
+// are reported.
+func TestESIIncludes(t *testing.T) {
+	s := `*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   Link           req 4 esi
+-   Link           req 6 esi
+-   Link           req 8 esi
+-   End
+**  << BeReq    >> 3
+--  Begin          bereq 2 fetch
+--  End
+**  << Request  >> 6
+--  Begin          req 2 esi
+--  End
+**  << Request  >> 4
+--  Begin          req 2 esi
+--  Link           req 5 esi
+--  End
+*** << Request  >> 5
+--- Begin          req 4 esi
+--- End
+**  << Request  >> 7
+--  Begin          req 2 esi
+--  End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse request group: %v", err)
+	}
+	got, err := e.ESIIncludes()
+	if err != nil {
+		t.Fatalf("gathering ESI includes should not fail, got: %v", err)
+	}
+	var flat []ESIInclude
+	var walk func(incs []*ESIInclude)
+	walk = func(incs []*ESIInclude) {
+		for _, inc := range incs {
+			flat = append(flat, ESIInclude{VXID: inc.VXID, Level: inc.Level})
+			if inc.Entry != nil && inc.Entry.VXID != inc.VXID {
+				t.Errorf("ESI include %d should have its entry, got %d", inc.VXID, inc.Entry.VXID)
+			}
+			walk(inc.Includes)
+		}
+	}
+	walk(got)
+	want := []ESIInclude{{VXID: 4, Level: 1}, {VXID: 5, Level: 2}, {VXID: 6, Level: 1}, {VXID: 8, Level: 1}, {VXID: 7, Level: 1}}
+	if !reflect.DeepEqual(want, flat) {
+		t.Errorf("gathering ESI includes should give %v, got %v", want, flat)
+	}
+	if got[2].Entry != nil {
+		t.Errorf("missing ESI include 8 should have no entry, got %v", got[2].Entry)
+	}
+	if e.IsESI() || !e.Children[1].IsESI() || e.Children[0].IsESI() {
+		t.Errorf("only ESI subrequests should be reported as such")
+	}
+
+	bad := NewEntry(Request, 2).Add("Link", "req x esi")
+	if _, err := bad.ESIIncludes(); err == nil {
+		t.Errorf("gathering ESI includes with malformed link should fail")
+	} else {
+		t.Logf("gathering ESI includes with malformed link gives: %v", err)
+	}
+}
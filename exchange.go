@@ -0,0 +1,55 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// BackendExchange holds the request sent to a backend and the response
+// received from it, as logged in a backend request.
+type BackendExchange struct {
+	VXID        uint64         // VXID of the backend request.
+	Backend     *BackendOpen   // Connection to the backend, nil if none was logged.
+	Request     *http.Request  // Request sent to the backend.
+	Response    *http.Response // Response received from the backend, nil if none was logged.
+	Timestamps  []*Timestamp   // Timestamps of the backend request.
+	FirstByte   time.Duration  // Time until the response headers were received, 0 if they were not.
+	Total       time.Duration  // Time since the start of the backend request of its last timestamp.
+	FetchErrors []*FetchError  // Errors of the fetch, see FetchErrors.
+}
+
+// BackendExchange gathers the request, response, timing and backend of a
+// backend request. The response is built only if the entry has a
+// BerespStatus record; such responses may be synthesized in
+// vcl_backend_error if the fetch failed, so FetchErrors should be checked.
+func (e *Entry) BackendExchange() (*BackendExchange, error) {
+	if e.Kind != BeReq {
+		return nil, errors.Errorf("%v entry is not a backend request", e.Kind)
+	}
+	x := &BackendExchange{VXID: e.VXID, FetchErrors: e.FetchErrors()}
+	var err error
+	if _, ok := e.Fields["BackendOpen"]; ok {
+		if x.Backend, err = e.BackendOpen(); err != nil {
+			return nil, err
+		}
+	}
+	if x.Request, err = e.ToHTTPRequest(); err != nil {
+		return nil, err
+	}
+	if _, ok := e.Fields["BerespStatus"]; ok {
+		if x.Response, err = e.ToHTTPResponse(); err != nil {
+			return nil, err
+		}
+	}
+	if x.Timestamps, err = e.Timestamps(); err != nil {
+		return nil, err
+	}
+	for _, ts := range x.Timestamps {
+		if ts.Event == "Beresp" {
+			x.FirstByte = ts.SinceStart
+		}
+		x.Total = ts.SinceStart
+	}
+	return x, nil
+}
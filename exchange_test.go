@@ -0,0 +1,74 @@
+package vslparser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBackendExchange tests that the request, response, timing and backend
+// are gathered from a backend request.
+func TestBackendExchange(t *testing.T) {
+	s := `*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   Timestamp      Start: 1545037998.000000 0.000000 0.000000
+-   BereqMethod    GET
+-   BereqURL       /foo
+-   BereqProtocol  HTTP/1.1
+-   BereqHeader    Host: example.com
+-   VCL_call       BACKEND_FETCH
+-   VCL_return     fetch
+-   BackendOpen    17 boot.default 127.0.0.1 8080 127.0.0.1 41234 connect
+-   Timestamp      Bereq: 1545037998.001000 0.001000 0.001000
+-   Timestamp      Beresp: 1545037998.021000 0.021000 0.020000
+-   BerespProtocol HTTP/1.1
+-   BerespStatus   200
+-   BerespReason   OK
+-   BerespHeader   Content-Type: text/plain
+-   Timestamp      BerespBody: 1545037998.031000 0.031000 0.010000
+-   End
+`
+	e, err := Parse(stringScanner(s))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	x, err := e.BackendExchange()
+	if err != nil {
+		t.Fatalf("gathering backend exchange should not fail, got: %v", err)
+	}
+	if x.VXID != 3 || x.Backend == nil || x.Backend.Name != "boot.default" {
+		t.Errorf("backend exchange should be of request 3 to boot.default, got %v", x)
+	}
+	if x.Request.URL.Path != "/foo" || x.Request.Host != "example.com" {
+		t.Errorf("backend exchange should have request of /foo, got %v", x.Request)
+	}
+	if x.Response == nil || x.Response.StatusCode != 200 || x.Response.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("backend exchange should have 200 response, got %v", x.Response)
+	}
+	if len(x.Timestamps) != 4 || x.FirstByte != 21*time.Millisecond || x.Total != 31*time.Millisecond {
+		t.Errorf("backend exchange has wrong timing, got %v and %v of %d timestamps", x.FirstByte, x.Total, len(x.Timestamps))
+	}
+	if len(x.FetchErrors) != 0 {
+		t.Errorf("backend exchange should have no fetch errors, got %v", x.FetchErrors)
+	}
+
+	e, err = Parse(stringScanner("* << BeReq >> 5\n- BereqMethod GET\n- BereqURL /\n- BereqProtocol HTTP/1.1\n" +
+		"- FetchError backend default: fail\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if x, err = e.BackendExchange(); err != nil || x.Backend != nil || x.Response != nil || len(x.FetchErrors) != 1 {
+		t.Errorf("failed backend exchange should have no backend or response, got %v (%v)", x, err)
+	}
+
+	for _, e := range []*Entry{
+		&Entry{Kind: Request},
+		&Entry{Kind: BeReq},
+		&Entry{Kind: BeReq, Fields: Fields{"BackendOpen": []string{"x"}}},
+	} {
+		if _, err := e.BackendExchange(); err == nil {
+			t.Errorf("gathering backend exchange of %v should fail", e)
+		} else {
+			t.Logf("gathering backend exchange of %v gives: %v", e, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// ExpKill represents a parsed ExpKill record, which the expiry thread and the
+// LRU logic emit for every step of the life of an object, e.g.:
+//
+//	EXP_Expired x=32769 t=-2 h=1
+//	LRU_Cand p=0x7f3f1c4b2340 f=0x0 r=1
+//
+// Records of this kind belong to no transaction, so they are found in raw
+// grouping output or in "<< Record >>" entries.
+type ExpKill struct {
+	Event   string            // Event, e.g. "EXP_Expired" or "LRU_Cand".
+	Values  map[string]string // Values of the event, keyed by their names.
+	ObjVXID uint64            // VXID of the object from the x value, if any.
+}
+
+// ParseExpKill parses the value of an ExpKill record.
+func ParseExpKill(v string) (*ExpKill, error) {
+	fs := strings.Fields(v)
+	if len(fs) == 0 {
+		return nil, errors.Errorf("ExpKill record %q is malformed", v)
+	}
+	k := &ExpKill{Event: fs[0], Values: make(map[string]string, len(fs)-1)}
+	for _, f := range fs[1:] {
+		name, val, ok := strings.Cut(f, "=")
+		if !ok || name == "" {
+			return nil, errors.Errorf("ExpKill record %q is malformed", v)
+		}
+		k.Values[name] = val
+	}
+	if x, ok := k.Values["x"]; ok {
+		var err error
+		if k.ObjVXID, err = strconv.ParseUint(x, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse ExpKill record %q", v)
+		}
+	}
+	return k, nil
+}
+
+// ExpBan represents a parsed ExpBan record, which reports an object removed
+// by a ban, e.g. "32769 banned lookup" or "32769 banned by lurker".
+type ExpBan struct {
+	ObjVXID uint64 // VXID of the object.
+	Reason  string // Reason, e.g. "banned lookup" or "banned by lurker".
+}
+
+// ParseExpBan parses the value of an ExpBan record.
+func ParseExpBan(v string) (*ExpBan, error) {
+	x, reason := splitLine(v)
+	if reason == "" {
+		return nil, errors.Errorf("ExpBan record %q is malformed", v)
+	}
+	vxid, err := strconv.ParseUint(x, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse ExpBan record %q", v)
+	}
+	return &ExpBan{ObjVXID: vxid, Reason: strings.TrimSpace(reason)}, nil
+}
+
+// ExpKills parses and returns all ExpKill records of the log entry, in the
+// order in which they appear.
+func (e *Entry) ExpKills() ([]*ExpKill, error) {
+	fs := e.Fields["ExpKill"]
+	ks := make([]*ExpKill, 0, len(fs))
+	for _, v := range fs {
+		k, err := ParseExpKill(v)
+		if err != nil {
+			return nil, err
+		}
+		ks = append(ks, k)
+	}
+	return ks, nil
+}
+
+// ExpBans parses and returns all ExpBan records of the log entry, in the
+// order in which they appear.
+func (e *Entry) ExpBans() ([]*ExpBan, error) {
+	fs := e.Fields["ExpBan"]
+	bs := make([]*ExpBan, 0, len(fs))
+	for _, v := range fs {
+		b, err := ParseExpBan(v)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
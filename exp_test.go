@@ -0,0 +1,83 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseExpKill tests that ExpKill records are parsed correctly and that
+// malformed ones produce errors.
+func TestParseExpKill(t *testing.T) {
+	samples := map[string]*ExpKill{
+		"EXP_Expired x=32769 t=-2 h=1": &ExpKill{
+			Event:   "EXP_Expired",
+			Values:  map[string]string{"x": "32769", "t": "-2", "h": "1"},
+			ObjVXID: 32769,
+		},
+		"LRU_Cand p=0x7f3f1c4b2340 f=0x0 r=1": &ExpKill{
+			Event:  "LRU_Cand",
+			Values: map[string]string{"p": "0x7f3f1c4b2340", "f": "0x0", "r": "1"},
+		},
+		"LRU_Exhausted": &ExpKill{
+			Event:  "LRU_Exhausted",
+			Values: map[string]string{},
+		},
+	}
+	for v, want := range samples {
+		got, err := ParseExpKill(v)
+		if err != nil {
+			t.Errorf("parsing ExpKill record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing ExpKill record %q should give %v, got %v", v, want, got)
+		}
+	}
+	for _, v := range []string{"", "EXP_Expired x", "EXP_Expired =1", "EXP_Expired x=foo"} {
+		if _, err := ParseExpKill(v); err == nil {
+			t.Errorf("parsing ExpKill record %q should fail", v)
+		} else {
+			t.Logf("parsing ExpKill record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestParseExpBan tests that ExpBan records are parsed correctly and that
+// malformed ones produce errors.
+func TestParseExpBan(t *testing.T) {
+	want := &ExpBan{ObjVXID: 32769, Reason: "banned by lurker"}
+	if got, err := ParseExpBan("32769 banned by lurker"); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing ExpBan record should give %v, got %v (%v)", want, got, err)
+	}
+	for _, v := range []string{"", "32769", "foo banned lookup"} {
+		if _, err := ParseExpBan(v); err == nil {
+			t.Errorf("parsing ExpBan record %q should fail", v)
+		} else {
+			t.Logf("parsing ExpBan record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestExpRecords tests that expiry records are gathered from raw entries.
+func TestExpRecords(t *testing.T) {
+	e, err := Parse(stringScanner("* << Record >> 0\n- ExpKill EXP_Expired x=32769 t=-2\n" +
+		"- ExpBan 32770 banned lookup\n- ExpKill LRU x=32771\n"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	ks, err := e.ExpKills()
+	if err != nil || len(ks) != 2 || ks[1].Event != "LRU" || ks[1].ObjVXID != 32771 {
+		t.Errorf("gathering ExpKill records should give both in order, got %v (%v)", ks, err)
+	}
+	bs, err := e.ExpBans()
+	if err != nil || len(bs) != 1 || bs[0].ObjVXID != 32770 {
+		t.Errorf("gathering ExpBan records should give the ban, got %v (%v)", bs, err)
+	}
+	bad := &Entry{Fields: Fields{"ExpKill": []string{""}, "ExpBan": []string{"x"}}}
+	if _, err := bad.ExpKills(); err == nil {
+		t.Errorf("gathering malformed ExpKill records should fail")
+	}
+	if _, err := bad.ExpBans(); err == nil {
+		t.Errorf("gathering malformed ExpBan records should fail")
+	}
+}
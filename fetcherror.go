@@ -0,0 +1,129 @@
+package vslparser
+
+import (
+	"strings"
+)
+
+// FetchErrorClass is the class of the failure reported by a FetchError record.
+type FetchErrorClass int
+
+const (
+	// FetchErrorOther is the class of failures not known to this package.
+	FetchErrorOther FetchErrorClass = iota
+	// FetchErrorNoBackend is the class of failures to pick a healthy
+	// backend.
+	FetchErrorNoBackend
+	// FetchErrorConnectionRefused is the class of refused backend
+	// connections.
+	FetchErrorConnectionRefused
+	// FetchErrorConnectTimeout is the class of backend connections timing
+	// out.
+	FetchErrorConnectTimeout
+	// FetchErrorFirstByteTimeout is the class of backends not responding in
+	// time.
+	FetchErrorFirstByteTimeout
+	// FetchErrorBetweenBytesTimeout is the class of backends stalling in the
+	// middle of a response.
+	FetchErrorBetweenBytesTimeout
+	// FetchErrorWrite is the class of failures to send the request to the
+	// backend.
+	FetchErrorWrite
+	// FetchErrorRead is the class of failures to read the response, e.g.
+	// connections closed by the backend.
+	FetchErrorRead
+	// FetchErrorHTTPFormat is the class of malformed responses.
+	FetchErrorHTTPFormat
+	// FetchErrorInsufficientBytes is the class of response bodies shorter
+	// than their Content-Length.
+	FetchErrorInsufficientBytes
+	// FetchErrorChunked is the class of malformed chunked response bodies.
+	FetchErrorChunked
+	// FetchErrorGzip is the class of failures to process gzipped bodies.
+	FetchErrorGzip
+	// FetchErrorStorage is the class of failures to allocate storage for
+	// objects.
+	FetchErrorStorage
+)
+
+// fetchErrorNames maps the classes of fetch errors to their names.
+var fetchErrorNames = [...]string{
+	FetchErrorOther:               "other",
+	FetchErrorNoBackend:           "no backend",
+	FetchErrorConnectionRefused:   "connection refused",
+	FetchErrorConnectTimeout:      "connect timeout",
+	FetchErrorFirstByteTimeout:    "first byte timeout",
+	FetchErrorBetweenBytesTimeout: "between bytes timeout",
+	FetchErrorWrite:               "write error",
+	FetchErrorRead:                "read error",
+	FetchErrorHTTPFormat:          "http format error",
+	FetchErrorInsufficientBytes:   "insufficient bytes",
+	FetchErrorChunked:             "chunked error",
+	FetchErrorGzip:                "gzip error",
+	FetchErrorStorage:             "storage error",
+}
+
+// String returns the name of the class, e.g. "first byte timeout".
+func (c FetchErrorClass) String() string {
+	if c < 0 || int(c) >= len(fetchErrorNames) {
+		return "other"
+	}
+	return fetchErrorNames[c]
+}
+
+// fetchErrorPatterns lists the substrings of the messages of fetch errors
+// along with their classes, in the order in which they are looked for. They
+// are matched case-insensitive.
+var fetchErrorPatterns = []struct {
+	pattern string
+	class   FetchErrorClass
+}{
+	{"first byte timeout", FetchErrorFirstByteTimeout},
+	{"between bytes timeout", FetchErrorBetweenBytesTimeout},
+	{"connection refused", FetchErrorConnectionRefused},
+	{"timed out", FetchErrorConnectTimeout},
+	{"no backend", FetchErrorNoBackend},
+	{"unhealthy", FetchErrorNoBackend},
+	{"straight insufficient bytes", FetchErrorInsufficientBytes},
+	{"chunked", FetchErrorChunked},
+	{"gzip", FetchErrorGzip},
+	{"gunzip", FetchErrorGzip},
+	{"could not get storage", FetchErrorStorage},
+	{"no space", FetchErrorStorage},
+	{"http format error", FetchErrorHTTPFormat},
+	{"received junk", FetchErrorHTTPFormat},
+	{"header too long", FetchErrorHTTPFormat},
+	{"write error", FetchErrorWrite},
+	{"read error", FetchErrorRead},
+	{"eof", FetchErrorRead},
+}
+
+// ClassifyFetchError returns the class of the failure described by the given
+// message of a FetchError record, e.g. "first byte timeout" or "backend
+// default: fail errno 111 (Connection refused)".
+func ClassifyFetchError(msg string) FetchErrorClass {
+	msg = strings.ToLower(msg)
+	for _, p := range fetchErrorPatterns {
+		if strings.Contains(msg, p.pattern) {
+			return p.class
+		}
+	}
+	return FetchErrorOther
+}
+
+// FetchError represents a classified FetchError record.
+type FetchError struct {
+	Class   FetchErrorClass // Class of the failure.
+	Message string          // Message of the record.
+}
+
+// FetchErrors returns the classified FetchError records of the log entry, in
+// the order in which they appear. The first one is usually the cause of the
+// following ones.
+func (e *Entry) FetchErrors() []*FetchError {
+	fs := e.Fields["FetchError"]
+	errs := make([]*FetchError, 0, len(fs))
+	for _, v := range fs {
+		errs = append(errs, &FetchError{Class: ClassifyFetchError(v), Message: v})
+	}
+	return errs
+}
@@ -0,0 +1,54 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestClassifyFetchError tests that common messages of fetch errors of
+// various versions of Varnish are classified correctly.
+func TestClassifyFetchError(t *testing.T) {
+	samples := map[string]FetchErrorClass{
+		"first byte timeout":                                     FetchErrorFirstByteTimeout,
+		"no backend connection":                                  FetchErrorNoBackend,
+		"backend default: unhealthy":                             FetchErrorNoBackend,
+		"backend default: fail errno 111 (Connection refused)":   FetchErrorConnectionRefused,
+		"backend default: fail errno 110 (Connection timed out)": FetchErrorConnectTimeout,
+		"http first read error: EOF":                             FetchErrorRead,
+		"http read error: overflow":                              FetchErrorRead,
+		"backend write error: 32 (Broken pipe)":                  FetchErrorWrite,
+		"http format error":                                      FetchErrorHTTPFormat,
+		"Received junk":                                          FetchErrorHTTPFormat,
+		"straight insufficient bytes":                            FetchErrorInsufficientBytes,
+		"chunked header non-hex":                                 FetchErrorChunked,
+		"Invalid Gzip data: incorrect header check":              FetchErrorGzip,
+		"Could not get storage":                                  FetchErrorStorage,
+		"Pass delivery abandoned":                                FetchErrorOther,
+	}
+	for msg, want := range samples {
+		if got := ClassifyFetchError(msg); got != want {
+			t.Errorf("classifying %q should give %v, got %v", msg, want, got)
+		}
+	}
+	if s := FetchErrorFirstByteTimeout.String(); s != "first byte timeout" {
+		t.Errorf("FetchErrorFirstByteTimeout should be named \"first byte timeout\", got %q", s)
+	}
+}
+
+// TestFetchErrors tests that all FetchError records are returned in order.
+func TestFetchErrors(t *testing.T) {
+	e := &Entry{Fields: Fields{"FetchError": []string{
+		"http first read error: EOF",
+		"Could not get storage",
+	}}}
+	want := []*FetchError{
+		&FetchError{Class: FetchErrorRead, Message: "http first read error: EOF"},
+		&FetchError{Class: FetchErrorStorage, Message: "Could not get storage"},
+	}
+	if got := e.FetchErrors(); !reflect.DeepEqual(want, got) {
+		t.Errorf("classifying fetch errors should give %v, got %v", want, got)
+	}
+	if got := example().FetchErrors(); len(got) != 0 {
+		t.Errorf("entry without FetchError records should have no fetch errors, got %v", got)
+	}
+}
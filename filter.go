@@ -0,0 +1,103 @@
+package vslparser
+
+import (
+	"slices"
+)
+
+// Filter selects log entries, e.g. the entries to be passed to a handler of
+// a Dispatcher.
+type Filter interface {
+	// Match returns whether the entry e is selected.
+	Match(e *Entry) bool
+}
+
+// FilterFunc adapts an ordinary function to a Filter.
+type FilterFunc func(e *Entry) bool
+
+// Match calls f(e).
+func (f FilterFunc) Match(e *Entry) bool {
+	return f(e)
+}
+
+// HasStatusClass returns a filter selecting the entries whose responses, see
+// Entry.Status, have a status code of one of the given classes, e.g. 4 and 5
+// for client and server errors. Entries without a status are not selected.
+func HasStatusClass(classes ...int) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		s, err := e.Status()
+		return err == nil && slices.Contains(classes, s/100)
+	})
+}
+
+// HasStatus returns a filter selecting the entries whose responses, see
+// Entry.Status, have one of the given status codes.
+func HasStatus(codes ...int) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		s, err := e.Status()
+		return err == nil && slices.Contains(codes, s)
+	})
+}
+
+// HasOutcome returns a filter selecting the entries whose cache lookups had
+// one of the given outcomes, see Entry.CacheOutcome, e.g. OutcomeMiss,
+// OutcomePass and OutcomeHitPass.
+func HasOutcome(outcomes ...Outcome) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		c, err := e.CacheOutcome()
+		return err == nil && slices.Contains(outcomes, c.Outcome)
+	})
+}
+
+// FetchFailed returns a filter selecting the entries holding a failed backend
+// request, i.e. backend requests which failed themselves and client requests
+// with such backend requests nested in them, see Grouping. A backend request
+// failed if it logged fetch errors or received no response or a server
+// error, like an attempt of a RetryChain, but backend requests which were
+// retried are not taken into account, so that fetches which succeeded after
+// a retry are not selected.
+func FetchFailed() Filter {
+	return FilterFunc(func(e *Entry) bool {
+		return !e.Walk(func(t *Entry) bool {
+			if t.Kind != BeReq || t.retried() {
+				return true
+			}
+			a, err := newFetchAttempt(t)
+			return err != nil || !a.Failed()
+		})
+	})
+}
+
+// And returns a filter selecting the entries matching all the given filters,
+// which are tried in order until one does not match. And of no filters
+// selects all entries.
+func And(filters ...Filter) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		for _, f := range filters {
+			if !f.Match(e) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or returns a filter selecting the entries matching any of the given
+// filters, which are tried in order until one matches. Or of no filters
+// selects no entries.
+func Or(filters ...Filter) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		for _, f := range filters {
+			if f.Match(e) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not returns a filter selecting the entries not matching f.
+func Not(f Filter) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		return !f.Match(e)
+	})
+}
@@ -0,0 +1,122 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestStatusFilters tests that entries are selected by the classes and codes
+// of their status and by the outcomes of their cache lookups.
+func TestStatusFilters(t *testing.T) {
+	ok := NewEntry(Request, 1).Add("Hit", "32769").Add("RespStatus", "200")
+	notFound := NewEntry(Request, 2).Add("RespStatus", "404").Add("VCL_call", "MISS")
+	failed := NewEntry(BeReq, 3).Add("BerespStatus", "503")
+	pass := NewEntry(Request, 4).Add("HitPass", "32771").Add("RespStatus", "200")
+	samples := []struct {
+		Name   string
+		Filter Filter
+		Want   []bool
+	}{
+		{"4xx/5xx", HasStatusClass(4, 5), []bool{false, true, true, false}},
+		{"2xx", HasStatusClass(2), []bool{true, false, false, true}},
+		{"200/503", HasStatus(200, 503), []bool{true, false, true, true}},
+		{"miss/hitpass", HasOutcome(OutcomeMiss, OutcomeHitPass), []bool{false, true, false, true}},
+		{"hit", HasOutcome(OutcomeHit), []bool{true, false, false, false}},
+	}
+	for _, s := range samples {
+		for i, e := range []*Entry{ok, notFound, failed, pass} {
+			if got := s.Filter.Match(e); got != s.Want[i] {
+				t.Errorf("filter %s should give %v for entry %d, got %v", s.Name, s.Want[i], e.VXID, got)
+			}
+		}
+	}
+	if HasStatusClass(2).Match(NewEntry(Request, 5)) || HasStatus(200).Match(NewEntry(Request, 5).Add("RespStatus", "OK")) {
+		t.Errorf("entries without valid status should not be selected")
+	}
+}
+
+// TestFetchFailed tests that entries holding failed backend requests are
+// selected, unless the failed backend requests were retried.
+func TestFetchFailed(t *testing.T) {
+	s := `
+*   << BeReq    >> 4
+-   Begin          bereq 3 retry
+-   BerespStatus   200
+-   End
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   BerespStatus   503
+-   Link           bereq 4 retry
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   End
+*   << BeReq    >> 6
+-   Begin          bereq 5 fetch
+-   FetchError     first byte timeout
+-   End
+*   << Request  >> 5
+-   Begin          req 1 rxreq
+-   Link           bereq 6 fetch
+-   End
+*   << Request  >> 7
+-   Begin          req 1 rxreq
+-   End
+`
+	got, _ := buildTrees(t, GroupRequest, s)
+	if len(got) != 3 {
+		t.Fatalf("building request trees should give 3 trees, got %v", got)
+	}
+	f := FetchFailed()
+	if f.Match(got[0]) || f.Match(got[0].Children[0]) {
+		t.Errorf("request whose fetch succeeded after a retry should not be selected")
+	}
+	if !f.Match(got[1]) || !f.Match(got[1].Children[0]) {
+		t.Errorf("request and backend request whose fetch failed should be selected")
+	}
+	if f.Match(got[2]) {
+		t.Errorf("request without fetch should not be selected")
+	}
+	if !f.Match(NewEntry(BeReq, 8)) {
+		t.Errorf("backend request without response should be selected")
+	}
+}
+
+// TestCombinators tests that filters are combined by And, Or and Not, and that
+// combined filters stop at the first filter deciding the result.
+func TestCombinators(t *testing.T) {
+	var calls []string
+	// f returns a filter of the given name selecting the entries of kind k
+	// and recording its calls.
+	f := func(name string, k Kind) Filter {
+		return FilterFunc(func(e *Entry) bool {
+			calls = append(calls, name)
+			return e.Kind == k
+		})
+	}
+	req, bereq := NewEntry(Request, 1), NewEntry(BeReq, 2)
+	samples := []struct {
+		Name   string
+		Filter Filter
+		Entry  *Entry
+		Want   bool
+		Calls  []string
+	}{
+		{"and", And(f("a", Request), f("b", Request)), req, true, []string{"a", "b"}},
+		{"and", And(f("a", BeReq), f("b", Request)), req, false, []string{"a"}},
+		{"empty and", And(), req, true, nil},
+		{"or", Or(f("a", BeReq), f("b", Request)), req, true, []string{"a", "b"}},
+		{"or", Or(f("a", Request), f("b", Request)), req, true, []string{"a"}},
+		{"or", Or(f("a", Session), f("b", Session)), bereq, false, []string{"a", "b"}},
+		{"empty or", Or(), req, false, nil},
+		{"not", Not(f("a", Request)), bereq, true, []string{"a"}},
+		{"nested", And(Or(f("a", BeReq), f("b", Request)), Not(f("c", BeReq))), bereq, false, []string{"a", "c"}},
+	}
+	for _, s := range samples {
+		calls = nil
+		if got := s.Filter.Match(s.Entry); got != s.Want || !reflect.DeepEqual(s.Calls, calls) {
+			t.Errorf("filter %s should give %v calling %v, got %v calling %v", s.Name, s.Want, s.Calls, got, calls)
+		}
+	}
+}
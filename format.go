@@ -0,0 +1,63 @@
+package vslparser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTo writes the entry and the transactions nested in it in the format
+// of varnishlog, so that e.g. filtered or redacted entries can be fed into
+// tools which expect its output.
+//
+// Transactions are written with the markers of their nesting levels, parents
+// before their children, like varnishlog prints groups, and followed by an
+// empty line. Records are written in the order in which they were logged;
+// entries without Records have their Fields written ordered by tag. Records
+// of verbose output are written in the default format, so parsing the
+// output with the grouping of the entry gives the same entry.
+//
+// Entries of records which belong to no transaction are written without an
+// End record, like varnishlog does. Truncated entries are written without
+// an End record and without the empty line, so that they are parsed again
+// as truncated if they are last in the input, see Options.AllowTruncated.
+func (e *Entry) WriteTo(w io.Writer) (int64, error) {
+	b := e.appendText(nil)
+	if !e.Truncated {
+		b = append(b, '\n')
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// Format returns the given entries as written by WriteTo, e.g. the trees of a
+// TreeBuilder.
+func Format(entries ...*Entry) string {
+	var s strings.Builder
+	for _, e := range entries {
+		e.WriteTo(&s)
+	}
+	return s.String()
+}
+
+// appendText appends the lines of the entry and the transactions nested in it
+// to b.
+func (e *Entry) appendText(b []byte) []byte {
+	level := max(e.Level, 1)
+	header := strings.Repeat("*", level)
+	if level > 3 {
+		header = fmt.Sprintf("*%d*", level)
+	}
+	b = fmt.Appendf(b, "%-3s << %-8s >> %d\n", header, e.kindName(), e.VXID)
+	prefix := recordPrefix(level)
+	for _, r := range e.records() {
+		b = fmt.Appendf(b, "%-3s %-14s %s\n", prefix, r.Tag, r.Value)
+	}
+	if e.Kind != Raw && !e.Truncated {
+		b = fmt.Appendf(b, "%-3s %-14s \n", prefix, "End")
+	}
+	for _, c := range e.Children {
+		b = c.appendText(b)
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+package vslparser
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWriteTo tests that entries are written in the format of varnishlog.
+func TestWriteTo(t *testing.T) {
+	e := NewEntry(Request, 2).
+		Add("Begin", "req 1 rxreq").
+		Add("ReqHeader", "Host: example.com").
+		AddChild(NewEntry(BeReq, 3).Add("Begin", "bereq 2 fetch"))
+	// varnishlog pads the empty value of End records, too.
+	want := "*   << Request  >> 2\n" +
+		"-   Begin          req 1 rxreq\n" +
+		"-   ReqHeader      Host: example.com\n" +
+		"-   End            \n" +
+		"**  << BeReq    >> 3\n" +
+		"--  Begin          bereq 2 fetch\n" +
+		"--  End            \n" +
+		"\n"
+	var b bytes.Buffer
+	n, err := e.WriteTo(&b)
+	if err != nil || n != int64(len(want)) || b.String() != want {
+		t.Errorf("writing entry should give %q, got %q (%d, %v)", want, b.String(), n, err)
+	}
+	if got := Format(e, NewEntry(Session, 1)); got != want+"*   << Session  >> 1\n-   End            \n\n" {
+		t.Errorf("formatting entries should give them one by one, got %q", got)
+	}
+
+	samples := map[string]*Entry{
+		"*   << Request  >> 1\n-   Begin          req 1 rxreq\n-   ReqURL         /\n-   End            \n\n": &Entry{
+			Kind:   Request,
+			VXID:   1,
+			Fields: Fields{"ReqURL": []string{"/"}, "Begin": []string{"req 1 rxreq"}},
+		},
+		"*   << Record   >> 0\n-   CLI            Rd ping\n\n":                NewEntry(Raw, 0).Add("CLI", "Rd ping"),
+		"*   << Future   >> 1\n-   End            \n\n":                       &Entry{RawKind: "Future", VXID: 1},
+		"*   << Request  >> 1\n-   ReqURL         /\n":                        &Entry{Kind: Request, VXID: 1, Records: Records{{"ReqURL", "/"}}, Truncated: true},
+		"*4* << BeReq    >> 1\n-4- BereqURL       /\n-4- End            \n\n": &Entry{Kind: BeReq, VXID: 1, Level: 4, Records: Records{{"BereqURL", "/"}}},
+	}
+	for want, e := range samples {
+		if got := Format(e); got != want {
+			t.Errorf("formatting %v should give %q, got %q", e, want, got)
+		}
+	}
+}
+
+// TestWriteToRoundTrip tests that parsing written entries gives the same
+// entries.
+func TestWriteToRoundTrip(t *testing.T) {
+	samples := []struct {
+		Name     string
+		Grouping Grouping
+		Input    string
+	}{
+		{"session", GroupSession, session},
+		{"vxid", GroupVXID, `
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   BereqHeader    Host: example.com
+-   Timestamp      Start: 1545037998.759333 0.000000 0.000000
+-   BerespStatus   503
+-   End
+
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   ReqHeader      Cookie:
+-   Link           bereq 3 fetch
+-   End
+
+*   << Record   >> 0
+-   CLI            Rd ping
+
+*   << Future   >> 4
+-   Begin          future 1
+-   End
+`},
+		{"verbose", GroupVXID, `
+*   << Request  >> 32770
+-      32770 Begin          c req 32769 rxreq
+-      32770 ReqURL         c /health
+-      32770 End            c
+`},
+		{"truncated", GroupVXID, `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   End
+
+*   << Request  >> 5
+-   Begin          req 1 rxreq
+-   ReqURL         /
+`},
+	}
+	for _, s := range samples {
+		opts := Options{Grouping: s.Grouping, AllowTruncated: true}
+		want, err := parseAllWithOptions(s.Input, opts)
+		if err != nil {
+			t.Fatalf("failed to parse %s sample: %v", s.Name, err)
+		}
+		text := Format(want...)
+		got, err := parseAllWithOptions(text, opts)
+		if err != nil {
+			t.Errorf("parsing written %s sample should not fail, got: %v\n%s", s.Name, err, text)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing written %s sample should give %v, got %v\n%s", s.Name, want, got, text)
+		}
+		if again := Format(got...); again != text {
+			t.Errorf("writing parsed %s sample should give %q, got %q", s.Name, text, again)
+		}
+	}
+}
+
+// parseAllWithOptions returns all entries parsed from s with the given
+// options.
+func parseAllWithOptions(s string, opts Options) ([]*Entry, error) {
+	p := NewParserWithOptions(strings.NewReader(s), opts)
+	var entries []*Entry
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,66 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Gzip represents a parsed Gzip record, which summarizes a gzip operation on
+// an object body, e.g.:
+//
+//	U F E 182 159 80 80 1392
+type Gzip struct {
+	Operation byte  // 'G' for gzip, 'U' for gunzip, 'u' for gunzip test.
+	Phase     byte  // 'F' for fetch, 'D' for delivery.
+	ESI       bool  // Whether the body is processed by ESI.
+	BytesIn   int64 // Number of bytes input.
+	BytesOut  int64 // Number of bytes output.
+	FirstBit  int64 // Bit offset of the first deflate block.
+	LastBit   int64 // Bit offset of the 'last' bit.
+	StopBit   int64 // Bit offset of the end of the deflate data.
+}
+
+// parseGzip parses the value of a Gzip record.
+func parseGzip(v string) (*Gzip, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 8 || len(fs[0]) != 1 || len(fs[1]) != 1 ||
+		(fs[2] != "E" && fs[2] != "-") {
+		return nil, errors.Errorf("Gzip record %q is malformed", v)
+	}
+	is, err := parseInts("Gzip", strings.Join(fs[3:], " "), 5)
+	if err != nil {
+		return nil, err
+	}
+	return &Gzip{
+		Operation: fs[0][0],
+		Phase:     fs[1][0],
+		ESI:       fs[2] == "E",
+		BytesIn:   is[0],
+		BytesOut:  is[1],
+		FirstBit:  is[2],
+		LastBit:   is[3],
+		StopBit:   is[4],
+	}, nil
+}
+
+// Gzip parses and returns the Gzip record of the log entry. Gzip records
+// reporting errors, e.g. "Gunzip error: -3 (invalid block type)", cannot be
+// parsed.
+func (e *Entry) Gzip() (*Gzip, error) {
+	fs, err := e.Field("Gzip")
+	if err != nil {
+		return nil, err
+	}
+	return parseGzip(fs[0])
+}
+
+// Filters returns the body filters listed by the Filters record of the log
+// entry, in the order in which they are applied, e.g. "gunzip", "esi" and
+// "gzip". A Filters record without filters gives an empty list.
+func (e *Entry) Filters() ([]string, error) {
+	fs, err := e.Field("Filters")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(fs[0]), nil
+}
@@ -0,0 +1,89 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestGzip tests that Gzip records are parsed correctly and that malformed
+// ones produce errors.
+func TestGzip(t *testing.T) {
+	samples := map[string]*Gzip{
+		"U F E 182 159 80 80 1392": &Gzip{
+			Operation: 'U',
+			Phase:     'F',
+			ESI:       true,
+			BytesIn:   182,
+			BytesOut:  159,
+			FirstBit:  80,
+			LastBit:   80,
+			StopBit:   1392,
+		},
+		"G F - 3000 1024 80 8136 8146": &Gzip{
+			Operation: 'G',
+			Phase:     'F',
+			BytesIn:   3000,
+			BytesOut:  1024,
+			FirstBit:  80,
+			LastBit:   8136,
+			StopBit:   8146,
+		},
+	}
+	for v, want := range samples {
+		got, err := parseGzip(v)
+		if err != nil {
+			t.Errorf("parsing Gzip record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing Gzip record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"Gunzip error: -3 (invalid block type)",
+		"U F E 182 159 80 80",
+		"U F X 182 159 80 80 1392",
+		"UU F E 182 159 80 80 1392",
+		"U F E 182 foo 80 80 1392",
+	}
+	for _, v := range bad {
+		if _, err := parseGzip(v); err == nil {
+			t.Errorf("parsing Gzip record %q should fail", v)
+		} else {
+			t.Logf("parsing Gzip record %q gives: %v", v, err)
+		}
+	}
+
+	e := &Entry{Fields: Fields{"Gzip": []string{"u F - 182 159 80 80 1392"}}}
+	if g, err := e.Gzip(); err != nil || g.Operation != 'u' {
+		t.Errorf("parsing Gzip record of entry should give a gunzip test, got %v (%v)", g, err)
+	}
+	if _, err := example().Gzip(); err == nil {
+		t.Errorf("parsing missing Gzip record should fail")
+	}
+}
+
+// TestFilters tests that the body filters are listed in order.
+func TestFilters(t *testing.T) {
+	samples := map[string][]string{
+		"gunzip esi gzip": []string{"gunzip", "esi", "gzip"},
+		" testgunzip":     []string{"testgunzip"},
+		"":                []string{},
+	}
+	for v, want := range samples {
+		e := &Entry{Fields: Fields{"Filters": []string{v}}}
+		got, err := e.Filters()
+		if err != nil {
+			t.Errorf("parsing Filters record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing Filters record %q should give %q, got %q", v, want, got)
+		}
+	}
+	if _, err := example().Filters(); err == nil {
+		t.Errorf("parsing missing Filters record should fail")
+	}
+}
@@ -0,0 +1,146 @@
+package vslparser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// h2FrameTypes maps the types of HTTP/2 frames to their names, see RFC 9113.
+var h2FrameTypes = [...]string{
+	"DATA",
+	"HEADERS",
+	"PRIORITY",
+	"RST_STREAM",
+	"SETTINGS",
+	"PUSH_PROMISE",
+	"PING",
+	"GOAWAY",
+	"WINDOW_UPDATE",
+	"CONTINUATION",
+}
+
+// H2Frame represents an HTTP/2 frame logged by Varnish in H2RxHdr and
+// H2TxHdr records, which hold the 9-byte frame header, and the H2RxBody and
+// H2TxBody records which follow them, which hold the payload. Varnish logs
+// these binary records as hexadecimal dumps, e.g.:
+//
+//	H2RxHdr        [000004080000000000]
+//	H2RxBody       [0000ffff]
+type H2Frame struct {
+	Rx      bool   // Whether the frame was received, as opposed to transmitted.
+	Length  int    // Length of the payload, as found in the frame header.
+	Type    byte   // Type of the frame, e.g. 0x1 for HEADERS.
+	Flags   byte   // Flags of the frame.
+	Stream  uint32 // Identifier of the stream, zero for the connection.
+	Payload []byte // Payload as logged, possibly truncated, nil if not logged.
+}
+
+// TypeName returns the name of the type of the frame, e.g. "HEADERS".
+func (f *H2Frame) TypeName() string {
+	if int(f.Type) < len(h2FrameTypes) {
+		return h2FrameTypes[f.Type]
+	}
+	return "UNKNOWN_" + strconv.Itoa(int(f.Type))
+}
+
+// parseH2Dump decodes the hexadecimal dump of a binary record, which may be
+// enclosed in brackets.
+func parseH2Dump(tag, v string) ([]byte, error) {
+	v = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(v), "["), "]")
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s record %q", tag, v)
+	}
+	return b, nil
+}
+
+// h2Frames parses the frames of one direction from the given frame header and
+// payload records.
+func h2Frames(rx bool, hdrTag string, hdrs []string, bodyTag string, bodies []string) ([]*H2Frame, error) {
+	frames := make([]*H2Frame, 0, len(hdrs))
+	for _, v := range hdrs {
+		b, err := parseH2Dump(hdrTag, v)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 9 {
+			return nil, errors.Errorf("%s record %q is malformed", hdrTag, v)
+		}
+		f := &H2Frame{
+			Rx:     rx,
+			Length: int(b[0])<<16 | int(b[1])<<8 | int(b[2]),
+			Type:   b[3],
+			Flags:  b[4],
+			Stream: binary.BigEndian.Uint32(b[5:]) &^ (1 << 31),
+		}
+		// Payloads are only logged for frames which have one.
+		if f.Length > 0 && len(bodies) > 0 {
+			if f.Payload, err = parseH2Dump(bodyTag, bodies[0]); err != nil {
+				return nil, err
+			}
+			bodies = bodies[1:]
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// orderedH2Frames parses the frames of both directions from the ordered
+// records of the entry.
+func (e *Entry) orderedH2Frames() ([]*H2Frame, error) {
+	var frames []*H2Frame
+	var last [2]*H2Frame // Last frame received and transmitted.
+	for _, r := range e.Records {
+		var err error
+		switch r.Tag {
+		case "H2RxHdr", "H2TxHdr":
+			rx := r.Tag == "H2RxHdr"
+			fs, err := h2Frames(rx, r.Tag, []string{r.Value}, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, fs[0])
+			last[h2Dir(rx)] = fs[0]
+		case "H2RxBody", "H2TxBody":
+			f := last[h2Dir(r.Tag == "H2RxBody")]
+			if f == nil || f.Length == 0 || f.Payload != nil {
+				continue
+			}
+			if f.Payload, err = parseH2Dump(r.Tag, r.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return frames, nil
+}
+
+// h2Dir returns the index of the direction of frames in orderedH2Frames.
+func h2Dir(rx bool) int {
+	if rx {
+		return 0
+	}
+	return 1
+}
+
+// H2Frames parses and returns the HTTP/2 frames logged for the log entry, in
+// the order in which they were logged, with each payload record attached to
+// the frame header record of the same direction preceding it. For entries
+// without Records, received frames come first, followed by the transmitted
+// ones.
+func (e *Entry) H2Frames() ([]*H2Frame, error) {
+	if len(e.Records) > 0 {
+		return e.orderedH2Frames()
+	}
+	rx, err := h2Frames(true, "H2RxHdr", e.Fields["H2RxHdr"], "H2RxBody", e.Fields["H2RxBody"])
+	if err != nil {
+		return nil, err
+	}
+	tx, err := h2Frames(false, "H2TxHdr", e.Fields["H2TxHdr"], "H2TxBody", e.Fields["H2TxBody"])
+	if err != nil {
+		return nil, err
+	}
+	return append(rx, tx...), nil
+}
@@ -0,0 +1,86 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestH2Frames tests that HTTP/2 frame headers are decoded and paired with
+// their payloads, and that malformed records produce errors.
+func TestH2Frames(t *testing.T) {
+	e, err := Parse(stringScanner("* << Session >> 1\n" +
+		"- H2RxHdr [000006040000000000]\n" +
+		"- H2RxBody [000300000064]\n" +
+		"- H2RxHdr [000000040100000000]\n" +
+		"- H2RxHdr [00000d010500000001]\n" +
+		"- H2RxBody [82868441896251f7310f52e621ff]\n" +
+		"- H2TxHdr 000004080000000001\n" +
+		"- H2TxBody 0000ffff\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := []*H2Frame{
+		&H2Frame{Rx: true, Length: 6, Type: 0x4, Payload: []byte{0, 3, 0, 0, 0, 0x64}},
+		&H2Frame{Rx: true, Type: 0x4, Flags: 0x1},
+		&H2Frame{Rx: true, Length: 13, Type: 0x1, Flags: 0x5, Stream: 1,
+			Payload: []byte{0x82, 0x86, 0x84, 0x41, 0x89, 0x62, 0x51, 0xf7, 0x31, 0x0f, 0x52, 0xe6, 0x21, 0xff}},
+		&H2Frame{Length: 4, Type: 0x8, Stream: 1, Payload: []byte{0, 0, 0xff, 0xff}},
+	}
+	got, err := e.H2Frames()
+	if err != nil {
+		t.Fatalf("parsing HTTP/2 frames should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing HTTP/2 frames should give %v, got %v", want, got)
+	}
+	names := []string{"SETTINGS", "SETTINGS", "HEADERS", "WINDOW_UPDATE"}
+	for i, f := range got {
+		if f.TypeName() != names[i] {
+			t.Errorf("frame %d should be %s, got %s", i, names[i], f.TypeName())
+		}
+	}
+	if n := (&H2Frame{Type: 0xfa}).TypeName(); n != "UNKNOWN_250" {
+		t.Errorf("unknown frame type should be named UNKNOWN_250, got %s", n)
+	}
+
+	// Frames of both directions are interleaved in the order of records.
+	e, err = Parse(stringScanner("* << Request >> 2\n" +
+		"- H2RxHdr [000004080000000001]\n" +
+		"- H2TxHdr [000000040100000000]\n" +
+		"- H2RxBody [0000ffff]\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want = []*H2Frame{
+		&H2Frame{Rx: true, Length: 4, Type: 0x8, Stream: 1, Payload: []byte{0, 0, 0xff, 0xff}},
+		&H2Frame{Type: 0x4, Flags: 0x1},
+	}
+	if got, err := e.H2Frames(); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing interleaved HTTP/2 frames should give %v, got %v (%v)", want, got, err)
+	}
+	for _, rs := range []Records{
+		{{"H2TxHdr", "[00000604000000000g]"}},
+		{{"H2RxHdr", "[000006040000000000]"}, {"H2RxBody", "[0003000]"}},
+	} {
+		if _, err := (&Entry{Records: rs}).H2Frames(); err == nil {
+			t.Errorf("parsing HTTP/2 frames from %v should fail", rs)
+		} else {
+			t.Logf("parsing HTTP/2 frames from %v gives: %v", rs, err)
+		}
+	}
+
+	bad := []Fields{
+		Fields{"H2RxHdr": []string{"[0000060400000000]"}},
+		Fields{"H2TxHdr": []string{"[00000604000000000g]"}},
+		Fields{"H2RxHdr": []string{"[000006040000000000]"}, "H2RxBody": []string{"[0003000]"}},
+	}
+	for _, fs := range bad {
+		if _, err := (&Entry{Fields: fs}).H2Frames(); err == nil {
+			t.Errorf("parsing HTTP/2 frames from %v should fail", fs)
+		} else {
+			t.Logf("parsing HTTP/2 frames from %v gives: %v", fs, err)
+		}
+	}
+}
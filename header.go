@@ -0,0 +1,170 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// isHeaderTag returns whether records of the given tag log headers, i.e.
+// whether it ends with "Header" or "Unset", such as ReqHeader or BerespUnset.
+func isHeaderTag(tag string) bool {
+	return strings.HasSuffix(tag, "Header") || strings.HasSuffix(tag, "Unset")
+}
+
+// canonicalHeader returns the header record v with the name of the header in
+// the canonical form, see textproto.CanonicalMIMEHeaderKey.
+func canonicalHeader(v string) string {
+	name, _, ok := strings.Cut(v, ":")
+	if !ok {
+		return v
+	}
+	return textproto.CanonicalMIMEHeaderKey(name) + v[len(name):]
+}
+
+// CanonicalizeHeaders converts the names of headers in all header records of
+// the entry and the transactions nested in it into the canonical form, e.g.
+// "content-length: 5" into "Content-Length: 5", so that records of headers
+// set in VCL with non-canonical names can be compared with the others. Names
+// which are not valid header names are left unchanged.
+func CanonicalizeHeaders(e *Entry) {
+	e.Walk(func(e *Entry) bool {
+		e.Edit(func(tag, v string) (string, bool) {
+			if isHeaderTag(tag) {
+				v = canonicalHeader(v)
+			}
+			return v, true
+		})
+		return true
+	})
+}
+
+// HeaderReplay holds the headers of a request or response as they were
+// before VCL processing and as they were after it.
+type HeaderReplay struct {
+	Received http.Header // Headers logged before VCL processing started.
+	Final    http.Header // Headers after replaying all set and unset records.
+}
+
+// ReplayHeaders replays the header records of the given family, i.e. "Req",
+// "Resp", "Bereq", "Beresp" or "Obj", in the order in which they were logged.
+// Records of the "<family>Header" tag add a header and records of the
+// "<family>Unset" tag remove a header with the same name and value. The
+// received headers are those logged before the first VCL_call record which
+// follows the first header record of the family, e.g.
+//
+//	ReqHeader      Cookie: foo=bar
+//	VCL_call       RECV
+//	ReqUnset       Cookie: foo=bar
+//	ReqHeader      Cookie: foo=baz
+//
+// gives "Cookie: foo=bar" as the received and "Cookie: foo=baz" as the final
+// header.
+func (e *Entry) ReplayHeaders(family string) (*HeaderReplay, error) {
+	set, unset := family+"Header", family+"Unset"
+	r := &HeaderReplay{Final: http.Header{}}
+	seen := false
+	for _, rec := range e.Records {
+		if rec.Tag == "VCL_call" && seen && r.Received == nil {
+			r.Received = r.Final.Clone()
+			continue
+		}
+		if rec.Tag != set && rec.Tag != unset {
+			continue
+		}
+		seen = true
+		name, val, err := splitHeader(rec.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse field %q as HTTP headers", rec.Tag)
+		}
+		if rec.Tag == set {
+			r.Final.Add(name, val)
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		vs := r.Final[name]
+		for i, v := range vs {
+			if v == val {
+				vs = append(vs[:i], vs[i+1:]...)
+				break
+			}
+		}
+		if len(vs) == 0 {
+			delete(r.Final, name)
+		} else {
+			r.Final[name] = vs
+		}
+	}
+	if r.Received == nil {
+		r.Received = r.Final.Clone()
+	}
+	return r, nil
+}
+
+// HeaderFilter is a Filter selecting entries by the values of a header of the
+// given family, see ReplayHeaders, so that headers unset in VCL are not
+// matched, e.g.
+//
+//	vslparser.HeaderMatches("Req", "Host", regexp.MustCompile(`^(www\.)?example\.com$`))
+//
+// Entries without the header are not selected.
+type HeaderFilter struct {
+	Family string         // Family of the header, e.g. "Req" or "Beresp".
+	Name   string         // Name of the header, case-insensitive.
+	Re     *regexp.Regexp // Regular expression matched against the values, nil for any value.
+	// Received makes the filter match the headers as received, i.e. before
+	// VCL processing, instead of the final ones.
+	Received bool
+}
+
+// HasHeader returns a filter selecting the entries with the header of the
+// given family and name.
+func HasHeader(family, name string) *HeaderFilter {
+	return &HeaderFilter{Family: family, Name: name}
+}
+
+// HeaderMatches returns a filter selecting the entries with a value of the
+// header of the given family and name which matches re.
+func HeaderMatches(family, name string, re *regexp.Regexp) *HeaderFilter {
+	return &HeaderFilter{Family: family, Name: name, Re: re}
+}
+
+// Values returns the values of the header of the entry e. Entries with
+// malformed header records of the family have none.
+func (f *HeaderFilter) Values(e *Entry) []string {
+	r, err := e.ReplayHeaders(f.Family)
+	if err != nil {
+		return nil
+	}
+	if f.Received {
+		return r.Received.Values(f.Name)
+	}
+	return r.Final.Values(f.Name)
+}
+
+// Match returns whether the entry e has the header with a matching value.
+func (f *HeaderFilter) Match(e *Entry) bool {
+	for _, v := range f.Values(e) {
+		if f.Re == nil || f.Re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderKey returns a function returning the final value of the header of the
+// given family and name of entries, e.g. to sample or limit entries by their
+// tenants, see SampleByKey and RateLimiter. The key of entries with several
+// values of the header is the first one, and it is empty for entries without
+// the header.
+func HeaderKey(family, name string) func(e *Entry) string {
+	f := HasHeader(family, name)
+	return func(e *Entry) string {
+		if vs := f.Values(e); len(vs) > 0 {
+			return vs[0]
+		}
+		return ""
+	}
+}
@@ -0,0 +1,136 @@
+package vslparser
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// TestReplayHeaders tests that set and unset records are replayed in order
+// and that the headers logged before VCL processing are kept.
+func TestReplayHeaders(t *testing.T) {
+	e, err := Parse(stringScanner("* << Request >> 2\n" +
+		"- ReqHeader Host: example.com\n" +
+		"- ReqHeader Cookie: foo=bar\n" +
+		"- ReqHeader Accept: text/html\n" +
+		"- ReqHeader Accept: */*\n" +
+		"- VCL_call RECV\n" +
+		"- ReqUnset Cookie: foo=bar\n" +
+		"- ReqHeader Cookie: foo=baz\n" +
+		"- ReqUnset Accept: text/html\n" +
+		"- ReqHeader X-Forwarded-For: 10.0.0.1\n" +
+		"- RespHeader Server: Varnish\n" +
+		"- VCL_call DELIVER\n" +
+		"- RespUnset Server: Varnish\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := &HeaderReplay{
+		Received: http.Header{
+			"Host":   []string{"example.com"},
+			"Cookie": []string{"foo=bar"},
+			"Accept": []string{"text/html", "*/*"},
+		},
+		Final: http.Header{
+			"Host":            []string{"example.com"},
+			"Cookie":          []string{"foo=baz"},
+			"Accept":          []string{"*/*"},
+			"X-Forwarded-For": []string{"10.0.0.1"},
+		},
+	}
+	if got, err := e.ReplayHeaders("Req"); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("replaying request headers should give %v, got %v (%v)", want, got, err)
+	}
+	want = &HeaderReplay{
+		Received: http.Header{"Server": []string{"Varnish"}},
+		Final:    http.Header{},
+	}
+	if got, err := e.ReplayHeaders("Resp"); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("replaying response headers should give %v, got %v (%v)", want, got, err)
+	}
+
+	e = &Entry{Records: Records{{"BerespUnset", "NoColon"}}}
+	if _, err := e.ReplayHeaders("Beresp"); err == nil {
+		t.Errorf("replaying malformed header should fail")
+	} else {
+		t.Logf("replaying malformed header gives: %v", err)
+	}
+}
+
+// TestCanonicalizeHeaders tests that names of headers are canonicalized in
+// header records only, both in Fields and Records, when parsing with the
+// CanonicalHeaders option.
+func TestCanonicalizeHeaders(t *testing.T) {
+	s := "* << Request >> 2\n- ReqHeader content-length:  5\n- ReqUnset x-FOO: bar\n" +
+		"- ReqHeader NoColon\n- ReqHeader in valid: x\n- VCL_Log foo: bar\n- End\n"
+	e, err := ParseWithOptions(stringScanner(s), Options{CanonicalHeaders: true})
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", s, err)
+	}
+	want := Records{
+		{"ReqHeader", "Content-Length:  5"},
+		{"ReqUnset", "X-Foo: bar"},
+		{"ReqHeader", "NoColon"},
+		{"ReqHeader", "in valid: x"},
+		{"VCL_Log", "foo: bar"},
+	}
+	if !reflect.DeepEqual(want, e.Records) {
+		t.Errorf("parsing %q with canonical headers should give %v, got %v", s, want, e.Records)
+	}
+	if v := e.Fields["ReqHeader"][0]; v != "Content-Length:  5" {
+		t.Errorf("parsing %q with canonical headers should give field Content-Length, got %q", s, v)
+	}
+
+	e, err = ParseWithOptions(stringScanner(s), Options{})
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", s, err)
+	}
+	if v := e.Records[0].Value; v != "content-length:  5" {
+		t.Errorf("parsing %q should keep header names, got %q", s, v)
+	}
+}
+
+// TestHeaderFilter tests that entries are selected by their final or received
+// headers, and that unset headers are not matched.
+func TestHeaderFilter(t *testing.T) {
+	e := NewEntry(Request, 2).
+		Add("ReqHeader", "Host: Example.com").
+		Add("ReqHeader", "X-Tenant: a").
+		Add("VCL_call", "RECV").
+		Add("ReqUnset", "Host: Example.com").
+		Add("ReqHeader", "host: example.com").
+		Add("ReqUnset", "X-Tenant: a").
+		Add("RespHeader", "Cache-Control: max-age=60")
+	samples := []struct {
+		Name   string
+		Filter Filter
+		Want   bool
+	}{
+		{"host", HasHeader("Req", "host"), true},
+		{"exact host", HeaderMatches("Req", "Host", regexp.MustCompile(`^example\.com$`)), true},
+		{"received host", &HeaderFilter{Family: "Req", Name: "Host", Re: regexp.MustCompile(`^example\.com$`), Received: true}, false},
+		{"unset tenant", HasHeader("Req", "X-Tenant"), false},
+		{"received tenant", &HeaderFilter{Family: "Req", Name: "X-Tenant", Received: true}, true},
+		{"cache control", HeaderMatches("Resp", "Cache-Control", regexp.MustCompile(`max-age=\d+`)), true},
+		{"request cache control", HasHeader("Req", "Cache-Control"), false},
+		{"backend host", HasHeader("Bereq", "Host"), false},
+	}
+	for _, s := range samples {
+		if got := s.Filter.Match(e); got != s.Want {
+			t.Errorf("filter %s should give %v, got %v", s.Name, s.Want, got)
+		}
+	}
+	if HasHeader("Req", "Host").Match(NewEntry(Request, 3).Add("ReqHeader", "NoColon")) {
+		t.Errorf("entry with malformed headers should not be selected")
+	}
+
+	key := HeaderKey("Req", "Host")
+	if k := key(e); k != "example.com" {
+		t.Errorf("key should be the final host example.com, got %q", k)
+	}
+	if k := HeaderKey("Req", "X-Tenant")(e); k != "" {
+		t.Errorf("key of unset header should be empty, got %q", k)
+	}
+}
@@ -0,0 +1,123 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+)
+
+// headerFamilies maps kinds of entries onto the families of their request
+// and response header records, see ReplayHeaders.
+var headerFamilies = map[Kind][2]string{
+	Request: {"Req", "Resp"},
+	BeReq:   {"Bereq", "Beresp"},
+}
+
+// ToHTTPRequest builds an http.Request from the final method, URL, protocol
+// and headers of a client or backend request. As in requests received by
+// net/http servers, the Host header is moved to the Host field of the request
+// and the remote address of client requests is taken from the ReqStart
+// record. The request has no body.
+func (e *Entry) ToHTTPRequest() (*http.Request, error) {
+	fam, ok := headerFamilies[e.Kind]
+	if !ok {
+		return nil, errors.Errorf("cannot build HTTP request from %v entry", e.Kind)
+	}
+	method, err := e.Method(Final)
+	if err != nil {
+		return nil, err
+	}
+	rawURL, err := e.URL(Final)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse URL %q", rawURL)
+	}
+	proto, err := e.Proto(Final)
+	if err != nil {
+		return nil, err
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		return nil, errors.Errorf("cannot parse protocol %q", proto)
+	}
+	h, err := e.ReplayHeaders(fam[0])
+	if err != nil {
+		return nil, err
+	}
+	r := &http.Request{
+		Method:     method,
+		URL:        u,
+		RequestURI: rawURL,
+		Proto:      proto,
+		ProtoMajor: major,
+		ProtoMinor: minor,
+		Header:     h.Final,
+		Body:       http.NoBody,
+		Host:       h.Final.Get("Host"),
+	}
+	r.Header.Del("Host")
+	if e.Kind == Request {
+		if rs, err := e.ReqStart(); err == nil {
+			r.RemoteAddr = netip.AddrPortFrom(rs.Addr, rs.Port).String()
+		}
+	}
+	return r, nil
+}
+
+// ToHTTPResponse builds an http.Response from the final status, protocol and
+// headers of the response to a client or backend request. ContentLength is
+// taken from the Length record, or the Content-Length header if there is no
+// such record, and is -1 if neither is present. Request is set to the result
+// of ToHTTPRequest if the request can be built. The response has no body.
+func (e *Entry) ToHTTPResponse() (*http.Response, error) {
+	fam, ok := headerFamilies[e.Kind]
+	if !ok {
+		return nil, errors.Errorf("cannot build HTTP response from %v entry", e.Kind)
+	}
+	status, err := e.Status()
+	if err != nil {
+		return nil, err
+	}
+	reason, err := e.Reason()
+	if err != nil {
+		return nil, err
+	}
+	_, proto, err := e.kindField("RespProtocol", "BerespProtocol")
+	if err != nil {
+		return nil, err
+	}
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		return nil, errors.Errorf("cannot parse protocol %q", proto)
+	}
+	h, err := e.ReplayHeaders(fam[1])
+	if err != nil {
+		return nil, err
+	}
+	r := &http.Response{
+		Status:        strconv.Itoa(status) + " " + reason,
+		StatusCode:    status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        h.Final,
+		Body:          http.NoBody,
+		ContentLength: -1,
+	}
+	if l, err := e.ObjectLength(); err == nil {
+		r.ContentLength = l
+	} else if v := h.Final.Get("Content-Length"); v != "" {
+		if r.ContentLength, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse Content-Length %q", v)
+		}
+	}
+	if req, err := e.ToHTTPRequest(); err == nil {
+		r.Request = req
+	}
+	return r, nil
+}
@@ -0,0 +1,117 @@
+package vslparser
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestToHTTPRequest tests that requests are built from the final request
+// records of client and backend requests.
+func TestToHTTPRequest(t *testing.T) {
+	e, err := Parse(stringScanner("* << Request >> 2\n" +
+		"- ReqStart 2001:db8::1 44876 a0\n" +
+		"- ReqMethod GET\n- ReqURL /foo?a=1\n- ReqProtocol HTTP/1.1\n" +
+		"- ReqHeader Host: example.com\n- ReqHeader Accept: */*\n" +
+		"- VCL_call RECV\n- ReqURL /bar?a=1\n- ReqUnset Accept: */*\n" +
+		"- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	r, err := e.ToHTTPRequest()
+	if err != nil {
+		t.Fatalf("building request should not fail, got: %v", err)
+	}
+	if r.Method != "GET" || r.URL.Path != "/bar" || r.URL.Query().Get("a") != "1" || r.RequestURI != "/bar?a=1" {
+		t.Errorf("request should be GET /bar?a=1, got %s %v", r.Method, r.URL)
+	}
+	if r.ProtoMajor != 1 || r.ProtoMinor != 1 {
+		t.Errorf("request protocol should be HTTP/1.1, got %s", r.Proto)
+	}
+	if r.Host != "example.com" || len(r.Header) != 0 {
+		t.Errorf("request should have Host example.com and no headers, got %q and %v", r.Host, r.Header)
+	}
+	if r.RemoteAddr != "[2001:db8::1]:44876" {
+		t.Errorf("request remote address should be [2001:db8::1]:44876, got %q", r.RemoteAddr)
+	}
+
+	e, err = Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BereqMethod POST\n- BereqURL /\n- BereqProtocol HTTP/1.0\n" +
+		"- BereqHeader X-Varnish: 3\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	r, err = e.ToHTTPRequest()
+	if err != nil {
+		t.Fatalf("building backend request should not fail, got: %v", err)
+	}
+	if r.Method != http.MethodPost || r.ProtoMinor != 0 || r.Header.Get("X-Varnish") != "3" || r.RemoteAddr != "" {
+		t.Errorf("backend request was built incorrectly, got %v", r)
+	}
+
+	bad := []*Entry{
+		&Entry{Kind: Session},
+		&Entry{Kind: Request, Fields: Fields{"ReqMethod": []string{"GET"}}},
+		&Entry{Kind: Request, Fields: Fields{"ReqMethod": []string{"GET"}, "ReqURL": []string{"foo"}, "ReqProtocol": []string{"HTTP/1.1"}}},
+		&Entry{Kind: Request, Fields: Fields{"ReqMethod": []string{"GET"}, "ReqURL": []string{"/"}, "ReqProtocol": []string{"HTTP"}}},
+	}
+	for _, e := range bad {
+		if _, err := e.ToHTTPRequest(); err == nil {
+			t.Errorf("building request from %v should fail", e)
+		} else {
+			t.Logf("building request from %v gives: %v", e, err)
+		}
+	}
+}
+
+// TestToHTTPResponse tests that responses are built from the final response
+// records of client and backend requests.
+func TestToHTTPResponse(t *testing.T) {
+	r, err := example().ToHTTPResponse()
+	if err != nil {
+		t.Fatalf("building response of example should not fail, got: %v", err)
+	}
+	if r.StatusCode != 200 || r.Status != "200 OK" || r.ProtoMinor != 1 {
+		t.Errorf("response of example should be HTTP/1.1 200 OK, got %s %s", r.Proto, r.Status)
+	}
+	if r.Header.Get("Server") != "Varnish" || r.ContentLength != 2 {
+		t.Errorf("response of example was built incorrectly, got %v", r)
+	}
+	if r.Request == nil || r.Request.URL.Path != "/health" {
+		t.Errorf("response of example should have the request for /health, got %v", r.Request)
+	}
+
+	e, err := Parse(stringScanner("* << BeReq >> 3\n" +
+		"- BerespProtocol HTTP/1.1\n- BerespStatus 503\n- BerespReason Service Unavailable\n" +
+		"- BerespHeader Content-Length: 12\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	r, err = e.ToHTTPResponse()
+	if err != nil {
+		t.Fatalf("building backend response should not fail, got: %v", err)
+	}
+	if r.StatusCode != 503 || r.ContentLength != 12 || r.Request != nil {
+		t.Errorf("backend response was built incorrectly, got %v", r)
+	}
+	e.add("Length", "34")
+	if r, err = e.ToHTTPResponse(); err != nil || r.ContentLength != 34 {
+		t.Errorf("backend response should have content length of Length record 34, got %v (%v)", r, err)
+	}
+
+	resp := Fields{"RespStatus": []string{"200"}, "RespReason": []string{"OK"}}
+	bad := []*Entry{
+		&Entry{Kind: Session},
+		&Entry{Kind: Request, Fields: Fields{"RespStatus": []string{"200"}}},
+		&Entry{Kind: Request, Fields: resp},
+		&Entry{Kind: Request, Fields: Fields{"RespProtocol": []string{"HTTP"}, "RespStatus": []string{"200"}, "RespReason": []string{"OK"}}},
+		&Entry{Kind: Request, Fields: Fields{"RespProtocol": []string{"HTTP/1.1"}, "RespStatus": []string{"200"}, "RespReason": []string{"OK"}},
+			Records: Records{{"RespHeader", "Content-Length: x"}}},
+	}
+	for _, e := range bad {
+		if _, err := e.ToHTTPResponse(); err == nil {
+			t.Errorf("building response from %v should fail", e)
+		} else {
+			t.Logf("building response from %v gives: %v", e, err)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+// Command gentags generates the table of VSL tags of vslparser from the tag
+// definitions in the Varnish sources, include/tbl/vsl_tags.h and
+// include/tbl/vsl_tags_http.h, of one or more versions of Varnish:
+//
+//	gentags -o tags_gen.go 6.0=path/to/varnish-6.0 7.4=https://host/varnish-7.4
+//
+// Each argument maps a version onto the root of a source tree, either a
+// directory or a base URL. The first version is the default one.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// tag describes a single VSL tag.
+type tag struct {
+	Name    string
+	Doc     string
+	Client  bool
+	Backend bool
+	Unsafe  bool
+	Binary  bool
+	Unused  bool
+}
+
+// version holds the tags of a version of Varnish indexed by their numeric
+// value, starting with 1.
+type version struct {
+	Name string
+	Tags []tag
+}
+
+// scope sets the Client and Backend fields of t from its short description,
+// which is the only place the definitions tell the transactions of a tag,
+// e.g. "Client request header" or "Backend connection opened". Tags
+// described otherwise, e.g. VCL_call or CLI, have neither set.
+func (t *tag) scope() {
+	t.Client = strings.HasPrefix(t.Doc, "Client ")
+	t.Backend = strings.HasPrefix(t.Doc, "Backend ")
+}
+
+// token is a token of C source, i.e. an identifier, a number, a string
+// literal (kept quoted) or a punctuator.
+type token string
+
+// isString returns whether the token is a string literal.
+func (t token) isString() bool {
+	return strings.HasPrefix(string(t), `"`)
+}
+
+// isIdent returns whether the token is an identifier.
+func (t token) isIdent() bool {
+	return t != "" && (unicode.IsLetter(rune(t[0])) || t[0] == '_')
+}
+
+// tokenize splits C source, with comments and preprocessor directives
+// already removed, into tokens.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string literal %q", s[i:])
+			}
+			toks = append(toks, token(s[i:j+1]))
+			i = j + 1
+		case c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token(s[i:j]))
+			i = j
+		case strings.HasPrefix(s[i:], "##"):
+			toks = append(toks, "##")
+			i += 2
+		default:
+			toks = append(toks, token(s[i:i+1]))
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// call is an invocation of a macro, with its arguments split into tokens.
+type call struct {
+	name string
+	args [][]token
+}
+
+// calls returns the invocations of the macro with the given name in toks.
+func calls(name string, toks []token) ([]call, error) {
+	var cs []call
+	for i := 0; i < len(toks); i++ {
+		if string(toks[i]) != name || i+1 >= len(toks) || toks[i+1] != "(" {
+			continue
+		}
+		c := call{name: name, args: [][]token{nil}}
+		depth := 0
+		for i += 2; ; i++ {
+			if i >= len(toks) {
+				return nil, fmt.Errorf("unterminated invocation of %s", name)
+			}
+			t := toks[i]
+			if t == ")" && depth == 0 {
+				break
+			}
+			switch t {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ",":
+				if depth == 0 {
+					c.args = append(c.args, nil)
+					continue
+				}
+			}
+			c.args[len(c.args)-1] = append(c.args[len(c.args)-1], t)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+// parser parses tag definitions of a single version of Varnish.
+type parser struct {
+	open    func(name string) (string, error)
+	strings map[string]string // Macros defined as string literals.
+	slth    *call             // Body of the current definition of SLTH.
+	tags    []tag
+}
+
+// str concatenates the string literals of toks, expanding string macros.
+func (p *parser) str(toks []token) (string, error) {
+	var b strings.Builder
+	for _, t := range toks {
+		switch {
+		case t.isString():
+			s, err := strconv.Unquote(string(t))
+			if err != nil {
+				return "", fmt.Errorf("malformed string literal %s: %v", t, err)
+			}
+			b.WriteString(s)
+		case p.strings[string(t)] != "":
+			b.WriteString(p.strings[string(t)])
+		}
+	}
+	return b.String(), nil
+}
+
+// has returns whether toks contain the token t.
+func has(toks []token, t token) bool {
+	for _, tok := range toks {
+		if tok == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFile parses the file of the given name, e.g. "vsl_tags.h".
+func (p *parser) parseFile(name string) error {
+	src, err := p.open(name)
+	if err != nil {
+		return err
+	}
+	src = stripComments(src)
+	var text strings.Builder
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "#") {
+			text.WriteString(lines[i] + "\n")
+			continue
+		}
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + " " + strings.TrimSpace(lines[i])
+		}
+		if err := p.parseText(name, text.String()); err != nil {
+			return err
+		}
+		text.Reset()
+		if err := p.directive(name, line); err != nil {
+			return err
+		}
+	}
+	return p.parseText(name, text.String())
+}
+
+// directive processes a preprocessor directive of the file of the given name.
+func (p *parser) directive(name, line string) error {
+	fs := strings.Fields(strings.TrimPrefix(line, "#"))
+	if len(fs) < 2 {
+		return nil
+	}
+	switch fs[0] {
+	case "define":
+		toks, err := tokenize(strings.Join(fs[1:], " "))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if toks[0] == "SLTH" {
+			cs, err := calls("SLTM", toks[1:])
+			if err != nil || len(cs) != 1 || len(cs[0].args) != 4 {
+				return fmt.Errorf("%s: malformed definition of SLTH %q", name, line)
+			}
+			p.slth = &cs[0]
+			return nil
+		}
+		if len(toks) > 1 && toks[1].isString() {
+			if p.strings[string(toks[0])], err = p.str(toks[1:]); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	case "undef":
+		if fs[1] == "SLTH" {
+			p.slth = nil
+		}
+	case "include":
+		inc, err := strconv.Unquote(fs[1])
+		if err != nil {
+			return fmt.Errorf("%s: malformed include %q", name, line)
+		}
+		return p.parseFile(filepath.Base(inc))
+	}
+	return nil
+}
+
+// parseText parses the invocations of SLTM, or SLTH if it is defined, in the
+// text of the file of the given name.
+func (p *parser) parseText(name, text string) error {
+	toks, err := tokenize(text)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if p.slth != nil {
+		return p.parseSLTH(name, toks)
+	}
+	cs, err := calls("SLTM", toks)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	for _, c := range cs {
+		if len(c.args) != 4 || len(c.args[0]) != 1 || !c.args[0][0].isIdent() {
+			return fmt.Errorf("%s: malformed invocation of SLTM %v", name, c.args)
+		}
+		t := tag{
+			Name:   string(c.args[0][0]),
+			Unsafe: has(c.args[1], "SLT_F_UNSAFE"),
+			Binary: has(c.args[1], "SLT_F_BINARY"),
+			Unused: has(c.args[1], "SLT_F_UNUSED"),
+		}
+		if t.Doc, err = p.str(c.args[2]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		p.add(t)
+	}
+	return nil
+}
+
+// parseSLTH parses the invocations of SLTH in toks as expanded by its current
+// definition, e.g.
+//
+//	SLTM(Req##tag, (req ? 0 : SLT_F_UNUSED), "Client request " sdesc, ldesc)
+func (p *parser) parseSLTH(name string, toks []token) error {
+	cs, err := calls("SLTH", toks)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	def := p.slth.args
+	if len(def[0]) != 3 || def[0][1] != "##" {
+		return fmt.Errorf("%s: malformed definition of SLTH %v", name, def)
+	}
+	prefix := string(def[0][0])
+	used := 2
+	if has(def[1], "resp") {
+		used = 3
+	}
+	for _, c := range cs {
+		if len(c.args) != 6 || len(c.args[0]) != 1 {
+			return fmt.Errorf("%s: malformed invocation of SLTH %v", name, c.args)
+		}
+		t := tag{Name: prefix + string(c.args[0][0])}
+		t.Unused = len(c.args[used]) == 1 && c.args[used][0] == "0"
+		sdesc, err := p.str(c.args[4])
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if t.Doc, err = p.str(def[2]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		t.Doc += sdesc
+		p.add(t)
+	}
+	return nil
+}
+
+// add appends the tag t to the parsed tags.
+func (p *parser) add(t tag) {
+	t.Doc = strings.TrimSuffix(strings.TrimSpace(t.Doc), ".")
+	t.scope()
+	p.tags = append(p.tags, t)
+}
+
+// stripComments removes C comments from src, keeping line breaks.
+func stripComments(src string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(src, "/*")
+		if i < 0 {
+			b.WriteString(src)
+			return b.String()
+		}
+		b.WriteString(src[:i])
+		j := strings.Index(src[i:], "*/")
+		if j < 0 {
+			return b.String()
+		}
+		b.WriteString(strings.Repeat("\n", strings.Count(src[i:i+j], "\n")))
+		src = src[i+j+2:]
+	}
+}
+
+// opener returns a function which reads files of include/tbl in the source
+// tree at root, which is either a directory or a base URL.
+func opener(root string) func(name string) (string, error) {
+	if strings.HasPrefix(root, "http://") || strings.HasPrefix(root, "https://") {
+		return func(name string) (string, error) {
+			u := strings.TrimSuffix(root, "/") + "/include/tbl/" + name
+			resp, err := http.Get(u)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("fetching %s: %s", u, resp.Status)
+			}
+			b, err := io.ReadAll(resp.Body)
+			return string(b), err
+		}
+	}
+	return func(name string) (string, error) {
+		b, err := os.ReadFile(filepath.Join(root, "include", "tbl", name))
+		return string(b), err
+	}
+}
+
+// parseVersion parses the tag definitions of a version of Varnish in the
+// source tree at root.
+func parseVersion(name, root string) (*version, error) {
+	p := &parser{open: opener(root), strings: map[string]string{}}
+	if err := p.parseFile("vsl_tags.h"); err != nil {
+		return nil, err
+	}
+	if len(p.tags) == 0 {
+		return nil, fmt.Errorf("no tags defined in %s", root)
+	}
+	return &version{Name: name, Tags: p.tags}, nil
+}
+
+// names returns the names of the tags of all versions, each once, in the
+// order in which they first appear.
+func names(versions []*version) []tag {
+	var all []tag
+	seen := map[string]bool{}
+	for _, v := range versions {
+		for _, t := range v.Tags {
+			if !seen[t.Name] {
+				seen[t.Name] = true
+				all = append(all, t)
+			}
+		}
+	}
+	return all
+}
+
+var output = template.Must(template.New("").Parse(`// Code generated by gentags; DO NOT EDIT.
+
+package vslparser
+
+// DefaultTagVersion is the version of Varnish whose tag table is used by
+// default, e.g. by BinaryReader.
+const DefaultTagVersion = {{printf "%q" .Default}}
+
+// tagTables lists the tags of the known versions of Varnish indexed by their
+// numeric value, which is how tags are stored in the binary log format. The
+// value 0 is never used by a valid record.
+var tagTables = map[string][]TagInfo{
+{{- range .Versions}}
+	{{printf "%q" .Name}}: {
+		{},
+{{- range .Tags}}
+		{Name: {{printf "%q" .Name}}, Doc: {{printf "%q" .Doc}}
+			{{- if .Client}}, Client: true{{end}}
+			{{- if .Backend}}, Backend: true{{end}}
+			{{- if .Unsafe}}, Unsafe: true{{end}}
+			{{- if .Binary}}, Binary: true{{end}}
+			{{- if .Unused}}, Unused: true{{end}}},
+{{- end}}
+	},
+{{- end}}
+}
+
+// Names of the known VSL tags of all versions in tagTables.
+const (
+{{- range .Names}}
+	Tag{{.Name}} = {{printf "%q" .Name}} // {{.Doc}}.
+{{- end}}
+)
+`))
+
+func main() {
+	out := flag.String("o", "tags_gen.go", "output `file`")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: gentags [-o file] version=root...")
+	}
+	var versions []*version
+	for _, arg := range flag.Args() {
+		name, root, ok := strings.Cut(arg, "=")
+		if !ok {
+			log.Fatalf("malformed argument %q, version=root expected", arg)
+		}
+		v, err := parseVersion(name, root)
+		if err != nil {
+			log.Fatalf("parsing tags of Varnish %s: %v", name, err)
+		}
+		versions = append(versions, v)
+	}
+	var b bytes.Buffer
+	err := output.Execute(&b, map[string]any{
+		"Default":  versions[0].Name,
+		"Versions": versions,
+		"Names":    names(versions),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("formatting generated code: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
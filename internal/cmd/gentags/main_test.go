@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseVersion tests that tags are parsed from the definitions in
+// testdata, including the expansion of SLTH and of string macros.
+func TestParseVersion(t *testing.T) {
+	v, err := parseVersion("6.0", "testdata/varnish-6.0")
+	if err != nil {
+		t.Fatalf("parsing tags should not fail, got: %v", err)
+	}
+	if len(v.Tags) != 96 {
+		t.Errorf("parsing tags should give 96 tags, got %d", len(v.Tags))
+	}
+	want := map[int]tag{
+		0:  {Name: "Debug", Doc: "Debug messages", Unsafe: true},
+		3:  {Name: "SessOpen", Doc: "Client connection opened", Client: true},
+		5:  {Name: "BackendOpen", Doc: "Backend connection opened", Backend: true},
+		14: {Name: "ReqMethod", Doc: "Client request method", Client: true},
+		17: {Name: "ReqStatus", Doc: "Client request status", Client: true, Unused: true},
+		25: {Name: "RespStatus", Doc: "Client response status", Client: true},
+		38: {Name: "BerespMethod", Doc: "Backend response method", Backend: true, Unused: true},
+		46: {Name: "ObjMethod", Doc: "Object method", Unused: true},
+		69: {Name: "Hash", Doc: "Value added to hash", Unsafe: true, Binary: true},
+		95: {Name: "VdpAcct", Doc: "Deliver filter accounting"},
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(w, v.Tags[i]) {
+			t.Errorf("tag %d should be %+v, got %+v", i+1, w, v.Tags[i])
+		}
+	}
+
+	if _, err := parseVersion("0.0", "testdata/missing"); err == nil {
+		t.Errorf("parsing tags of a missing source tree should fail")
+	}
+}
+
+// TestParseErrors tests that malformed definitions produce errors.
+func TestParseErrors(t *testing.T) {
+	samples := []string{
+		`SLTM(Debug, 0, "Debug messages", ""`,
+		`SLTM(Debug, 0, "Debug messages)`,
+		`SLTM(Debug, 0, "Debug")`,
+		`SLTM(Debug Foo, 0, "Debug", "")`,
+		"#define SLTH(tag) SLTM(tag)\n",
+		"#define SLTH(tag, ind, req, resp, sdesc, ldesc) SLTM(tag, 0, sdesc, ldesc)\nSLTH(Method, A, 1, 0, \"method\", \"\")\n",
+		"#include <vsl_tags_http.h>\n",
+	}
+	for _, s := range samples {
+		p := &parser{
+			open:    func(string) (string, error) { return s, nil },
+			strings: map[string]string{},
+		}
+		if err := p.parseFile("vsl_tags.h"); err == nil {
+			t.Errorf("parsing %q should fail", s)
+		} else {
+			t.Logf("parsing %q gives: %v", s, err)
+		}
+	}
+}
+
+// TestStripComments tests that comments are removed and line breaks kept.
+func TestStripComments(t *testing.T) {
+	s := "a /* b\n c */ d\n/* e */"
+	if got := stripComments(s); got != "a \n d\n" || strings.Count(got, "\n") != 2 {
+		t.Errorf("stripping comments of %q should give %q, got %q", s, "a \n d\n", got)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+ * Tag definitions in the format of include/tbl/vsl_tags.h of the Varnish
+ * sources, reconstructed for Varnish 6.0 with the long descriptions left
+ * out. They are used to test gentags and to generate the committed tag table
+ * until it is regenerated from the Varnish sources.
+ */
+
+/*lint -save -e525 -e539 */
+
+#define NODEF_NOTICE \
+    "NB: This log record is masked by default.\n\n"
+
+SLTM(Debug, SLT_F_UNSAFE, "Debug messages",
+	""
+)
+
+SLTM(Error, 0, "Error messages",
+	""
+)
+
+SLTM(CLI, 0, "CLI communication",
+	""
+)
+
+SLTM(SessOpen, 0, "Client connection opened",
+	""
+)
+
+SLTM(SessClose, 0, "Client connection closed",
+	""
+)
+
+SLTM(BackendOpen, 0, "Backend connection opened",
+	""
+)
+
+SLTM(BackendReuse, 0, "Backend connection put up for reuse",
+	""
+)
+
+SLTM(BackendClose, 0, "Backend connection closed",
+	""
+)
+
+SLTM(HttpGarbage, SLT_F_UNSAFE, "Unparseable HTTP request",
+	""
+)
+
+SLTM(Proxy, 0, "PROXY protocol information",
+	""
+)
+
+SLTM(ProxyGarbage, 0, "Unparseable PROXY request",
+	""
+)
+
+SLTM(Backend, 0, "Backend selected",
+	""
+)
+
+SLTM(Length, 0, "Size of object body",
+	""
+)
+
+SLTM(FetchError, 0, "Error while fetching object",
+	""
+)
+
+#define SLTH(tag, ind, req, resp, sdesc, ldesc) \
+	SLTM(Req##tag, (req ? 0 : SLT_F_UNUSED), "Client request " sdesc, ldesc)
+#include "tbl/vsl_tags_http.h"
+#undef SLTH
+
+#define SLTH(tag, ind, req, resp, sdesc, ldesc) \
+	SLTM(Resp##tag, (resp ? 0 : SLT_F_UNUSED), "Client response " sdesc, ldesc)
+#include "tbl/vsl_tags_http.h"
+#undef SLTH
+
+#define SLTH(tag, ind, req, resp, sdesc, ldesc) \
+	SLTM(Bereq##tag, (req ? 0 : SLT_F_UNUSED), "Backend request " sdesc, ldesc)
+#include "tbl/vsl_tags_http.h"
+#undef SLTH
+
+#define SLTH(tag, ind, req, resp, sdesc, ldesc) \
+	SLTM(Beresp##tag, (resp ? 0 : SLT_F_UNUSED), "Backend response " sdesc, ldesc)
+#include "tbl/vsl_tags_http.h"
+#undef SLTH
+
+#define SLTH(tag, ind, req, resp, sdesc, ldesc) \
+	SLTM(Obj##tag, (resp ? 0 : SLT_F_UNUSED), "Object " sdesc, ldesc)
+#include "tbl/vsl_tags_http.h"
+#undef SLTH
+
+SLTM(BogoHeader, 0, "Bogus HTTP received",
+	""
+)
+
+SLTM(LostHeader, 0, "Failed attempt to set HTTP header",
+	""
+)
+
+SLTM(TTL, 0, "TTL set on object",
+	""
+)
+
+SLTM(Fetch_Body, 0, "Body fetched from backend",
+	""
+)
+
+SLTM(VCL_acl, 0, "VCL ACL check results",
+	""
+)
+
+SLTM(VCL_call, 0, "VCL method called",
+	""
+)
+
+SLTM(VCL_trace, 0, "VCL trace data",
+	""
+)
+
+SLTM(VCL_return, 0, "VCL method return value",
+	""
+)
+
+SLTM(ReqStart, 0, "Client request start",
+	""
+)
+
+SLTM(Hit, 0, "Hit object in cache",
+	""
+)
+
+SLTM(HitPass, 0, "Hit for pass object in cache",
+	""
+)
+
+SLTM(ExpBan, 0, "Object evicted due to ban",
+	""
+)
+
+SLTM(ExpKill, 0, "Object expiry event",
+	""
+)
+
+SLTM(WorkThread, 0, "Logs thread start/stop events",
+	""
+)
+
+SLTM(ESI_xmlerror, 0, "ESI parser error or warning message",
+	""
+)
+
+SLTM(Hash, SLT_F_UNSAFE | SLT_F_BINARY, "Value added to hash",
+	NODEF_NOTICE
+)
+
+SLTM(Backend_health, 0, "Backend health check",
+	""
+)
+
+SLTM(VCL_Log, 0, "Log statement from VCL",
+	""
+)
+
+SLTM(VCL_Error, 0, "VCL execution error message",
+	""
+)
+
+SLTM(Gzip, 0, "G(un)zip performed on object",
+	""
+)
+
+SLTM(Link, 0, "Links to a child VXID",
+	""
+)
+
+SLTM(Begin, 0, "Marks the start of a VXID",
+	""
+)
+
+SLTM(End, 0, "Marks the end of a VXID",
+	""
+)
+
+SLTM(VSL, 0, "VSL API warnings and error message",
+	""
+)
+
+SLTM(Storage, 0, "Where object is stored",
+	""
+)
+
+SLTM(Timestamp, 0, "Timing information",
+	""
+)
+
+SLTM(ReqAcct, 0, "Request handling byte counts",
+	""
+)
+
+SLTM(PipeAcct, 0, "Pipe byte counts",
+	""
+)
+
+SLTM(BereqAcct, 0, "Backend request accounting",
+	""
+)
+
+SLTM(VfpAcct, 0, "Fetch filter accounting",
+	""
+)
+
+SLTM(Witness, 0, "Lock order witness records",
+	""
+)
+
+SLTM(BackendStart, 0, "Backend request start",
+	""
+)
+
+SLTM(H2RxHdr, SLT_F_BINARY, "Received HTTP2 frame header",
+	""
+)
+
+SLTM(H2RxBody, SLT_F_BINARY, "Received HTTP2 frame body",
+	""
+)
+
+SLTM(H2TxHdr, SLT_F_BINARY, "Transmitted HTTP2 frame header",
+	""
+)
+
+SLTM(H2TxBody, SLT_F_BINARY, "Transmitted HTTP2 frame body",
+	""
+)
+
+SLTM(HitMiss, 0, "Hit for miss object in cache",
+	""
+)
+
+SLTM(Filters, 0, "Body filters",
+	""
+)
+
+SLTM(SessError, 0, "Client connection accept failed",
+	""
+)
+
+SLTM(VCL_use, 0, "VCL in use",
+	""
+)
+
+SLTM(Notice, 0, "Informational messages about request handling",
+	""
+)
+
+SLTM(VdpAcct, 0, "Deliver filter accounting",
+	""
+)
+
+#undef NODEF_NOTICE
+#undef SLTM
+
+/*lint -restore */
@@ -0,0 +1,25 @@
+/*
+ * HTTP header tag definitions in the format of include/tbl/vsl_tags_http.h of
+ * the Varnish sources, reconstructed for Varnish 6.0. See vsl_tags.h.
+ *
+ * Arguments:
+ *	Tag-Name
+ *	struct http header index
+ *	1 if this header is used in requests
+ *	1 if this header is used in responses
+ *	short description postfix
+ *	long description (in RST "definition list" format)
+ */
+
+/*lint -save -e525 -e539 */
+
+SLTH(Method,	HTTP_HDR_METHOD,	1, 0, "method", "")
+SLTH(URL,	HTTP_HDR_URL,		1, 0, "URL", "")
+SLTH(Protocol,	HTTP_HDR_PROTO,		1, 1, "protocol", "")
+SLTH(Status,	HTTP_HDR_STATUS,	0, 1, "status", "")
+SLTH(Reason,	HTTP_HDR_REASON,	0, 1, "response", "")
+SLTH(Header,	HTTP_HDR_FIRST,		1, 1, "header", "")
+SLTH(Unset,	HTTP_HDR_UNSET,		0, 0, "unset header", "")
+SLTH(Lost,	HTTP_HDR_LOST,		0, 0, "lost header", "")
+
+/*lint -restore */
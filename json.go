@@ -0,0 +1,197 @@
+package vslparser
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+// jsonHeaderFamilies are the families of headers in the headers view of the
+// JSON representation of entries, see Entry.MarshalJSON.
+var jsonHeaderFamilies = []string{"Req", "Resp", "Bereq", "Beresp", "Obj"}
+
+// jsonEntry is the JSON representation of an entry, see Entry.MarshalJSON.
+type jsonEntry struct {
+	Kind       string                         `json:"kind"`
+	VXID       uint64                         `json:"vxid"`
+	Level      int                            `json:"level"`
+	Records    []jsonRecord                   `json:"records"`
+	Truncated  bool                           `json:"truncated,omitempty"`
+	Incomplete bool                           `json:"incomplete,omitempty"`
+	Headers    map[string]map[string][]string `json:"headers,omitempty"`
+	Timestamps []jsonTimestamp                `json:"timestamps,omitempty"`
+	Children   []*jsonEntry                   `json:"children,omitempty"`
+}
+
+// jsonInput is the JSON representation of an entry as read by
+// Entry.UnmarshalJSON, without the views.
+type jsonInput struct {
+	Kind       *string      `json:"kind"`
+	VXID       uint64       `json:"vxid"`
+	Level      int          `json:"level"`
+	Records    []jsonRecord `json:"records"`
+	Truncated  bool         `json:"truncated"`
+	Incomplete bool         `json:"incomplete"`
+	Children   []*Entry     `json:"children"`
+}
+
+// jsonRecord is the JSON representation of a record.
+type jsonRecord struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// jsonTimestamp is the JSON representation of a Timestamp record.
+type jsonTimestamp struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	SinceStart float64   `json:"since_start"`
+	SinceLast  float64   `json:"since_last"`
+}
+
+// MarshalJSON returns the entry and the transactions nested in it as a JSON
+// object of a stable schema, e.g.
+//
+//	{
+//		"kind": "Request",
+//		"vxid": 2,
+//		"level": 1,
+//		"records": [
+//			{"tag": "Begin", "value": "req 1 rxreq"},
+//			{"tag": "Timestamp", "value": "Start: 1545037998.759333 0.000000 0.000000"},
+//			{"tag": "ReqHeader", "value": "Host: example.com"}
+//		],
+//		"headers": {"Req": {"Host": ["example.com"]}},
+//		"timestamps": [
+//			{"event": "Start", "time": "2018-12-17T09:13:18.759333Z", "since_start": 0, "since_last": 0}
+//		],
+//		"children": [{"kind": "BeReq", "vxid": 3, "level": 2, "records": []}]
+//	}
+//
+// Kind is the name of the kind as found in entry headers, or RawKind for
+// kinds unknown to this package. Records are the records in the order in
+// which they were logged; for entries without Records, the Fields ordered by
+// tag. Truncated and Incomplete are only present if set, and children only if
+// there are nested transactions.
+//
+// Headers and timestamps are views parsed from the records for the
+// convenience of log stores, which are only present if the entry has such
+// records. Headers holds the final headers of each family, see
+// ReplayHeaders, by their canonical names; families whose records cannot be
+// parsed are left out. Timestamps holds the Timestamp records in the order in
+// which they were logged, with times in UTC and durations in seconds; it is
+// left out if any of them cannot be parsed. The views are ignored by
+// UnmarshalJSON, since the records hold the same information.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.jsonEntry())
+}
+
+// jsonEntry returns the JSON representation of the entry.
+func (e *Entry) jsonEntry() *jsonEntry {
+	j := &jsonEntry{
+		Kind:       e.kindName(),
+		VXID:       e.VXID,
+		Level:      e.Level,
+		Records:    []jsonRecord{},
+		Truncated:  e.Truncated,
+		Incomplete: e.Incomplete,
+	}
+	v := e
+	if len(e.Records) == 0 && len(e.Fields) > 0 {
+		v = &Entry{Fields: e.Fields, Records: e.records()}
+	}
+	for _, r := range v.Records {
+		j.Records = append(j.Records, jsonRecord{Tag: r.Tag, Value: r.Value})
+	}
+	for _, family := range jsonHeaderFamilies {
+		if _, ok := v.Fields[family+"Header"]; !ok {
+			continue
+		}
+		r, err := v.ReplayHeaders(family)
+		if err != nil {
+			continue
+		}
+		if j.Headers == nil {
+			j.Headers = map[string]map[string][]string{}
+		}
+		j.Headers[family] = r.Final
+	}
+	if stamps, err := v.Timestamps(); err == nil {
+		for _, ts := range stamps {
+			j.Timestamps = append(j.Timestamps, jsonTimestamp{
+				Event:      ts.Event,
+				Time:       ts.AbsTime.UTC(),
+				SinceStart: ts.SinceStart.Seconds(),
+				SinceLast:  ts.SinceLast.Seconds(),
+			})
+		}
+	}
+	for _, c := range e.Children {
+		j.Children = append(j.Children, c.jsonEntry())
+	}
+	return j
+}
+
+// UnmarshalJSON sets the entry to the one marshaled into the JSON object b
+// by MarshalJSON, including the transactions nested in it, so that entries
+// can be passed between programs without being written as varnishlog text.
+// Fields are made of the records. Kinds unknown to this package are kept in
+// RawKind, like the parser does. The views of headers and timestamps, as well
+// as members unknown to this package, are ignored.
+func (e *Entry) UnmarshalJSON(b []byte) error {
+	var j jsonInput
+	if err := json.Unmarshal(b, &j); err != nil {
+		return errors.Wrap(err, "cannot unmarshal entry")
+	}
+	if j.Kind == nil || *j.Kind == "" {
+		return errors.New("cannot unmarshal entry without kind")
+	}
+	*e = *newEntry()
+	var err error
+	if e.Kind, err = ParseKind(*j.Kind); err != nil {
+		e.RawKind = *j.Kind
+	}
+	e.VXID = j.VXID
+	e.Level = j.Level
+	for i, r := range j.Records {
+		if r.Tag == "" {
+			return errors.Errorf("cannot unmarshal record %d of entry %d without tag", i, e.VXID)
+		}
+		e.add(r.Tag, r.Value)
+	}
+	e.Truncated = j.Truncated
+	e.Incomplete = j.Incomplete
+	for i, c := range j.Children {
+		if c == nil {
+			return errors.Errorf("cannot unmarshal child %d of entry %d", i, e.VXID)
+		}
+	}
+	e.Children = j.Children
+	return nil
+}
+
+// kindName returns the name of the kind of the entry as found in its header,
+// i.e. RawKind for kinds unknown to this package.
+func (e *Entry) kindName() string {
+	if e.Kind == Unknown && e.RawKind != "" {
+		return e.RawKind
+	}
+	return e.Kind.String()
+}
+
+// records returns the records of the entry, or records made of Fields ordered
+// by tag for entries without Records.
+func (e *Entry) records() Records {
+	if len(e.Records) > 0 || len(e.Fields) == 0 {
+		return e.Records
+	}
+	var rs Records
+	for _, tag := range slices.Sorted(maps.Keys(e.Fields)) {
+		for _, v := range e.Fields[tag] {
+			rs = append(rs, Record{Tag: tag, Value: v})
+		}
+	}
+	return rs
+}
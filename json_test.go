@@ -0,0 +1,160 @@
+package vslparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestMarshalJSON tests that entries are marshaled into the documented
+// schema, including the views of their headers and timestamps.
+func TestMarshalJSON(t *testing.T) {
+	e := NewEntry(Request, 2).
+		Add("Begin", "req 1 rxreq").
+		Add("Timestamp", "Start: 1545037998.759333 0.000000 0.000000").
+		Add("ReqHeader", "host: example.com").
+		Add("ReqHeader", "Cookie: a=b").
+		Add("VCL_call", "RECV").
+		Add("ReqUnset", "Cookie: a=b").
+		Add("Timestamp", "Resp: 1545037998.760333 0.001000 0.001000").
+		AddChild(NewEntry(BeReq, 3))
+	e.Incomplete = true
+	want := `{
+	"kind": "Request",
+	"vxid": 2,
+	"level": 1,
+	"records": [
+		{"tag": "Begin", "value": "req 1 rxreq"},
+		{"tag": "Timestamp", "value": "Start: 1545037998.759333 0.000000 0.000000"},
+		{"tag": "ReqHeader", "value": "host: example.com"},
+		{"tag": "ReqHeader", "value": "Cookie: a=b"},
+		{"tag": "VCL_call", "value": "RECV"},
+		{"tag": "ReqUnset", "value": "Cookie: a=b"},
+		{"tag": "Timestamp", "value": "Resp: 1545037998.760333 0.001000 0.001000"}
+	],
+	"incomplete": true,
+	"headers": {"Req": {"Host": ["example.com"]}},
+	"timestamps": [
+		{"event": "Start", "time": "2018-12-17T09:13:18.759333Z", "since_start": 0, "since_last": 0},
+		{"event": "Resp", "time": "2018-12-17T09:13:18.760333Z", "since_start": 0.001, "since_last": 0.001}
+	],
+	"children": [{"kind": "BeReq", "vxid": 3, "level": 2, "records": []}]
+}`
+	checkJSON(t, e, want)
+
+	samples := []struct {
+		Entry *Entry
+		Want  string
+	}{
+		{
+			&Entry{RawKind: "Future", VXID: 1, Truncated: true},
+			`{"kind": "Future", "vxid": 1, "level": 0, "records": [], "truncated": true}`,
+		},
+		{
+			NewEntry(Raw, 0).Add("CLI", "Rd ping"),
+			`{"kind": "Record", "vxid": 0, "level": 1, "records": [{"tag": "CLI", "value": "Rd ping"}]}`,
+		},
+		{
+			NewEntry(Request, 1).Add("ReqURL", "/").Add("Timestamp", "Start: foo"),
+			`{"kind": "Request", "vxid": 1, "level": 1, "records": [{"tag": "ReqURL", "value": "/"}, {"tag": "Timestamp", "value": "Start: foo"}]}`,
+		},
+		{
+			NewEntry(Request, 1).Add("ReqHeader", "foo"),
+			`{"kind": "Request", "vxid": 1, "level": 1, "records": [{"tag": "ReqHeader", "value": "foo"}]}`,
+		},
+		{
+			&Entry{Kind: BeReq, VXID: 1, Fields: Fields{"BereqURL": []string{"/"}, "BereqHeader": []string{"A: 1"}}},
+			`{"kind": "BeReq", "vxid": 1, "level": 0, "records": [{"tag": "BereqHeader", "value": "A: 1"}, {"tag": "BereqURL", "value": "/"}], "headers": {"Bereq": {"A": ["1"]}}}`,
+		},
+	}
+	for _, s := range samples {
+		checkJSON(t, s.Entry, s.Want)
+	}
+}
+
+// checkJSON checks that the entry e is marshaled into the JSON object want,
+// ignoring white space.
+func checkJSON(t *testing.T, e *Entry, want string) {
+	t.Helper()
+	got, err := json.Marshal(e)
+	if err != nil {
+		t.Errorf("marshaling %v should not fail, got: %v", e, err)
+		return
+	}
+	var w bytes.Buffer
+	if err := json.Compact(&w, []byte(want)); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	if !bytes.Equal(got, w.Bytes()) {
+		t.Errorf("marshaling %v should give %s, got %s", e, w.Bytes(), got)
+	}
+}
+
+// TestUnmarshalJSON tests that unmarshaling marshaled entries gives the same
+// entries.
+func TestUnmarshalJSON(t *testing.T) {
+	samples := []struct {
+		Grouping Grouping
+		Input    string
+	}{
+		{GroupSession, session},
+		{GroupVXID, `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1545037998.759333 0.000000 0.000000
+-   ReqHeader      Host: example.com
+-   ReqHeader      Host: example.com
+-   End
+
+*   << Record   >> 0
+-   CLI            Rd ping
+
+*   << Future   >> 4
+-   Begin          future 1
+-   End
+
+*   << Request  >> 5
+`},
+	}
+	for _, s := range samples {
+		want, err := parseAllWithOptions(s.Input, Options{Grouping: s.Grouping, AllowTruncated: true})
+		if err != nil {
+			t.Fatalf("failed to parse sample: %v", err)
+		}
+		b, err := json.Marshal(want)
+		if err != nil {
+			t.Fatalf("marshaling %v should not fail, got: %v", want, err)
+		}
+		var got []*Entry
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("unmarshaling %s should not fail, got: %v", b, err)
+		} else if !reflect.DeepEqual(want, got) {
+			t.Errorf("unmarshaling %s should give %v, got %v", b, want, got)
+		}
+	}
+
+	var e Entry
+	if err := json.Unmarshal([]byte(`{"kind": "BeReq", "vxid": 3, "level": 1, "records": [{"tag": "BereqURL", "value": "/"}], "headers": 1, "foo": "bar"}`), &e); err != nil {
+		t.Errorf("unmarshaling entry with unknown members should not fail, got: %v", err)
+	} else if want := NewEntry(BeReq, 3).Add("BereqURL", "/"); !reflect.DeepEqual(want, &e) {
+		t.Errorf("unmarshaling entry with unknown members should give %v, got %v", want, &e)
+	}
+
+	bad := []string{
+		`[]`,
+		`{}`,
+		`{"kind": ""}`,
+		`{"kind": "Request", "vxid": "1"}`,
+		`{"kind": "Request", "records": [{"value": "/"}]}`,
+		`{"kind": "Request", "children": [null]}`,
+		`{"kind": "Request", "children": [{"vxid": 2}]}`,
+	}
+	for _, s := range bad {
+		if err := json.Unmarshal([]byte(s), &e); err == nil {
+			t.Errorf("unmarshaling %s should fail", s)
+		} else {
+			t.Logf("unmarshaling %s gives: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Kind is the type of a transaction, as found in the header of its entry,
+// e.g. "<< Request  >>".
+type Kind int
+
+const (
+	// Unknown is the kind of transactions of an unknown type.
+	Unknown Kind = iota
+	// Session is the kind of client sessions.
+	Session
+	// Request is the kind of client requests, including ESI subrequests.
+	Request
+	// BeReq is the kind of backend requests.
+	BeReq
+	// Raw is the kind of records which belong to no transaction, e.g. CLI
+	// or Backend_health records. Varnish reports them as "<< Record >>".
+	Raw
+)
+
+// kindNames maps the kinds to their names found in entry headers.
+var kindNames = [...]string{
+	Unknown: "Unknown",
+	Session: "Session",
+	Request: "Request",
+	BeReq:   "BeReq",
+	Raw:     "Record",
+}
+
+// String returns the name of the kind, as found in entry headers.
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Unknown"
+	}
+	return kindNames[k]
+}
+
+// ParseKind returns the kind of the given name, as found in entry headers,
+// e.g. "Request". Unknown is returned along with an error if the name is not
+// known.
+func ParseKind(s string) (Kind, error) {
+	for k, n := range kindNames {
+		if n == s {
+			return Kind(k), nil
+		}
+	}
+	return Unknown, errors.Errorf("unknown transaction kind %q", s)
+}
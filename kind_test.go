@@ -0,0 +1,38 @@
+package vslparser
+
+import (
+	"testing"
+)
+
+// TestParseKind tests that kinds are converted to and from their names.
+func TestParseKind(t *testing.T) {
+	samples := map[string]Kind{
+		"Unknown": Unknown,
+		"Session": Session,
+		"Request": Request,
+		"BeReq":   BeReq,
+		"Record":  Raw,
+	}
+	for s, want := range samples {
+		k, err := ParseKind(s)
+		if err != nil {
+			t.Errorf("parsing kind %q should not fail, got: %v", s, err)
+		}
+		if k != want {
+			t.Errorf("parsing kind %q should give %v, got %v", s, want, k)
+		}
+		if k.String() != s {
+			t.Errorf("kind %v should be named %q, got %q", k, s, k.String())
+		}
+	}
+	for _, s := range []string{"", "request", "Foo"} {
+		if k, err := ParseKind(s); err == nil || k != Unknown {
+			t.Errorf("parsing kind %q should fail with Unknown, got %v (%v)", s, k, err)
+		} else {
+			t.Logf("parsing kind %q gives: %v", s, err)
+		}
+	}
+	if s := Kind(42).String(); s != "Unknown" {
+		t.Errorf("invalid kind should be named Unknown, got %q", s)
+	}
+}
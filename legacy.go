@@ -0,0 +1,125 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// legacyClientTags maps the tags of client records of Varnish 3.x onto their
+// modern equivalents.
+var legacyClientTags = map[string]string{
+	"RxRequest":    "ReqMethod",
+	"RxURL":        "ReqURL",
+	"RxProtocol":   "ReqProtocol",
+	"RxHeader":     "ReqHeader",
+	"RxLostHeader": "LostHeader",
+	"TxProtocol":   "RespProtocol",
+	"TxStatus":     "RespStatus",
+	"TxResponse":   "RespReason",
+	"TxHeader":     "RespHeader",
+	"TxLostHeader": "LostHeader",
+}
+
+// legacyBackendTags maps the tags of backend records of Varnish 3.x onto their
+// modern equivalents. The direction of the traffic is reversed compared to
+// client records, e.g. TxHeader is a header of the backend request.
+var legacyBackendTags = map[string]string{
+	"TxRequest":    "BereqMethod",
+	"TxURL":        "BereqURL",
+	"TxProtocol":   "BereqProtocol",
+	"TxHeader":     "BereqHeader",
+	"TxLostHeader": "LostHeader",
+	"RxProtocol":   "BerespProtocol",
+	"RxStatus":     "BerespStatus",
+	"RxResponse":   "BerespReason",
+	"RxHeader":     "BerespHeader",
+	"RxLostHeader": "LostHeader",
+}
+
+// legacyTags maps the tags of Varnish 3.x records which are the same for both
+// directions onto their modern equivalents.
+var legacyTags = map[string]string{
+	"ObjResponse":  "ObjReason",
+	"SessionOpen":  "SessOpen",
+	"SessionClose": "SessClose",
+}
+
+// legacyTag returns the modern equivalent of the Varnish 3.x tag of a record
+// with the given client/backend marker.
+func legacyTag(tag, mark string) string {
+	var m map[string]string
+	switch mark {
+	case "c":
+		m = legacyClientTags
+	case "b":
+		m = legacyBackendTags
+	}
+	if t, ok := m[tag]; ok {
+		return t
+	}
+	if t, ok := legacyTags[tag]; ok {
+		return t
+	}
+	return tag
+}
+
+// legacyKinds maps the client/backend markers of Varnish 3.x records to the
+// kinds of entries.
+var legacyKinds = map[string]Kind{
+	"c": Request,
+	"b": BeReq,
+}
+
+// parseLegacyEntry parses the Varnish 3.x transaction starting at the current
+// line into e. Transactions of varnishlog -o are groups of records of the same
+// file descriptor, terminated by an empty line, e.g.:
+//
+//	12 ReqStart     c 10.0.0.1 53602 1234567890
+//	12 RxRequest    c GET
+//	12 RxURL        c /health
+//	12 ReqEnd       c 1234567890 1545037998.759302 1545037998.759333 0.000031 0.000016 0.000015
+//
+// Legacy tags are mapped onto the modern ones, e.g. RxURL of a client
+// transaction is stored as ReqURL. Since Varnish 3.x has no VXIDs, the VXID
+// of a client transaction is the XID from its ReqStart record, and zero for
+// other transactions. The Kind of transactions which are neither client nor
+// backend ones, e.g. CLI records, is Unknown.
+func (p *Parser) parseLegacyEntry(e *Entry) error {
+	e.Level = 1
+	fd := ""
+	for {
+		line := p.text
+		id, rest := splitLine(line)
+		if !digits(id) {
+			return errors.Errorf("parse error on line %q: file descriptor expected", line)
+		}
+		tag, mark, value, ok := splitVerbose(rest)
+		if !ok {
+			return errors.Errorf("parse error on line %q: malformed record", line)
+		}
+		if fd == "" {
+			fd = id
+			e.Kind = legacyKinds[mark]
+		} else if id != fd {
+			return errors.Errorf("parse error on line %q: file descriptor %s expected", line, fd)
+		}
+		if tag == "ReqStart" {
+			if fs := strings.Fields(value); len(fs) == 3 {
+				xid, err := strconv.ParseUint(fs[2], 10, 64)
+				if err != nil {
+					return errors.Wrapf(err, "parse error on line %q: cannot parse XID", line)
+				}
+				e.VXID = xid
+			}
+		}
+		tag = legacyTag(tag, mark)
+		if p.keep(tag, value) {
+			e.add(tag, value)
+		}
+		if !p.scan() || p.text == "" {
+			break
+		}
+	}
+	return p.err()
+}
@@ -0,0 +1,114 @@
+package vslparser
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLegacy tests that grouped Varnish 3.x logs are parsed with the tags
+// mapped onto the modern ones, and that modern entries are still recognized.
+func TestLegacy(t *testing.T) {
+	p := NewParser(strings.NewReader(`   12 SessionOpen  c 10.0.0.1 53602 :80
+   12 ReqStart     c 10.0.0.1 53602 1234567890
+   12 RxRequest    c GET
+   12 RxURL        c /health
+   12 RxHeader     c Host: example.com
+   12 TxStatus     c 200
+   12 TxHeader     c Content-Length: 2
+   12 ReqEnd       c 1234567890 1545037998.759302 1545037998.759333 0.000031 0.000016 0.000015
+
+   14 BackendOpen  b default 127.0.0.1 41234 127.0.0.1 8080
+   14 TxRequest    b GET
+   14 TxHeader     b Host: example.com
+   14 RxStatus     b 200
+   14 RxResponse   b OK
+
+*   << Request  >> 2
+-   ReqURL         /
+-   End
+`))
+	p.Legacy = true
+	want := []*Entry{
+		&Entry{
+			Kind:  Request,
+			VXID:  1234567890,
+			Level: 1,
+			Fields: Fields{
+				"SessOpen":   []string{"10.0.0.1 53602 :80"},
+				"ReqStart":   []string{"10.0.0.1 53602 1234567890"},
+				"ReqMethod":  []string{"GET"},
+				"ReqURL":     []string{"/health"},
+				"ReqHeader":  []string{"Host: example.com"},
+				"RespStatus": []string{"200"},
+				"RespHeader": []string{"Content-Length: 2"},
+				"ReqEnd":     []string{"1234567890 1545037998.759302 1545037998.759333 0.000031 0.000016 0.000015"},
+			},
+			Records: Records{
+				{"SessOpen", "10.0.0.1 53602 :80"},
+				{"ReqStart", "10.0.0.1 53602 1234567890"},
+				{"ReqMethod", "GET"},
+				{"ReqURL", "/health"},
+				{"ReqHeader", "Host: example.com"},
+				{"RespStatus", "200"},
+				{"RespHeader", "Content-Length: 2"},
+				{"ReqEnd", "1234567890 1545037998.759302 1545037998.759333 0.000031 0.000016 0.000015"},
+			},
+		},
+		&Entry{
+			Kind:  BeReq,
+			Level: 1,
+			Fields: Fields{
+				"BackendOpen":  []string{"default 127.0.0.1 41234 127.0.0.1 8080"},
+				"BereqMethod":  []string{"GET"},
+				"BereqHeader":  []string{"Host: example.com"},
+				"BerespStatus": []string{"200"},
+				"BerespReason": []string{"OK"},
+			},
+			Records: Records{
+				{"BackendOpen", "default 127.0.0.1 41234 127.0.0.1 8080"},
+				{"BereqMethod", "GET"},
+				{"BereqHeader", "Host: example.com"},
+				{"BerespStatus", "200"},
+				{"BerespReason", "OK"},
+			},
+		},
+		&Entry{
+			Kind:  Request,
+			VXID:  2,
+			Level: 1,
+			Fields: Fields{
+				"ReqURL": []string{"/"},
+			},
+			Records: Records{{"ReqURL", "/"}},
+		},
+	}
+	for _, e := range want {
+		got, err := p.Next()
+		if err != nil {
+			t.Fatalf("p.Next() should not fail, got: %v", err)
+		}
+		if !reflect.DeepEqual(e, got) {
+			t.Errorf("p.Next() should give %v, got %v", e, got)
+		}
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("p.Next() should return io.EOF at the end of input, got: %v", err)
+	}
+
+	bad := []string{
+		"   12 RxURL\n",
+		"   12 RxURL        c /\n   13 RxURL        c /\n",
+		"   12 ReqStart     c 10.0.0.1 53602 xid\n",
+	}
+	for _, s := range bad {
+		p := NewParser(strings.NewReader(s))
+		p.Legacy = true
+		if _, err := p.Next(); err == nil {
+			t.Errorf("parsing %q should fail", s)
+		} else {
+			t.Logf("parsing %q gives: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+)
+
+// Match returns whether the value of any record of the given tag of the entry
+// matches re.
+func Match(e *Entry, tag string, re *regexp.Regexp) bool {
+	for _, v := range e.Fields[tag] {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldMatcher matches the values of records of a tag against a regular
+// expression and extracts its capture groups, e.g.
+//
+//	m := vslparser.MustCompileFieldMatcher("ReqHeader", `^Host:\s*(?P<host>[^:]+)`)
+//	host := m.Named(e)["host"]
+//
+// A FieldMatcher is a Filter, which selects entries with a matching record.
+type FieldMatcher struct {
+	Tag string         // Tag of the matched records.
+	Re  *regexp.Regexp // Regular expression matched against the values.
+}
+
+// CompileFieldMatcher returns a matcher of records of the given tag against
+// the regular expression expr.
+func CompileFieldMatcher(tag, expr string) (*FieldMatcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot compile matcher of %s", tag)
+	}
+	return &FieldMatcher{Tag: tag, Re: re}, nil
+}
+
+// MustCompileFieldMatcher is like CompileFieldMatcher but panics if expr is
+// not a valid regular expression.
+func MustCompileFieldMatcher(tag, expr string) *FieldMatcher {
+	m, err := CompileFieldMatcher(tag, expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Match returns whether the value of any record of the tag of the entry
+// matches.
+func (m *FieldMatcher) Match(e *Entry) bool {
+	return Match(e, m.Tag, m.Re)
+}
+
+// Submatch returns the match of the first matching record of the entry
+// followed by the matches of the capture groups, see
+// regexp.Regexp.FindStringSubmatch, or nil if no record matches.
+func (m *FieldMatcher) Submatch(e *Entry) []string {
+	for _, v := range e.Fields[m.Tag] {
+		if sm := m.Re.FindStringSubmatch(v); sm != nil {
+			return sm
+		}
+	}
+	return nil
+}
+
+// AllSubmatches returns the submatches, see Submatch, of all matching records
+// of the entry in the order in which they were logged.
+func (m *FieldMatcher) AllSubmatches(e *Entry) [][]string {
+	var all [][]string
+	for _, v := range e.Fields[m.Tag] {
+		if sm := m.Re.FindStringSubmatch(v); sm != nil {
+			all = append(all, sm)
+		}
+	}
+	return all
+}
+
+// Named returns the matches of the named capture groups of the first matching
+// record of the entry by their names, or nil if no record matches. Groups
+// which did not participate in the match are empty.
+func (m *FieldMatcher) Named(e *Entry) map[string]string {
+	sm := m.Submatch(e)
+	if sm == nil {
+		return nil
+	}
+	named := map[string]string{}
+	for i, name := range m.Re.SubexpNames() {
+		if name != "" {
+			named[name] = sm[i]
+		}
+	}
+	return named
+}
@@ -0,0 +1,53 @@
+package vslparser
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// TestMatch tests that records of a tag are matched against regular
+// expressions and that capture groups are extracted from the first or all
+// matching records.
+func TestMatch(t *testing.T) {
+	e := NewEntry(Request, 2).
+		Add("ReqURL", "/foo?bar=1").
+		Add("ReqHeader", "Host: example.com:8080").
+		Add("ReqHeader", "X-Forwarded-For: 192.0.2.1").
+		Add("ReqHeader", "Host: www.example.com")
+
+	if !Match(e, "ReqURL", regexp.MustCompile(`^/foo\?`)) {
+		t.Errorf("ReqURL should match ^/foo\\?")
+	}
+	if Match(e, "ReqURL", regexp.MustCompile(`^/bar`)) || Match(e, "BereqURL", regexp.MustCompile(``)) {
+		t.Errorf("ReqURL should not match ^/bar and missing BereqURL should not match")
+	}
+
+	m := MustCompileFieldMatcher("ReqHeader", `^Host:\s*(?P<host>[^:]+)(?::(?P<port>\d+))?`)
+	if !m.Match(e) {
+		t.Errorf("matcher %v should match", m.Re)
+	}
+	if want, got := []string{"Host: example.com:8080", "example.com", "8080"}, m.Submatch(e); !reflect.DeepEqual(want, got) {
+		t.Errorf("submatch should give %q, got %q", want, got)
+	}
+	want := [][]string{
+		{"Host: example.com:8080", "example.com", "8080"},
+		{"Host: www.example.com", "www.example.com", ""},
+	}
+	if got := m.AllSubmatches(e); !reflect.DeepEqual(want, got) {
+		t.Errorf("all submatches should give %q, got %q", want, got)
+	}
+	if got := m.Named(e); !reflect.DeepEqual(map[string]string{"host": "example.com", "port": "8080"}, got) {
+		t.Errorf("named submatches should give host and port, got %q", got)
+	}
+
+	m = MustCompileFieldMatcher("ReqHeader", `^Cookie:`)
+	if m.Match(e) || m.Submatch(e) != nil || m.AllSubmatches(e) != nil || m.Named(e) != nil {
+		t.Errorf("matcher %v should not match", m.Re)
+	}
+	if _, err := CompileFieldMatcher("ReqHeader", `(`); err == nil {
+		t.Errorf("compiling invalid regular expression should fail")
+	} else {
+		t.Logf("compiling invalid regular expression gives: %v", err)
+	}
+}
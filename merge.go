@@ -0,0 +1,103 @@
+package vslparser
+
+import (
+	"container/heap"
+	"github.com/pkg/errors"
+	"io"
+	"iter"
+	"time"
+)
+
+// Source is a named parser of a capture, e.g. of one node of a fleet of
+// Varnish servers.
+type Source struct {
+	Name   string  // Name of the source, e.g. the host name of the node.
+	Parser *Parser // Parser of the entries of the source.
+}
+
+// SourceEntry is an entry tagged with the name of the source it was parsed
+// from.
+type SourceEntry struct {
+	Source string
+	*Entry
+}
+
+// mergeHead is the next entry of a source to be merged.
+type mergeHead struct {
+	index int       // Index of the source.
+	entry *Entry    // Next entry of the source.
+	time  time.Time // Time of the entry, see Merge.
+}
+
+// mergeHeap orders the next entries of sources by time and then by the order
+// of the sources.
+type mergeHeap []*mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].index < h[j].index
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x any) { *h = append(*h, x.(*mergeHead)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Merge returns an iterator over the entries of the given sources merged in
+// the order of time, each tagged with its source. Since varnishlog outputs
+// transactions when they end, entries are ordered by their EndTime; entries
+// without one, e.g. of records which belong to no transaction, take the time
+// of the previous entry of their source, so that they keep their place. Each
+// source is expected to be ordered the same, which holds for captures of
+// varnishlog, so that entries of the sources can be merged as they are read.
+//
+// Iteration stops once all sources are exhausted, or after the first error,
+// which is yielded along with a zero SourceEntry and reports the name of its
+// source.
+func Merge(sources ...Source) iter.Seq2[SourceEntry, error] {
+	return func(yield func(SourceEntry, error) bool) {
+		h := make(mergeHeap, 0, len(sources))
+		last := make([]time.Time, len(sources))
+		// next reads the next entry of source i onto the heap.
+		next := func(i int) error {
+			e, err := sources[i].Parser.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrapf(err, "cannot parse source %q", sources[i].Name)
+			}
+			if t, err := e.EndTime(); err == nil {
+				last[i] = t
+			}
+			heap.Push(&h, &mergeHead{index: i, entry: e, time: last[i]})
+			return nil
+		}
+		for i := range sources {
+			if err := next(i); err != nil {
+				yield(SourceEntry{}, err)
+				return
+			}
+		}
+		for h.Len() > 0 {
+			head := heap.Pop(&h).(*mergeHead)
+			if !yield(SourceEntry{Source: sources[head.index].Name, Entry: head.entry}, nil) {
+				return
+			}
+			if err := next(head.index); err != nil {
+				yield(SourceEntry{}, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package vslparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMerge tests that entries of several sources are merged by their end
+// times, that entries without times keep their place and that errors report
+// their source.
+func TestMerge(t *testing.T) {
+	a := `
+*   << Request  >> 2
+-   Timestamp      Start: 1545037990.000000 0.000000 0.000000
+-   Timestamp      Resp: 1545037991.000000 1.000000 1.000000
+-   End
+*   << Record   >> 0
+-   CLI            Rd ping
+-   End
+*   << Request  >> 4
+-   Timestamp      Start: 1545037992.000000 0.000000 0.000000
+-   Timestamp      Resp: 1545037994.000000 2.000000 2.000000
+-   End
+`
+	b := `
+*   << Request  >> 3
+-   Timestamp      Start: 1545037990.500000 0.000000 0.000000
+-   Timestamp      Resp: 1545037991.000000 0.500000 0.500000
+-   End
+*   << BeReq    >> 5
+-   Timestamp      Start: 1545037992.000000 0.000000 0.000000
+-   Timestamp      BerespBody: 1545037993.000000 1.000000 1.000000
+-   End
+`
+	type tagged struct {
+		Source string
+		VXID   uint64
+	}
+	var got []tagged
+	for e, err := range Merge(
+		Source{Name: "a", Parser: NewParser(strings.NewReader(a))},
+		Source{Name: "b", Parser: NewParser(strings.NewReader(b))},
+	) {
+		if err != nil {
+			t.Fatalf("merging should not fail, got: %v", err)
+		}
+		got = append(got, tagged{e.Source, e.VXID})
+	}
+	want := []tagged{{"a", 2}, {"a", 0}, {"b", 3}, {"b", 5}, {"a", 4}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("merging should give %v, got %v", want, got)
+	}
+
+	n := 0
+	for _, err := range Merge(
+		Source{Name: "a", Parser: NewParser(strings.NewReader(a))},
+		Source{Name: "broken", Parser: NewParser(strings.NewReader(b + "* << Request >> 6\n- End\nfoo"))},
+	) {
+		if err != nil {
+			if !strings.Contains(err.Error(), `"broken"`) {
+				t.Errorf("merging error should report its source, got: %v", err)
+			}
+			t.Logf("merging broken source gives: %v", err)
+			break
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("merging should give 5 entries before the error, got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package vslparser
+
+// The methods in this file change the records of an entry, keeping Fields and
+// Records consistent. Records are authoritative: if an entry has any, Fields
+// are rebuilt from them after a change, otherwise only Fields are changed.
+
+// Set replaces all records with the given tag by a single record with the
+// given value, placed where the first of them was, or appended if there was
+// none. It returns the entry.
+func (e *Entry) Set(tag, value string) *Entry {
+	if len(e.Records) == 0 {
+		if len(e.Fields) == 0 {
+			return e.Add(tag, value)
+		}
+		e.Fields[tag] = []string{value}
+		return e
+	}
+	found := false
+	e.Edit(func(t, v string) (string, bool) {
+		if t != tag {
+			return v, true
+		}
+		if found {
+			return "", false
+		}
+		found = true
+		return value, true
+	})
+	if !found {
+		e.add(tag, value)
+	}
+	return e
+}
+
+// Delete removes all records with the given tag. It returns the entry.
+func (e *Entry) Delete(tag string) *Entry {
+	return e.Edit(func(t, v string) (string, bool) {
+		return v, t != tag
+	})
+}
+
+// Edit calls fn for each record of the entry, in order, and replaces the value
+// of the record with the returned value, or removes the record if fn returns
+// false. It returns the entry.
+func (e *Entry) Edit(fn func(tag, value string) (string, bool)) *Entry {
+	if len(e.Records) == 0 {
+		for tag, vs := range e.Fields {
+			kept := vs[:0]
+			for _, v := range vs {
+				if v, ok := fn(tag, v); ok {
+					kept = append(kept, v)
+				}
+			}
+			if len(kept) == 0 {
+				delete(e.Fields, tag)
+			} else {
+				e.Fields[tag] = kept
+			}
+		}
+		return e
+	}
+	recs := e.Records[:0]
+	for _, r := range e.Records {
+		if v, ok := fn(r.Tag, r.Value); ok {
+			recs = append(recs, Record{Tag: r.Tag, Value: v})
+		}
+	}
+	clear(e.Records[len(recs):])
+	e.Records = recs
+	if e.Fields == nil {
+		e.Fields = Fields{}
+	}
+	clear(e.Fields)
+	for _, r := range e.Records {
+		e.Fields[r.Tag] = append(e.Fields[r.Tag], r.Value)
+	}
+	return e
+}
@@ -0,0 +1,70 @@
+package vslparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mutateSample is a sample entry changed by the tests below.
+const mutateSample = "* << Request >> 2\n- ReqURL /foo\n- ReqHeader Host: example.com\n" +
+	"- ReqURL /bar\n- ReqHeader Accept: */*\n- End\n"
+
+// testMutate tests that fn changes the records of the sample entry to want
+// and that the fields are consistent with them.
+func testMutate(t *testing.T, name string, fn func(e *Entry), want Records) {
+	e, err := Parse(stringScanner(mutateSample))
+	if err != nil {
+		t.Fatalf("failed to parse sample entry: %v", err)
+	}
+	fn(e)
+	if !reflect.DeepEqual(want, e.Records) {
+		t.Errorf("%s should give records %v, got %v", name, want, e.Records)
+	}
+	if fs := want.Fields(); !reflect.DeepEqual(fs, e.Fields) {
+		t.Errorf("%s should give fields %v, got %v", name, fs, e.Fields)
+	}
+}
+
+// TestMutate tests that records are set, deleted and edited in place.
+func TestMutate(t *testing.T) {
+	testMutate(t, "setting ReqURL", func(e *Entry) { e.Set("ReqURL", "/baz") }, Records{
+		{"ReqURL", "/baz"},
+		{"ReqHeader", "Host: example.com"},
+		{"ReqHeader", "Accept: */*"},
+	})
+	testMutate(t, "setting RespStatus", func(e *Entry) { e.Set("RespStatus", "200") }, Records{
+		{"ReqURL", "/foo"},
+		{"ReqHeader", "Host: example.com"},
+		{"ReqURL", "/bar"},
+		{"ReqHeader", "Accept: */*"},
+		{"RespStatus", "200"},
+	})
+	testMutate(t, "deleting ReqHeader", func(e *Entry) { e.Delete("ReqHeader").Delete("Missing") }, Records{
+		{"ReqURL", "/foo"},
+		{"ReqURL", "/bar"},
+	})
+	testMutate(t, "editing records", func(e *Entry) {
+		e.Edit(func(tag, v string) (string, bool) {
+			return strings.ToUpper(v), tag != "ReqURL" || v != "/foo"
+		})
+	}, Records{
+		{"ReqHeader", "HOST: EXAMPLE.COM"},
+		{"ReqURL", "/BAR"},
+		{"ReqHeader", "ACCEPT: */*"},
+	})
+	testMutate(t, "deleting all records", func(e *Entry) {
+		e.Edit(func(tag, v string) (string, bool) { return v, false })
+	}, Records{})
+
+	// Entries without records only have their fields changed.
+	e := &Entry{Fields: Fields{"ReqURL": []string{"/foo", "/bar"}, "ReqHeader": []string{"Host: example.com"}}}
+	e.Set("ReqURL", "/baz").Set("RespStatus", "200").Delete("ReqHeader")
+	want := Fields{"ReqURL": []string{"/baz"}, "RespStatus": []string{"200"}}
+	if !reflect.DeepEqual(want, e.Fields) || e.Records != nil {
+		t.Errorf("changing entry without records should give fields %v, got %v", want, e.Fields)
+	}
+	if e := (&Entry{}).Set("ReqURL", "/"); !reflect.DeepEqual(Records{{"ReqURL", "/"}}, e.Records) {
+		t.Errorf("setting field of an empty entry should add a record, got %v", e.Records)
+	}
+}
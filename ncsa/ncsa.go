@@ -0,0 +1,209 @@
+// Package ncsa parses the output of varnishncsa, the NCSA-style access log of
+// Varnish, in the default as well as custom formats.
+package ncsa
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFormat is the default log format of varnishncsa.
+const DefaultFormat = `%h %l %u %t "%r" %s %b "%{Referer}i" "%{User-agent}i"`
+
+// timeLayout is the layout of time-stamps produced by the %t directive.
+const timeLayout = "[02/Jan/2006:15:04:05 -0700]"
+
+// Record holds a single parsed varnishncsa log line. The well-known
+// directives are converted into the typed fields, if present in the format.
+// The raw values of all directives, including the well-known ones, are kept in
+// Values, keyed by the directive as it appears in the format, e.g. "%h" or
+// "%{X-Forwarded-For}i".
+type Record struct {
+	RemoteHost string        // %h
+	Ident      string        // %l
+	RemoteUser string        // %u
+	Time       time.Time     // %t
+	Method     string        // %m, or from %r
+	URL        string        // %U%q, or from %r
+	Protocol   string        // %H, or from %r
+	Status     int           // %s
+	Bytes      int64         // %b, zero if "-"
+	Duration   time.Duration // %D, or %T if %D is missing
+	Referer    string        // %{Referer}i
+	UserAgent  string        // %{User-agent}i
+	Values     map[string]string
+}
+
+// token is a single element of a compiled format, either a literal or a
+// directive.
+type token struct {
+	literal   string
+	directive string
+}
+
+// Format is a compiled varnishncsa log format.
+type Format struct {
+	tokens []token
+}
+
+// Compile compiles the varnishncsa log format f, using the syntax of the -F
+// option of varnishncsa. Two directives may only follow each other without a
+// literal in between if they are %U and %q, since their values could not be
+// told apart otherwise.
+func Compile(f string) (*Format, error) {
+	var tokens []token
+	lit := strings.Builder{}
+	flush := func() {
+		if lit.Len() > 0 {
+			tokens = append(tokens, token{literal: lit.String()})
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			lit.WriteByte(f[i])
+			continue
+		}
+		start := i
+		i++
+		if i < len(f) && f[i] == '%' {
+			lit.WriteByte('%')
+			continue
+		}
+		if i < len(f) && f[i] == '{' {
+			end := strings.IndexByte(f[i:], '}')
+			if end == -1 {
+				return nil, errors.Errorf("unterminated directive at offset %d", start)
+			}
+			i += end + 1
+		}
+		if i >= len(f) {
+			return nil, errors.Errorf("incomplete directive at offset %d", start)
+		}
+		flush()
+		d := f[start : i+1]
+		if n := len(tokens); n > 0 && tokens[n-1].directive != "" &&
+			!(tokens[n-1].directive == "%U" && d == "%q") {
+			return nil, errors.Errorf("directive %s immediately follows directive %s",
+				d, tokens[n-1].directive)
+		}
+		tokens = append(tokens, token{directive: d})
+	}
+	flush()
+	return &Format{tokens: tokens}, nil
+}
+
+// defaultFormat is DefaultFormat compiled.
+var defaultFormat, _ = Compile(DefaultFormat)
+
+// Parse parses a line of varnishncsa output in the default format.
+func Parse(line string) (*Record, error) {
+	return defaultFormat.Parse(line)
+}
+
+// Parse parses a line of varnishncsa output in the format f. The value of a
+// directive extends up to the first occurrence of the literal which follows
+// it in the format.
+func (f *Format) Parse(line string) (*Record, error) {
+	r := &Record{
+		Values: make(map[string]string, len(f.tokens)),
+	}
+	rest := line
+	for i, t := range f.tokens {
+		if t.literal != "" {
+			if !strings.HasPrefix(rest, t.literal) {
+				return nil, errors.Errorf("line %q does not match format, %q expected", line, t.literal)
+			}
+			rest = rest[len(t.literal):]
+			continue
+		}
+		var v string
+		switch {
+		case i+1 == len(f.tokens):
+			v, rest = rest, ""
+		case f.tokens[i+1].directive == "%q":
+			// %U immediately followed by %q, which starts at the "?" of the
+			// query string, if there is one before the literal following %q.
+			end := len(rest)
+			if i+2 < len(f.tokens) {
+				if end = strings.Index(rest, f.tokens[i+2].literal); end == -1 {
+					return nil, errors.Errorf("line %q does not match format, %q expected", line, f.tokens[i+2].literal)
+				}
+			}
+			if q := strings.IndexByte(rest[:end], '?'); q != -1 {
+				end = q
+			}
+			v, rest = rest[:end], rest[end:]
+		default:
+			end := strings.Index(rest, f.tokens[i+1].literal)
+			if end == -1 {
+				return nil, errors.Errorf("line %q does not match format, %q expected", line, f.tokens[i+1].literal)
+			}
+			v, rest = rest[:end], rest[end:]
+		}
+		if err := r.set(t.directive, v); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s in line %q", t.directive, line)
+		}
+	}
+	if rest != "" {
+		return nil, errors.Errorf("line %q does not match format, trailing %q", line, rest)
+	}
+	return r, nil
+}
+
+// set stores the value v of the directive d in the record.
+func (r *Record) set(d, v string) error {
+	r.Values[d] = v
+	var err error
+	switch d {
+	case "%h":
+		r.RemoteHost = v
+	case "%l":
+		r.Ident = v
+	case "%u":
+		r.RemoteUser = v
+	case "%t":
+		r.Time, err = time.Parse(timeLayout, v)
+	case "%r":
+		fs := strings.Fields(v)
+		if len(fs) != 3 {
+			return errors.Errorf("malformed request line %q", v)
+		}
+		r.Method, r.URL, r.Protocol = fs[0], fs[1], fs[2]
+	case "%m":
+		r.Method = v
+	case "%U":
+		r.URL = v + r.URL
+	case "%q":
+		r.URL += v
+	case "%H":
+		r.Protocol = v
+	case "%s":
+		r.Status, err = strconv.Atoi(v)
+	case "%b":
+		if v != "-" {
+			r.Bytes, err = strconv.ParseInt(v, 10, 64)
+		}
+	case "%D":
+		var us int64
+		us, err = strconv.ParseInt(v, 10, 64)
+		r.Duration = time.Duration(us) * time.Microsecond
+	case "%T":
+		if _, ok := r.Values["%D"]; !ok {
+			var s int64
+			s, err = strconv.ParseInt(v, 10, 64)
+			r.Duration = time.Duration(s) * time.Second
+		}
+	default:
+		// Header names are case-insensitive.
+		switch strings.ToLower(d) {
+		case "%{referer}i":
+			r.Referer = v
+		case "%{user-agent}i":
+			r.UserAgent = v
+		}
+	}
+	return err
+}
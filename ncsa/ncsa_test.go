@@ -0,0 +1,106 @@
+package ncsa
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParse tests that lines in the default format are parsed correctly and
+// that malformed lines produce errors.
+func TestParse(t *testing.T) {
+	line := `192.168.1.1 - frank [17/Dec/2018:09:13:18 +0000] "GET http://example.com/health?x=1 HTTP/1.1" 200 2 "-" "curl/7.58.0"`
+	r, err := Parse(line)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", line, err)
+	}
+	if r.RemoteHost != "192.168.1.1" || r.Ident != "-" || r.RemoteUser != "frank" {
+		t.Errorf("parsing %q gives wrong host/ident/user: %q %q %q", line, r.RemoteHost, r.Ident, r.RemoteUser)
+	}
+	if want := time.Date(2018, 12, 17, 9, 13, 18, 0, time.UTC); !r.Time.Equal(want) {
+		t.Errorf("parsing %q should give time %v, got %v", line, want, r.Time)
+	}
+	if r.Method != "GET" || r.URL != "http://example.com/health?x=1" || r.Protocol != "HTTP/1.1" {
+		t.Errorf("parsing %q gives wrong request line: %q %q %q", line, r.Method, r.URL, r.Protocol)
+	}
+	if r.Status != 200 || r.Bytes != 2 {
+		t.Errorf("parsing %q gives wrong status/bytes: %d %d", line, r.Status, r.Bytes)
+	}
+	if r.Referer != "-" || r.UserAgent != "curl/7.58.0" {
+		t.Errorf("parsing %q gives wrong referer/user agent: %q %q", line, r.Referer, r.UserAgent)
+	}
+
+	bad := []string{
+		"",
+		`192.168.1.1 - - [17/Dec/2018:09:13:18 +0000] "GET /" 200 2 "-" "curl"`,
+		`192.168.1.1 - - [yesterday] "GET / HTTP/1.1" 200 2 "-" "curl"`,
+		`192.168.1.1 - - [17/Dec/2018:09:13:18 +0000] "GET / HTTP/1.1" OK 2 "-" "curl"`,
+		`192.168.1.1 - - [17/Dec/2018:09:13:18 +0000] "GET / HTTP/1.1" 200 2`,
+	}
+	for _, line := range bad {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("parsing %q should fail", line)
+		} else {
+			t.Logf("parsing %q gives: %v", line, err)
+		}
+	}
+}
+
+// TestCustomFormat tests that custom formats are compiled and parsed.
+func TestCustomFormat(t *testing.T) {
+	f, err := Compile(`%h %m %U%q %H %s %b %D "%{X-Forwarded-For}i" %{Varnish:handling}x 100%%`)
+	if err != nil {
+		t.Fatalf("failed to compile format: %v", err)
+	}
+	line := "::1 POST /foo?bar=baz HTTP/2.0 503 - 1500 \"10.0.0.1, 10.0.0.2\" miss 100%"
+	r, err := f.Parse(line)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", line, err)
+	}
+	if r.URL != "/foo?bar=baz" || r.Method != "POST" || r.Protocol != "HTTP/2.0" {
+		t.Errorf("parsing %q gives wrong request: %q %q %q", line, r.Method, r.URL, r.Protocol)
+	}
+	if r.Status != 503 || r.Bytes != 0 || r.Duration != 1500*time.Microsecond {
+		t.Errorf("parsing %q gives wrong status/bytes/duration: %d %d %v", line, r.Status, r.Bytes, r.Duration)
+	}
+	if v := r.Values["%{X-Forwarded-For}i"]; v != "10.0.0.1, 10.0.0.2" {
+		t.Errorf("parsing %q gives wrong X-Forwarded-For: %q", line, v)
+	}
+	if v := r.Values["%{Varnish:handling}x"]; v != "miss" {
+		t.Errorf("parsing %q gives wrong handling: %q", line, v)
+	}
+
+	r, err = f.Parse("::1 GET /foo HTTP/1.1 200 5 10 \"-\" hit 100%")
+	if err != nil || r.URL != "/foo" {
+		t.Errorf("parsing a URL without query should give /foo, got %v (%v)", r, err)
+	}
+
+	// A "?" in a later field is not part of the query string.
+	f, err = Compile(`%U%q "%{Referer}i"`)
+	if err != nil {
+		t.Fatalf("failed to compile format: %v", err)
+	}
+	r, err = f.Parse(`/foo "http://example.com/?a=b"`)
+	if err != nil || r.URL != "/foo" || r.Referer != "http://example.com/?a=b" {
+		t.Errorf("parsing a URL without query before a Referer with query should give /foo, got %v (%v)", r, err)
+	}
+	r, err = f.Parse(`/foo?x=1 "http://example.com/?a=b"`)
+	if err != nil || r.URL != "/foo?x=1" || r.Referer != "http://example.com/?a=b" {
+		t.Errorf("parsing a URL with query before a Referer with query should give /foo?x=1, got %v (%v)", r, err)
+	}
+	if r, err := f.Parse("/foo?x=1"); err == nil {
+		t.Errorf("parsing a line missing the literal following %%q should fail, got %v", r)
+	}
+
+	badFormats := []string{
+		"%h%u",
+		"%{Referer",
+		"%",
+	}
+	for _, f := range badFormats {
+		if _, err := Compile(f); err == nil {
+			t.Errorf("compiling %q should fail", f)
+		} else {
+			t.Logf("compiling %q gives: %v", f, err)
+		}
+	}
+}
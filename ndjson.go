@@ -0,0 +1,228 @@
+package vslparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// jsonFields are the names of the members of the JSON representation of
+// entries, see Entry.MarshalJSON.
+var jsonFields = []string{
+	"kind", "vxid", "level", "records", "truncated", "incomplete",
+	"headers", "timestamps", "children",
+}
+
+// NDJSONWriter writes entries as newline-delimited JSON, one JSON object per
+// line as marshaled by Entry.MarshalJSON, e.g. for log shippers reading
+// files or pipes. Trees are written as single objects with their nested
+// transactions as children, unless Flatten is set.
+//
+// Lines are buffered and flushed when the buffer is full, at the latest
+// FlushInterval after a line was buffered, and by Flush and Close. Once
+// writing fails, e.g. in a flush after FlushInterval, the error is returned
+// by all later calls of Write, Flush and Close. The writer implements
+// pipeline.Sink and is safe for concurrent use, but its options must not be
+// changed once in use.
+type NDJSONWriter struct {
+	// Flatten makes the writer write each transaction of a tree on a line
+	// of its own, without children, in the order of Entry.Walk.
+	Flatten bool
+	// FlushInterval is the longest time lines are held in the buffer. If it
+	// is not positive, every entry is flushed once written.
+	FlushInterval time.Duration
+	// MaxLineSize is the maximum size of a line in bytes, excluding the
+	// newline, if positive. Lines exceeding it, e.g. those of requests with
+	// huge headers, are not written but counted, see Skipped, since many log
+	// shippers reject or split such lines.
+	MaxLineSize int
+
+	mu      sync.Mutex
+	w       *bufio.Writer
+	fields  map[string]bool // Selected members, all if nil.
+	timer   *time.Timer     // Pending flush, if any.
+	err     error           // First error writing to w, returned by all later calls.
+	skipped int
+}
+
+// NewNDJSONWriter returns a writer of entries as newline-delimited JSON to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: bufio.NewWriter(w)}
+}
+
+// Select limits the members of the written objects, and of their children,
+// to the given ones, e.g. "kind", "vxid" and "headers"; see
+// Entry.MarshalJSON for their names. The members are written in the order of
+// the schema.
+func (w *NDJSONWriter) Select(fields ...string) error {
+	sel := map[string]bool{}
+	for _, f := range fields {
+		if !slices.Contains(jsonFields, f) {
+			return errors.Errorf("unknown JSON field %q", f)
+		}
+		sel[f] = true
+	}
+	w.fields = sel
+	return nil
+}
+
+// Write writes the entry e, or each transaction of it if Flatten is set.
+func (w *NDJSONWriter) Write(e *Entry) error {
+	var lines [][]byte
+	if w.Flatten {
+		var err error
+		e.Walk(func(t *Entry) bool {
+			c := *t
+			c.Children = nil
+			var b []byte
+			if b, err = w.line(&c); err != nil {
+				return false
+			}
+			lines = append(lines, b)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		b, err := w.line(e)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, b)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	for _, b := range lines {
+		if w.MaxLineSize > 0 && len(b) > w.MaxLineSize {
+			w.skipped++
+			continue
+		}
+		if _, err := w.w.Write(b); err != nil {
+			w.err = errors.Wrap(err, "cannot write entry")
+			return w.err
+		}
+		if err := w.w.WriteByte('\n'); err != nil {
+			w.err = errors.Wrap(err, "cannot write entry")
+			return w.err
+		}
+	}
+	if w.FlushInterval <= 0 {
+		return w.flush()
+	}
+	if w.timer == nil && w.w.Buffered() > 0 {
+		w.timer = time.AfterFunc(w.FlushInterval, func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.timer = nil
+			// The error is kept for the next call.
+			w.flush()
+		})
+	}
+	return nil
+}
+
+// line returns the entry e marshaled with the selected members.
+func (w *NDJSONWriter) line(e *Entry) ([]byte, error) {
+	b, err := e.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal entry %d", e.VXID)
+	}
+	if w.fields == nil {
+		return b, nil
+	}
+	return selectJSON(b, w.fields)
+}
+
+// selectJSON returns the JSON object b of an entry with the selected members
+// only, including those of its children.
+func selectJSON(b []byte, fields map[string]bool) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Token(); err != nil {
+		return nil, errors.Wrap(err, "cannot select JSON fields")
+	}
+	out := []byte{'{'}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot select JSON fields")
+		}
+		var v json.RawMessage
+		if err := d.Decode(&v); err != nil {
+			return nil, errors.Wrap(err, "cannot select JSON fields")
+		}
+		k, _ := t.(string)
+		if !fields[k] {
+			continue
+		}
+		if k == "children" {
+			var children []json.RawMessage
+			if err := json.Unmarshal(v, &children); err != nil {
+				return nil, errors.Wrap(err, "cannot select JSON fields")
+			}
+			for i, c := range children {
+				if children[i], err = selectJSON(c, fields); err != nil {
+					return nil, err
+				}
+			}
+			if v, err = json.Marshal(children); err != nil {
+				return nil, errors.Wrap(err, "cannot select JSON fields")
+			}
+		}
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(out, '"')
+		out = append(out, k...)
+		out = append(out, '"', ':')
+		out = append(out, v...)
+	}
+	return append(out, '}'), nil
+}
+
+// flush flushes the buffered lines, keeping the first error for all later
+// calls. The caller must hold w.mu.
+func (w *NDJSONWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.w.Flush(); err != nil {
+		w.err = errors.Wrap(err, "cannot flush entries")
+	}
+	return w.err
+}
+
+// Flush writes the buffered lines to the underlying writer.
+func (w *NDJSONWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// Skipped returns the number of lines not written because they exceeded
+// MaxLineSize.
+func (w *NDJSONWriter) Skipped() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.skipped
+}
+
+// Close stops the pending flush, if any, and flushes the buffered lines. It
+// does not close the underlying writer.
+func (w *NDJSONWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	return w.flush()
+}
@@ -0,0 +1,173 @@
+package vslparser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// TestNDJSONWriter tests that entries and trees are written one per line
+// with the selected fields, and that lines exceeding the size limit are
+// skipped.
+func TestNDJSONWriter(t *testing.T) {
+	tree := NewEntry(Request, 2).
+		Add("ReqURL", "/").
+		AddChild(NewEntry(BeReq, 3).Add("BereqURL", "/"))
+	samples := []struct {
+		Name    string
+		Flatten bool
+		Fields  []string
+		Want    string
+	}{
+		{
+			"tree", false, nil,
+			`{"kind":"Request","vxid":2,"level":1,"records":[{"tag":"ReqURL","value":"/"}],"children":[{"kind":"BeReq","vxid":3,"level":2,"records":[{"tag":"BereqURL","value":"/"}]}]}` + "\n" +
+				`{"kind":"Session","vxid":1,"level":1,"records":[]}` + "\n",
+		},
+		{
+			"flatten", true, nil,
+			`{"kind":"Request","vxid":2,"level":1,"records":[{"tag":"ReqURL","value":"/"}]}` + "\n" +
+				`{"kind":"BeReq","vxid":3,"level":2,"records":[{"tag":"BereqURL","value":"/"}]}` + "\n" +
+				`{"kind":"Session","vxid":1,"level":1,"records":[]}` + "\n",
+		},
+		{
+			"select", false, []string{"children", "vxid"},
+			`{"vxid":2,"children":[{"vxid":3}]}` + "\n" + `{"vxid":1}` + "\n",
+		},
+		{
+			"flatten select", true, []string{"kind"},
+			`{"kind":"Request"}` + "\n" + `{"kind":"BeReq"}` + "\n" + `{"kind":"Session"}` + "\n",
+		},
+	}
+	for _, s := range samples {
+		var b strings.Builder
+		w := NewNDJSONWriter(&b)
+		w.Flatten = s.Flatten
+		if s.Fields != nil {
+			if err := w.Select(s.Fields...); err != nil {
+				t.Fatalf("selecting fields %v should not fail, got: %v", s.Fields, err)
+			}
+		}
+		for _, e := range []*Entry{tree, NewEntry(Session, 1)} {
+			if err := w.Write(e); err != nil {
+				t.Errorf("writing %s sample should not fail, got: %v", s.Name, err)
+			}
+		}
+		if got := b.String(); got != s.Want {
+			t.Errorf("writing %s sample should give %q, got %q", s.Name, s.Want, got)
+		}
+	}
+
+	var b strings.Builder
+	w := NewNDJSONWriter(&b)
+	w.Flatten = true
+	w.MaxLineSize = len(`{"kind":"BeReq","vxid":3,"level":2,"records":[{"tag":"BereqURL","value":"/"}]}`)
+	if err := w.Write(tree.Clone().Add("ReqHeader", "Cookie: a=b")); err != nil {
+		t.Errorf("writing entry exceeding the size limit should not fail, got: %v", err)
+	}
+	if got := b.String(); w.Skipped() != 1 || got != `{"kind":"BeReq","vxid":3,"level":2,"records":[{"tag":"BereqURL","value":"/"}]}`+"\n" {
+		t.Errorf("writing with size limit should skip 1 line, skipped %d giving %q", w.Skipped(), got)
+	}
+
+	if err := w.Select("kind", "url"); err == nil {
+		t.Errorf("selecting unknown field should fail")
+	} else {
+		t.Logf("selecting unknown field gives: %v", err)
+	}
+}
+
+// TestNDJSONWriterFlush tests that buffered lines are flushed after the flush
+// interval and on Close.
+func TestNDJSONWriterFlush(t *testing.T) {
+	var b syncBuffer
+	w := NewNDJSONWriter(&b)
+	w.FlushInterval = 10 * time.Millisecond
+	if err := w.Write(NewEntry(Session, 1)); err != nil {
+		t.Fatalf("writing entry should not fail, got: %v", err)
+	}
+	if got := b.String(); got != "" {
+		t.Errorf("entry should be buffered, got %q", got)
+	}
+	for deadline := time.Now().Add(5 * time.Second); b.String() == "" && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := b.String(); got != `{"kind":"Session","vxid":1,"level":1,"records":[]}`+"\n" {
+		t.Errorf("entry should be flushed after the flush interval, got %q", got)
+	}
+
+	var c syncBuffer
+	w = NewNDJSONWriter(&c)
+	w.FlushInterval = time.Hour
+	if err := w.Write(NewEntry(Session, 2)); err != nil {
+		t.Fatalf("writing entry should not fail, got: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("closing writer should not fail, got: %v", err)
+	}
+	if got := c.String(); got != `{"kind":"Session","vxid":2,"level":1,"records":[]}`+"\n" {
+		t.Errorf("entry should be flushed on close, got %q", got)
+	}
+}
+
+// failingWriter is an io.Writer which always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// TestNDJSONWriterErrors tests that an error of a flush after the flush
+// interval is returned by the following calls.
+func TestNDJSONWriterErrors(t *testing.T) {
+	w := NewNDJSONWriter(failingWriter{})
+	w.FlushInterval = time.Millisecond
+	if err := w.Write(NewEntry(Session, 1)); err != nil {
+		t.Fatalf("writing buffered entry should not fail, got: %v", err)
+	}
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); err == nil && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		w.mu.Lock()
+		err = w.err
+		w.mu.Unlock()
+	}
+	if err == nil {
+		t.Fatalf("flushing after the flush interval should fail")
+	}
+	t.Logf("flushing after the flush interval gives: %v", err)
+	if err := w.Write(NewEntry(Session, 2)); err == nil {
+		t.Errorf("writing after a failed flush should fail")
+	}
+	if err := w.Flush(); err == nil {
+		t.Errorf("flushing after a failed flush should fail")
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("closing after a failed flush should fail")
+	}
+
+	w = NewNDJSONWriter(failingWriter{})
+	if err := w.Write(NewEntry(Session, 1)); err == nil {
+		t.Errorf("writing unbuffered entry should fail")
+	}
+}
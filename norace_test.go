@@ -0,0 +1,7 @@
+//go:build !race
+
+package vslparser
+
+// raceEnabled is set when the tests are built with the race detector, which
+// makes allocation counts unreliable.
+const raceEnabled = false
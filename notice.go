@@ -0,0 +1,61 @@
+package vslparser
+
+import (
+	"strings"
+)
+
+// Notice represents a Notice record, which carries an informational message
+// about an unusual situation, prefixed by its source, e.g. "core" or the name
+// of a VMOD:
+//
+//	vmod_example: deprecated function called
+type Notice struct {
+	Source  string // Source of the notice, empty if not prefixed.
+	Message string // Message of the notice.
+}
+
+// parseNotice parses the value of a Notice record.
+func parseNotice(v string) *Notice {
+	src, msg, ok := strings.Cut(v, ":")
+	if !ok || src == "" || strings.ContainsAny(src, " \t") {
+		return &Notice{Message: v}
+	}
+	return &Notice{Source: src, Message: strings.TrimSpace(msg)}
+}
+
+// Notices returns the Notice records of the log entry, in the order in which
+// they appear.
+func (e *Entry) Notices() []*Notice {
+	fs := e.Fields["Notice"]
+	ns := make([]*Notice, 0, len(fs))
+	for _, v := range fs {
+		ns = append(ns, parseNotice(v))
+	}
+	return ns
+}
+
+// anomalyTags are the tags of records reporting malformed HTTP.
+var anomalyTags = []string{"BogoHeader", "LostHeader", "HttpGarbage"}
+
+// Anomaly represents a record reporting malformed HTTP received or produced
+// by a transaction.
+type Anomaly struct {
+	// Tag of the record: "BogoHeader" for malformed headers, "LostHeader"
+	// for headers which did not fit, or "HttpGarbage" for unparsable data.
+	Tag   string
+	Value string // Value of the record, e.g. the offending header.
+}
+
+// ProtocolAnomalies returns the records of the log entry which report
+// malformed HTTP, grouped by tag in the order BogoHeader, LostHeader and
+// HttpGarbage, and in the order in which they appear within each group. A
+// transaction without anomalies has an empty list.
+func (e *Entry) ProtocolAnomalies() []Anomaly {
+	var as []Anomaly
+	for _, tag := range anomalyTags {
+		for _, v := range e.Fields[tag] {
+			as = append(as, Anomaly{Tag: tag, Value: v})
+		}
+	}
+	return as
+}
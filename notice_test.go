@@ -0,0 +1,51 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNotices tests that Notice records are collected with their sources
+// split out.
+func TestNotices(t *testing.T) {
+	e := &Entry{Fields: Fields{"Notice": []string{
+		"vmod_example: deprecated function called",
+		"core:Grace object served",
+		"no source here",
+		"Message with a colon: inside",
+	}}}
+	want := []*Notice{
+		&Notice{Source: "vmod_example", Message: "deprecated function called"},
+		&Notice{Source: "core", Message: "Grace object served"},
+		&Notice{Message: "no source here"},
+		&Notice{Message: "Message with a colon: inside"},
+	}
+	if got := e.Notices(); !reflect.DeepEqual(want, got) {
+		t.Errorf("collecting notices should give %v, got %v", want, got)
+	}
+	if got := example().Notices(); len(got) != 0 {
+		t.Errorf("entry without Notice records should have no notices, got %v", got)
+	}
+}
+
+// TestProtocolAnomalies tests that records reporting malformed HTTP are
+// gathered by tag.
+func TestProtocolAnomalies(t *testing.T) {
+	e := &Entry{Fields: Fields{
+		"HttpGarbage": []string{"GET\x01/"},
+		"BogoHeader":  []string{"Illegal char 0x20 in header name", "Header has ctrl char 0x0d"},
+		"LostHeader":  []string{"X-Too-Many: 1"},
+	}}
+	want := []Anomaly{
+		{Tag: "BogoHeader", Value: "Illegal char 0x20 in header name"},
+		{Tag: "BogoHeader", Value: "Header has ctrl char 0x0d"},
+		{Tag: "LostHeader", Value: "X-Too-Many: 1"},
+		{Tag: "HttpGarbage", Value: "GET\x01/"},
+	}
+	if got := e.ProtocolAnomalies(); !reflect.DeepEqual(want, got) {
+		t.Errorf("gathering anomalies should give %v, got %v", want, got)
+	}
+	if got := example().ProtocolAnomalies(); len(got) != 0 {
+		t.Errorf("entry without anomalies should have none, got %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package vslparser
+
+import (
+	"slices"
+)
+
+// Options configure the parsing of varnishlog output. The zero value is the
+// strict default configuration, which expects the format produced by
+// varnishlog in the default VXID grouping and fails on malformed input.
+type Options struct {
+	// MaxLineLength is the maximum length of a log line in bytes. The internal
+	// buffer grows as needed up to this size. If zero, DefaultMaxLineLength is
+	// used. Like KeepCR, it has no effect when reading from a scanner
+	// configured by the caller.
+	MaxLineLength int
+	// KeepCR disables the handling of carriage returns as line terminators.
+	// By default, lines may be terminated by "\n", "\r\n" or a bare "\r",
+	// so that captures which passed through Windows tooling can be parsed. If
+	// KeepCR is set, only "\n" terminates lines and any "\r" is kept as part
+	// of the line.
+	KeepCR bool
+
+	// Grouping selects how transactions are expected to be grouped in the
+	// input, which must match the -g option passed to varnishlog. Transactions
+	// nested in a group are attached to the Children of the entry they are
+	// nested in, and only top-level entries are returned by Next.
+	Grouping Grouping
+
+	// Legacy enables the compatibility mode for logs of Varnish 3.x, whose
+	// varnishlog groups records by file descriptor (varnishlog -o) instead
+	// of printing entry headers, and uses different tag names, e.g. RxURL
+	// instead of ReqURL. The tags are mapped onto their modern equivalents.
+	// Entries in the modern format, which is also used by Varnish 4.0, are
+	// still recognized, so that archives spanning an upgrade can be parsed.
+	// Grouping is ignored for legacy entries.
+	Legacy bool
+
+	// AllowTruncated makes the parser return the entry being parsed when the
+	// input ends before its "End" record, which happens when varnishlog is
+	// killed in the middle of a transaction, instead of failing. Such entries
+	// have Truncated set.
+	AllowTruncated bool
+
+	// Resync enables the lenient mode, in which a parse error does not stop
+	// the parser. Instead, it skips forward to the next top-level entry
+	// header, i.e. a line starting with "* <<", and resumes parsing there.
+	// In the Legacy mode, parsing also resumes after an empty line. Errors
+	// of the underlying reader are still returned.
+	Resync bool
+	// OnSkip, if set, is called in the Resync mode with the lines skipped
+	// due to a parse error, including the lines of the broken entry read
+	// before the error, and the error itself. The lines must not be retained
+	// after OnSkip returns.
+	OnSkip func(lines []string, err error)
+
+	// CanonicalHeaders makes the parser convert the names of headers in
+	// header records, such as ReqHeader or RespUnset, into the canonical
+	// form, see CanonicalizeHeaders.
+	CanonicalHeaders bool
+
+	// StrictTags makes records of tags unknown to this package, see
+	// IsKnownTag, parse errors, e.g. to detect captures from a version of
+	// Varnish with new tags or records mangled by other tools. Records of
+	// legacy entries, many of whose tags have no modern equivalent, are not
+	// checked.
+	StrictTags bool
+
+	// Kinds restricts the parsed entries to the given kinds, e.g. Request
+	// and BeReq. Entries of other kinds are skipped, including the
+	// transactions nested in them. If empty, entries of all kinds are
+	// returned.
+	Kinds []Kind
+	// Filter, if set, restricts the parsed entries to those it matches, see
+	// And, Or and Not to combine filters. It applies to entries of the
+	// selected Kinds, along with the transactions nested in them, after
+	// their records are selected.
+	Filter Filter
+
+	// Records, if set, selects the records kept in parsed entries, see
+	// RecordFilter. Other records are dropped as they are read, before they
+	// are copied out of the buffer of the input, so that they cost no
+	// allocations, except in the Resync mode and in the Legacy format. This
+	// also makes accessors of the dropped records fail. Grouping by the
+	// parser relies on entry headers only and is not affected, unlike e.g. a
+	// TreeBuilder, which needs Begin and Link records.
+	Records *RecordFilter
+}
+
+// keep returns whether the record with the given tag and value should be kept
+// according to the options.
+func (o *Options) keep(tag, value string) bool {
+	return o.Records == nil || o.Records.Keep(tag, value)
+}
+
+// accept returns whether the parsed entry e should be returned according to
+// the options.
+func (o *Options) accept(e *Entry) bool {
+	return (len(o.Kinds) == 0 || slices.Contains(o.Kinds, e.Kind)) && (o.Filter == nil || o.Filter.Match(e))
+}
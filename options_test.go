@@ -0,0 +1,69 @@
+package vslparser
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOptions tests that parsers created with options honour them.
+func TestOptions(t *testing.T) {
+	s := `* << Session >> 1
+- Begin sess 0 HTTP/1
+- End
+
+* << Request >> 2
+- ReqURL /foo
+- End
+
+* << BeReq >> 3
+- BereqURL /foo
+- End
+`
+	p := NewParserWithOptions(strings.NewReader(s), Options{Kinds: []Kind{Request, BeReq}})
+	var vxids []uint64
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("p.Next() should not fail, got: %v", err)
+		}
+		vxids = append(vxids, e.VXID)
+	}
+	if !reflect.DeepEqual(vxids, []uint64{2, 3}) {
+		t.Errorf("parsing %q restricted to requests should give VXIDs [2 3], got %v", s, vxids)
+	}
+
+	e, err := ParseWithOptions(stringScanner(s), Options{Kinds: []Kind{BeReq}})
+	if err != nil || e.VXID != 3 {
+		t.Errorf("parsing %q restricted to backend requests should give VXID 3, got %v (%v)", s, e, err)
+	}
+
+	e, err = ParseWithOptions(stringScanner(s), Options{Filter: Not(HasHeader("Req", "Host"))})
+	if err != nil || e.VXID != 1 {
+		t.Errorf("parsing %q restricted to entries without host should give VXID 1, got %v (%v)", s, e, err)
+	}
+	filter := Or(MustCompileQuery("BereqURL"), FilterFunc(func(e *Entry) bool { return e.Kind == Session }))
+	p = NewParserWithOptions(strings.NewReader(s), Options{Kinds: []Kind{Request, BeReq}, Filter: filter})
+	if e, err := p.Next(); err != nil || e.VXID != 3 {
+		t.Errorf("parsing %q restricted to requests and filtered should give VXID 3, got %v (%v)", s, e, err)
+	}
+
+	e, err = ParseWithOptions(stringScanner("* << Request >> 1\n- ReqURL /foo\n"), Options{AllowTruncated: true})
+	if err != nil || !e.Truncated {
+		t.Errorf("parsing a truncated entry should be allowed by the options, got %v (%v)", e, err)
+	}
+
+	s = "* << Request >> 1\n- ReqURL /foo\n- ReqUrl /bar\n- End\n"
+	if _, err := ParseWithOptions(stringScanner(s), Options{StrictTags: true}); err == nil {
+		t.Errorf("parsing %q with strict tags should fail", s)
+	} else {
+		t.Logf("parsing %q with strict tags gives: %v", s, err)
+	}
+	if _, err := ParseWithOptions(stringScanner(s), Options{}); err != nil {
+		t.Errorf("parsing %q should not fail, got: %v", s, err)
+	}
+}
@@ -2,17 +2,36 @@ package vslparser
 
 import (
 	"bufio"
+	"bytes"
 	"github.com/pkg/errors"
 	"io"
 	"strconv"
 	"strings"
 )
 
+// Grouping describes how transactions are grouped in varnishlog output, see
+// the -g option of varnishlog.
+type Grouping int
+
 const (
-	Request = "Request"
-	BeReq   = "BeReq"
+	// GroupVXID is the default grouping, where each transaction is output
+	// separately.
+	GroupVXID Grouping = iota
+	// GroupSession groups all transactions belonging to a client session,
+	// i.e. the session, its requests and their backend requests and ESI
+	// subrequests, nested in this order.
+	GroupSession
+	// GroupRequest groups client requests with their backend requests and
+	// ESI subrequests.
+	GroupRequest
 )
 
+// DefaultMaxLineLength is the default maximum length of a log line accepted by
+// a Parser which owns its reader. It is well above the default limit of
+// bufio.Scanner, since lines carrying large headers or VCL_Log payloads
+// routinely exceed it.
+const DefaultMaxLineLength = 1024 * 1024
+
 // white returns whether the byte b is considered a whitespace character for
 // the purpose of parsing of the log.
 func white(b byte) bool {
@@ -21,7 +40,7 @@ func white(b byte) bool {
 
 // splitLine splits the log line s into a key and value component efficiently
 // on white-space boundaries.
-func splitLine(s string) (string, string) {
+func splitLine[S string | []byte](s S) (S, S) {
 	l := len(s)
 	ks := 0
 	for ks < l {
@@ -47,6 +66,156 @@ func splitLine(s string) (string, string) {
 	return s[ks:ke], s[vs:]
 }
 
+// digits returns whether s is a non-empty string of decimal digits.
+func digits[S string | []byte](s S) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// marker returns whether s is a valid client/backend marker of a record of
+// verbose varnishlog output: "c" for client, "b" for backend or "-" for
+// neither.
+func marker[S string | []byte](s S) bool {
+	return len(s) == 1 && (s[0] == 'c' || s[0] == 'b' || s[0] == '-')
+}
+
+// splitVerbose splits the remainder of a record line of verbose varnishlog
+// output, which follows the VXID, into the tag, the client/backend marker and
+// the value components, e.g.:
+//
+//	ReqURL         c /health
+//
+// ok is false if the marker is missing or invalid.
+func splitVerbose[S string | []byte](s S) (tag, mark, value S, ok bool) {
+	tag, rest := splitLine(s)
+	mark, value = splitLine(rest)
+	return tag, mark, value, len(tag) > 0 && marker(mark)
+}
+
+// ParseError is returned by a Parser when an entry cannot be parsed. It
+// carries the part of the entry parsed before the error, which is useful for
+// showing as much of a broken transaction as possible.
+type ParseError struct {
+	Line  int    // Number of the line at which parsing failed.
+	Entry *Entry // Partially parsed entry, including its nested transactions.
+	Err   error  // The underlying error.
+}
+
+// Error returns the message of the underlying error.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parser reads log entries from varnishlog output one at a time. Unlike
+// Parse, a Parser owns the buffering of its input and keeps it between
+// entries, which makes it suitable for long-running consumers tailing the
+// output of varnishlog.
+//
+// The embedded Options configure the parser. They must be set before the
+// first entry is read.
+type Parser struct {
+	Options
+
+	r       io.Reader
+	scanner *bufio.Scanner
+	line    int
+	text    string
+	unread  bool
+	trail   []string
+}
+
+// NewParser returns a new Parser reading varnishlog output from r. The
+// internal buffer grows as needed to accommodate long log lines.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{
+		r: r,
+	}
+}
+
+// NewParserWithOptions returns a new Parser reading varnishlog output from r,
+// configured by opts.
+func NewParserWithOptions(r io.Reader, opts Options) *Parser {
+	return &Parser{
+		Options: opts,
+		r:       r,
+	}
+}
+
+// init creates the scanner of the parser according to its configuration, if
+// it does not exist yet.
+func (p *Parser) init() {
+	if p.scanner != nil {
+		return
+	}
+	max := p.MaxLineLength
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+	p.scanner = bufio.NewScanner(p.r)
+	p.scanner.Buffer(nil, max)
+	if p.KeepCR {
+		p.scanner.Split(scanLFLines)
+	} else {
+		p.scanner.Split(scanLines)
+	}
+}
+
+// scanLines is a bufio.SplitFunc which splits the input into lines terminated
+// by "\n", "\r\n" or a bare "\r". The line terminators are not returned.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// A "\n" might follow the "\r", request more data.
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// scanLFLines is a bufio.SplitFunc which splits the input into lines
+// terminated by "\n". Unlike bufio.ScanLines, the "\r" preceding the "\n" is
+// kept as part of the line.
+func scanLFLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 // Parse will attempt to produce a single Entry from the log, which it reads
 // using the given scanner.
 //
@@ -56,62 +225,354 @@ func splitLine(s string) (string, string) {
 // lines into fields with a key and a value, are performed. The Entry struct
 // provides various convenience methods which perform the subsequent parsing.
 func Parse(scanner *bufio.Scanner) (*Entry, error) {
+	return ParseWithOptions(scanner, Options{})
+}
+
+// ParseWithOptions is like Parse, but configured by opts. Since the scanner is
+// configured by the caller, MaxLineLength and KeepCR have no effect.
+//
+// Grouped transactions, as well as the Legacy and Resync modes, require
+// reading the line following an entry, e.g. the header of the next group,
+// which is lost once the call returns. Use a Parser, see NewScannerParser,
+// to parse consecutive entries of such input.
+func ParseWithOptions(scanner *bufio.Scanner, opts Options) (*Entry, error) {
+	return NewScannerParser(scanner, opts).Next()
+}
+
+// NewScannerParser returns a new Parser reading varnishlog output using the
+// given scanner, configured by opts. Since the scanner is configured by the
+// caller, MaxLineLength and KeepCR have no effect. The scanner must not be
+// read otherwise while the parser is in use.
+func NewScannerParser(scanner *bufio.Scanner, opts Options) *Parser {
+	return &Parser{
+		Options: opts,
+		scanner: scanner,
+	}
+}
+
+// ParseReader parses a single Entry from r, handling buffering internally.
+// Since the input is buffered, r may be read past the end of the entry. Use a
+// Parser to read multiple entries from the same reader.
+func ParseReader(r io.Reader) (*Entry, error) {
+	return NewParser(r).Next()
+}
+
+// Line returns the number of the last line read by the parser, starting from
+// one. It is zero if no line has been read yet.
+func (p *Parser) Line() int {
+	return p.line
+}
+
+// err returns the error encountered by the scanner of the parser, if any.
+func (p *Parser) err() error {
+	err := p.scanner.Err()
+	if err == bufio.ErrTooLong {
+		return errors.Wrapf(err,
+			"line %d exceeds the maximum line length", p.line+1)
+	}
+	return err
+}
+
+// scan advances the parser to the next line, which is then available as
+// p.text, keeping track of the line number. If a line has been pushed back by
+// unscan, it is returned again instead.
+func (p *Parser) scan() bool {
+	if p.unread {
+		p.unread = false
+		return true
+	}
+	if !p.scanner.Scan() {
+		return false
+	}
+	p.line++
+	p.text = p.scanner.Text()
+	if p.Resync {
+		p.trail = append(p.trail, p.text)
+	}
+	return true
+}
+
+// scanRecord is like scan, except that it skips the lines of records of an
+// entry whose records start with prefix which are dropped by Records, so
+// that dropped records are never converted to strings.
+func (p *Parser) scanRecord(prefix string) bool {
+	if p.unread || p.Records == nil || p.Resync {
+		return p.scan()
+	}
+	for p.scanner.Scan() {
+		p.line++
+		if b := p.scanner.Bytes(); !p.dropRecord(b, prefix) {
+			p.text = string(b)
+			return true
+		}
+	}
+	return false
+}
+
+// dropRecord returns whether the line b is a well-formed record starting with
+// prefix which is dropped by Records.
+func (p *Parser) dropRecord(b []byte, prefix string) bool {
+	if len(b) <= len(prefix) || string(b[:len(prefix)]) != prefix {
+		return false
+	}
+	k, v := splitLine(b[len(prefix):])
+	if digits(k) {
+		var ok bool
+		if k, _, v, ok = splitVerbose(v); !ok {
+			return false
+		}
+	}
+	if len(k) == 0 || string(k) == "End" || p.StrictTags && !knownTags[string(k)] {
+		return false
+	}
+	return !p.Records.keepBytes(k, v)
+}
+
+// unscan pushes the current line back, so that it is returned by the next
+// scan call again.
+func (p *Parser) unscan() {
+	p.unread = true
+}
+
+// Next parses the next Entry from the log. See Parse for details. io.EOF is
+// returned once there are no more entries to be read. If an entry cannot be
+// parsed, the error is a *ParseError holding the partially parsed entry.
+func (p *Parser) Next() (*Entry, error) {
 	e := newEntry()
+	if err := p.next(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// next parses the next entry from the log into e, which must be empty.
+// Entries not accepted by the options, and in the Resync mode also entries
+// which fail to parse, are skipped.
+func (p *Parser) next(e *Entry) error {
+	p.init()
+	for {
+		err := p.parse(e)
+		if err == io.EOF || err != nil && p.err() != nil {
+			return err
+		}
+		if err == nil {
+			if p.accept(e) {
+				if p.CanonicalHeaders {
+					CanonicalizeHeaders(e)
+				}
+				return nil
+			}
+		} else if !p.Resync {
+			return &ParseError{Line: p.line, Entry: e, Err: err}
+		} else {
+			p.skip(err)
+		}
+		e.reset()
+	}
+}
+
+// skip skips the lines following a parse error err up to the next top-level
+// entry, which is pushed back, and reports them along with the lines of the
+// broken entry to OnSkip.
+func (p *Parser) skip(err error) {
+	// The line the error occurred at may already be the header of the next
+	// entry, so it is checked first.
+	current := true
+	for current || p.scan() {
+		current = false
+		if p.Legacy && p.text == "" {
+			break
+		}
+		// The first line of the trail is the header of the broken entry.
+		if fs := strings.Fields(p.text); len(p.trail) > 1 && len(fs) > 0 && fs[0] == "*" {
+			p.unscan()
+			p.trail = p.trail[:len(p.trail)-1]
+			break
+		}
+	}
+	if p.OnSkip != nil {
+		p.OnSkip(p.trail, err)
+	}
+}
+
+// parse parses the next entry from the log into e, which must be empty.
+func (p *Parser) parse(e *Entry) error {
 	// Skip empty log lines, they convey no meaning.
 	eof := true
-	for scanner.Scan() {
-		if scanner.Text() != "" {
+	for p.scan() {
+		if p.text != "" {
 			eof = false
 			break
 		}
 	}
-	if eof && scanner.Err() == nil {
-		return nil, io.EOF
+	if eof {
+		if err := p.err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	// Keep track of the lines of the entry in case it needs to be skipped.
+	if p.Resync {
+		p.trail = append(p.trail[:0], p.text)
+	}
+	if p.Legacy && !strings.HasPrefix(p.text, "*") {
+		return p.parseLegacyEntry(e)
+	}
+	if err := p.parseEntry(e); err != nil {
+		return err
+	}
+	if p.Grouping == GroupVXID {
+		return nil
+	}
+	return p.parseChildren(e)
+}
+
+// headerLevel returns the nesting level of the transaction whose header
+// starts with the given marker, e.g. 1 for "*", 3 for "***" or 5 for "*5*".
+func headerLevel(m string) (int, bool) {
+	return level(m, '*')
+}
+
+// level returns the nesting level encoded in the marker m made of the byte c,
+// i.e. one to three times c, or a digit enclosed in c for deeper levels.
+func level(m string, c byte) (int, bool) {
+	switch {
+	case m == "":
+		return 0, false
+	case len(m) <= 3 && strings.Count(m, string(c)) == len(m):
+		return len(m), true
+	case len(m) == 3 && m[0] == c && m[2] == c && m[1] >= '4' && m[1] <= '9':
+		return int(m[1] - '0'), true
+	}
+	return 0, false
+}
+
+// recordPrefix returns the prefix of record lines of a transaction at the
+// given nesting level, e.g. "-" for level 1 or "-5-" for level 5.
+func recordPrefix(level int) string {
+	if level > 3 {
+		return "-" + strconv.Itoa(level) + "-"
 	}
+	return strings.Repeat("-", level)
+}
+
+// parseEntry parses the entry whose header is the current line into e.
+func (p *Parser) parseEntry(e *Entry) error {
 	// Parse log entry header, e.g.:
 	// *   << BeReq    >> 32086823
 	// *   << Request  >> 32742536
 	// *   << Session  >> 29236595
-	header := strings.Fields(scanner.Text())
-	if len(header) != 5 || header[0] != "*" {
-		return nil, errors.New("header line was expected")
+	//
+	// The number of asterisks is the nesting level of the transaction when
+	// transactions are grouped, e.g. "**" or "*4*".
+	header := strings.Fields(p.text)
+	if len(header) != 5 {
+		return errors.New("header line was expected")
+	}
+	var ok bool
+	if e.Level, ok = headerLevel(header[0]); !ok {
+		return errors.New("header line was expected")
 	}
 	var err error
-	e.Kind = header[2]
-	if e.VXID, err = strconv.Atoi(header[4]); err != nil {
-		return nil, errors.Wrap(err, "failed to parse VXID")
+	// Kinds unknown to this package, e.g. those of a future version of
+	// Varnish, are kept as is.
+	if e.Kind, err = ParseKind(header[2]); err != nil {
+		e.RawKind = header[2]
+	}
+	if e.VXID, err = strconv.ParseUint(header[4], 10, 64); err != nil {
+		return errors.Wrap(err, "failed to parse VXID")
 	}
 	// Parse log entries, e.g.:
 	// -   ReqStart       136.243.103.218 53602
 	// -   ReqURL         /health
 	// -   Timestamp      Process: 1545037998.759333 0.000031 0.000031
+	//
+	// In verbose output (varnishlog -v), each record is prefixed by its VXID
+	// and followed by the client/backend marker, e.g.:
+	// -      32770 ReqURL         c /health
+	//
+	// Records which belong to no transaction are reported as "<< Record >>"
+	// entries, which have no "End" record and end with an empty line.
+	prefix := recordPrefix(e.Level)
 	foundEnd := false
-	for scanner.Scan() {
-		line := scanner.Text()
+	for p.scanRecord(prefix) {
+		line := p.text
+		if line == "" && e.Kind == Raw {
+			foundEnd = true
+			break
+		}
 		if line == "" {
-			return nil, errors.Errorf("parse error: unexpected empty line")
+			return errors.Errorf("parse error: unexpected empty line")
 		}
-		if line[0] != '-' {
-			return nil, errors.Errorf("parse error on line %q: does not start with '-'", line)
+		if !strings.HasPrefix(line, prefix) {
+			return errors.Errorf("parse error on line %q: does not start with %q", line, prefix)
 		}
-		k, v := splitLine(line[1:])
+		k, v := splitLine(line[len(prefix):])
 		if k == "" {
-			return nil, errors.Errorf("parse error on line %q: empty key", line)
+			return errors.Errorf("parse error on line %q: empty key", line)
+		}
+		if digits(k) {
+			// Tags never start with a digit, so this is verbose output.
+			if k, _, v, ok = splitVerbose(v); !ok {
+				return errors.Errorf("parse error on line %q: malformed verbose record", line)
+			}
 		}
 		if k == "End" {
 			foundEnd = true
 			break
 		}
-		if _, ok := e.Fields[k]; !ok {
-			e.Fields[k] = make([]string, 0)
+		if p.StrictTags && !IsKnownTag(k) {
+			return errors.Errorf("parse error on line %q: unknown tag %q", line, k)
+		}
+		if p.keep(k, v) {
+			e.add(k, v)
 		}
-		e.Fields[k] = append(e.Fields[k], v)
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if err := p.err(); err != nil {
+		return err
+	}
+	if !foundEnd && e.Kind != Raw {
+		if p.AllowTruncated {
+			e.Truncated = true
+			return nil
+		}
+		return errors.New("unexpected EOF in the middle of a log entry")
 	}
-	if !foundEnd {
-		return nil, errors.New("unexpected EOF in the middle of a log entry")
+	return nil
+}
+
+// parseChildren parses the nested transactions which follow the root entry
+// of a group and attaches them to the entries they are nested in. The group
+// ends with an empty line, the end of the input, or with the header of the
+// next top-level transaction.
+func (p *Parser) parseChildren(root *Entry) error {
+	// parents[i] is the last entry seen at nesting level i+1.
+	parents := []*Entry{root}
+	for p.scan() {
+		// Lines of white space only, e.g. those left by KeepCR, end the
+		// group like empty lines.
+		fs := strings.Fields(p.text)
+		if len(fs) == 0 {
+			return nil
+		}
+		if l, ok := headerLevel(fs[0]); !ok || l <= root.Level {
+			p.unscan()
+			return nil
+		}
+		// The entry is attached even if it is broken, so that it is part of
+		// the partial entry reported along with the error.
+		e := newEntry()
+		err := p.parseEntry(e)
+		if l := e.Level - root.Level; l > 0 && l <= len(parents) {
+			parent := parents[l-1]
+			parent.Children = append(parent.Children, e)
+			parents = append(parents[:l], e)
+		} else if err == nil {
+			return errors.Errorf("transaction %d nested too deep at level %d", e.VXID, e.Level)
+		}
+		if err != nil {
+			return err
+		}
 	}
-	return e, nil
+	return p.err()
 }
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 type kv struct {
@@ -87,12 +88,14 @@ func TestParse(t *testing.T) {
 	testParseOK(t, &Entry{
 		Kind: BeReq,
 		VXID: 123,
+		Level: 1,
 		Fields: Fields{},
 	}, "* << BeReq >> 123\n- End")
 
 	testParseOK(t, &Entry{
 		Kind: Request,
 		VXID: 40000000,
+		Level: 1,
 		Fields: Fields{
 			"Foo": []string{
 				"Bar",
@@ -102,25 +105,83 @@ func TestParse(t *testing.T) {
 				"Foo  Bar    Baz	", // Trailing tab valid.
 			},
 		},
+		Records: Records{{"Foo", "Bar"}, {"Foo", "Baz"}, {"Bar", "Foo  Bar    Baz	"}},
 	}, "*   <<  Request >> 40000000\n- Foo Bar\n-Foo Baz\n- Bar     Foo  Bar    Baz	\n- End")
 	testParseMultipleOK(t, []*Entry{
 		&Entry{
 			Kind:   BeReq,
 			VXID:   123,
+			Level:  1,
 			Fields: Fields{},
 		},
 		&Entry{
 			Kind:   BeReq,
 			VXID:   124,
+			Level:  1,
 			Fields: Fields{},
 		},
 	}, "* << BeReq >> 123\n- End\n\n* << BeReq >> 124\n- End")
 
+	testParseOK(t, &Entry{
+		Kind: Request,
+		VXID: 32770,
+		Level: 1,
+		Fields: Fields{
+			"Begin":  []string{"req 32769 rxreq"},
+			"ReqURL": []string{"/health"},
+			"Empty":  []string{""},
+		},
+		Records: Records{{"Begin", "req 32769 rxreq"}, {"ReqURL", "/health"}, {"Empty", ""}},
+	}, "*   << Request  >> 32770     \n-      32770 Begin          c req 32769 rxreq\n"+
+		"-      32770 ReqURL         c /health\n-      32770 Empty          - \n"+
+		"-      32770 End            c \n")
+
+	testParseOK(t, &Entry{
+		Kind:    Raw,
+		Level:   1,
+		Fields:  Fields{"CLI": []string{"Rd ping"}},
+		Records: Records{{"CLI", "Rd ping"}},
+	}, "* << Record >> 0\n- CLI Rd ping\n")
+	testParseMultipleOK(t, []*Entry{
+		&Entry{
+			Kind:    Raw,
+			Level:   1,
+			Fields:  Fields{"CLI": []string{"Rd ping"}},
+			Records: Records{{"CLI", "Rd ping"}},
+		},
+		&Entry{
+			Kind:   Request,
+			VXID:   2,
+			Level:  1,
+			Fields: Fields{},
+		},
+	}, "* << Record >> 0\n- CLI Rd ping\n\n* << Request >> 2\n- End\n")
+
+	testParseOK(t, &Entry{
+		Kind:    Unknown,
+		RawKind: "SomethingNew",
+		VXID:    7,
+		Level:   1,
+		Fields:  Fields{},
+	}, "* << SomethingNew >> 7\n- End")
+
+	// Varnish 7.x uses 64-bit VXIDs.
+	testParseOK(t, &Entry{
+		Kind:   Request,
+		VXID:   18446744073709551615,
+		Level:  1,
+		Fields: Fields{},
+	}, "* << Request >> 18446744073709551615\n- End")
+
 	testParseError(t, "")
 	testParseError(t, "- ")
+	testParseError(t, "* << Request >> 18446744073709551616\n- End")
+	testParseError(t, "* << Request >> -1\n- End")
 	testParseError(t, "* << Request >> 1\n - Foo Bar\n- End")
 	testParseError(t, "* << Request >> Foo")
 	testParseError(t, "* << Request >> 1")
+	testParseError(t, "* << Request >> 1\n- 1 ReqURL /foo\n- End")
+	testParseError(t, "* << Request >> 1\n- 1\n- End")
 }
 
 func TestEOF(t *testing.T) {
@@ -135,3 +196,488 @@ func TestEOF(t *testing.T) {
 		t.Logf("parsing properly returned EOF")
 	}
 }
+
+// TestParser tests that a Parser yields consecutive entries from its reader
+// and reports io.EOF once the input is exhausted.
+func TestParser(t *testing.T) {
+	p := NewParser(strings.NewReader(
+		"* << BeReq >> 123\n- Foo Bar\n- End\n\n* << Request >> 124\n- End\n\n"))
+	want := []*Entry{
+		&Entry{
+			Kind: BeReq,
+			VXID: 123,
+			Level: 1,
+			Fields: Fields{
+				"Foo": []string{"Bar"},
+			},
+			Records: Records{{"Foo", "Bar"}},
+		},
+		&Entry{
+			Kind:   Request,
+			VXID:   124,
+			Level:  1,
+			Fields: Fields{},
+		},
+	}
+	for _, e := range want {
+		got, err := p.Next()
+		if err != nil {
+			t.Fatalf("p.Next() should not fail, got: %v", err)
+		}
+		if !reflect.DeepEqual(e, got) {
+			t.Errorf("p.Next() should give %v, got %v", e, got)
+		}
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("p.Next() should return io.EOF at the end of input, got: %v", err)
+	}
+}
+
+// TestParseReader tests that ParseReader parses an entry from a plain reader
+// and copes with lines longer than the default limit of bufio.Scanner.
+func TestParseReader(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	got, err := ParseReader(strings.NewReader(
+		"* << Request >> 1\n- ReqHeader X-Long: " + long + "\n- End\n"))
+	if err != nil {
+		t.Fatalf("ParseReader should not fail, got: %v", err)
+	}
+	e := &Entry{
+		Kind: Request,
+		VXID: 1,
+		Level: 1,
+		Fields: Fields{
+			"ReqHeader": []string{"X-Long: " + long},
+		},
+		Records: Records{{"ReqHeader", "X-Long: " + long}},
+	}
+	if !reflect.DeepEqual(e, got) {
+		t.Errorf("ParseReader returned an unexpected entry")
+	}
+}
+
+// TestMaxLineLength tests that lines longer than MaxLineLength are reported
+// as errors and that shorter lines are parsed fine.
+func TestMaxLineLength(t *testing.T) {
+	s := "* << Request >> 1\n- ReqHeader X-Long: " + strings.Repeat("x", 200) + "\n- End\n"
+	p := NewParser(strings.NewReader(s))
+	p.MaxLineLength = 100
+	if _, err := p.Next(); err == nil {
+		t.Errorf("parsing a line longer than MaxLineLength should fail")
+	} else {
+		t.Logf("parsing a line longer than MaxLineLength gives: %v", err)
+	}
+	p = NewParser(strings.NewReader(s))
+	p.MaxLineLength = 300
+	if _, err := p.Next(); err != nil {
+		t.Errorf("parsing a line shorter than MaxLineLength should not fail, got: %v", err)
+	}
+}
+
+// TestScanLines tests that scanLines splits lines on all kinds of line
+// terminators, including such split across reads.
+func TestScanLines(t *testing.T) {
+	samples := map[string][]string{
+		"":                        nil,
+		"foo":                     []string{"foo"},
+		"foo\nbar\n":              []string{"foo", "bar"},
+		"foo\r\nbar\r\n":          []string{"foo", "bar"},
+		"foo\rbar\r":              []string{"foo", "bar"},
+		"foo\r\n\r\nbar\rbaz\n\r": []string{"foo", "", "bar", "baz", ""},
+	}
+	for s, lines := range samples {
+		// One byte at a time, so that "\r\n" is split across reads.
+		scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(s)))
+		scanner.Split(scanLines)
+		var got []string
+		for scanner.Scan() {
+			got = append(got, scanner.Text())
+		}
+		if !reflect.DeepEqual(got, lines) {
+			t.Errorf("splitting %q should give %q, got %q", s, lines, got)
+		}
+	}
+}
+
+// TestParseCRLF tests that entries with mixed line endings are parsed the
+// same as entries with "\n" line endings, unless KeepCR is set.
+func TestParseCRLF(t *testing.T) {
+	s := "* << Request >> 1\r\n- ReqURL /foo\r\n- ReqProtocol HTTP/1.1\r- End\n"
+	e := &Entry{
+		Kind: Request,
+		VXID: 1,
+		Level: 1,
+		Fields: Fields{
+			"ReqURL":      []string{"/foo"},
+			"ReqProtocol": []string{"HTTP/1.1"},
+		},
+		Records: Records{{"ReqURL", "/foo"}, {"ReqProtocol", "HTTP/1.1"}},
+	}
+	got, err := NewParser(strings.NewReader(s)).Next()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", s, err)
+	}
+	if !reflect.DeepEqual(e, got) {
+		t.Errorf("parsing %q should give %v, got %v", s, e, got)
+	}
+
+	s = "* << Request >> 1\n- ReqURL /foo\r\n- End\n"
+	p := NewParser(strings.NewReader(s))
+	p.KeepCR = true
+	got, err = p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", s, err)
+	}
+	if v := got.TryField("ReqURL"); v != "/foo\r" {
+		t.Errorf("parsing %q with KeepCR should keep the CR, got %q", s, v)
+	}
+}
+
+// session is a sample of varnishlog output in session grouping.
+const session = `
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   Link           req 2 rxreq
+-   End
+**  << Request  >> 2
+--  Begin          req 1 rxreq
+--  Link           bereq 3 fetch
+--  End
+*** << BeReq    >> 3
+--- Begin          bereq 2 fetch
+--- End
+**  << Request  >> 4
+--  Begin          req 1 rxreq
+--  End
+
+*   << Session  >> 5
+-   Begin          sess 0 HTTP/1
+-   End
+`
+
+// TestGroupSession tests that nested transactions are attached to their
+// parents in session grouping.
+func TestGroupSession(t *testing.T) {
+	p := NewParser(strings.NewReader(session))
+	p.Grouping = GroupSession
+	got, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse session group: %v", err)
+	}
+	bereq := &Entry{
+		Kind:    BeReq,
+		VXID:    3,
+		Level:   3,
+		Fields:  Fields{"Begin": []string{"bereq 2 fetch"}},
+		Records: Records{{"Begin", "bereq 2 fetch"}},
+	}
+	e := &Entry{
+		Kind:  Session,
+		VXID:  1,
+		Level: 1,
+		Fields: Fields{
+			"Begin": []string{"sess 0 HTTP/1"},
+			"Link":  []string{"req 2 rxreq"},
+		},
+		Records: Records{{"Begin", "sess 0 HTTP/1"}, {"Link", "req 2 rxreq"}},
+		Children: []*Entry{
+			&Entry{
+				Kind:  Request,
+				VXID:  2,
+				Level: 2,
+				Fields: Fields{
+					"Begin": []string{"req 1 rxreq"},
+					"Link":  []string{"bereq 3 fetch"},
+				},
+				Records:  Records{{"Begin", "req 1 rxreq"}, {"Link", "bereq 3 fetch"}},
+				Children: []*Entry{bereq},
+			},
+			&Entry{
+				Kind:    Request,
+				VXID:    4,
+				Level:   2,
+				Fields:  Fields{"Begin": []string{"req 1 rxreq"}},
+				Records: Records{{"Begin", "req 1 rxreq"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(e, got) {
+		t.Errorf("parsing session group should give %v, got %v", e, got)
+	}
+	if got, err = p.Next(); err != nil || got.VXID != 5 || got.Children != nil {
+		t.Errorf("parsing second session group should give session 5, got %v (%v)", got, err)
+	}
+	if _, err = p.Next(); err != io.EOF {
+		t.Errorf("parsing should end with EOF, got: %v", err)
+	}
+
+	// Without grouping, nested transactions are returned one by one.
+	n := 0
+	for e, err := range Entries(strings.NewReader(session)) {
+		if err != nil {
+			t.Fatalf("failed to parse session group without grouping: %v", err)
+		}
+		if e.Children != nil {
+			t.Errorf("entry %d should have no children without grouping", e.VXID)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("parsing session group without grouping should give 5 entries, got %d", n)
+	}
+
+	testParseError(t, "** << Request >> 1\n- End")
+	testParseError(t, "*5 << Request >> 1\n-5 End")
+	p = NewParser(strings.NewReader("* << Session >> 1\n- End\n*** << BeReq >> 2\n--- End\n"))
+	p.Grouping = GroupSession
+	if _, err := p.Next(); err == nil {
+		t.Errorf("parsing a transaction nested too deep should fail")
+	}
+}
+
+// TestGroupRequest tests that backend requests and ESI subrequests are
+// attached to their client request in request grouping, preserving the
+// nesting levels.
+func TestGroupRequest(t *testing.T) {
+	s := `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   End
+**  << BeReq    >> 3
+--  Begin          bereq 2 fetch
+--  End
+**  << Request  >> 4
+--  Begin          req 2 esi
+--  End
+*** << BeReq    >> 5
+--- Begin          bereq 4 fetch
+--- End
+
+*   << Request  >> 6
+-   Begin          req 1 rxreq
+-   End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse request group: %v", err)
+	}
+	var vxids []uint64
+	var levels []int
+	e.Walk(func(e *Entry) bool {
+		vxids = append(vxids, e.VXID)
+		levels = append(levels, e.Level)
+		return true
+	})
+	if !reflect.DeepEqual(vxids, []uint64{2, 3, 4, 5}) {
+		t.Errorf("walking request group should visit [2 3 4 5], got %v", vxids)
+	}
+	if !reflect.DeepEqual(levels, []int{1, 2, 2, 3}) {
+		t.Errorf("request group should have levels [1 2 2 3], got %v", levels)
+	}
+	if len(e.Children) != 2 || len(e.Children[1].Children) != 1 {
+		t.Errorf("request 2 should have 2 children and ESI request 4 one child")
+	}
+	n := 0
+	if e.Walk(func(e *Entry) bool { n++; return e.VXID != 3 }) || n != 2 {
+		t.Errorf("walking should stop once the callback returns false, visited %d", n)
+	}
+	if e, err = p.Next(); err != nil || e.VXID != 6 {
+		t.Errorf("parsing the next request group should give request 6, got %v (%v)", e, err)
+	}
+
+	// A line of white space only ends a group like an empty line.
+	p = NewParser(strings.NewReader("*   << Request  >> 1\n-   ReqURL /\n-   End\n   \n*   << Request  >> 2\n-   End\n"))
+	p.Grouping = GroupRequest
+	for _, vxid := range []uint64{1, 2} {
+		if e, err = p.Next(); err != nil || e.VXID != vxid {
+			t.Errorf("parsing request group after white space should give request %d, got %v (%v)", vxid, e, err)
+		}
+	}
+	if _, err = p.Next(); err != io.EOF {
+		t.Errorf("parsing request groups after white space should end with EOF, got: %v", err)
+	}
+
+	// Groups parsed from a scanner, which are not separated by an empty line,
+	// so that the header of the second one is read ahead.
+	p = NewScannerParser(stringScanner("* << Request >> 2\n- End\n** << BeReq >> 3\n-- End\n* << Request >> 6\n- End\n"), Options{Grouping: GroupRequest})
+	if e, err = p.Next(); err != nil || e.VXID != 2 || len(e.Children) != 1 {
+		t.Errorf("parsing the first request group from a scanner should give request 2, got %v (%v)", e, err)
+	}
+	if e, err = p.Next(); err != nil || e.VXID != 6 {
+		t.Errorf("parsing the next request group from a scanner should give request 6, got %v (%v)", e, err)
+	}
+	if _, err = p.Next(); err != io.EOF {
+		t.Errorf("parsing request groups from a scanner should end with EOF, got: %v", err)
+	}
+}
+
+// TestAllowTruncated tests that the entry missing its "End" record at the end
+// of input is returned flagged as truncated if allowed, and rejected
+// otherwise.
+func TestAllowTruncated(t *testing.T) {
+	s := "* << Request >> 1\n- ReqURL /foo\n- End\n\n* << Request >> 2\n- ReqURL /bar\n"
+	p := NewParser(strings.NewReader(s))
+	p.AllowTruncated = true
+	want := []*Entry{
+		&Entry{
+			Kind:    Request,
+			VXID:    1,
+			Level:   1,
+			Fields:  Fields{"ReqURL": []string{"/foo"}},
+			Records: Records{{"ReqURL", "/foo"}},
+		},
+		&Entry{
+			Kind:      Request,
+			VXID:      2,
+			Level:     1,
+			Fields:    Fields{"ReqURL": []string{"/bar"}},
+			Records:   Records{{"ReqURL", "/bar"}},
+			Truncated: true,
+		},
+	}
+	for _, e := range want {
+		got, err := p.Next()
+		if err != nil {
+			t.Fatalf("p.Next() should not fail, got: %v", err)
+		}
+		if !reflect.DeepEqual(e, got) {
+			t.Errorf("p.Next() should give %v, got %v", e, got)
+		}
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("p.Next() should return io.EOF at the end of input, got: %v", err)
+	}
+
+	p = NewParser(strings.NewReader("* << Session >> 1\n- Begin sess 0 HTTP/1\n- End\n** << Request >> 2\n-- ReqURL /foo\n"))
+	p.Grouping = GroupSession
+	p.AllowTruncated = true
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("p.Next() should not fail, got: %v", err)
+	}
+	if e.Truncated || len(e.Children) != 1 || !e.Children[0].Truncated {
+		t.Errorf("only the nested request should be truncated, got %v", e)
+	}
+
+	p = NewParser(strings.NewReader(s))
+	p.Next()
+	if _, err := p.Next(); err == nil || err == io.EOF {
+		t.Errorf("truncated entry should be rejected by default, got: %v", err)
+	}
+}
+
+// TestResync tests that in the Resync mode broken entries are skipped and
+// reported, and parsing resumes at the next entry.
+func TestResync(t *testing.T) {
+	s := `* << Request >> 1
+- ReqURL /foo
+- End
+
+* << Request >> 2
+- ReqURL /bar
+garbage
+- End
+
+* << Request >> Foo
+- End
+* << Request >> 3
+- ReqURL /baz
+* << Request >> 4
+- End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Resync = true
+	var skipped [][]string
+	p.OnSkip = func(lines []string, err error) {
+		t.Logf("skipping %q: %v", lines, err)
+		skipped = append(skipped, append([]string(nil), lines...))
+	}
+	var vxids []uint64
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("p.Next() should not fail in the Resync mode, got: %v", err)
+		}
+		vxids = append(vxids, e.VXID)
+	}
+	if !reflect.DeepEqual(vxids, []uint64{1, 4}) {
+		t.Errorf("parsing %q should give VXIDs [1 4], got %v", s, vxids)
+	}
+	want := [][]string{
+		{"* << Request >> 2", "- ReqURL /bar", "garbage", "- End", ""},
+		{"* << Request >> Foo", "- End"},
+		{"* << Request >> 3", "- ReqURL /baz"},
+	}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("parsing %q should skip %q, got %q", s, want, skipped)
+	}
+
+	// Entries parsed from a scanner, where skipping the broken entry reads
+	// the header of the next one.
+	sp := NewScannerParser(stringScanner("* << Request >> 1\n- End\n* << Request >> 2\ngarbage\n* << Request >> 3\n- End\n"), Options{Resync: true})
+	for _, vxid := range []uint64{1, 3} {
+		if e, err := sp.Next(); err != nil || e.VXID != vxid {
+			t.Errorf("parsing from a scanner in the Resync mode should give request %d, got %v (%v)", vxid, e, err)
+		}
+	}
+	if _, err := sp.Next(); err != io.EOF {
+		t.Errorf("parsing from a scanner in the Resync mode should end with EOF, got: %v", err)
+	}
+
+	p = NewParser(strings.NewReader("   12 RxURL        c /\n   13 RxURL\n   13 RxURL        c /\n\n   14 RxURL        c /bar\n"))
+	p.Legacy = true
+	p.Resync = true
+	e, err := p.Next()
+	if err != nil || !reflect.DeepEqual(e.Fields["ReqURL"], []string{"/bar"}) {
+		t.Errorf("parsing legacy log should resume after an empty line, got %v (%v)", e, err)
+	}
+}
+
+// TestParseErrorEntry tests that a parse error carries the partially parsed
+// entry and the line at which parsing failed.
+func TestParseErrorEntry(t *testing.T) {
+	s := "* << Session >> 1\n- Begin sess 0 HTTP/1\n- End\n** << Request >> 2\n-- ReqURL /foo\n-- ReqHeader Host: example.com\ngarbage\n"
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupSession
+	_, err := p.Next()
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("parsing %q should give a *ParseError, got: %v", s, err)
+	}
+	t.Logf("parsing %q gives: %v", s, err)
+	if perr.Line != 7 {
+		t.Errorf("parsing %q should fail at line 7, got %d", s, perr.Line)
+	}
+	want := &Entry{
+		Kind:    Session,
+		VXID:    1,
+		Level:   1,
+		Fields:  Fields{"Begin": []string{"sess 0 HTTP/1"}},
+		Records: Records{{"Begin", "sess 0 HTTP/1"}},
+		Children: []*Entry{
+			&Entry{
+				Kind:  Request,
+				VXID:  2,
+				Level: 2,
+				Fields: Fields{
+					"ReqURL":    []string{"/foo"},
+					"ReqHeader": []string{"Host: example.com"},
+				},
+				Records: Records{{"ReqURL", "/foo"}, {"ReqHeader", "Host: example.com"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(want, perr.Entry) {
+		t.Errorf("parsing %q should give the partial entry %v, got %v", s, want, perr.Entry)
+	}
+
+	p = NewParser(iotest.ErrReader(io.ErrUnexpectedEOF))
+	if _, err := p.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("errors of the reader should be returned as is, got: %v", err)
+	}
+}
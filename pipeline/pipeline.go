@@ -0,0 +1,233 @@
+// Package pipeline composes programs processing varnishlog output out of
+// stages, which filter, transform or group entries, and sinks, which consume
+// the entries passed through all stages:
+//
+//	p := pipeline.New(pipeline.FanOut(store, metrics),
+//		pipeline.Filter(vslparser.FilterFunc(func(e *vslparser.Entry) bool {
+//			return e.Kind == vslparser.Request
+//		})),
+//		pipeline.Redact(&vslparser.Redactor{Headers: []string{"Cookie"}}),
+//	)
+//	err := p.Run(vslparser.NewParser(os.Stdin))
+package pipeline
+
+import (
+	"context"
+	"github.com/Showmax/vslparser"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// Stage is a step of a pipeline, which processes entries one by one and
+// passes the resulting entries on to the next step.
+type Stage interface {
+	// Process processes the entry e and returns the entries to be passed
+	// on, none if e is dropped, or more if the stage releases entries it
+	// held, e.g. trees completed by e.
+	Process(e *vslparser.Entry) ([]*vslparser.Entry, error)
+	// Flush returns the entries held by the stage at the end of the input.
+	Flush() ([]*vslparser.Entry, error)
+}
+
+// Sink consumes the entries passed through all stages of a pipeline, e.g. by
+// shipping them to a log store.
+type Sink interface {
+	// Write consumes the entry e.
+	Write(e *vslparser.Entry) error
+	// Close releases the resources of the sink at the end of the input.
+	Close() error
+}
+
+// Pipeline passes entries through its stages, in order, into its sink.
+type Pipeline struct {
+	stages []Stage
+	sink   Sink
+}
+
+// New returns a pipeline passing entries through the given stages into sink.
+func New(sink Sink, stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages, sink: sink}
+}
+
+// Push passes the entry e through the pipeline. Entries are handed over to
+// the stages and the sink, so e must not be reused for parsing, see
+// vslparser.ParseFunc.
+func (p *Pipeline) Push(e *vslparser.Entry) error {
+	return p.push(0, []*vslparser.Entry{e})
+}
+
+// push passes the given entries through the stages starting with the i-th.
+func (p *Pipeline) push(i int, entries []*vslparser.Entry) error {
+	if i == len(p.stages) {
+		for _, e := range entries {
+			if err := p.sink.Write(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, e := range entries {
+		out, err := p.stages[i].Process(e)
+		if err != nil {
+			return err
+		}
+		if err := p.push(i+1, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes the stages, in order, passing the entries they held through
+// the following stages, and closes the sink.
+func (p *Pipeline) Close() error {
+	for i, s := range p.stages {
+		out, err := s.Flush()
+		if err == nil {
+			err = p.push(i+1, out)
+		}
+		if err != nil {
+			p.sink.Close()
+			return err
+		}
+	}
+	return p.sink.Close()
+}
+
+// Run pushes the entries parsed by parser through the pipeline until the end
+// of its input, and closes the pipeline. It stops at the first error, which
+// is returned.
+func (p *Pipeline) Run(parser *vslparser.Parser) error {
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			return p.Close()
+		}
+		if err == nil {
+			err = p.Push(e)
+		}
+		if err != nil {
+			p.sink.Close()
+			return errors.Wrapf(err, "pipeline failed at line %d", parser.Line())
+		}
+	}
+}
+
+// StageFunc adapts an ordinary function to a Stage which holds no entries.
+type StageFunc func(e *vslparser.Entry) ([]*vslparser.Entry, error)
+
+// Process calls f(e).
+func (f StageFunc) Process(e *vslparser.Entry) ([]*vslparser.Entry, error) {
+	return f(e)
+}
+
+// Flush returns no entries.
+func (f StageFunc) Flush() ([]*vslparser.Entry, error) {
+	return nil, nil
+}
+
+// Filter returns a stage passing on only the entries matching f.
+func Filter(f vslparser.Filter) Stage {
+	return StageFunc(func(e *vslparser.Entry) ([]*vslparser.Entry, error) {
+		if !f.Match(e) {
+			return nil, nil
+		}
+		return []*vslparser.Entry{e}, nil
+	})
+}
+
+// Transform returns a stage changing entries in place by fn, e.g. to delete
+// records by Entry.Delete.
+func Transform(fn func(e *vslparser.Entry) error) Stage {
+	return StageFunc(func(e *vslparser.Entry) ([]*vslparser.Entry, error) {
+		if err := fn(e); err != nil {
+			return nil, err
+		}
+		return []*vslparser.Entry{e}, nil
+	})
+}
+
+// Redact returns a stage redacting entries by r.
+func Redact(r *vslparser.Redactor) Stage {
+	return Transform(func(e *vslparser.Entry) error {
+		r.Redact(e)
+		return nil
+	})
+}
+
+// Throttle returns a stage limiting the rate of entries by l, see
+// vslparser.RateLimiter, deferring the entries in excess of the rate. To
+// drop them instead, l can be used as a Filter.
+func Throttle(l *vslparser.RateLimiter) Stage {
+	return StageFunc(func(e *vslparser.Entry) ([]*vslparser.Entry, error) {
+		if err := l.Wait(context.Background(), e); err != nil {
+			return nil, err
+		}
+		return []*vslparser.Entry{e}, nil
+	})
+}
+
+// groupStage assembles trees of entries by a TreeBuilder.
+type groupStage struct {
+	b *vslparser.TreeBuilder
+}
+
+// Group returns a stage assembling the entries into trees by b, passing on
+// the trees, see vslparser.TreeBuilder. The trees held by b are passed on
+// when the pipeline is closed.
+func Group(b *vslparser.TreeBuilder) Stage {
+	return &groupStage{b: b}
+}
+
+// Process adds e to the builder and returns the trees completed by it.
+func (s *groupStage) Process(e *vslparser.Entry) ([]*vslparser.Entry, error) {
+	return s.b.Add(e)
+}
+
+// Flush returns the trees held by the builder.
+func (s *groupStage) Flush() ([]*vslparser.Entry, error) {
+	return s.b.Flush(), nil
+}
+
+// SinkFunc adapts an ordinary function to a Sink with nothing to close.
+type SinkFunc func(e *vslparser.Entry) error
+
+// Write calls f(e).
+func (f SinkFunc) Write(e *vslparser.Entry) error {
+	return f(e)
+}
+
+// Close does nothing.
+func (f SinkFunc) Close() error {
+	return nil
+}
+
+// fanOut writes entries to several sinks.
+type fanOut []Sink
+
+// FanOut returns a sink writing each entry to all the given sinks, in order.
+// The sinks share the entries, so they must not modify them.
+func FanOut(sinks ...Sink) Sink {
+	return fanOut(sinks)
+}
+
+// Write writes e to all sinks, stopping at the first error.
+func (f fanOut) Write(e *vslparser.Entry) error {
+	for _, s := range f {
+		if err := s.Write(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all sinks and returns the first error.
+func (f fanOut) Close() error {
+	var first error
+	for _, s := range f {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"errors"
+	"github.com/Showmax/vslparser"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect is a sink collecting the VXIDs of the entries written to it.
+type collect struct {
+	vxids  []uint64
+	closed bool
+}
+
+func (c *collect) Write(e *vslparser.Entry) error {
+	c.vxids = append(c.vxids, e.VXID)
+	return nil
+}
+
+func (c *collect) Close() error {
+	c.closed = true
+	return nil
+}
+
+// TestPipeline tests that entries are filtered, redacted and grouped in
+// order, that held trees are flushed at the end and that all sinks get them.
+func TestPipeline(t *testing.T) {
+	s := `
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   ReqHeader      Cookie: a=b
+-   Link           bereq 3 fetch
+-   End
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   End
+*   << Request  >> 4
+-   Begin          req 1 rxreq
+-   Link           bereq 5 fetch
+-   End
+`
+	a, b := &collect{}, &collect{}
+	var cookies []string
+	p := New(FanOut(a, b),
+		Filter(vslparser.FilterFunc(func(e *vslparser.Entry) bool {
+			return e.Kind != vslparser.Session
+		})),
+		Redact(&vslparser.Redactor{Headers: []string{"Cookie"}}),
+		Group(vslparser.NewTreeBuilder(vslparser.GroupRequest)),
+		Transform(func(e *vslparser.Entry) error {
+			cookies = append(cookies, e.Fields["ReqHeader"]...)
+			return nil
+		}),
+	)
+	if err := p.Run(vslparser.NewParser(strings.NewReader(s))); err != nil {
+		t.Fatalf("running pipeline should not fail, got: %v", err)
+	}
+	for _, c := range []*collect{a, b} {
+		if want := []uint64{2, 4}; !reflect.DeepEqual(want, c.vxids) || !c.closed {
+			t.Errorf("sink should get %v and be closed, got %v (%v)", want, c.vxids, c.closed)
+		}
+	}
+	if want := []string{"Cookie: [REDACTED]"}; !reflect.DeepEqual(want, cookies) {
+		t.Errorf("transform should see %v, got %v", want, cookies)
+	}
+
+	fail := errors.New("sink is down")
+	p = New(SinkFunc(func(*vslparser.Entry) error { return fail }))
+	if err := p.Run(vslparser.NewParser(strings.NewReader(s))); !errors.Is(err, fail) {
+		t.Errorf("running pipeline should give the error of the sink, got: %v", err)
+	} else {
+		t.Logf("running pipeline with failing sink gives: %v", err)
+	}
+	p = New(&collect{})
+	if err := p.Run(vslparser.NewParser(strings.NewReader("foo"))); err == nil {
+		t.Errorf("running pipeline on malformed input should fail")
+	}
+}
+
+// TestThrottle tests that entries in excess of the rate are deferred.
+func TestThrottle(t *testing.T) {
+	c := &collect{}
+	p := New(c, Throttle(vslparser.NewRateLimiter(100, 1)))
+	start := time.Now()
+	for i := range 3 {
+		if err := p.Push(vslparser.NewEntry(vslparser.Request, uint64(i))); err != nil {
+			t.Fatalf("pushing entry should not fail, got: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 15*time.Millisecond || len(c.vxids) != 3 {
+		t.Errorf("throttling 3 entries at 100 per second should take 20ms, took %v for %v", d, c.vxids)
+	}
+}
@@ -0,0 +1,539 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a compiled query of the varnishlog query language, see
+// vsl-query(7), e.g.
+//
+//	ReqHeader:host ~ 'example.com' and Timestamp:Resp[2] > 0.5
+//
+// A query is made of record tests combined by "and", "or" and "not", in order
+// of increasing precedence, and grouped by parentheses. A record test has the
+// form
+//
+//	{level}taglist:prefix[field] operator operand
+//
+// where all parts but the taglist are optional:
+//
+//   - The level restricts the test to transactions of the given nesting level,
+//     e.g. {2}, or of levels up to or from it, e.g. {2-} or {2+}. Entries
+//     which are not grouped are at level 1.
+//   - The taglist is a comma-separated list of tags, e.g. "ReqURL,BereqURL",
+//     each of which may start or end with a wildcard, e.g. "Req*". Tags are
+//     case-insensitive and must match known tags, see IsKnownTag. The pseudo
+//     tag "vxid" tests the VXID of transactions instead of their records.
+//   - The prefix selects records whose value starts with the prefix followed
+//     by a colon, case-insensitive, e.g. headers by their names, and the test
+//     applies to the rest of the value without leading white space.
+//   - The field selects the field of the value with the given index, starting
+//     with 1, where fields are separated by white space.
+//   - The operator is one of the numeric comparisons "==", "!=", "<", "<=",
+//     ">" and ">=", the string comparisons "eq" and "ne", or the regular
+//     expression matches "~" and "!~". Numeric comparisons are of integers if
+//     the operand is an integer and of floating point numbers otherwise, and
+//     values which are not numbers of the type do not match. Regular
+//     expressions are of the syntax of package regexp. Operands are numbers,
+//     words or strings in single or double quotes. Without an operator, the
+//     test is whether any record is selected.
+//
+// A record test is true if any selected record of any transaction of the
+// group matches, so that tests combined by "and" may be true of different
+// records or transactions, like in varnishlog.
+type Query struct {
+	src  string
+	expr queryExpr
+}
+
+// queryExpr is a node of a compiled query.
+type queryExpr interface {
+	eval(e *Entry) bool
+}
+
+type queryAnd struct{ l, r queryExpr }
+
+func (q queryAnd) eval(e *Entry) bool { return q.l.eval(e) && q.r.eval(e) }
+
+type queryOr struct{ l, r queryExpr }
+
+func (q queryOr) eval(e *Entry) bool { return q.l.eval(e) || q.r.eval(e) }
+
+type queryNot struct{ x queryExpr }
+
+func (q queryNot) eval(e *Entry) bool { return !q.x.eval(e) }
+
+// queryOperand is the type of the operand of a record test.
+type queryOperand int
+
+const (
+	operandNone queryOperand = iota
+	operandInt
+	operandFloat
+	operandString
+	operandRegexp
+)
+
+// queryTest is a record test of a query.
+type queryTest struct {
+	level   int             // Level of transactions, 0 for all.
+	levelOp byte            // '=', '-' or '+' for levels up to or from level.
+	vxid    bool            // Whether the VXID is tested instead of records.
+	tags    map[string]bool // Tags of selected records.
+	prefix  string          // Prefix of selected records, empty for none.
+	field   int             // Index of the selected field, 0 for the whole value.
+	op      string          // Operator of the test, empty for none.
+	operand queryOperand    // Type of the operand.
+	i       int64
+	f       float64
+	s       string
+	re      *regexp.Regexp
+}
+
+// eval returns whether the test is true of any transaction of the group e.
+func (q *queryTest) eval(e *Entry) bool {
+	return !e.Walk(func(t *Entry) bool {
+		return !q.matchEntry(t)
+	})
+}
+
+// matchEntry returns whether the test is true of the transaction e.
+func (q *queryTest) matchEntry(e *Entry) bool {
+	level := max(e.Level, 1)
+	switch {
+	case q.level == 0:
+	case q.levelOp == '=' && level != q.level,
+		q.levelOp == '-' && level > q.level,
+		q.levelOp == '+' && level < q.level:
+		return false
+	}
+	if q.vxid {
+		return q.compare(strconv.FormatUint(e.VXID, 10))
+	}
+	for tag, vs := range e.Fields {
+		if !q.tags[tag] {
+			continue
+		}
+		for _, v := range vs {
+			if v, ok := q.selectValue(v); ok && q.compare(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectValue returns the part of the record value v the test applies to, or
+// false if the record is not selected.
+func (q *queryTest) selectValue(v string) (string, bool) {
+	if q.prefix != "" {
+		n := len(q.prefix)
+		if len(v) <= n || v[n] != ':' || !strings.EqualFold(v[:n], q.prefix) {
+			return "", false
+		}
+		v = strings.TrimLeftFunc(v[n+1:], unicode.IsSpace)
+	}
+	if q.field > 0 {
+		fs := strings.Fields(v)
+		if len(fs) < q.field {
+			return "", false
+		}
+		v = fs[q.field-1]
+	}
+	return v, true
+}
+
+// compare returns whether the selected value v satisfies the operator.
+func (q *queryTest) compare(v string) bool {
+	var c int
+	switch q.operand {
+	case operandNone:
+		return true
+	case operandInt:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return false
+		}
+		c = compareNumbers(i, q.i)
+	case operandFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return false
+		}
+		c = compareNumbers(f, q.f)
+	case operandString:
+		return (v == q.s) == (q.op == "eq")
+	case operandRegexp:
+		return q.re.MatchString(v) == (q.op == "~")
+	}
+	switch q.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// compareNumbers returns -1, 0 or 1 if a is less than, equal to or greater
+// than b.
+func compareNumbers[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// CompileQuery parses the query s, see Query.
+func CompileQuery(s string) (*Query, error) {
+	p := &queryParser{src: s}
+	p.next()
+	expr, err := p.parseOr()
+	if err == nil && p.tok.kind != tokenEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid query %q", s)
+	}
+	return &Query{src: s, expr: expr}, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the query is invalid.
+func MustCompileQuery(s string) *Query {
+	q, err := CompileQuery(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Match returns whether the query is true of the entry e and the transactions
+// nested in it, see Query. Query is a Filter.
+func (q *Query) Match(e *Entry) bool {
+	return q.expr.eval(e)
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// queryTokenKind is the kind of a token of a query.
+type queryTokenKind int
+
+const (
+	tokenEOF queryTokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenPunct
+	tokenInvalid
+)
+
+// queryToken is a token of a query.
+type queryToken struct {
+	kind queryTokenKind
+	text string // Text of the token, unquoted for strings.
+	pos  int    // Offset of the token in the query.
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// queryOps are the operators of the query language.
+var queryOps = []string{"==", "!=", "<=", ">=", "!~", "<", ">", "~"}
+
+// queryParser is a recursive descent parser of queries.
+type queryParser struct {
+	src string
+	pos int
+	tok queryToken
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return errors.Errorf(format+" at offset %d", append(args, p.tok.pos)...)
+}
+
+// next reads the next token.
+func (p *queryParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.src) {
+		p.tok = queryToken{kind: tokenEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	if strings.IndexByte("(){}[]:,", c) >= 0 {
+		p.pos++
+		p.tok = queryToken{kind: tokenPunct, text: string(c), pos: start}
+		return
+	}
+	if c == '\'' || c == '"' {
+		// A backslash escapes the quote, other backslashes are kept, e.g.
+		// in regular expressions.
+		var b strings.Builder
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] != c; p.pos++ {
+			if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == c {
+				p.pos++
+			}
+			b.WriteByte(p.src[p.pos])
+		}
+		if p.pos == len(p.src) {
+			p.tok = queryToken{kind: tokenInvalid, text: p.src[start:], pos: start}
+			return
+		}
+		p.pos++
+		p.tok = queryToken{kind: tokenString, text: b.String(), pos: start}
+		return
+	}
+	for _, op := range queryOps {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			p.tok = queryToken{kind: tokenOp, text: op, pos: start}
+			return
+		}
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if unicode.IsSpace(rune(c)) || strings.IndexByte("(){}[]:,'\"=!<>~", c) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++
+		p.tok = queryToken{kind: tokenInvalid, text: p.src[start:p.pos], pos: start}
+		return
+	}
+	p.tok = queryToken{kind: tokenWord, text: p.src[start:p.pos], pos: start}
+}
+
+// isKeyword returns whether the current token is the given keyword.
+func (p *queryParser) isKeyword(k string) bool {
+	return p.tok.kind == tokenWord && p.tok.text == k
+}
+
+// expect skips the punctuation c, or fails if it is missing.
+func (p *queryParser) expect(c string) error {
+	if p.tok.kind != tokenPunct || p.tok.text != c {
+		return p.errorf("expected '%s', got %s", c, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	l, err := p.parseAnd()
+	for err == nil && p.isKeyword("or") {
+		p.next()
+		var r queryExpr
+		if r, err = p.parseAnd(); err == nil {
+			l = queryOr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	l, err := p.parseNot()
+	for err == nil && p.isKeyword("and") {
+		p.next()
+		var r queryExpr
+		if r, err = p.parseNot(); err == nil {
+			l = queryAnd{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.isKeyword("not") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{x}, nil
+	}
+	if p.tok.kind == tokenPunct && p.tok.text == "(" {
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+	return p.parseTest()
+}
+
+// parseTest parses a record test.
+func (p *queryParser) parseTest() (queryExpr, error) {
+	q := &queryTest{}
+	if p.tok.kind == tokenPunct && p.tok.text == "{" {
+		p.next()
+		if err := p.parseLevel(q); err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parseTags(q); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenPunct && p.tok.text == ":" {
+		p.next()
+		if p.tok.kind != tokenWord && p.tok.kind != tokenString || p.tok.text == "" {
+			return nil, p.errorf("expected record prefix, got %s", p.tok)
+		}
+		q.prefix = p.tok.text
+		p.next()
+	}
+	if p.tok.kind == tokenPunct && p.tok.text == "[" {
+		p.next()
+		n, err := strconv.Atoi(p.tok.text)
+		if p.tok.kind != tokenWord || err != nil || n < 1 {
+			return nil, p.errorf("expected field index, got %s", p.tok)
+		}
+		q.field = n
+		p.next()
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	if q.vxid && (q.prefix != "" || q.field > 0) {
+		return nil, p.errorf("vxid takes neither a record prefix nor a field")
+	}
+	if p.tok.kind == tokenOp || p.isKeyword("eq") || p.isKeyword("ne") {
+		q.op = p.tok.text
+		p.next()
+		if err := p.parseOperand(q); err != nil {
+			return nil, err
+		}
+	}
+	if q.vxid && q.operand != operandInt {
+		return nil, p.errorf("vxid must be compared to an integer")
+	}
+	return q, nil
+}
+
+// parseLevel parses the level of a record test, e.g. "2", "2-" or "2+".
+func (p *queryParser) parseLevel(q *queryTest) error {
+	s := p.tok.text
+	q.levelOp = '='
+	if strings.HasSuffix(s, "+") || strings.HasSuffix(s, "-") {
+		q.levelOp = s[len(s)-1]
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if p.tok.kind != tokenWord || err != nil || n < 1 {
+		return p.errorf("expected level, got %s", p.tok)
+	}
+	q.level = n
+	p.next()
+	return nil
+}
+
+// parseTags parses the taglist of a record test.
+func (p *queryParser) parseTags(q *queryTest) error {
+	for {
+		if p.tok.kind != tokenWord {
+			return p.errorf("expected tag, got %s", p.tok)
+		}
+		if p.tok.text == "vxid" {
+			if q.tags != nil {
+				return p.errorf("vxid cannot be part of a taglist")
+			}
+			q.vxid = true
+			p.next()
+			if p.tok.kind == tokenPunct && p.tok.text == "," {
+				return p.errorf("vxid cannot be part of a taglist")
+			}
+			return nil
+		}
+		if q.tags == nil {
+			q.tags = map[string]bool{}
+		}
+		set, err := expandTags([]string{p.tok.text})
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		maps.Copy(q.tags, set)
+		p.next()
+		if p.tok.kind != tokenPunct || p.tok.text != "," {
+			return nil
+		}
+		p.next()
+	}
+}
+
+// matchTagGlob returns whether tag matches the pattern, which may start or end
+// with a wildcard, case-insensitive.
+func matchTagGlob(pattern, tag string) bool {
+	pattern, tag = strings.ToLower(pattern), strings.ToLower(tag)
+	prefix := strings.HasSuffix(pattern, "*")
+	pattern = strings.TrimSuffix(pattern, "*")
+	suffix := strings.HasPrefix(pattern, "*")
+	pattern = strings.TrimPrefix(pattern, "*")
+	switch {
+	case prefix && suffix:
+		return strings.Contains(tag, pattern)
+	case prefix:
+		return strings.HasPrefix(tag, pattern)
+	case suffix:
+		return strings.HasSuffix(tag, pattern)
+	}
+	return tag == pattern
+}
+
+// parseOperand parses the operand of the operator of a record test.
+func (p *queryParser) parseOperand(q *queryTest) error {
+	if p.tok.kind != tokenWord && p.tok.kind != tokenString {
+		return p.errorf("expected operand, got %s", p.tok)
+	}
+	s := p.tok.text
+	switch q.op {
+	case "eq", "ne":
+		q.operand, q.s = operandString, s
+	case "~", "!~":
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return p.errorf("invalid regular expression %s: %v", p.tok, err)
+		}
+		q.operand, q.re = operandRegexp, re
+	default:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			q.operand, q.i = operandInt, i
+		} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+			q.operand, q.f = operandFloat, f
+		} else {
+			return p.errorf("expected number, got %s", p.tok)
+		}
+	}
+	p.next()
+	return nil
+}
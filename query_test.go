@@ -0,0 +1,123 @@
+package vslparser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestQuery tests that queries of the varnishlog query language select the
+// same groups as varnishlog, and that invalid queries are rejected.
+func TestQuery(t *testing.T) {
+	s := `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   ReqURL         /foo
+-   ReqHeader      Host: www.example.com
+-   ReqHeader      X-Forwarded-For: 192.0.2.1
+-   Timestamp      Resp: 1545037998.267831 0.750000 0.000047
+-   RespStatus     200
+-   End
+**  << BeReq    >> 3
+--  Begin          bereq 2 fetch
+--  BereqURL       /foo
+--  BerespStatus   503
+--  End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse request group: %v", err)
+	}
+	samples := map[string]bool{
+		`ReqURL`:                                 true,
+		`ReqMethod`:                              false,
+		`ReqURL eq "/foo"`:                       true,
+		`ReqURL ne /foo`:                         false,
+		`requrl eq /foo`:                         true,
+		`ReqHeader:host ~ 'example.com'`:         true,
+		`ReqHeader:host ~ '^example\.com$'`:      false,
+		`ReqHeader:host !~ '^example\.com$'`:     true,
+		`ReqHeader:ho`:                           false,
+		`ReqHeader:x-forwarded-for eq 192.0.2.1`: true,
+		`Timestamp:Resp[2] > 0.5`:                true,
+		`Timestamp:Resp[2] > 0.75`:               false,
+		`Timestamp:Resp[2] >= 0.75`:              true,
+		`Timestamp:Resp[4] >= 0`:                 false,
+		`ReqHeader:host ~ 'example.com' and Timestamp:Resp[2] > 0.5`: true,
+		`RespStatus == 200`:                                   true,
+		`RespStatus == 200.0`:                                 true,
+		`RespStatus != 200`:                                   false,
+		`RespStatus < 300 and BerespStatus >= 500`:            true,
+		`*Status >= 500`:                                      true,
+		`Resp*,Beresp* == 503`:                                true,
+		`RespStatus == 503 or BerespStatus == 503`:            true,
+		`not BerespStatus == 503`:                             false,
+		`not (RespStatus == 503 or BerespStatus == 200)`:      true,
+		`RespStatus == 503 or BerespStatus == 200 and ReqURL`: false,
+		`{1}BerespStatus == 503`:                              false,
+		`{2}BerespStatus == 503`:                              true,
+		`{1-}BereqURL`:                                        false,
+		`{2+}BereqURL`:                                        true,
+		`{2}ReqURL`:                                           false,
+		`vxid == 3`:                                           true,
+		`{1}vxid == 3`:                                        false,
+		`vxid > 3`:                                            false,
+		`Begin[3] eq fetch`:                                   true,
+		`Begin[2] == 1`:                                       true,
+		`Begin[2] == 1.5`:                                     false,
+		`ReqURL == 1`:                                         false,
+	}
+	for src, want := range samples {
+		q, err := CompileQuery(src)
+		if err != nil {
+			t.Errorf("compiling %q should not fail, got: %v", src, err)
+			continue
+		}
+		if got := q.Match(e); got != want {
+			t.Errorf("query %q should give %v, got %v", src, want, got)
+		}
+		if q.String() != src {
+			t.Errorf("query %q should have its source, got %q", src, q.String())
+		}
+	}
+	if q := MustCompileQuery(`vxid == 2`); !q.Match(NewEntry(Request, 2)) {
+		t.Errorf("query %v should match entry without grouping", q)
+	}
+
+	bad := []string{
+		``,
+		`ReqURL eq`,
+		`ReqURL ==`,
+		`ReqURL == foo`,
+		`ReqURL ~ '('`,
+		`ReqURL eq 'foo`,
+		`ReqURL eq foo bar`,
+		`NoSuchTag`,
+		`Foo*`,
+		`(ReqURL`,
+		`ReqURL)`,
+		`ReqURL and`,
+		`not`,
+		`{x}ReqURL`,
+		`{0}ReqURL`,
+		`{1ReqURL`,
+		`ReqURL[0]`,
+		`ReqURL[1`,
+		`ReqHeader:`,
+		`vxid`,
+		`vxid eq 1`,
+		`vxid == 1.5`,
+		`vxid,ReqURL == 1`,
+		`ReqURL,vxid == 1`,
+		`vxid:foo == 1`,
+		`ReqURL = 1`,
+	}
+	for _, src := range bad {
+		if _, err := CompileQuery(src); err == nil {
+			t.Errorf("compiling %q should fail", src)
+		} else {
+			t.Logf("compiling %q gives: %v", src, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package vslparser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bucket is the token bucket of a key of a RateLimiter.
+type bucket struct {
+	tokens float64   // Available tokens, negative if reserved ahead.
+	last   time.Time // Time the tokens were last refilled.
+}
+
+// RateLimiter limits the rate of entries by token buckets, e.g. to protect a
+// sink from traffic spikes. Each entry takes a token from the bucket of its
+// key, which holds up to Burst tokens and is refilled at Rate tokens per
+// second. Entries in excess of the rate are either dropped, by Allow or by
+// using the limiter as a Filter, or deferred until a token is available, by
+// Wait.
+//
+// Unlike other filters of this package, the rate limiter works with the
+// time of arrival of entries, not with the time they were logged at. The
+// zero value does not limit entries. RateLimiters are safe for concurrent
+// use, but must not be changed once in use.
+type RateLimiter struct {
+	// Rate is the number of entries per second let through for each key. If
+	// it is not positive, entries are not limited.
+	Rate float64
+	// Burst is the number of entries let through for each key at once,
+	// e.g. after a period of inactivity. If less than 1, it is 1.
+	Burst int
+	// Key, if set, returns the key of entries limited separately, e.g. their
+	// URL or backend. If nil, all entries share a single bucket.
+	Key func(e *Entry) string
+	// Now, if set, returns the current time instead of time.Now, e.g. for
+	// testing.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	purgeAt int // Number of buckets at which full buckets are purged.
+}
+
+// NewRateLimiter returns a rate limiter letting through rate entries per
+// second with bursts of up to burst entries.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst}
+}
+
+// key returns the key of the bucket of the entry e.
+func (l *RateLimiter) key(e *Entry) string {
+	if l.Key == nil {
+		return ""
+	}
+	return l.Key(e)
+}
+
+// take takes a token from the bucket of the entry e, if one is available, or
+// if reserve is set, in advance. It returns the time to wait until the token
+// is available, 0 if it is, and whether a token was taken.
+func (l *RateLimiter) take(e *Entry, reserve bool) (time.Duration, bool) {
+	if l.Rate <= 0 {
+		return 0, true
+	}
+	key := l.key(e)
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	t := now()
+	burst := float64(max(l.Burst, 1))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = map[string]*bucket{}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		l.purge(t, burst)
+		b = &bucket{tokens: burst, last: t}
+		l.buckets[key] = b
+	}
+	if t.After(b.last) {
+		b.tokens = min(burst, b.tokens+t.Sub(b.last).Seconds()*l.Rate)
+		b.last = t
+	}
+	if b.tokens < 1 && !reserve {
+		return 0, false
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-b.tokens / l.Rate * float64(time.Second)), true
+}
+
+// purge removes the buckets which are full at time t, and hence the same as
+// new ones, once there are too many buckets, so that the buckets of keys
+// seen once do not pile up.
+func (l *RateLimiter) purge(t time.Time, burst float64) {
+	if len(l.buckets) < l.purgeAt {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.tokens+t.Sub(b.last).Seconds()*l.Rate >= burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.purgeAt = max(2*len(l.buckets), 1024)
+}
+
+// Allow returns whether the entry e is let through, taking a token if it is.
+func (l *RateLimiter) Allow(e *Entry) bool {
+	_, ok := l.take(e, false)
+	return ok
+}
+
+// Match is the same as Allow, so that RateLimiter is a Filter dropping the
+// entries in excess of the rate.
+func (l *RateLimiter) Match(e *Entry) bool {
+	return l.Allow(e)
+}
+
+// Wait blocks until the entry e is let through. If ctx is done before, the
+// token reserved for e is given back and the error of ctx is returned.
+func (l *RateLimiter) Wait(ctx context.Context, e *Entry) error {
+	d, _ := l.take(e, true)
+	if d == 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.giveBack(e)
+		return ctx.Err()
+	}
+}
+
+// giveBack returns a token reserved for the entry e to its bucket.
+func (l *RateLimiter) giveBack(e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[l.key(e)]; ok {
+		b.tokens++
+	}
+}
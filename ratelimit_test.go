@@ -0,0 +1,74 @@
+package vslparser
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter tests that entries in excess of the rate are dropped, per
+// key if a key is set, and that waiting entries are deferred.
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1545037998, 0)
+	l := NewRateLimiter(2, 3)
+	l.Key = func(e *Entry) string { return e.TryField("ReqURL") }
+	l.Now = func() time.Time { return now }
+	foo := NewEntry(Request, 1).Add("ReqURL", "/foo")
+	bar := NewEntry(Request, 2).Add("ReqURL", "/bar")
+
+	// allowed returns the number of n entries e allowed at once.
+	allowed := func(e *Entry, n int) int {
+		a := 0
+		for range n {
+			if l.Allow(e) {
+				a++
+			}
+		}
+		return a
+	}
+	if n := allowed(foo, 5); n != 3 {
+		t.Errorf("limiter should allow a burst of 3 entries, got %d", n)
+	}
+	if n := allowed(bar, 5); n != 3 {
+		t.Errorf("limiter should allow a burst of 3 entries of another key, got %d", n)
+	}
+	now = now.Add(time.Second)
+	if n := allowed(foo, 5); n != 2 {
+		t.Errorf("limiter should allow 2 entries after a second, got %d", n)
+	}
+	now = now.Add(time.Hour)
+	if n := allowed(foo, 5); n != 3 {
+		t.Errorf("limiter should allow no more than a burst of 3 entries after an hour, got %d", n)
+	}
+	if !l.Match(bar) || l.Match(foo) {
+		t.Errorf("limiter should be a filter allowing entries of refilled keys only")
+	}
+
+	var unlimited RateLimiter
+	if !unlimited.Allow(foo) || unlimited.Wait(context.Background(), foo) != nil {
+		t.Errorf("zero limiter should allow all entries")
+	}
+
+	l = NewRateLimiter(100, 1)
+	start := time.Now()
+	for range 3 {
+		if err := l.Wait(context.Background(), foo); err != nil {
+			t.Fatalf("waiting should not fail, got: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("waiting for 3 entries at 100 per second should take 20ms, took %v", d)
+	}
+	l = NewRateLimiter(0.001, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, foo); err != nil {
+		t.Errorf("first entry should not wait, got: %v", err)
+	}
+	if err := l.Wait(ctx, foo); err != context.Canceled {
+		t.Errorf("waiting should give the error of a canceled context, got: %v", err)
+	}
+	if l.buckets[""].tokens < 0 {
+		t.Errorf("canceled wait should give its token back, got %v tokens", l.buckets[""].tokens)
+	}
+}
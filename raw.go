@@ -0,0 +1,164 @@
+package vslparser
+
+import (
+	"bufio"
+	"github.com/pkg/errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RawRecord is a single log record as found in raw grouping output of
+// varnishlog (varnishlog -g raw), which lacks entry headers and "End"
+// terminators. Each record carries its own VXID instead, e.g.:
+//
+//	32770 ReqURL         c /health
+//	    0 Backend_health - boot.default Still healthy 4---X-RH 5 3 5 0.000602 0.000783 HTTP/1.1 200 OK
+type RawRecord struct {
+	VXID  uint64 // VXID of the transaction the record belongs to.
+	Tag   string // Tag of the record, e.g. "ReqURL".
+	Type  byte   // 'c' for client, 'b' for backend and '-' for other records.
+	Value string // Value of the record.
+}
+
+// ParseRaw will attempt to produce a single RawRecord from raw grouping
+// output of varnishlog, which it reads using the given scanner. Empty lines
+// are skipped. io.EOF is returned once there are no more records to be read.
+func ParseRaw(scanner *bufio.Scanner) (*RawRecord, error) {
+	p := &Parser{scanner: scanner}
+	return p.NextRaw()
+}
+
+// NextRaw parses the next RawRecord from raw grouping output of varnishlog.
+// See ParseRaw for details.
+func (p *Parser) NextRaw() (*RawRecord, error) {
+	p.init()
+	for p.scan() {
+		if p.text == "" {
+			continue
+		}
+		return parseRawLine(p.text)
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// parseRawLine parses a single line of raw grouping output of varnishlog.
+func parseRawLine(line string) (*RawRecord, error) {
+	id, rest := splitLine(line)
+	vxid, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse error on line %q: cannot parse VXID", line)
+	}
+	tag, mark, value, ok := splitVerbose(rest)
+	if !ok {
+		return nil, errors.Errorf("parse error on line %q: malformed record", line)
+	}
+	return &RawRecord{
+		VXID:  vxid,
+		Tag:   tag,
+		Type:  mark[0],
+		Value: value,
+	}, nil
+}
+
+// beginKinds maps the transaction types found in Begin records to the kinds
+// of entries.
+var beginKinds = map[string]Kind{
+	"sess":  Session,
+	"req":   Request,
+	"bereq": BeReq,
+}
+
+// vxidGrouper assembles entries from interleaved raw records the same way
+// varnishlog does in its default VXID grouping. Records which belong to no
+// transaction, i.e. whose VXID is zero, are dropped.
+//
+// Entries of transactions whose first records are missing, e.g. since they
+// began before the log was attached to or their records were overwritten,
+// have Incomplete set. Once there are more than max entries open, the
+// oldest ones are returned with Truncated set, since the End records of
+// their transactions were most likely lost.
+type vxidGrouper struct {
+	max   int // Maximum number of open entries, not limited if not positive.
+	open  map[uint64]*Entry
+	order []uint64 // VXIDs in the order their entries were opened, including closed ones.
+	ready []*Entry // Entries complete or evicted, yet to be returned.
+}
+
+// add adds the record r to the entry of its transaction. Once the "End"
+// record of a transaction is added, its entry is complete and returned by
+// next.
+func (g *vxidGrouper) add(r *RawRecord) {
+	if r.VXID == 0 {
+		return
+	}
+	if g.open == nil {
+		g.open = make(map[uint64]*Entry)
+	}
+	e, ok := g.open[r.VXID]
+	if !ok {
+		e = newEntry()
+		e.VXID = r.VXID
+		e.Level = 1
+		e.Incomplete = r.Tag != "Begin"
+		g.open[r.VXID] = e
+		g.order = append(g.order, r.VXID)
+		g.evict()
+	}
+	switch r.Tag {
+	case "End":
+		delete(g.open, r.VXID)
+		g.ready = append(g.ready, e)
+		return
+	case "Begin":
+		t, _, _ := strings.Cut(r.Value, " ")
+		var ok bool
+		if e.Kind, ok = beginKinds[t]; !ok {
+			e.RawKind = t
+		}
+	}
+	e.add(r.Tag, r.Value)
+}
+
+// evict makes the oldest entries ready, flagged as truncated, while there are
+// more than g.max open, and drops the VXIDs of closed entries from g.order
+// once they outnumber the open ones.
+func (g *vxidGrouper) evict() {
+	for g.max > 0 && len(g.open) > g.max {
+		vxid := g.order[0]
+		g.order = g.order[1:]
+		if e, ok := g.open[vxid]; ok {
+			delete(g.open, vxid)
+			e.Truncated = true
+			g.ready = append(g.ready, e)
+		}
+	}
+	if len(g.order) > 2*len(g.open)+16 {
+		g.order = slices.DeleteFunc(g.order, func(vxid uint64) bool {
+			_, ok := g.open[vxid]
+			return !ok
+		})
+	}
+}
+
+// next returns the next entry which is complete or was evicted, or nil if
+// there is none.
+func (g *vxidGrouper) next() *Entry {
+	if len(g.ready) == 0 {
+		return nil
+	}
+	e := g.ready[0]
+	g.ready[0] = nil
+	g.ready = g.ready[1:]
+	return e
+}
+
+// reset drops the open entries, e.g. after records were lost.
+func (g *vxidGrouper) reset() {
+	g.open = nil
+	g.order = nil
+}
@@ -0,0 +1,126 @@
+package vslparser
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseRaw tests that raw grouping output is parsed into records and that
+// malformed lines produce errors.
+func TestParseRaw(t *testing.T) {
+	s := `
+         0 CLI            - Rd ping
+     32770 Begin          c req 32769 rxreq
+
+     32770 ReqURL         c /health
+     32771 BerespStatus   b 200
+     32770 End            c 
+ 4294967298 ReqURL         c /
+`
+	want := []*RawRecord{
+		&RawRecord{VXID: 0, Tag: "CLI", Type: '-', Value: "Rd ping"},
+		&RawRecord{VXID: 32770, Tag: "Begin", Type: 'c', Value: "req 32769 rxreq"},
+		&RawRecord{VXID: 32770, Tag: "ReqURL", Type: 'c', Value: "/health"},
+		&RawRecord{VXID: 32771, Tag: "BerespStatus", Type: 'b', Value: "200"},
+		&RawRecord{VXID: 32770, Tag: "End", Type: 'c', Value: ""},
+		&RawRecord{VXID: 4294967298, Tag: "ReqURL", Type: 'c', Value: "/"},
+	}
+	scanner := stringScanner(s)
+	for _, r := range want {
+		got, err := ParseRaw(scanner)
+		if err != nil {
+			t.Fatalf("failed to parse raw record: %v", err)
+		}
+		if !reflect.DeepEqual(r, got) {
+			t.Errorf("parsing should give %v, got %v", r, got)
+		}
+	}
+	if _, err := ParseRaw(scanner); err != io.EOF {
+		t.Errorf("parsing should result in an EOF error, got: %v", err)
+	}
+
+	bad := []string{
+		"foo ReqURL c /health",
+		"32770 ReqURL /health",
+		"32770",
+		"18446744073709551616 ReqURL c /health",
+	}
+	for _, s := range bad {
+		if _, err := NewParser(strings.NewReader(s)).NextRaw(); err == nil {
+			t.Errorf("parsing %q should be a parse error", s)
+		} else {
+			t.Logf("parsing %q gives: %v", s, err)
+		}
+	}
+}
+
+// TestVXIDGrouper tests that records are assembled into entries, keeping the
+// kinds of unknown transaction types, that partial entries are flagged and
+// that the number of open entries is bounded.
+func TestVXIDGrouper(t *testing.T) {
+	g := &vxidGrouper{}
+	records := []*RawRecord{
+		&RawRecord{VXID: 5, Tag: "Begin", Type: 'c', Value: "quic 1 rxreq"},
+		&RawRecord{VXID: 0, Tag: "CLI", Type: '-', Value: "Rd ping"},
+		&RawRecord{VXID: 6, Tag: "Begin", Type: 'b', Value: "bereq 5 fetch"},
+		&RawRecord{VXID: 5, Tag: "End", Type: 'c'},
+	}
+	for _, r := range records {
+		g.add(r)
+	}
+	got := g.next()
+	want := &Entry{
+		Kind:    Unknown,
+		RawKind: "quic",
+		VXID:    5,
+		Level:   1,
+		Fields:  Fields{"Begin": []string{"quic 1 rxreq"}},
+		Records: Records{{"Begin", "quic 1 rxreq"}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("grouping records should give %v, got %v", want, got)
+	}
+	if e := g.next(); e != nil {
+		t.Errorf("grouping records should give a single entry, got %v", e)
+	}
+
+	// Transactions which began before the records were read.
+	g = &vxidGrouper{max: 2}
+	g.add(&RawRecord{VXID: 7, Tag: "ReqURL", Type: 'c', Value: "/"})
+	g.add(&RawRecord{VXID: 7, Tag: "End", Type: 'c'})
+	if e := g.next(); e == nil || e.VXID != 7 || !e.Incomplete || e.Truncated || e.Kind != Unknown {
+		t.Errorf("grouping records of a partial transaction should give incomplete entry 7, got %v", e)
+	}
+
+	// Transactions whose End records were lost are evicted, oldest first.
+	for vxid := uint64(10); vxid < 1000; vxid++ {
+		g.add(&RawRecord{VXID: vxid, Tag: "Begin", Type: 'c', Value: "req 1 rxreq"})
+		if vxid%2 == 0 {
+			g.add(&RawRecord{VXID: vxid, Tag: "End", Type: 'c'})
+		}
+	}
+	var evicted []uint64
+	for e := g.next(); e != nil; e = g.next() {
+		if e.Truncated != (e.VXID%2 == 1) || e.Incomplete {
+			t.Errorf("only entries of transactions without End should be truncated, got %v", e)
+		}
+		if e.Truncated {
+			evicted = append(evicted, e.VXID)
+		}
+	}
+	if len(evicted) != 493 || evicted[0] != 11 || evicted[len(evicted)-1] != 995 {
+		t.Errorf("grouping too many open transactions should evict 11 to 995, got %v", evicted)
+	}
+	if len(g.open) != 2 || len(g.order) > 2*len(g.open)+16 {
+		t.Errorf("grouper should hold 2 open entries, got %d open and %d queued", len(g.open), len(g.order))
+	}
+	g.reset()
+	g.add(&RawRecord{VXID: 997, Tag: "End", Type: 'c'})
+	for e := g.next(); e != nil; e = g.next() {
+		if e.VXID == 997 && !e.Incomplete {
+			t.Errorf("transaction 997 should be incomplete after reset, got %v", e)
+		}
+	}
+}
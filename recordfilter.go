@@ -0,0 +1,216 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+// RecordFilter selects the records to be kept by the parser, see
+// Options.Records, like the -i, -x, -I and -X options of varnishlog:
+//
+//	// varnishlog -i ReqURL,ReqHeader -X ReqHeader:^Cookie:
+//	f := &vslparser.RecordFilter{}
+//	f.Include("ReqURL", "ReqHeader")
+//	f.ExcludeMatching(regexp.MustCompile("^Cookie:"), "ReqHeader")
+//
+// Tags may start or end with a wildcard and are case-insensitive, like in a
+// Query. Like in varnishlog, a record is kept if it matches any regular
+// expression of IncludeMatching or any tag of Include, otherwise dropped if
+// it matches any regular expression of ExcludeMatching or any tag of Exclude.
+// Other records are dropped if the first selection was Include or
+// IncludeMatching and kept otherwise. Later selections of a tag by Include
+// and Exclude override earlier ones. The zero value keeps all records.
+type RecordFilter struct {
+	include, exclude     map[string]bool
+	includeRE, excludeRE []recordRegexp
+	selected             bool // Whether the filter was configured.
+	dropDefault          bool // Whether unselected records are dropped.
+}
+
+// recordRegexp is a regular expression matched against the values of records
+// of the given tags, or of all tags if tags is nil.
+type recordRegexp struct {
+	tags map[string]bool
+	re   *regexp.Regexp
+}
+
+// match returns whether the record with the given tag and value matches.
+func (r recordRegexp) match(tag, value string) bool {
+	return (r.tags == nil || r.tags[tag]) && r.re.MatchString(value)
+}
+
+// matchBytes is like match, for records in the buffer of the input.
+func (r recordRegexp) matchBytes(tag, value []byte) bool {
+	return (r.tags == nil || r.tags[string(tag)]) && r.re.Match(value)
+}
+
+// expandTags returns the set of known tags matching the given tags, which may
+// contain wildcards, see matchTagGlob, or an error if any of them matches no
+// known tag.
+func expandTags(tags []string) (map[string]bool, error) {
+	set := map[string]bool{}
+	for _, t := range tags {
+		n := 0
+		for tag := range knownTags {
+			if matchTagGlob(t, tag) {
+				set[tag] = true
+				n++
+			}
+		}
+		if n == 0 {
+			return nil, errors.Errorf("tag %q matches no tags", t)
+		}
+	}
+	return set, nil
+}
+
+// start records the first selection of the filter, which decides whether
+// unselected records are dropped.
+func (f *RecordFilter) start(include bool) {
+	if !f.selected {
+		f.selected = true
+		f.dropDefault = include
+		f.include, f.exclude = map[string]bool{}, map[string]bool{}
+	}
+}
+
+// Include keeps the records of the given tags, like varnishlog -i.
+func (f *RecordFilter) Include(tags ...string) error {
+	set, err := expandTags(tags)
+	if err != nil {
+		return err
+	}
+	f.start(true)
+	for tag := range set {
+		f.include[tag] = true
+		delete(f.exclude, tag)
+	}
+	return nil
+}
+
+// Exclude drops the records of the given tags, like varnishlog -x.
+func (f *RecordFilter) Exclude(tags ...string) error {
+	set, err := expandTags(tags)
+	if err != nil {
+		return err
+	}
+	f.start(false)
+	for tag := range set {
+		f.exclude[tag] = true
+		delete(f.include, tag)
+	}
+	return nil
+}
+
+// IncludeMatching keeps the records whose values match re, only of the given
+// tags unless none are given, like varnishlog -I.
+func (f *RecordFilter) IncludeMatching(re *regexp.Regexp, tags ...string) error {
+	r, err := newRecordRegexp(re, tags)
+	if err != nil {
+		return err
+	}
+	f.start(true)
+	f.includeRE = append(f.includeRE, r)
+	return nil
+}
+
+// ExcludeMatching drops the records whose values match re, only of the given
+// tags unless none are given, like varnishlog -X.
+func (f *RecordFilter) ExcludeMatching(re *regexp.Regexp, tags ...string) error {
+	r, err := newRecordRegexp(re, tags)
+	if err != nil {
+		return err
+	}
+	f.start(false)
+	f.excludeRE = append(f.excludeRE, r)
+	return nil
+}
+
+// newRecordRegexp returns the regular expression re restricted to the given
+// tags.
+func newRecordRegexp(re *regexp.Regexp, tags []string) (recordRegexp, error) {
+	r := recordRegexp{re: re}
+	if len(tags) > 0 {
+		var err error
+		if r.tags, err = expandTags(tags); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// Option applies the varnishlog option opt, one of 'i', 'x', 'I' and 'X', with
+// the argument arg as passed to varnishlog, e.g. "ReqURL,Resp*" for -i or
+// "ReqHeader:^Host:" for -X, so that command-line arguments can be reused.
+func (f *RecordFilter) Option(opt byte, arg string) error {
+	switch opt {
+	case 'i':
+		return f.Include(strings.Split(arg, ",")...)
+	case 'x':
+		return f.Exclude(strings.Split(arg, ",")...)
+	case 'I', 'X':
+		var tags []string
+		if l, expr, ok := strings.Cut(arg, ":"); ok {
+			tags, arg = strings.Split(l, ","), expr
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return errors.Wrapf(err, "invalid regular expression of -%c", opt)
+		}
+		if opt == 'I' {
+			return f.IncludeMatching(re, tags...)
+		}
+		return f.ExcludeMatching(re, tags...)
+	}
+	return errors.Errorf("unknown option -%c", opt)
+}
+
+// Keep returns whether the record with the given tag and value is kept.
+func (f *RecordFilter) Keep(tag, value string) bool {
+	if !f.selected {
+		return true
+	}
+	for _, r := range f.includeRE {
+		if r.match(tag, value) {
+			return true
+		}
+	}
+	if f.include[tag] {
+		return true
+	}
+	for _, r := range f.excludeRE {
+		if r.match(tag, value) {
+			return false
+		}
+	}
+	if f.exclude[tag] {
+		return false
+	}
+	return !f.dropDefault
+}
+
+// keepBytes is like Keep, for records in the buffer of the input, so that
+// records can be dropped without being copied out of it.
+func (f *RecordFilter) keepBytes(tag, value []byte) bool {
+	if !f.selected {
+		return true
+	}
+	for _, r := range f.includeRE {
+		if r.matchBytes(tag, value) {
+			return true
+		}
+	}
+	if f.include[string(tag)] {
+		return true
+	}
+	for _, r := range f.excludeRE {
+		if r.matchBytes(tag, value) {
+			return false
+		}
+	}
+	if f.exclude[string(tag)] {
+		return false
+	}
+	return !f.dropDefault
+}
@@ -0,0 +1,131 @@
+package vslparser
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRecordFilter tests that records are selected like by the -i, -x, -I and
+// -X options of varnishlog, and that the parser drops unselected records.
+func TestRecordFilter(t *testing.T) {
+	records := Records{
+		{"Begin", "req 1 rxreq"},
+		{"ReqURL", "/foo"},
+		{"ReqHeader", "Host: example.com"},
+		{"ReqHeader", "Cookie: a=b"},
+		{"RespStatus", "200"},
+		{"RespHeader", "Set-Cookie: a=c"},
+	}
+	type option struct {
+		Opt byte
+		Arg string
+	}
+	samples := []struct {
+		Options []option
+		Want    []string
+	}{
+		{nil, []string{"Begin", "ReqURL", "Host", "Cookie", "RespStatus", "Set-Cookie"}},
+		{[]option{{'i', "ReqURL,RespStatus"}}, []string{"ReqURL", "RespStatus"}},
+		{[]option{{'i', "Req*"}}, []string{"ReqURL", "Host", "Cookie"}},
+		{[]option{{'i', "*header"}}, []string{"Host", "Cookie", "Set-Cookie"}},
+		{[]option{{'x', "ReqHeader"}}, []string{"Begin", "ReqURL", "RespStatus", "Set-Cookie"}},
+		{[]option{{'i', "Req*"}, {'x', "ReqHeader"}}, []string{"ReqURL"}},
+		{[]option{{'x', "Req*"}, {'i', "ReqURL"}}, []string{"Begin", "ReqURL", "RespStatus", "Set-Cookie"}},
+		{[]option{{'I', "Cookie"}}, []string{"Cookie", "Set-Cookie"}},
+		{[]option{{'I', "ReqHeader:Cookie"}}, []string{"Cookie"}},
+		{[]option{{'X', "Cookie"}}, []string{"Begin", "ReqURL", "Host", "RespStatus"}},
+		{[]option{{'X', "ReqHeader,RespHeader:^(Set-)?Cookie:"}}, []string{"Begin", "ReqURL", "Host", "RespStatus"}},
+		{[]option{{'I', "example"}, {'x', "ReqHeader"}}, []string{"Host"}},
+		{[]option{{'i', "ReqHeader"}, {'X', "Cookie"}}, []string{"Host", "Cookie"}},
+	}
+	// name identifies the record by its tag or the name of its header.
+	name := func(r Record) string {
+		if n, _, ok := strings.Cut(r.Value, ":"); ok {
+			return n
+		}
+		return r.Tag
+	}
+	for _, s := range samples {
+		f := &RecordFilter{}
+		for _, o := range s.Options {
+			if err := f.Option(o.Opt, o.Arg); err != nil {
+				t.Fatalf("option -%c %s should not fail, got: %v", o.Opt, o.Arg, err)
+			}
+		}
+		var got []string
+		for _, r := range records {
+			if f.Keep(r.Tag, r.Value) {
+				got = append(got, name(r))
+			}
+		}
+		if !reflect.DeepEqual(s.Want, got) {
+			t.Errorf("filtering by %v should keep %v, got %v", s.Options, s.Want, got)
+		}
+	}
+
+	f := &RecordFilter{}
+	if err := f.Include("ReqURL"); err != nil {
+		t.Fatalf("including ReqURL should not fail, got: %v", err)
+	}
+	if err := f.IncludeMatching(regexp.MustCompile("^Host:"), "ReqHeader"); err != nil {
+		t.Fatalf("including ReqHeader matching ^Host: should not fail, got: %v", err)
+	}
+	p := NewParser(strings.NewReader(`
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   ReqURL         /foo
+-   ReqHeader      Host: example.com
+-   ReqHeader      Cookie: a=b
+-   End
+`))
+	p.Records = f
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("parsing with record filter should not fail, got: %v", err)
+	}
+	want := Records{{"ReqURL", "/foo"}, {"ReqHeader", "Host: example.com"}}
+	if !reflect.DeepEqual(want, e.Records) || !reflect.DeepEqual(want.Fields(), e.Fields) {
+		t.Errorf("parsing with record filter should keep %v, got %v", want, e.Records)
+	}
+	if e.Kind != Request || e.VXID != 2 {
+		t.Errorf("parsing with record filter should give request 2, got %v %d", e.Kind, e.VXID)
+	}
+
+	// Dropped records cost no allocations.
+	if raceEnabled {
+		return
+	}
+	allocs := func(dropped string) float64 {
+		s := "* << Request >> 2\n- ReqURL /foo\n" + strings.Repeat(dropped, 50) + "- End\n"
+		return testing.AllocsPerRun(100, func() {
+			p := NewParser(strings.NewReader(s))
+			p.Records = f
+			if _, err := p.Next(); err != nil {
+				t.Fatalf("parsing with record filter should not fail, got: %v", err)
+			}
+		})
+	}
+	base := allocs("")
+	for _, dropped := range []string{"- ReqHeader Cookie: a=b\n", "- 2 Timestamp c Start: 1545037998.759333 0.000000 0.000000\n"} {
+		if n := allocs(dropped); n != base {
+			t.Errorf("parsing 50 dropped records %q should allocate %v times, got %v", dropped, base, n)
+		}
+	}
+
+	bad := []option{
+		{'i', "NoSuchTag"},
+		{'x', "ReqURL,"},
+		{'I', "NoSuchTag:foo"},
+		{'X', "("},
+		{'q', "ReqURL"},
+	}
+	for _, o := range bad {
+		if err := (&RecordFilter{}).Option(o.Opt, o.Arg); err == nil {
+			t.Errorf("option -%c %s should fail", o.Opt, o.Arg)
+		} else {
+			t.Logf("option -%c %s gives: %v", o.Opt, o.Arg, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package vslparser
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// DefaultRedactToken replaces redacted values in RedactMask mode unless the
+// Redactor specifies another token.
+const DefaultRedactToken = "[REDACTED]"
+
+// RedactMode determines how a Redactor redacts values.
+type RedactMode int
+
+const (
+	// RedactMask replaces values with a fixed token.
+	RedactMask RedactMode = iota
+	// RedactHash replaces values with the hex-encoded HMAC-SHA256 of the
+	// value, so that equal values can still be correlated, see
+	// Redactor.Key.
+	RedactHash
+	// RedactDrop removes the records entirely.
+	RedactDrop
+)
+
+// Redactor redacts the values of configured tags and headers of entries, e.g.
+// before they are shipped to a third-party log store:
+//
+//	r := &vslparser.Redactor{
+//		Tags:    []string{"ReqStart"},
+//		Headers: []string{"Authorization", "Cookie", "Set-Cookie"},
+//	}
+//	r.Redact(e)
+//
+// Headers are redacted in all header records, i.e. records of tags ending
+// with "Header" or "Unset", such as ReqHeader or BerespUnset. Only the value
+// of the header is replaced, the name is kept.
+type Redactor struct {
+	Tags    []string   // Tags whose values are redacted.
+	Headers []string   // Names of headers whose values are redacted, compared case-insensitively.
+	Mode    RedactMode // How the values are redacted.
+	Token   string     // Token replacing values in RedactMask mode, DefaultRedactToken if empty.
+	// Key is the key of the HMAC in RedactHash mode. If it is empty, a
+	// random key is generated for the Redactor, so that values redacted by
+	// different Redactors cannot be correlated. Without a secret key, values
+	// with few possible values, such as client addresses, could be recovered
+	// by hashing candidates.
+	Key []byte
+
+	once sync.Once
+	key  []byte // Key generated if Key is empty.
+}
+
+// Redact redacts the entry and all transactions nested in it in place, both
+// in Fields and Records.
+func (r *Redactor) Redact(e *Entry) {
+	e.Walk(func(e *Entry) bool {
+		e.Edit(r.redact)
+		return true
+	})
+}
+
+// redact returns the redacted value of a record with the given tag and
+// whether the record should be kept.
+func (r *Redactor) redact(tag, v string) (string, bool) {
+	for _, t := range r.Tags {
+		if t == tag {
+			return r.replace(v)
+		}
+	}
+	if !isHeaderTag(tag) {
+		return v, true
+	}
+	name, val, err := rfc7230Split(v)
+	if err != nil {
+		return v, true
+	}
+	for _, h := range r.Headers {
+		if strings.EqualFold(h, name) {
+			val, ok := r.replace(val)
+			return name + ": " + val, ok
+		}
+	}
+	return v, true
+}
+
+// replace returns the replacement of the given value according to the mode
+// of the Redactor and whether the value should be kept.
+func (r *Redactor) replace(v string) (string, bool) {
+	switch r.Mode {
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.hashKey())
+		mac.Write([]byte(v))
+		return hex.EncodeToString(mac.Sum(nil)), true
+	case RedactDrop:
+		return "", false
+	}
+	if r.Token != "" {
+		return r.Token, true
+	}
+	return DefaultRedactToken, true
+}
+
+// hashKey returns the key of the HMAC in RedactHash mode, i.e. Key, or the
+// random key generated for the Redactor if Key is empty.
+func (r *Redactor) hashKey() []byte {
+	if len(r.Key) > 0 {
+		return r.Key
+	}
+	r.once.Do(func() {
+		r.key = make([]byte, sha256.Size)
+		if _, err := rand.Read(r.key); err != nil {
+			panic("cannot generate redaction key: " + err.Error())
+		}
+	})
+	return r.key
+}
@@ -0,0 +1,98 @@
+package vslparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// redactSample is a sample entry with sensitive values.
+const redactSample = `*   << Request  >> 2
+-   ReqStart       10.0.0.1 44876 a0
+-   ReqURL         /login
+-   ReqHeader      Authorization: Basic Zm9vOmJhcg==
+-   ReqHeader      Host: example.com
+-   VCL_call       RECV
+-   ReqUnset       authorization: Basic Zm9vOmJhcg==
+-   RespHeader     Set-Cookie: sid=abc
+-   End
+`
+
+// TestRedactor tests that the configured tags and headers are masked, hashed
+// or dropped in both Fields and Records.
+func TestRedactor(t *testing.T) {
+	samples := map[RedactMode]Records{
+		RedactMask: Records{
+			{"ReqStart", "[REDACTED]"},
+			{"ReqURL", "/login"},
+			{"ReqHeader", "Authorization: [REDACTED]"},
+			{"ReqHeader", "Host: example.com"},
+			{"VCL_call", "RECV"},
+			{"ReqUnset", "authorization: [REDACTED]"},
+			{"RespHeader", "Set-Cookie: [REDACTED]"},
+		},
+		RedactHash: Records{
+			{"ReqStart", "f07d58618a6eb316750f534c878a148cc3da2955819b0e3c2abde483f9354c69"},
+			{"ReqURL", "/login"},
+			{"ReqHeader", "Authorization: fad5d9ff71f1e2f5da158772089642cab914f0b5ba464fe1ef2526900e1992fc"},
+			{"ReqHeader", "Host: example.com"},
+			{"VCL_call", "RECV"},
+			{"ReqUnset", "authorization: fad5d9ff71f1e2f5da158772089642cab914f0b5ba464fe1ef2526900e1992fc"},
+			{"RespHeader", "Set-Cookie: f4a6794715fb7d8763f5569ab4a675633de790f5564176910fed1502ecf5317d"},
+		},
+		RedactDrop: Records{
+			{"ReqURL", "/login"},
+			{"ReqHeader", "Host: example.com"},
+			{"VCL_call", "RECV"},
+		},
+	}
+	for mode, want := range samples {
+		e, err := Parse(stringScanner(redactSample))
+		if err != nil {
+			t.Fatalf("failed to parse entry: %v", err)
+		}
+		r := &Redactor{
+			Tags:    []string{"ReqStart"},
+			Headers: []string{"Authorization", "set-cookie"},
+			Mode:    mode,
+			Key:     []byte("secret"),
+		}
+		r.Redact(e)
+		if !reflect.DeepEqual(want, e.Records) {
+			t.Errorf("redacting in mode %d should give %v, got %v", mode, want, e.Records)
+		}
+		fields := Fields{}
+		for _, rec := range want {
+			fields[rec.Tag] = append(fields[rec.Tag], rec.Value)
+		}
+		if !reflect.DeepEqual(fields, e.Fields) {
+			t.Errorf("redacting in mode %d should give fields %v, got %v", mode, fields, e.Fields)
+		}
+	}
+
+	e := &Entry{Fields: Fields{"ReqURL": []string{"/secret"}}}
+	(&Redactor{Tags: []string{"ReqURL"}, Token: "x"}).Redact(e)
+	if v := e.TryField("ReqURL"); v != "x" {
+		t.Errorf("redacting with token x should give x, got %q", v)
+	}
+
+	// Without a key, hashes are keyed by a random key of the Redactor.
+	unkeyed := sha256.Sum256([]byte("/secret"))
+	hash := func(r *Redactor) string {
+		e := &Entry{Fields: Fields{"ReqURL": []string{"/secret"}}}
+		r.Redact(e)
+		return e.TryField("ReqURL")
+	}
+	r := &Redactor{Tags: []string{"ReqURL"}, Mode: RedactHash}
+	h := hash(r)
+	if h == hex.EncodeToString(unkeyed[:]) || len(h) != 64 {
+		t.Errorf("redacting without key should not give the plain SHA-256, got %q", h)
+	}
+	if hash(r) != h {
+		t.Errorf("redacting twice by the same Redactor should give the same hash")
+	}
+	if hash(&Redactor{Tags: []string{"ReqURL"}, Mode: RedactHash}) == h {
+		t.Errorf("redacting by different Redactors without key should give different hashes")
+	}
+}
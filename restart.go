@@ -0,0 +1,85 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+)
+
+// chain follows the Link records with the given reason from the log entry to
+// the transactions of its kind nested in it, e.g. from a client request to
+// its restart and on to the restart of the restart. It returns the entries
+// of the chain, starting with e, and whether the chain is complete, i.e. the
+// last link of each entry with the given reason leads to an attached entry.
+func (e *Entry) chain(reason string) ([]*Entry, bool, error) {
+	entries := []*Entry{e}
+	for cur := e; ; {
+		links, err := cur.Links()
+		if err != nil {
+			return nil, false, err
+		}
+		var next *Link
+		for _, l := range links {
+			if l.Kind == e.Kind && l.Reason == reason {
+				next = l
+			}
+		}
+		if next == nil {
+			return entries, true, nil
+		}
+		var c *Entry
+		for _, ch := range cur.Children {
+			if ch.VXID == next.Child && ch.Kind == e.Kind {
+				c = ch
+				break
+			}
+		}
+		if c == nil {
+			return entries, false, nil
+		}
+		entries = append(entries, c)
+		cur = c
+	}
+}
+
+// RestartChain represents a client request which was restarted in VCL, e.g.
+// by return (restart) in vcl_deliver. Varnish logs each restart as a new
+// client request nested in the one it restarts, so a single request of a
+// client is logged as several transactions.
+type RestartChain struct {
+	Attempts   []*Entry // Entries of the attempts, the original request first.
+	Incomplete bool     // Whether the entry of a restart is missing.
+}
+
+// Final returns the entry of the last attempt, which was delivered to the
+// client unless the chain is incomplete.
+func (c *RestartChain) Final() *Entry {
+	return c.Attempts[len(c.Attempts)-1]
+}
+
+// Restarts returns the number of restarts logged in the chain.
+func (c *RestartChain) Restarts() int {
+	return len(c.Attempts) - 1
+}
+
+// IsRestart returns whether the log entry is a restart of a client request,
+// i.e. its Begin record has the "restart" reason. Restarts are part of the
+// RestartChain of the original request and should not be counted as
+// requests of their own.
+func (e *Entry) IsRestart() bool {
+	b, err := e.Begin()
+	return err == nil && e.Kind == Request && b.Reason == "restart"
+}
+
+// RestartChain stitches the restarts of the client request together by
+// following the Link records with the "restart" reason. The entry must have
+// its nested transactions attached, see Grouping and TreeBuilder. A request
+// which was not restarted forms a chain of its own.
+func (e *Entry) RestartChain() (*RestartChain, error) {
+	if e.Kind != Request {
+		return nil, errors.Errorf("%v entry is not a client request", e.Kind)
+	}
+	entries, complete, err := e.chain("restart")
+	if err != nil {
+		return nil, err
+	}
+	return &RestartChain{Attempts: entries, Incomplete: !complete}, nil
+}
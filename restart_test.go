@@ -0,0 +1,68 @@
+package vslparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRestartChain tests that restarts are stitched together in order, that
+// missing restarts are reported and that only client requests have chains.
+func TestRestartChain(t *testing.T) {
+	s := `
+*   << BeReq    >> 5
+-   Begin          bereq 4 fetch
+-   End
+*   << Request  >> 4
+-   Begin          req 3 restart
+-   Link           bereq 5 fetch
+-   End
+*   << Request  >> 3
+-   Begin          req 2 restart
+-   Link           req 4 restart
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           req 3 restart
+-   End
+`
+	got, _ := buildTrees(t, GroupRequest, s)
+	if len(got) != 1 {
+		t.Fatalf("building request trees should give 1 tree, got %v", got)
+	}
+	c, err := got[0].RestartChain()
+	if err != nil {
+		t.Fatalf("stitching restart chain should not fail, got: %v", err)
+	}
+	var vxids []uint64
+	for _, e := range c.Attempts {
+		vxids = append(vxids, e.VXID)
+	}
+	if want := []uint64{2, 3, 4}; !reflect.DeepEqual(want, vxids) || c.Incomplete {
+		t.Errorf("restart chain should be complete with attempts %v, got %v (%v)", want, vxids, c.Incomplete)
+	}
+	if c.Final().VXID != 4 || c.Restarts() != 2 {
+		t.Errorf("restart chain should end with request 4 after 2 restarts, got %d after %d",
+			c.Final().VXID, c.Restarts())
+	}
+	if got[0].IsRestart() || !c.Final().IsRestart() || c.Final().Children[0].IsRestart() {
+		t.Errorf("only restarted requests should be reported as restarts")
+	}
+
+	_, flushed := buildTrees(t, GroupRequest, s[strings.Index(s, "*   << Request  >> 3"):])
+	if c, err := flushed[0].RestartChain(); err != nil || !c.Incomplete || c.Restarts() != 1 {
+		t.Errorf("restart chain without restart 4 should be incomplete, got %+v (%v)", c, err)
+	}
+
+	bad := []*Entry{
+		NewEntry(BeReq, 3),
+		NewEntry(Request, 2).Add("Link", "req x restart"),
+	}
+	for _, e := range bad {
+		if _, err := e.RestartChain(); err == nil {
+			t.Errorf("restart chain of %v should fail", e)
+		} else {
+			t.Logf("restart chain of %v gives: %v", e, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+)
+
+// FetchAttempt represents one attempt of a backend fetch and its outcome.
+type FetchAttempt struct {
+	Entry   *Entry        // Entry of the backend request of the attempt.
+	Status  int           // Final status of the response, 0 if none was logged.
+	Errors  []*FetchError // Errors of the attempt, see FetchErrors.
+	Retried bool          // Whether the attempt was retried.
+}
+
+// Failed returns whether the attempt failed, i.e. it logged fetch errors or
+// received no response or a server error, which includes responses
+// synthesized in vcl_backend_error.
+func (a *FetchAttempt) Failed() bool {
+	return len(a.Errors) > 0 || a.Status == 0 || a.Status >= 500
+}
+
+// RetryChain represents a backend fetch which was retried in VCL, e.g. by
+// return (retry) in vcl_backend_response. Varnish logs each retry as a new
+// backend request nested in the one it retries, so a single fetch from the
+// origin is logged as several transactions.
+type RetryChain struct {
+	Attempts   []*FetchAttempt // Attempts of the fetch, the original one first.
+	Incomplete bool            // Whether the entry of a retry is missing.
+}
+
+// Final returns the last attempt, whose outcome is the outcome of the fetch
+// unless the chain is incomplete.
+func (c *RetryChain) Final() *FetchAttempt {
+	return c.Attempts[len(c.Attempts)-1]
+}
+
+// Retries returns the number of retries logged in the chain.
+func (c *RetryChain) Retries() int {
+	return len(c.Attempts) - 1
+}
+
+// IsRetry returns whether the log entry is a retry of a backend request, i.e.
+// its Begin record has the "retry" reason. Retries are part of the
+// RetryChain of the original backend request and should not be counted as
+// fetches of their own.
+func (e *Entry) IsRetry() bool {
+	b, err := e.Begin()
+	return err == nil && e.Kind == BeReq && b.Reason == "retry"
+}
+
+// RetryChain groups the retries of the backend request by following the Link
+// records with the "retry" reason. The entry must have its nested
+// transactions attached, see Grouping and TreeBuilder. A backend request
+// which was not retried forms a chain of its own.
+func (e *Entry) RetryChain() (*RetryChain, error) {
+	if e.Kind != BeReq {
+		return nil, errors.Errorf("%v entry is not a backend request", e.Kind)
+	}
+	entries, complete, err := e.chain("retry")
+	if err != nil {
+		return nil, err
+	}
+	c := &RetryChain{Incomplete: !complete}
+	for i, a := range entries {
+		fa, err := newFetchAttempt(a)
+		if err != nil {
+			return nil, err
+		}
+		fa.Retried = i < len(entries)-1 || !complete
+		c.Attempts = append(c.Attempts, fa)
+	}
+	return c, nil
+}
+
+// newFetchAttempt returns the attempt of the backend request e. Whether it
+// was retried is left to the caller.
+func newFetchAttempt(e *Entry) (*FetchAttempt, error) {
+	a := &FetchAttempt{Entry: e, Errors: e.FetchErrors()}
+	if _, ok := e.Fields["BerespStatus"]; ok {
+		var err error
+		if a.Status, err = e.Status(); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// retried returns whether the backend request e links a retry of itself.
+func (e *Entry) retried() bool {
+	links, err := e.Links()
+	if err != nil {
+		return false
+	}
+	for _, l := range links {
+		if l.Kind == BeReq && l.Reason == "retry" {
+			return true
+		}
+	}
+	return false
+}
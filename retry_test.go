@@ -0,0 +1,79 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRetryChain tests that retries of backend requests are grouped in order
+// with their outcomes, and that only backend requests have chains.
+func TestRetryChain(t *testing.T) {
+	s := `
+*   << BeReq    >> 5
+-   Begin          bereq 4 retry
+-   BerespStatus   200
+-   End
+*   << BeReq    >> 4
+-   Begin          bereq 3 retry
+-   BerespStatus   503
+-   Link           bereq 5 retry
+-   End
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   FetchError     first byte timeout
+-   Link           bereq 4 retry
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   End
+`
+	got, _ := buildTrees(t, GroupRequest, s)
+	if len(got) != 1 || len(got[0].Children) != 1 {
+		t.Fatalf("building request trees should give 1 tree, got %v", got)
+	}
+	bereq := got[0].Children[0]
+	c, err := bereq.RetryChain()
+	if err != nil {
+		t.Fatalf("grouping retry chain should not fail, got: %v", err)
+	}
+	type outcome struct {
+		VXID    uint64
+		Status  int
+		Errors  int
+		Retried bool
+		Failed  bool
+	}
+	var outcomes []outcome
+	for _, a := range c.Attempts {
+		outcomes = append(outcomes, outcome{a.Entry.VXID, a.Status, len(a.Errors), a.Retried, a.Failed()})
+	}
+	want := []outcome{
+		{3, 0, 1, true, true},
+		{4, 503, 0, true, true},
+		{5, 200, 0, false, false},
+	}
+	if !reflect.DeepEqual(want, outcomes) || c.Incomplete {
+		t.Errorf("retry chain should be complete with attempts %v, got %v (%v)", want, outcomes, c.Incomplete)
+	}
+	if c.Final().Entry.VXID != 5 || c.Retries() != 2 {
+		t.Errorf("retry chain should end with backend request 5 after 2 retries, got %d after %d",
+			c.Final().Entry.VXID, c.Retries())
+	}
+	if bereq.IsRetry() || !c.Final().Entry.IsRetry() || got[0].IsRetry() {
+		t.Errorf("only retried backend requests should be reported as retries")
+	}
+
+	bad := []*Entry{
+		NewEntry(Request, 2),
+		NewEntry(BeReq, 3).Add("Link", "bereq x retry"),
+		NewEntry(BeReq, 3).Add("BerespStatus", "OK"),
+	}
+	for _, e := range bad {
+		if _, err := e.RetryChain(); err == nil {
+			t.Errorf("retry chain of %v should fail", e)
+		} else {
+			t.Logf("retry chain of %v gives: %v", e, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package vslparser
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// Sampler is a Filter selecting a representative subset of entries, e.g. to
+// process only a part of the traffic of a busy site. A sampler decides on the
+// entries passed to it as a whole, so to keep complete traces of the sampled
+// transactions, it must be applied to groups, see Grouping and TreeBuilder,
+// whose children are then kept or dropped along with their roots. Samplers
+// are safe for concurrent use.
+type Sampler struct {
+	every uint64                // Sample every n-th entry, if not 0.
+	rate  float64               // Fraction of sampled entries otherwise.
+	key   func(e *Entry) string // Key of entries sampled by their keys.
+	rand  *rand.Rand            // Source of randomness, nil for the global one.
+
+	mu sync.Mutex
+	n  uint64 // Number of entries seen by an every n-th sampler.
+}
+
+// SampleEvery returns a sampler selecting every n-th entry, starting with the
+// first one. A sampler of n less than 2 selects all entries.
+func SampleEvery(n int) *Sampler {
+	return &Sampler{every: uint64(max(n, 1))}
+}
+
+// SampleRate returns a sampler selecting entries randomly with the given
+// probability, e.g. 0.01 for one percent of entries. If r is nil, the global
+// generator of math/rand/v2 is used.
+func SampleRate(rate float64, r *rand.Rand) *Sampler {
+	return &Sampler{rate: rate, rand: r}
+}
+
+// SampleByKey returns a sampler selecting entries by their keys with the
+// given probability, such that entries of the same key are either all
+// selected or all dropped, e.g. all requests of a client by ClientAddrKey.
+// Keys are hashed, so the selected keys are the same for all samplers of the
+// same rate, e.g. of other processes.
+func SampleByKey(rate float64, key func(e *Entry) string) *Sampler {
+	return &Sampler{rate: rate, key: key}
+}
+
+// Match returns whether the entry e is sampled.
+func (s *Sampler) Match(e *Entry) bool {
+	switch {
+	case s.every > 0:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.n++
+		return (s.n-1)%s.every == 0
+	case s.key != nil:
+		h := fnv.New64a()
+		h.Write([]byte(s.key(e)))
+		return s.rate >= 1 || float64(mix64(h.Sum64())) < s.rate*math.MaxUint64
+	case s.rand != nil:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.rand.Float64() < s.rate
+	}
+	return rand.Float64() < s.rate
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads the bits of the hash h.
+// FNV leaves the high bits of hashes of similar short keys, e.g. addresses,
+// mostly the same.
+func mix64(h uint64) uint64 {
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
+
+// ClientAddrKey returns the address of the client of a client request or
+// session, as logged by ReqStart or SessOpen, as the key of a sampler. The key
+// is empty for other entries and if the records cannot be parsed.
+func ClientAddrKey(e *Entry) string {
+	if _, ok := e.Fields["ReqStart"]; ok {
+		if r, err := e.ReqStart(); err == nil {
+			return r.Addr.String()
+		}
+	}
+	if _, ok := e.Fields["SessOpen"]; ok {
+		if o, err := e.SessOpen(); err == nil {
+			return o.RemoteAddr.String()
+		}
+	}
+	return ""
+}
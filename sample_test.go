@@ -0,0 +1,65 @@
+package vslparser
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// TestSampler tests that samplers select every n-th entry, entries at random
+// with the given rate, or all entries of sampled keys.
+func TestSampler(t *testing.T) {
+	s := SampleEvery(3)
+	var got []uint64
+	for i := uint64(1); i <= 7; i++ {
+		if s.Match(NewEntry(Request, i)) {
+			got = append(got, i)
+		}
+	}
+	if want := []uint64{1, 4, 7}; !reflect.DeepEqual(want, got) {
+		t.Errorf("sampling every 3rd entry should select %v, got %v", want, got)
+	}
+	if s := SampleEvery(0); !s.Match(NewEntry(Request, 1)) || !s.Match(NewEntry(Request, 2)) {
+		t.Errorf("sampling every 0th entry should select all entries")
+	}
+
+	// count returns the number of entries of 1000 selected by s, each of a
+	// different client.
+	count := func(s *Sampler) int {
+		n := 0
+		for i := range 1000 {
+			e := NewEntry(Request, uint64(i)).Add("ReqStart", "192.0.2."+strconv.Itoa(i%250)+" 1234 a0")
+			if s.Match(e) {
+				n++
+			}
+		}
+		return n
+	}
+	r := rand.New(rand.NewPCG(1, 2))
+	if n := count(SampleRate(0.2, r)); n < 150 || n > 250 {
+		t.Errorf("sampling at rate 0.2 should select about 200 of 1000 entries, got %d", n)
+	}
+	if n := count(SampleRate(0, nil)); n != 0 {
+		t.Errorf("sampling at rate 0 should select no entries, got %d", n)
+	}
+	if n := count(SampleRate(1, nil)); n != 1000 {
+		t.Errorf("sampling at rate 1 should select all entries, got %d", n)
+	}
+
+	s = SampleByKey(0.5, ClientAddrKey)
+	if n := count(s); n%4 != 0 || n < 300 || n > 700 {
+		t.Errorf("sampling by client at rate 0.5 should select all 4 entries of about half of the clients, got %d", n)
+	}
+	a := NewEntry(Request, 1).Add("ReqStart", "192.0.2.1 1234 a0")
+	b := NewEntry(Session, 2).Add("SessOpen", "192.0.2.1 44876 a0 127.0.0.1 6081 1545037998.267700 17")
+	if ClientAddrKey(a) != "192.0.2.1" || ClientAddrKey(b) != "192.0.2.1" || s.Match(a) != s.Match(b) {
+		t.Errorf("request and session of the same client should be sampled alike")
+	}
+	if k := ClientAddrKey(NewEntry(BeReq, 3)); k != "" {
+		t.Errorf("backend request should have no client key, got %q", k)
+	}
+	if n := count(SampleByKey(1, ClientAddrKey)); n != 1000 {
+		t.Errorf("sampling by client at rate 1 should select all entries, got %d", n)
+	}
+}
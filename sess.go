@@ -0,0 +1,189 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAddrPort parses an address and a port as found in records of Varnish,
+// e.g. "192.168.1.1" and "53602", or "::1" and "6081". IPv6 addresses may be
+// enclosed in brackets. An unknown endpoint reported as "-" results in an
+// invalid address and zero port.
+func parseAddrPort(addr, port string) (netip.Addr, uint16, error) {
+	if addr == "-" && (port == "-" || port == "0") {
+		return netip.Addr{}, 0, nil
+	}
+	a, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, 0, errors.Wrapf(err, "cannot parse address %q", addr)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return netip.Addr{}, 0, errors.Wrapf(err, "cannot parse port %q", port)
+	}
+	return a, uint16(p), nil
+}
+
+// SessOpen represents a parsed SessOpen record, which reports a new client
+// connection, e.g.:
+//
+//	127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 17
+//
+// Varnish before 5.0 does not report the file descriptor, in which case FD is
+// -1.
+type SessOpen struct {
+	RemoteAddr netip.Addr // Address of the client.
+	RemotePort uint16     // Port of the client.
+	Listener   string     // Name of the listen socket, e.g. "a0".
+	LocalAddr  netip.Addr // Local address of the connection.
+	LocalPort  uint16     // Local port of the connection.
+	Time       time.Time  // Time the connection was accepted.
+	FD         int        // File descriptor of the connection.
+}
+
+// parseSessOpen parses the value of a SessOpen record.
+func parseSessOpen(v string) (*SessOpen, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 6 && len(fs) != 7 {
+		return nil, errors.Errorf("SessOpen record %q is malformed", v)
+	}
+	s := &SessOpen{Listener: fs[2], FD: -1}
+	var err error
+	if s.RemoteAddr, s.RemotePort, err = parseAddrPort(fs[0], fs[1]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse SessOpen record %q", v)
+	}
+	if s.LocalAddr, s.LocalPort, err = parseAddrPort(fs[3], fs[4]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse SessOpen record %q", v)
+	}
+	if s.Time, err = parseAbsTime(fs[5]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse SessOpen record %q", v)
+	}
+	if len(fs) == 7 {
+		if s.FD, err = strconv.Atoi(fs[6]); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse SessOpen record %q", v)
+		}
+	}
+	return s, nil
+}
+
+// SessOpen parses and returns the SessOpen record of the log entry.
+func (e *Entry) SessOpen() (*SessOpen, error) {
+	fs, err := e.Field("SessOpen")
+	if err != nil {
+		return nil, err
+	}
+	return parseSessOpen(fs[0])
+}
+
+// SessClose represents a parsed SessClose record, which reports the end of a
+// client connection, e.g. "REM_CLOSE 0.008".
+type SessClose struct {
+	Reason   string        // Reason of the close, e.g. "REM_CLOSE" or "RX_TIMEOUT".
+	Duration time.Duration // Duration of the session.
+}
+
+// parseSessClose parses the value of a SessClose record.
+func parseSessClose(v string) (*SessClose, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 2 {
+		return nil, errors.Errorf("SessClose record %q is malformed", v)
+	}
+	d, err := parseDuration(fs[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse SessClose record %q", v)
+	}
+	return &SessClose{Reason: fs[0], Duration: d}, nil
+}
+
+// SessClose parses and returns the SessClose record of the log entry.
+func (e *Entry) SessClose() (*SessClose, error) {
+	fs, err := e.Field("SessClose")
+	if err != nil {
+		return nil, err
+	}
+	return parseSessClose(fs[0])
+}
+
+// ReqStart represents a parsed ReqStart record, which reports the client of
+// a request, e.g. "127.0.0.1 44876 a0". Varnish before 6.0 does not report
+// the listen socket, in which case Listener is empty.
+type ReqStart struct {
+	Addr     netip.Addr // Address of the client.
+	Port     uint16     // Port of the client.
+	Listener string     // Name of the listen socket, e.g. "a0".
+}
+
+// parseReqStart parses the value of a ReqStart record.
+func parseReqStart(v string) (*ReqStart, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 2 && len(fs) != 3 {
+		return nil, errors.Errorf("ReqStart record %q is malformed", v)
+	}
+	r := &ReqStart{}
+	var err error
+	if r.Addr, r.Port, err = parseAddrPort(fs[0], fs[1]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse ReqStart record %q", v)
+	}
+	if len(fs) == 3 {
+		r.Listener = fs[2]
+	}
+	return r, nil
+}
+
+// ReqStart parses and returns the ReqStart record of the log entry.
+func (e *Entry) ReqStart() (*ReqStart, error) {
+	fs, err := e.Field("ReqStart")
+	if err != nil {
+		return nil, err
+	}
+	return parseReqStart(fs[0])
+}
+
+// Proxy represents a parsed Proxy record, which reports the endpoints of the
+// original connection received through the PROXY protocol, e.g. from a TLS
+// terminator such as hitch:
+//
+//	2 203.0.113.5 51234 198.51.100.1 443
+//
+// Connections established by the proxy itself, e.g. for health checks, are
+// reported as "local", in which case Local is set and the endpoints are
+// empty.
+type Proxy struct {
+	Version    int        // Version of the PROXY protocol, 1 or 2.
+	Local      bool       // Whether the connection is local to the proxy.
+	ClientAddr netip.Addr // Address of the original client.
+	ClientPort uint16     // Port of the original client.
+	ServerAddr netip.Addr // Address the original client connected to.
+	ServerPort uint16     // Port the original client connected to.
+}
+
+// Proxy parses and returns the Proxy record of the log entry.
+func (e *Entry) Proxy() (*Proxy, error) {
+	fs, err := e.Field("Proxy")
+	if err != nil {
+		return nil, err
+	}
+	v := fs[0]
+	parts := strings.Fields(v)
+	if len(parts) != 5 {
+		return nil, errors.Errorf("Proxy record %q is malformed", v)
+	}
+	p := &Proxy{}
+	if p.Version, err = strconv.Atoi(parts[0]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse Proxy record %q", v)
+	}
+	if parts[1] == "local" {
+		p.Local = true
+		return p, nil
+	}
+	if p.ClientAddr, p.ClientPort, err = parseAddrPort(parts[1], parts[2]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse Proxy record %q", v)
+	}
+	if p.ServerAddr, p.ServerPort, err = parseAddrPort(parts[3], parts[4]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse Proxy record %q", v)
+	}
+	return p, nil
+}
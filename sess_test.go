@@ -0,0 +1,201 @@
+package vslparser
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSessOpen tests that SessOpen records are parsed correctly, including
+// IPv6 addresses, and that malformed ones produce errors.
+func TestSessOpen(t *testing.T) {
+	samples := map[string]*SessOpen{
+		"127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 17": &SessOpen{
+			RemoteAddr: netip.MustParseAddr("127.0.0.1"),
+			RemotePort: 44876,
+			Listener:   "a0",
+			LocalAddr:  netip.MustParseAddr("127.0.0.1"),
+			LocalPort:  6081,
+			Time:       time.Unix(1545037998, 267700000).UTC(),
+			FD:         17,
+		},
+		"2001:db8::1 51234 public [::1] 80 1545037998.5 23": &SessOpen{
+			RemoteAddr: netip.MustParseAddr("2001:db8::1"),
+			RemotePort: 51234,
+			Listener:   "public",
+			LocalAddr:  netip.MustParseAddr("::1"),
+			LocalPort:  80,
+			Time:       time.Unix(1545037998, 500000000).UTC(),
+			FD:         23,
+		},
+		"10.0.0.1 53602 :80 - - 1437592459.053899": &SessOpen{
+			RemoteAddr: netip.MustParseAddr("10.0.0.1"),
+			RemotePort: 53602,
+			Listener:   ":80",
+			Time:       time.Unix(1437592459, 53899000).UTC(),
+			FD:         -1,
+		},
+	}
+	for v, want := range samples {
+		got, err := parseSessOpen(v)
+		if err != nil {
+			t.Errorf("parsing SessOpen record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing SessOpen record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"127.0.0.1 44876 a0 127.0.0.1 6081",
+		"localhost 44876 a0 127.0.0.1 6081 1545037998.267700 17",
+		"127.0.0.1 65536 a0 127.0.0.1 6081 1545037998.267700 17",
+		"127.0.0.1 44876 a0 127.0.0.1 6081 yesterday 17",
+		"127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 fd",
+	}
+	for _, v := range bad {
+		if _, err := parseSessOpen(v); err == nil {
+			t.Errorf("parsing SessOpen record %q should fail", v)
+		} else {
+			t.Logf("parsing SessOpen record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestSessClose tests that SessClose records are parsed correctly and that
+// malformed ones produce errors.
+func TestSessClose(t *testing.T) {
+	e, err := Parse(stringScanner("* << Session >> 1\n- Begin sess 0 HTTP/1\n- SessClose REM_CLOSE 0.008\n- End"))
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	want := &SessClose{Reason: "REM_CLOSE", Duration: 8 * time.Millisecond}
+	got, err := e.SessClose()
+	if err != nil {
+		t.Fatalf("parsing SessClose record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing SessClose record should give %v, got %v", want, got)
+	}
+	if _, err := e.SessOpen(); err == nil {
+		t.Errorf("parsing missing SessOpen record should fail")
+	}
+
+	for _, v := range []string{"", "REM_CLOSE", "REM_CLOSE soon"} {
+		if _, err := parseSessClose(v); err == nil {
+			t.Errorf("parsing SessClose record %q should fail", v)
+		} else {
+			t.Logf("parsing SessClose record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestReqStart tests that ReqStart records are parsed correctly, including
+// IPv6 addresses, and that malformed ones produce errors.
+func TestReqStart(t *testing.T) {
+	want := &ReqStart{Addr: netip.MustParseAddr("127.0.0.1"), Port: 44876}
+	got, err := example().ReqStart()
+	if err != nil {
+		t.Fatalf("parsing ReqStart record should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing ReqStart record should give %v, got %v", want, got)
+	}
+
+	samples := map[string]*ReqStart{
+		"192.168.1.1 53602 a0": &ReqStart{
+			Addr:     netip.MustParseAddr("192.168.1.1"),
+			Port:     53602,
+			Listener: "a0",
+		},
+		"2001:db8::1 51234 public": &ReqStart{
+			Addr:     netip.MustParseAddr("2001:db8::1"),
+			Port:     51234,
+			Listener: "public",
+		},
+		"::ffff:10.0.0.1 80": &ReqStart{
+			Addr: netip.MustParseAddr("::ffff:10.0.0.1"),
+			Port: 80,
+		},
+	}
+	for v, want := range samples {
+		got, err := parseReqStart(v)
+		if err != nil {
+			t.Errorf("parsing ReqStart record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing ReqStart record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"127.0.0.1",
+		"127.0.0.1:44876 a0",
+		"127.0.0.1 port a0",
+		"127.0.0.1 44876 a0 extra",
+	}
+	for _, v := range bad {
+		if _, err := parseReqStart(v); err == nil {
+			t.Errorf("parsing ReqStart record %q should fail", v)
+		} else {
+			t.Logf("parsing ReqStart record %q gives: %v", v, err)
+		}
+	}
+}
+
+// TestProxy tests that Proxy records are parsed correctly, including local
+// connections, and that malformed ones produce errors.
+func TestProxy(t *testing.T) {
+	samples := map[string]*Proxy{
+		"2 203.0.113.5 51234 198.51.100.1 443": &Proxy{
+			Version:    2,
+			ClientAddr: netip.MustParseAddr("203.0.113.5"),
+			ClientPort: 51234,
+			ServerAddr: netip.MustParseAddr("198.51.100.1"),
+			ServerPort: 443,
+		},
+		"1 2001:db8::5 51234 2001:db8::1 443": &Proxy{
+			Version:    1,
+			ClientAddr: netip.MustParseAddr("2001:db8::5"),
+			ClientPort: 51234,
+			ServerAddr: netip.MustParseAddr("2001:db8::1"),
+			ServerPort: 443,
+		},
+		"2 local local local local": &Proxy{
+			Version: 2,
+			Local:   true,
+		},
+	}
+	for v, want := range samples {
+		e := &Entry{Fields: Fields{"Proxy": []string{v}}}
+		got, err := e.Proxy()
+		if err != nil {
+			t.Errorf("parsing Proxy record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing Proxy record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"2 203.0.113.5 51234",
+		"v2 203.0.113.5 51234 198.51.100.1 443",
+		"2 client 51234 198.51.100.1 443",
+		"2 203.0.113.5 51234 198.51.100.1 https",
+	}
+	for _, v := range bad {
+		e := &Entry{Fields: Fields{"Proxy": []string{v}}}
+		if _, err := e.Proxy(); err == nil {
+			t.Errorf("parsing Proxy record %q should fail", v)
+		} else {
+			t.Logf("parsing Proxy record %q gives: %v", v, err)
+		}
+	}
+}
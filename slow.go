@@ -0,0 +1,64 @@
+package vslparser
+
+import (
+	"time"
+)
+
+// SlowThreshold is a threshold of the time a transaction took until an event,
+// see Slow.
+type SlowThreshold struct {
+	// Event is the event of the timestamp, e.g. "Resp" for the time until
+	// the response was delivered to the client or "Beresp" for the time
+	// until the headers of the backend response were received.
+	Event string
+	// Max is the time the event may take. Events which take longer exceed
+	// the threshold.
+	Max time.Duration
+	// SinceLast makes the threshold apply to the time since the previous
+	// timestamp, e.g. of "Beresp" since "Fetch" for the time to the first
+	// byte of the backend, instead of the time since the start.
+	SinceLast bool
+}
+
+// exceeded returns whether the timestamp ts exceeds the threshold.
+func (s SlowThreshold) exceeded(ts *Timestamp) bool {
+	if ts.Event != s.Event {
+		return false
+	}
+	if s.SinceLast {
+		return ts.SinceLast > s.Max
+	}
+	return ts.SinceStart > s.Max
+}
+
+// Slow returns a filter selecting the entries holding a transaction with a
+// timestamp which exceeds any of the given thresholds, i.e. transactions
+// which were slow themselves and transactions with such transactions nested
+// in them, see Grouping, e.g.
+//
+//	// Requests which took more than a second or whose backends took more
+//	// than 200ms to respond.
+//	vslparser.Slow(
+//		vslparser.SlowThreshold{Event: "Resp", Max: time.Second},
+//		vslparser.SlowThreshold{Event: "Beresp", Max: 200 * time.Millisecond, SinceLast: true},
+//	)
+//
+// Transactions with malformed timestamps are not selected.
+func Slow(thresholds ...SlowThreshold) Filter {
+	return FilterFunc(func(e *Entry) bool {
+		return !e.Walk(func(t *Entry) bool {
+			stamps, err := t.Timestamps()
+			if err != nil {
+				return true
+			}
+			for _, ts := range stamps {
+				for _, s := range thresholds {
+					if s.exceeded(ts) {
+						return false
+					}
+				}
+			}
+			return true
+		})
+	})
+}
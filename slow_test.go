@@ -0,0 +1,48 @@
+package vslparser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSlow tests that entries are selected by the times since the start and
+// since the previous timestamp of their events, including those of nested
+// transactions.
+func TestSlow(t *testing.T) {
+	bereq := NewEntry(BeReq, 3).
+		Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000").
+		Add("Timestamp", "Fetch: 1545037998.100000 0.100000 0.100000").
+		Add("Timestamp", "Beresp: 1545037998.400000 0.400000 0.300000")
+	req := NewEntry(Request, 2).
+		Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000").
+		Add("Timestamp", "Resp: 1545037998.500000 0.500000 0.500000").
+		AddChild(bereq)
+	samples := []struct {
+		Threshold SlowThreshold
+		Req       bool
+		Bereq     bool
+	}{
+		{SlowThreshold{Event: "Resp", Max: 400 * time.Millisecond}, true, false},
+		{SlowThreshold{Event: "Resp", Max: 500 * time.Millisecond}, false, false},
+		{SlowThreshold{Event: "Beresp", Max: 350 * time.Millisecond}, true, true},
+		{SlowThreshold{Event: "Beresp", Max: 350 * time.Millisecond, SinceLast: true}, false, false},
+		{SlowThreshold{Event: "Beresp", Max: 250 * time.Millisecond, SinceLast: true}, true, true},
+		{SlowThreshold{Event: "Process", Max: 0}, false, false},
+	}
+	for _, s := range samples {
+		f := Slow(s.Threshold)
+		if got := f.Match(req); got != s.Req {
+			t.Errorf("threshold %+v should give %v for request, got %v", s.Threshold, s.Req, got)
+		}
+		if got := f.Match(bereq); got != s.Bereq {
+			t.Errorf("threshold %+v should give %v for backend request, got %v", s.Threshold, s.Bereq, got)
+		}
+	}
+	f := Slow(SlowThreshold{Event: "Resp", Max: time.Hour}, SlowThreshold{Event: "Fetch", Max: 50 * time.Millisecond})
+	if !f.Match(req) {
+		t.Errorf("request should be selected if any threshold is exceeded")
+	}
+	if Slow().Match(req) || f.Match(NewEntry(Request, 4).Add("Timestamp", "Resp: foo")) {
+		t.Errorf("entries without thresholds or with malformed timestamps should not be selected")
+	}
+}
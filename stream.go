@@ -0,0 +1,114 @@
+package vslparser
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"iter"
+)
+
+// ParseFunc parses entries from r and calls fn for each of them, until the end
+// of the input or until fn returns an error, which is then returned by
+// ParseFunc. The end of the input is not considered an error.
+//
+// The memory of the entry passed to fn is reused for the following entries, so
+// fn must not retain the entry or its fields after it returns.
+func ParseFunc(r io.Reader, fn func(*Entry) error) error {
+	p := NewParser(r)
+	e := newEntry()
+	for {
+		e.reset()
+		err := p.next(e)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// streamBuffer is the capacity of the entry channel returned by Stream. Once
+// it fills up, parsing stalls until the consumer catches up.
+const streamBuffer = 64
+
+// ParseAll parses all entries from r until the end of the input. If an entry
+// cannot be parsed, the error reports its index and the line at which parsing
+// failed, and the entries parsed so far are returned along with it.
+func ParseAll(r io.Reader) ([]*Entry, error) {
+	p := NewParser(r)
+	entries := make([]*Entry, 0)
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, errors.Wrapf(err,
+				"cannot parse entry %d at line %d", len(entries), p.Line())
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Entries returns an iterator over the entries parsed from r. Iteration stops
+// at the end of the input, or after the first error, which is yielded along
+// with a nil Entry.
+func Entries(r io.Reader) iter.Seq2[*Entry, error] {
+	return NewParser(r).Entries()
+}
+
+// Entries returns an iterator over the remaining entries of the parser. See
+// the package-level Entries function for details.
+func (p *Parser) Entries() iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		for {
+			e, err := p.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(e, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Stream parses entries from r in a separate goroutine and delivers them over
+// the returned entry channel, which is closed at the end of the input. The
+// channel is bounded, so a slow consumer stalls the parsing instead of letting
+// entries pile up in memory.
+//
+// At most one error is delivered over the error channel, which is closed
+// along with the entry channel. The end of the input is not considered an
+// error. When ctx is done, parsing is aborted and the error of the context is
+// delivered.
+func Stream(ctx context.Context, r io.Reader) (<-chan *Entry, <-chan error) {
+	entc := make(chan *Entry, streamBuffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(entc)
+		p := NewParserContext(ctx, r)
+		for {
+			e, err := p.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case entc <- e:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return entc, errc
+}
@@ -0,0 +1,123 @@
+package vslparser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestParseAll tests that ParseAll reads all entries until EOF and reports
+// the position of an entry which failed to parse.
+func TestParseAll(t *testing.T) {
+	s := "\n* << BeReq >> 1\n- End\n\n* << Request >> 2\n- Foo Bar\n- End\n\n"
+	entries, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("ParseAll(%q) should not fail, got: %v", s, err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("ParseAll(%q) should give 2 entries, got %d", s, len(entries))
+	}
+
+	s = "* << BeReq >> 1\n- End\n\n* << Request >> 2\n- Foo Bar\n Baz\n- End\n"
+	entries, err = ParseAll(strings.NewReader(s))
+	if err == nil {
+		t.Fatalf("ParseAll(%q) should fail", s)
+	}
+	t.Logf("ParseAll(%q) gives: %v", s, err)
+	if len(entries) != 1 {
+		t.Errorf("ParseAll(%q) should return 1 entry parsed before the error, got %d", s, len(entries))
+	}
+	if want := "entry 1 at line 6"; !strings.Contains(err.Error(), want) {
+		t.Errorf("ParseAll(%q) error should contain %q, got: %v", s, want, err)
+	}
+}
+
+// TestEntries tests that Entries iterates over all entries and stops after
+// the first error.
+func TestEntries(t *testing.T) {
+	s := "* << BeReq >> 1\n- End\n\n* << BeReq >> 2\n- End\n\n"
+	var vxids []uint64
+	for e, err := range Entries(strings.NewReader(s)) {
+		if err != nil {
+			t.Fatalf("iterating over %q should not fail, got: %v", s, err)
+		}
+		vxids = append(vxids, e.VXID)
+	}
+	if len(vxids) != 2 || vxids[0] != 1 || vxids[1] != 2 {
+		t.Errorf("iterating over %q should give VXIDs [1 2], got %v", s, vxids)
+	}
+
+	s = "* << BeReq >> 1\n- End\n\n* << BeReq >> Foo\n- End\n\n* << BeReq >> 3\n- End\n"
+	n, errs := 0, 0
+	for _, err := range Entries(strings.NewReader(s)) {
+		n++
+		if err != nil {
+			errs++
+			t.Logf("iterating over %q gives: %v", s, err)
+		}
+	}
+	if n != 2 || errs != 1 {
+		t.Errorf("iterating over %q should stop after the first error, got %d entries", s, n)
+	}
+}
+
+// TestStream tests that Stream delivers all entries followed by no error, and
+// that it delivers the error of a cancelled context.
+func TestStream(t *testing.T) {
+	s := "* << BeReq >> 1\n- End\n\n* << BeReq >> 2\n- End\n\n"
+	entc, errc := Stream(context.Background(), strings.NewReader(s))
+	n := 0
+	for range entc {
+		n++
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("streaming %q should not fail, got: %v", s, err)
+	}
+	if n != 2 {
+		t.Errorf("streaming %q should give 2 entries, got %d", s, n)
+	}
+
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	entc, errc = Stream(ctx, r)
+	cancel()
+	for range entc {
+		t.Errorf("streaming from an empty pipe should give no entries")
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("streaming should fail with context.Canceled, got: %v", err)
+	}
+}
+
+// TestParseFunc tests that ParseFunc calls the callback for every entry and
+// stops at the first error returned by the callback.
+func TestParseFunc(t *testing.T) {
+	s := "* << BeReq >> 1\n- Foo Bar\n- End\n\n* << BeReq >> 2\n- End\n\n* << BeReq >> 3\n- End\n"
+	var vxids []uint64
+	err := ParseFunc(strings.NewReader(s), func(e *Entry) error {
+		vxids = append(vxids, e.VXID)
+		if e.VXID == 2 && len(e.Fields) != 0 {
+			t.Errorf("entry 2 should have no fields, got %v", e.Fields)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ParseFunc(%q) should not fail, got: %v", s, err)
+	}
+	if len(vxids) != 3 {
+		t.Errorf("ParseFunc(%q) should call the callback 3 times, got %d", s, len(vxids))
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = ParseFunc(strings.NewReader(s), func(e *Entry) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("ParseFunc(%q) should stop with the callback error, got %v after %d calls", s, err, n)
+	}
+}
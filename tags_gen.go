@@ -0,0 +1,212 @@
+// Code generated by gentags; DO NOT EDIT.
+
+package vslparser
+
+// DefaultTagVersion is the version of Varnish whose tag table is used by
+// default, e.g. by BinaryReader.
+const DefaultTagVersion = "6.0"
+
+// tagTables lists the tags of the known versions of Varnish indexed by their
+// numeric value, which is how tags are stored in the binary log format. The
+// value 0 is never used by a valid record.
+var tagTables = map[string][]TagInfo{
+	"6.0": {
+		{},
+		{Name: "Debug", Doc: "Debug messages", Unsafe: true},
+		{Name: "Error", Doc: "Error messages"},
+		{Name: "CLI", Doc: "CLI communication"},
+		{Name: "SessOpen", Doc: "Client connection opened", Client: true},
+		{Name: "SessClose", Doc: "Client connection closed", Client: true},
+		{Name: "BackendOpen", Doc: "Backend connection opened", Backend: true},
+		{Name: "BackendReuse", Doc: "Backend connection put up for reuse", Backend: true},
+		{Name: "BackendClose", Doc: "Backend connection closed", Backend: true},
+		{Name: "HttpGarbage", Doc: "Unparseable HTTP request", Unsafe: true},
+		{Name: "Proxy", Doc: "PROXY protocol information"},
+		{Name: "ProxyGarbage", Doc: "Unparseable PROXY request"},
+		{Name: "Backend", Doc: "Backend selected", Backend: true},
+		{Name: "Length", Doc: "Size of object body"},
+		{Name: "FetchError", Doc: "Error while fetching object"},
+		{Name: "ReqMethod", Doc: "Client request method", Client: true},
+		{Name: "ReqURL", Doc: "Client request URL", Client: true},
+		{Name: "ReqProtocol", Doc: "Client request protocol", Client: true},
+		{Name: "ReqStatus", Doc: "Client request status", Client: true, Unused: true},
+		{Name: "ReqReason", Doc: "Client request response", Client: true, Unused: true},
+		{Name: "ReqHeader", Doc: "Client request header", Client: true},
+		{Name: "ReqUnset", Doc: "Client request unset header", Client: true, Unused: true},
+		{Name: "ReqLost", Doc: "Client request lost header", Client: true, Unused: true},
+		{Name: "RespMethod", Doc: "Client response method", Client: true, Unused: true},
+		{Name: "RespURL", Doc: "Client response URL", Client: true, Unused: true},
+		{Name: "RespProtocol", Doc: "Client response protocol", Client: true},
+		{Name: "RespStatus", Doc: "Client response status", Client: true},
+		{Name: "RespReason", Doc: "Client response response", Client: true},
+		{Name: "RespHeader", Doc: "Client response header", Client: true},
+		{Name: "RespUnset", Doc: "Client response unset header", Client: true, Unused: true},
+		{Name: "RespLost", Doc: "Client response lost header", Client: true, Unused: true},
+		{Name: "BereqMethod", Doc: "Backend request method", Backend: true},
+		{Name: "BereqURL", Doc: "Backend request URL", Backend: true},
+		{Name: "BereqProtocol", Doc: "Backend request protocol", Backend: true},
+		{Name: "BereqStatus", Doc: "Backend request status", Backend: true, Unused: true},
+		{Name: "BereqReason", Doc: "Backend request response", Backend: true, Unused: true},
+		{Name: "BereqHeader", Doc: "Backend request header", Backend: true},
+		{Name: "BereqUnset", Doc: "Backend request unset header", Backend: true, Unused: true},
+		{Name: "BereqLost", Doc: "Backend request lost header", Backend: true, Unused: true},
+		{Name: "BerespMethod", Doc: "Backend response method", Backend: true, Unused: true},
+		{Name: "BerespURL", Doc: "Backend response URL", Backend: true, Unused: true},
+		{Name: "BerespProtocol", Doc: "Backend response protocol", Backend: true},
+		{Name: "BerespStatus", Doc: "Backend response status", Backend: true},
+		{Name: "BerespReason", Doc: "Backend response response", Backend: true},
+		{Name: "BerespHeader", Doc: "Backend response header", Backend: true},
+		{Name: "BerespUnset", Doc: "Backend response unset header", Backend: true, Unused: true},
+		{Name: "BerespLost", Doc: "Backend response lost header", Backend: true, Unused: true},
+		{Name: "ObjMethod", Doc: "Object method", Unused: true},
+		{Name: "ObjURL", Doc: "Object URL", Unused: true},
+		{Name: "ObjProtocol", Doc: "Object protocol"},
+		{Name: "ObjStatus", Doc: "Object status"},
+		{Name: "ObjReason", Doc: "Object response"},
+		{Name: "ObjHeader", Doc: "Object header"},
+		{Name: "ObjUnset", Doc: "Object unset header", Unused: true},
+		{Name: "ObjLost", Doc: "Object lost header", Unused: true},
+		{Name: "BogoHeader", Doc: "Bogus HTTP received"},
+		{Name: "LostHeader", Doc: "Failed attempt to set HTTP header"},
+		{Name: "TTL", Doc: "TTL set on object"},
+		{Name: "Fetch_Body", Doc: "Body fetched from backend"},
+		{Name: "VCL_acl", Doc: "VCL ACL check results"},
+		{Name: "VCL_call", Doc: "VCL method called"},
+		{Name: "VCL_trace", Doc: "VCL trace data"},
+		{Name: "VCL_return", Doc: "VCL method return value"},
+		{Name: "ReqStart", Doc: "Client request start", Client: true},
+		{Name: "Hit", Doc: "Hit object in cache"},
+		{Name: "HitPass", Doc: "Hit for pass object in cache"},
+		{Name: "ExpBan", Doc: "Object evicted due to ban"},
+		{Name: "ExpKill", Doc: "Object expiry event"},
+		{Name: "WorkThread", Doc: "Logs thread start/stop events"},
+		{Name: "ESI_xmlerror", Doc: "ESI parser error or warning message"},
+		{Name: "Hash", Doc: "Value added to hash", Unsafe: true, Binary: true},
+		{Name: "Backend_health", Doc: "Backend health check", Backend: true},
+		{Name: "VCL_Log", Doc: "Log statement from VCL"},
+		{Name: "VCL_Error", Doc: "VCL execution error message"},
+		{Name: "Gzip", Doc: "G(un)zip performed on object"},
+		{Name: "Link", Doc: "Links to a child VXID"},
+		{Name: "Begin", Doc: "Marks the start of a VXID"},
+		{Name: "End", Doc: "Marks the end of a VXID"},
+		{Name: "VSL", Doc: "VSL API warnings and error message"},
+		{Name: "Storage", Doc: "Where object is stored"},
+		{Name: "Timestamp", Doc: "Timing information"},
+		{Name: "ReqAcct", Doc: "Request handling byte counts"},
+		{Name: "PipeAcct", Doc: "Pipe byte counts"},
+		{Name: "BereqAcct", Doc: "Backend request accounting", Backend: true},
+		{Name: "VfpAcct", Doc: "Fetch filter accounting"},
+		{Name: "Witness", Doc: "Lock order witness records"},
+		{Name: "BackendStart", Doc: "Backend request start", Backend: true},
+		{Name: "H2RxHdr", Doc: "Received HTTP2 frame header", Binary: true},
+		{Name: "H2RxBody", Doc: "Received HTTP2 frame body", Binary: true},
+		{Name: "H2TxHdr", Doc: "Transmitted HTTP2 frame header", Binary: true},
+		{Name: "H2TxBody", Doc: "Transmitted HTTP2 frame body", Binary: true},
+		{Name: "HitMiss", Doc: "Hit for miss object in cache"},
+		{Name: "Filters", Doc: "Body filters"},
+		{Name: "SessError", Doc: "Client connection accept failed", Client: true},
+		{Name: "VCL_use", Doc: "VCL in use"},
+		{Name: "Notice", Doc: "Informational messages about request handling"},
+		{Name: "VdpAcct", Doc: "Deliver filter accounting"},
+	},
+}
+
+// Names of the known VSL tags of all versions in tagTables.
+const (
+	TagDebug          = "Debug"          // Debug messages.
+	TagError          = "Error"          // Error messages.
+	TagCLI            = "CLI"            // CLI communication.
+	TagSessOpen       = "SessOpen"       // Client connection opened.
+	TagSessClose      = "SessClose"      // Client connection closed.
+	TagBackendOpen    = "BackendOpen"    // Backend connection opened.
+	TagBackendReuse   = "BackendReuse"   // Backend connection put up for reuse.
+	TagBackendClose   = "BackendClose"   // Backend connection closed.
+	TagHttpGarbage    = "HttpGarbage"    // Unparseable HTTP request.
+	TagProxy          = "Proxy"          // PROXY protocol information.
+	TagProxyGarbage   = "ProxyGarbage"   // Unparseable PROXY request.
+	TagBackend        = "Backend"        // Backend selected.
+	TagLength         = "Length"         // Size of object body.
+	TagFetchError     = "FetchError"     // Error while fetching object.
+	TagReqMethod      = "ReqMethod"      // Client request method.
+	TagReqURL         = "ReqURL"         // Client request URL.
+	TagReqProtocol    = "ReqProtocol"    // Client request protocol.
+	TagReqStatus      = "ReqStatus"      // Client request status.
+	TagReqReason      = "ReqReason"      // Client request response.
+	TagReqHeader      = "ReqHeader"      // Client request header.
+	TagReqUnset       = "ReqUnset"       // Client request unset header.
+	TagReqLost        = "ReqLost"        // Client request lost header.
+	TagRespMethod     = "RespMethod"     // Client response method.
+	TagRespURL        = "RespURL"        // Client response URL.
+	TagRespProtocol   = "RespProtocol"   // Client response protocol.
+	TagRespStatus     = "RespStatus"     // Client response status.
+	TagRespReason     = "RespReason"     // Client response response.
+	TagRespHeader     = "RespHeader"     // Client response header.
+	TagRespUnset      = "RespUnset"      // Client response unset header.
+	TagRespLost       = "RespLost"       // Client response lost header.
+	TagBereqMethod    = "BereqMethod"    // Backend request method.
+	TagBereqURL       = "BereqURL"       // Backend request URL.
+	TagBereqProtocol  = "BereqProtocol"  // Backend request protocol.
+	TagBereqStatus    = "BereqStatus"    // Backend request status.
+	TagBereqReason    = "BereqReason"    // Backend request response.
+	TagBereqHeader    = "BereqHeader"    // Backend request header.
+	TagBereqUnset     = "BereqUnset"     // Backend request unset header.
+	TagBereqLost      = "BereqLost"      // Backend request lost header.
+	TagBerespMethod   = "BerespMethod"   // Backend response method.
+	TagBerespURL      = "BerespURL"      // Backend response URL.
+	TagBerespProtocol = "BerespProtocol" // Backend response protocol.
+	TagBerespStatus   = "BerespStatus"   // Backend response status.
+	TagBerespReason   = "BerespReason"   // Backend response response.
+	TagBerespHeader   = "BerespHeader"   // Backend response header.
+	TagBerespUnset    = "BerespUnset"    // Backend response unset header.
+	TagBerespLost     = "BerespLost"     // Backend response lost header.
+	TagObjMethod      = "ObjMethod"      // Object method.
+	TagObjURL         = "ObjURL"         // Object URL.
+	TagObjProtocol    = "ObjProtocol"    // Object protocol.
+	TagObjStatus      = "ObjStatus"      // Object status.
+	TagObjReason      = "ObjReason"      // Object response.
+	TagObjHeader      = "ObjHeader"      // Object header.
+	TagObjUnset       = "ObjUnset"       // Object unset header.
+	TagObjLost        = "ObjLost"        // Object lost header.
+	TagBogoHeader     = "BogoHeader"     // Bogus HTTP received.
+	TagLostHeader     = "LostHeader"     // Failed attempt to set HTTP header.
+	TagTTL            = "TTL"            // TTL set on object.
+	TagFetch_Body     = "Fetch_Body"     // Body fetched from backend.
+	TagVCL_acl        = "VCL_acl"        // VCL ACL check results.
+	TagVCL_call       = "VCL_call"       // VCL method called.
+	TagVCL_trace      = "VCL_trace"      // VCL trace data.
+	TagVCL_return     = "VCL_return"     // VCL method return value.
+	TagReqStart       = "ReqStart"       // Client request start.
+	TagHit            = "Hit"            // Hit object in cache.
+	TagHitPass        = "HitPass"        // Hit for pass object in cache.
+	TagExpBan         = "ExpBan"         // Object evicted due to ban.
+	TagExpKill        = "ExpKill"        // Object expiry event.
+	TagWorkThread     = "WorkThread"     // Logs thread start/stop events.
+	TagESI_xmlerror   = "ESI_xmlerror"   // ESI parser error or warning message.
+	TagHash           = "Hash"           // Value added to hash.
+	TagBackend_health = "Backend_health" // Backend health check.
+	TagVCL_Log        = "VCL_Log"        // Log statement from VCL.
+	TagVCL_Error      = "VCL_Error"      // VCL execution error message.
+	TagGzip           = "Gzip"           // G(un)zip performed on object.
+	TagLink           = "Link"           // Links to a child VXID.
+	TagBegin          = "Begin"          // Marks the start of a VXID.
+	TagEnd            = "End"            // Marks the end of a VXID.
+	TagVSL            = "VSL"            // VSL API warnings and error message.
+	TagStorage        = "Storage"        // Where object is stored.
+	TagTimestamp      = "Timestamp"      // Timing information.
+	TagReqAcct        = "ReqAcct"        // Request handling byte counts.
+	TagPipeAcct       = "PipeAcct"       // Pipe byte counts.
+	TagBereqAcct      = "BereqAcct"      // Backend request accounting.
+	TagVfpAcct        = "VfpAcct"        // Fetch filter accounting.
+	TagWitness        = "Witness"        // Lock order witness records.
+	TagBackendStart   = "BackendStart"   // Backend request start.
+	TagH2RxHdr        = "H2RxHdr"        // Received HTTP2 frame header.
+	TagH2RxBody       = "H2RxBody"       // Received HTTP2 frame body.
+	TagH2TxHdr        = "H2TxHdr"        // Transmitted HTTP2 frame header.
+	TagH2TxBody       = "H2TxBody"       // Transmitted HTTP2 frame body.
+	TagHitMiss        = "HitMiss"        // Hit for miss object in cache.
+	TagFilters        = "Filters"        // Body filters.
+	TagSessError      = "SessError"      // Client connection accept failed.
+	TagVCL_use        = "VCL_use"        // VCL in use.
+	TagNotice         = "Notice"         // Informational messages about request handling.
+	TagVdpAcct        = "VdpAcct"        // Deliver filter accounting.
+)
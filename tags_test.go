@@ -0,0 +1,45 @@
+package vslparser
+
+import (
+	"testing"
+)
+
+// TestIsKnownTag tests that tags of the tag table are known, and that other
+// names, including those differing only in case, are not.
+func TestIsKnownTag(t *testing.T) {
+	for _, tag := range []string{TagReqURL, TagTimestamp, TagVCL_call, TagVdpAcct} {
+		if !IsKnownTag(tag) {
+			t.Errorf("tag %q should be known", tag)
+		}
+	}
+	for _, tag := range []string{"", "requrl", "ReqUrl", "Foo"} {
+		if IsKnownTag(tag) {
+			t.Errorf("tag %q should not be known", tag)
+		}
+	}
+}
+
+// TestTagTable tests that tags of the default version are described and that
+// unknown versions have no tags.
+func TestTagTable(t *testing.T) {
+	tags := TagTable(DefaultTagVersion)
+	names := TagNames(DefaultTagVersion)
+	if len(tags) == 0 || len(tags) != len(names) || tags[0].Name != "" {
+		t.Fatalf("tag table of %s should start with the unused tag 0, got %d tags", DefaultTagVersion, len(tags))
+	}
+	for i, tag := range tags[1:] {
+		if tag.Name != names[i+1] || tag.Doc == "" {
+			t.Errorf("tag %d should be named %q and described, got %+v", i+1, names[i+1], tag)
+		}
+	}
+	tag, ok := LookupTag(TagBereqURL)
+	if !ok || tag.Client || !tag.Backend || tag.Doc != "Backend request URL" {
+		t.Errorf("BereqURL should be a backend tag, got %+v", tag)
+	}
+	if _, ok := LookupTag(""); ok {
+		t.Errorf("looking up an empty tag should fail")
+	}
+	if TagTable("1.0") != nil || TagNames("1.0") != nil {
+		t.Errorf("tag table of an unknown version should be nil")
+	}
+}
@@ -0,0 +1,96 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// finalEvents maps the kinds of transactions to the events of the timestamps
+// which mark their end. Client requests end when the response was delivered,
+// the connection was piped or the request was restarted; backend requests
+// end when the body was fetched, the fetch failed or was retried.
+var finalEvents = map[Kind][]string{
+	Request: {"Resp", "PipeSess", "Restart"},
+	BeReq:   {"BerespBody", "Error", "Retry"},
+}
+
+// finalTimestamp parses and returns the last timestamp of the log entry
+// marking the end of a transaction of its kind. For kinds with no such known
+// events, the last timestamp of any event is returned.
+func (e *Entry) finalTimestamp() (*Timestamp, error) {
+	events, known := finalEvents[e.Kind]
+	fs := e.Fields["Timestamp"]
+	for i := len(fs) - 1; i >= 0; i-- {
+		name, fv, err := rfc7230Split(fs[i])
+		if err != nil || (known && !slices.Contains(events, name)) {
+			continue
+		}
+		return parseTimestamp(name, strings.Fields(fv))
+	}
+	return nil, errors.Errorf("%v entry has no final timestamp", e.Kind)
+}
+
+// Duration returns the duration of the transaction, i.e. the time between its
+// Start timestamp and its final one: Resp, PipeSess or Restart of client
+// requests and BerespBody, Error or Retry of backend requests. Sessions log
+// no timestamps, the duration reported by SessClose is returned for them.
+func (e *Entry) Duration() (time.Duration, error) {
+	if e.Kind == Session {
+		c, err := e.SessClose()
+		if err != nil {
+			return 0, err
+		}
+		return c.Duration, nil
+	}
+	start, err := e.Timestamp("Start")
+	if err != nil {
+		return 0, err
+	}
+	end, err := e.finalTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	return end.AbsTime.Sub(start.AbsTime), nil
+}
+
+// StartTime returns the wall-clock time the transaction started at, i.e. the
+// absolute time of its Start timestamp, or of the SessOpen record of
+// sessions.
+func (e *Entry) StartTime() (time.Time, error) {
+	if e.Kind == Session {
+		o, err := e.SessOpen()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return o.Time, nil
+	}
+	start, err := e.Timestamp("Start")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return start.AbsTime, nil
+}
+
+// EndTime returns the wall-clock time the transaction ended at, i.e. the
+// absolute time of its final timestamp, see Duration. The end of sessions is
+// their start plus the duration reported by SessClose.
+func (e *Entry) EndTime() (time.Time, error) {
+	if e.Kind == Session {
+		start, err := e.StartTime()
+		if err != nil {
+			return time.Time{}, err
+		}
+		d, err := e.Duration()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return start.Add(d), nil
+	}
+	end, err := e.finalTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return end.AbsTime, nil
+}
@@ -0,0 +1,107 @@
+package vslparser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDuration tests that durations are taken from the final timestamps of
+// the kinds of entries and that missing or malformed ones produce errors.
+func TestDuration(t *testing.T) {
+	samples := map[*Entry]time.Duration{
+		example(): 85 * time.Microsecond,
+		&Entry{Kind: BeReq, Fields: Fields{"Timestamp": []string{
+			"Start: 1545037998.000000 0.000000 0.000000",
+			"Beresp: 1545037998.021000 0.021000 0.021000",
+			"BerespBody: 1545037998.031000 0.031000 0.010000",
+		}}}: 31 * time.Millisecond,
+		&Entry{Kind: Request, Fields: Fields{"Timestamp": []string{
+			"Start: 1545037998.000000 0.000000 0.000000",
+			"Restart: 1545037998.002500 0.002500 0.002500",
+		}}}: 2500 * time.Microsecond,
+		&Entry{Kind: Raw, Fields: Fields{"Timestamp": []string{
+			"Start: 1545037998.000000 0.000000 0.000000",
+			"Foo: 1545037998.000100 0.000100 0.000100",
+		}}}: 100 * time.Microsecond,
+		&Entry{Kind: Session, Fields: Fields{"SessClose": []string{"REM_CLOSE 0.008"}}}: 8 * time.Millisecond,
+	}
+	for e, want := range samples {
+		got, err := e.Duration()
+		if err != nil {
+			t.Errorf("duration of %v should not fail, got: %v", e, err)
+		} else if got != want {
+			t.Errorf("duration of %v should be %v, got %v", e, want, got)
+		}
+	}
+
+	bad := []*Entry{
+		&Entry{Kind: Request, Fields: Fields{"Timestamp": []string{
+			"Resp: 1545037998.000100 0.000100 0.000100",
+		}}},
+		&Entry{Kind: Request, Fields: Fields{"Timestamp": []string{
+			"Start: 1545037998.000000 0.000000 0.000000",
+			"Process: 1545037998.000100 0.000100 0.000100",
+		}}},
+		&Entry{Kind: BeReq, Fields: Fields{"Timestamp": []string{
+			"Start: 1545037998.000000 0.000000 0.000000",
+			"BerespBody: 1545037998.000100 foo 0.000100",
+		}}},
+		&Entry{Kind: Raw, Fields: Fields{}},
+		&Entry{Kind: Session, Fields: Fields{}},
+	}
+	for _, e := range bad {
+		if _, err := e.Duration(); err == nil {
+			t.Errorf("duration of %v should fail", e)
+		} else {
+			t.Logf("duration of %v gives: %v", e, err)
+		}
+	}
+}
+
+// TestStartEndTime tests that the wall-clock times of transactions are taken
+// from their timestamps exactly, or from the records of sessions.
+func TestStartEndTime(t *testing.T) {
+	samples := map[*Entry][2]time.Time{
+		example(): {
+			time.Unix(1545037998, 267746000).UTC(),
+			time.Unix(1545037998, 267831000).UTC(),
+		},
+		&Entry{Kind: Session, Fields: Fields{
+			"SessOpen":  []string{"127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 17"},
+			"SessClose": []string{"REM_CLOSE 0.008"},
+		}}: {
+			time.Unix(1545037998, 267700000).UTC(),
+			time.Unix(1545037998, 275700000).UTC(),
+		},
+	}
+	for e, want := range samples {
+		start, err := e.StartTime()
+		if err != nil || !start.Equal(want[0]) {
+			t.Errorf("start of %v should be %v, got %v (%v)", e, want[0], start, err)
+		}
+		end, err := e.EndTime()
+		if err != nil || !end.Equal(want[1]) {
+			t.Errorf("end of %v should be %v, got %v (%v)", e, want[1], end, err)
+		}
+	}
+
+	bad := []*Entry{
+		&Entry{Kind: Request, Fields: Fields{}},
+		&Entry{Kind: Session, Fields: Fields{"SessClose": []string{"REM_CLOSE 0.008"}}},
+		&Entry{Kind: Session, Fields: Fields{
+			"SessOpen": []string{"127.0.0.1 44876 a0 127.0.0.1 6081 1545037998.267700 17"},
+		}},
+	}
+	for _, e := range bad {
+		_, serr := e.StartTime()
+		_, eerr := e.EndTime()
+		if eerr == nil {
+			t.Errorf("end of %v should fail", e)
+		} else {
+			t.Logf("start and end of %v give: %v, %v", e, serr, eerr)
+		}
+	}
+	if _, err := bad[0].StartTime(); err == nil {
+		t.Errorf("start of %v should fail", bad[0])
+	}
+}
@@ -0,0 +1,151 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// FetchTrace summarizes a backend request of a RequestTrace.
+type FetchTrace struct {
+	VXID      uint64        // VXID of the backend request.
+	Reason    string        // Reason of the backend request, e.g. "fetch", "bgfetch" or "retry".
+	Backend   string        // Name of the backend, empty if no connection was logged.
+	Method    string        // Final method of the backend request.
+	URL       string        // Final URL of the backend request.
+	Status    int           // Final status of the response, 0 if none was logged.
+	FirstByte time.Duration // Time until the response headers were received, 0 if they were not.
+	Duration  time.Duration // Time since the start of the backend request of its last timestamp.
+	Errors    []*FetchError // Errors of the fetch, see FetchErrors.
+}
+
+// newFetchTrace summarizes the backend request e.
+func newFetchTrace(e *Entry) (*FetchTrace, error) {
+	f := &FetchTrace{VXID: e.VXID, Errors: e.FetchErrors()}
+	if _, reason, ok := e.Parent(); ok {
+		f.Reason = reason
+	}
+	if _, ok := e.Fields["BackendOpen"]; ok {
+		b, err := e.BackendOpen()
+		if err != nil {
+			return nil, err
+		}
+		f.Backend = b.Name
+	}
+	var err error
+	if f.Method, err = e.Method(Final); err != nil {
+		return nil, err
+	}
+	if f.URL, err = e.URL(Final); err != nil {
+		return nil, err
+	}
+	if _, ok := e.Fields["BerespStatus"]; ok {
+		if f.Status, err = e.Status(); err != nil {
+			return nil, err
+		}
+	}
+	stamps, err := e.Timestamps()
+	if err != nil {
+		return nil, err
+	}
+	for _, ts := range stamps {
+		if ts.Event == "Beresp" {
+			f.FirstByte = ts.SinceStart
+		}
+		f.Duration = ts.SinceStart
+	}
+	return f, nil
+}
+
+// RequestTrace summarizes a client request and the transactions nested in it
+// in the things usually reported on, e.g. by observability integrations.
+// Restarts of the request are part of the trace, so the request line is the
+// one received from the client, while the response and the cache outcome
+// are those of the last attempt.
+type RequestTrace struct {
+	VXID        uint64        // VXID of the request.
+	Start       time.Time     // Time the request started at.
+	Duration    time.Duration // Time until the end of the last attempt, see Duration.
+	Method      string        // Method as received from the client.
+	URL         string        // URL as received from the client.
+	Proto       string        // Protocol as received from the client.
+	Status      int           // Status of the response, 0 if none was logged, e.g. for piped requests.
+	Reason      string        // Reason phrase of the response.
+	Outcome     Outcome       // Outcome of the cache lookup of the last attempt.
+	Restarts    int           // Number of restarts, see RestartChain.
+	BytesTx     int64         // Bytes transmitted to the client, 0 if not logged.
+	ESIIncludes int           // Number of ESI subrequests, at all include levels.
+	Fetches     []*FetchTrace // Backend requests, including those of ESI subrequests, depth-first.
+	Incomplete  bool          // Whether transactions of the trace are missing.
+}
+
+// RequestTrace summarizes the client request, which must have its nested
+// transactions attached, see Grouping and TreeBuilder. An error is returned
+// if the entry is not a client request, or if its request line or timestamps
+// are missing or records of the trace cannot be parsed.
+func (e *Entry) RequestTrace() (*RequestTrace, error) {
+	chain, err := e.RestartChain()
+	if err != nil {
+		return nil, err
+	}
+	last := chain.Final()
+	tr := &RequestTrace{
+		VXID:       e.VXID,
+		Restarts:   chain.Restarts(),
+		Incomplete: e.Incomplete || chain.Incomplete,
+	}
+	if tr.Start, err = e.StartTime(); err != nil {
+		return nil, err
+	}
+	end, err := last.EndTime()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot determine the end of request %d", last.VXID)
+	}
+	tr.Duration = end.Sub(tr.Start)
+	if tr.Method, err = e.Method(Initial); err != nil {
+		return nil, err
+	}
+	if tr.URL, err = e.URL(Initial); err != nil {
+		return nil, err
+	}
+	if tr.Proto, err = e.Proto(Initial); err != nil {
+		return nil, err
+	}
+	if _, ok := last.Fields["RespStatus"]; ok {
+		if tr.Status, err = last.Status(); err != nil {
+			return nil, err
+		}
+		tr.Reason, _ = last.Reason()
+	}
+	c, err := last.CacheOutcome()
+	if err != nil {
+		return nil, err
+	}
+	tr.Outcome = c.Outcome
+	for _, a := range chain.Attempts {
+		if _, ok := a.Fields["ReqAcct"]; !ok {
+			continue
+		}
+		acct, err := a.ReqAcct()
+		if err != nil {
+			return nil, err
+		}
+		tr.BytesTx += acct.TotalTx
+	}
+	e.Walk(func(t *Entry) bool {
+		switch {
+		case t.Kind == BeReq:
+			var f *FetchTrace
+			if f, err = newFetchTrace(t); err != nil {
+				return false
+			}
+			tr.Fetches = append(tr.Fetches, f)
+		case t.IsESI():
+			tr.ESIIncludes++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
@@ -0,0 +1,120 @@
+package vslparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestTrace tests that a restarted request with backend requests and
+// ESI subrequests is summarized across its attempts.
+func TestRequestTrace(t *testing.T) {
+	s := `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1545037998.000000 0.000000 0.000000
+-   ReqMethod      GET
+-   ReqURL         /page
+-   ReqProtocol    HTTP/1.1
+-   ReqURL         /rewritten
+-   VCL_call       RECV
+-   VCL_call       MISS
+-   Link           bereq 3 fetch
+-   Link           req 4 restart
+-   Timestamp      Restart: 1545037998.010000 0.010000 0.010000
+-   ReqAcct        100 0 100 0 0 0
+-   End
+**  << BeReq    >> 3
+--  Begin          bereq 2 fetch
+--  Timestamp      Start: 1545037998.001000 0.000000 0.000000
+--  BereqMethod    GET
+--  BereqURL       /rewritten
+--  BackendOpen    17 boot.default 127.0.0.1 8080 127.0.0.1 41234 connect
+--  Timestamp      Beresp: 1545037998.005000 0.004000 0.004000
+--  BerespStatus   404
+--  Timestamp      BerespBody: 1545037998.006000 0.005000 0.001000
+--  End
+**  << Request  >> 4
+--  Begin          req 2 restart
+--  Timestamp      Start: 1545037998.010000 0.010000 0.000000
+--  ReqMethod      GET
+--  ReqURL         /fallback
+--  ReqProtocol    HTTP/1.1
+--  Hit            32769 119.998 10.000 0.000
+--  Link           req 5 esi
+--  RespStatus     200
+--  RespReason     OK
+--  Timestamp      Resp: 1545037998.020000 0.020000 0.010000
+--  ReqAcct        0 0 0 200 1000 1200
+--  End
+*** << Request  >> 5
+--- Begin          req 4 esi
+--- Timestamp      Start: 1545037998.012000 0.000000 0.000000
+--- ReqMethod      GET
+--- ReqURL         /fragment
+--- ReqProtocol    HTTP/1.1
+--- Timestamp      Resp: 1545037998.015000 0.003000 0.003000
+--- End
+`
+	p := NewParser(strings.NewReader(s))
+	p.Grouping = GroupRequest
+	e, err := p.Next()
+	if err != nil {
+		t.Fatalf("failed to parse request group: %v", err)
+	}
+	want := &RequestTrace{
+		VXID:        2,
+		Start:       time.Unix(1545037998, 0).UTC(),
+		Duration:    20 * time.Millisecond,
+		Method:      "GET",
+		URL:         "/page",
+		Proto:       "HTTP/1.1",
+		Status:      200,
+		Reason:      "OK",
+		Outcome:     OutcomeHit,
+		Restarts:    1,
+		BytesTx:     1200,
+		ESIIncludes: 1,
+		Fetches: []*FetchTrace{
+			&FetchTrace{
+				VXID:      3,
+				Reason:    "fetch",
+				Backend:   "boot.default",
+				Method:    "GET",
+				URL:       "/rewritten",
+				Status:    404,
+				FirstByte: 4 * time.Millisecond,
+				Duration:  5 * time.Millisecond,
+				Errors:    []*FetchError{},
+			},
+		},
+	}
+	got, err := e.RequestTrace()
+	if err != nil {
+		t.Fatalf("tracing request should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("tracing request should give %+v, got %+v", want, got)
+	}
+
+	bad := []*Entry{
+		NewEntry(BeReq, 3),
+		NewEntry(Request, 2).Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000"),
+		NewEntry(Request, 2).
+			Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000").
+			Add("Timestamp", "Resp: 1545037998.020000 0.020000 0.010000"),
+		NewEntry(Request, 2).
+			Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000").
+			Add("ReqMethod", "GET").Add("ReqURL", "/").Add("ReqProtocol", "HTTP/1.1").
+			Add("RespStatus", "OK").
+			Add("Timestamp", "Resp: 1545037998.020000 0.020000 0.010000"),
+	}
+	for _, e := range bad {
+		if _, err := e.RequestTrace(); err == nil {
+			t.Errorf("tracing %v should fail", e)
+		} else {
+			t.Logf("tracing %v gives: %v", e, err)
+		}
+	}
+}
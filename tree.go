@@ -0,0 +1,325 @@
+package vslparser
+
+import (
+	"slices"
+	"time"
+)
+
+// FlushPolicy selects when a TreeBuilder returns trees.
+type FlushPolicy int
+
+const (
+	// FlushOnComplete returns trees once their root and all transactions
+	// linked from the tree were added. Trees which cannot be completed are
+	// held until they are evicted, see MaxEntries and MaxAge, or flushed.
+	FlushOnComplete FlushPolicy = iota
+	// FlushOnRootEnd returns trees once their root was added, i.e. the root
+	// transaction ended, even if transactions linked from the tree are
+	// missing. Transactions which end after their root are evicted.
+	FlushOnRootEnd
+	// FlushOnEvict is like FlushOnComplete, except that trees evicted due to
+	// MaxEntries or MaxAge are returned by Add instead of being passed to
+	// OnEvict, e.g. the requests of a long-lived session, which are then
+	// returned without their session.
+	FlushOnEvict
+)
+
+// treeNode is an entry held by a TreeBuilder until its tree is complete.
+type treeNode struct {
+	entry  *Entry
+	begin  *Begin    // Begin record of the entry, nil if it has none.
+	links  []uint64  // VXIDs of the children linked from the entry.
+	parent *treeNode // Node the entry is attached to, nil if none yet.
+	seq    int       // Order in which the entry was added.
+	time   time.Time // Time of the log when the entry was added.
+	gone   bool      // Whether the entry was returned or evicted.
+}
+
+// TreeBuilder assembles entries of transactions parsed separately, i.e. in the
+// default VXID grouping, into trees of transactions like varnishlog does in
+// the session or request grouping. Transactions are tied together by their
+// Begin and Link records: client requests are attached to their sessions,
+// and backend requests, ESI subrequests and restarted requests to the client
+// requests they originate from.
+//
+// Varnish logs transactions once they end, which is usually before the end of
+// the transactions they are nested in, so the builder holds entries until the
+// root of their tree and all the transactions linked from the tree were
+// added. Failures of Varnish to log a transaction, e.g. due to an overrun of
+// the shared memory log, or Options which skip some kinds of entries, prevent
+// trees from being completed; such trees are only returned by Flush.
+//
+// Trees which cannot be completed would be held forever, so when reading from
+// a live stream, MaxEntries or MaxAge should be set to evict them. Trees
+// returned before their transactions were all added have Incomplete set on
+// their topmost entry, see Policy.
+//
+// The builder takes ownership of the entries added to it and attaches them to
+// each other, so entries must not be reused for parsing, see ParseFunc.
+type TreeBuilder struct {
+	// MaxEntries is the maximum number of entries held by the builder. Once
+	// it is exceeded, the trees holding the oldest entries are evicted. If
+	// zero, the number of entries is not limited.
+	MaxEntries int
+	// MaxAge is the maximum age of entries held by the builder, after which
+	// the trees holding them are evicted. Ages are measured in the time of
+	// the log, i.e. relative to the end of the latest transaction added,
+	// see EndTime, so that captures are processed the same as live streams.
+	// If zero, the age of entries is not limited.
+	MaxAge time.Duration
+	// OnEvict, if set, is called with the topmost entry of each evicted
+	// tree, e.g. a backend request whose client request is missing or a
+	// session which outlived MaxAge.
+	OnEvict func(tree *Entry)
+	// Policy selects when trees are returned. The default is
+	// FlushOnComplete.
+	Policy FlushPolicy
+
+	grouping Grouping
+	nodes    map[uint64]*treeNode   // Entries held, by VXID.
+	waiting  map[uint64][]*treeNode // Entries waiting for their parent, by its VXID.
+	queue    []*treeNode            // Entries held, in the order they were added.
+	seq      int
+	now      time.Time // Time of the log, see MaxAge.
+}
+
+// NewTreeBuilder returns a builder assembling trees of the given grouping.
+// GroupSession roots trees at sessions, GroupRequest at client requests which
+// were received from clients. In GroupVXID, entries are returned as they are
+// added.
+func NewTreeBuilder(g Grouping) *TreeBuilder {
+	return &TreeBuilder{
+		grouping: g,
+		nodes:    make(map[uint64]*treeNode),
+		waiting:  make(map[uint64][]*treeNode),
+	}
+}
+
+// standalone returns whether the entry of the node is not grouped with other
+// transactions in the grouping of the builder, such as entries without a
+// Begin record, entries of unknown kinds and sessions in the request
+// grouping.
+func (b *TreeBuilder) standalone(n *treeNode) bool {
+	switch {
+	case n.begin == nil || n.entry.Kind == Unknown || n.entry.Kind == Raw:
+		return true
+	case b.grouping == GroupSession:
+		return false
+	case b.grouping == GroupRequest:
+		return n.entry.Kind == Session
+	}
+	return true
+}
+
+// isRoot returns whether the entry of the node is the root of a tree of the
+// grouping of the builder.
+func (b *TreeBuilder) isRoot(n *treeNode) bool {
+	if b.grouping == GroupSession {
+		return n.entry.Kind == Session
+	}
+	return n.entry.Kind == Request && n.begin.Reason != "esi" && n.begin.Reason != "restart"
+}
+
+// Add adds the entry e to the builder and returns the trees completed by it,
+// if any, as top-level entries with the transactions nested in them attached
+// to their Children, ordered as they are linked. An error is returned if the
+// Begin or Link records of e cannot be parsed, in which case e is not added.
+func (b *TreeBuilder) Add(e *Entry) ([]*Entry, error) {
+	n := &treeNode{entry: e, seq: b.seq}
+	if _, ok := e.Fields["Begin"]; ok {
+		var err error
+		if n.begin, err = e.Begin(); err != nil {
+			return nil, err
+		}
+	}
+	links, err := e.Links()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		n.links = append(n.links, l.Child)
+	}
+	n.time = b.clock(e)
+	e.Level = 1
+	if b.standalone(n) || b.isRoot(n) && len(n.links) == 0 {
+		return append([]*Entry{e}, b.evict()...), nil
+	}
+	b.seq++
+	b.nodes[e.VXID] = n
+	b.queue = append(b.queue, n)
+	if !b.isRoot(n) {
+		if p := b.nodes[n.begin.Parent]; p != nil {
+			b.attach(p, n)
+		} else {
+			b.waiting[n.begin.Parent] = append(b.waiting[n.begin.Parent], n)
+		}
+	}
+	for _, c := range b.waiting[e.VXID] {
+		b.attach(n, c)
+	}
+	delete(b.waiting, e.VXID)
+
+	root := n
+	for root.parent != nil {
+		root = root.parent
+	}
+	var trees []*Entry
+	if b.isRoot(root) {
+		complete := b.complete(root)
+		if complete || b.Policy == FlushOnRootEnd {
+			b.remove(root)
+			root.entry.Incomplete = !complete
+			trees = append(trees, root.entry)
+		}
+	}
+	return append(trees, b.evict()...), nil
+}
+
+// clock advances the time of the log to the end of the transaction of e, if
+// it is known and later, and returns the time of the entry for the purpose
+// of eviction.
+func (b *TreeBuilder) clock(e *Entry) time.Time {
+	t, err := e.EndTime()
+	if err != nil {
+		if t, err = e.StartTime(); err != nil {
+			return b.now
+		}
+	}
+	if t.After(b.now) {
+		b.now = t
+	}
+	return t
+}
+
+// evict evicts the trees holding the oldest entries while there are more
+// than MaxEntries entries or the oldest entry is older than MaxAge. The
+// evicted trees are returned if the policy is FlushOnEvict.
+func (b *TreeBuilder) evict() []*Entry {
+	defer b.compact()
+	var trees []*Entry
+	for len(b.queue) > 0 {
+		n := b.queue[0]
+		switch {
+		case n.gone:
+		case b.MaxEntries > 0 && len(b.nodes) > b.MaxEntries,
+			b.MaxAge > 0 && b.now.Sub(n.time) > b.MaxAge:
+			for n.parent != nil {
+				n = n.parent
+			}
+			b.remove(n)
+			n.entry.Incomplete = true
+			if b.Policy == FlushOnEvict {
+				trees = append(trees, n.entry)
+			} else if b.OnEvict != nil {
+				b.OnEvict(n.entry)
+			}
+		default:
+			return trees
+		}
+		b.queue[0] = nil
+		b.queue = b.queue[1:]
+	}
+	return trees
+}
+
+// compact drops the nodes which were returned or evicted from the queue once
+// they outnumber the nodes held, so that resolved nodes behind an old node
+// which is still held, e.g. a backend request whose client request is
+// missing, do not hold on to their entries.
+func (b *TreeBuilder) compact() {
+	if len(b.queue) <= 2*len(b.nodes)+16 {
+		return
+	}
+	b.queue = slices.DeleteFunc(b.queue, func(n *treeNode) bool {
+		return n.gone
+	})
+}
+
+// attach attaches the entry of the node c to the entry of the node p,
+// keeping the children of p in the order in which they are linked from p.
+// Children which are not linked are kept after the linked ones.
+func (b *TreeBuilder) attach(p, c *treeNode) {
+	c.parent = p
+	p.entry.AddChild(c.entry)
+	index := func(e *Entry) int {
+		if i := slices.Index(p.links, e.VXID); i >= 0 {
+			return i
+		}
+		return len(p.links)
+	}
+	slices.SortStableFunc(p.entry.Children, func(x, y *Entry) int {
+		return index(x) - index(y)
+	})
+}
+
+// complete returns whether all transactions linked from the entry of the node
+// and from the transactions nested in it were attached.
+func (b *TreeBuilder) complete(n *treeNode) bool {
+	for _, vxid := range n.links {
+		c := b.nodes[vxid]
+		if c == nil || c.parent != n || !b.complete(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// remove forgets the topmost node n of a tree and the nodes attached to it.
+func (b *TreeBuilder) remove(n *treeNode) {
+	if w := b.waiting[n.parentVXID()]; w != nil {
+		if w = slices.DeleteFunc(w, func(c *treeNode) bool { return c == n }); len(w) == 0 {
+			delete(b.waiting, n.parentVXID())
+		} else {
+			b.waiting[n.parentVXID()] = w
+		}
+	}
+	n.entry.Walk(func(e *Entry) bool {
+		if c := b.nodes[e.VXID]; c != nil {
+			c.gone = true
+			delete(b.nodes, e.VXID)
+		}
+		return true
+	})
+}
+
+// parentVXID returns the VXID of the parent of the entry of the node, zero
+// if it has none.
+func (n *treeNode) parentVXID() uint64 {
+	if n.begin == nil {
+		return 0
+	}
+	return n.begin.Parent
+}
+
+// Flush returns the trees held by the builder, which are not complete and
+// have Incomplete set, in the order in which their top-level entries were
+// added, and empties the builder. Trees whose root was not added are returned
+// as their topmost entries, e.g. a client request whose session is missing.
+// Flush is meant to be called at the end of the input, or on demand, e.g.
+// when a daemon shuts down.
+func (b *TreeBuilder) Flush() []*Entry {
+	var tops []*treeNode
+	for _, n := range b.nodes {
+		if n.parent == nil {
+			tops = append(tops, n)
+		}
+	}
+	slices.SortFunc(tops, func(x, y *treeNode) int {
+		return x.seq - y.seq
+	})
+	trees := make([]*Entry, 0, len(tops))
+	for _, n := range tops {
+		n.entry.Incomplete = true
+		trees = append(trees, n.entry)
+	}
+	clear(b.nodes)
+	clear(b.waiting)
+	clear(b.queue)
+	b.queue = b.queue[:0]
+	return trees
+}
+
+// Len returns the number of entries held by the builder.
+func (b *TreeBuilder) Len() int {
+	return len(b.nodes)
+}
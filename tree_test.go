@@ -0,0 +1,327 @@
+package vslparser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildTrees adds the entries parsed separately from s to a builder of the
+// given grouping and returns the trees it completes, followed by the flushed
+// ones.
+func buildTrees(t *testing.T, g Grouping, s string) (complete, flushed []*Entry) {
+	t.Helper()
+	entries, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("failed to parse entries: %v", err)
+	}
+	b := NewTreeBuilder(g)
+	for _, e := range entries {
+		trees, err := b.Add(e)
+		if err != nil {
+			t.Fatalf("adding entry %d should not fail, got: %v", e.VXID, err)
+		}
+		complete = append(complete, trees...)
+	}
+	return complete, b.Flush()
+}
+
+// TestTreeBuilderSession tests that separately logged transactions are
+// assembled into the same trees as those of varnishlog in session grouping.
+func TestTreeBuilderSession(t *testing.T) {
+	// The transactions of the session sample, in the order in which Varnish
+	// logs them when they end.
+	s := `
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   End
+*   << Request  >> 4
+-   Begin          req 1 rxreq
+-   End
+*   << Session  >> 5
+-   Begin          sess 0 HTTP/1
+-   End
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   Link           req 2 rxreq
+-   End
+`
+	p := NewParser(strings.NewReader(session))
+	p.Grouping = GroupSession
+	var want []*Entry
+	for e, err := range p.Entries() {
+		if err != nil {
+			t.Fatalf("failed to parse session group: %v", err)
+		}
+		want = append(want, e)
+	}
+	// Session 5 has no requests and is returned first.
+	want[0], want[1] = want[1], want[0]
+	got, flushed := buildTrees(t, GroupSession, s)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("building session trees should give %v, got %v", want, got)
+	}
+	if len(flushed) != 0 {
+		t.Errorf("building session trees should leave no trees, got %v", flushed)
+	}
+
+	// Without the session, the requests are left incomplete.
+	got, flushed = buildTrees(t, GroupSession, s[:strings.LastIndex(s, "*   << Session  >> 1")])
+	if len(got) != 1 || got[0].VXID != 5 {
+		t.Errorf("building trees without session 1 should give session 5, got %v", got)
+	}
+	var vxids []uint64
+	for _, e := range flushed {
+		vxids = append(vxids, e.VXID)
+	}
+	if !reflect.DeepEqual(vxids, []uint64{2, 4}) || len(flushed[0].Children) != 1 {
+		t.Errorf("flushing trees without session 1 should give requests 2 and 4, got %v", flushed)
+	}
+}
+
+// TestTreeBuilderRequest tests that backend requests, ESI subrequests and
+// restarts are attached to client requests in request grouping, ordered as
+// they are linked, and that sessions are returned as they are.
+func TestTreeBuilderRequest(t *testing.T) {
+	s := `
+*   << BeReq    >> 5
+-   Begin          bereq 4 fetch
+-   End
+*   << Request  >> 4
+-   Begin          req 2 esi
+-   Link           bereq 5 fetch
+-   End
+*   << Request  >> 7
+-   Begin          req 2 restart
+-   End
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   End
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   Link           req 2 rxreq
+-   End
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   Link           req 4 esi
+-   Link           req 7 restart
+-   End
+*   << Request  >> 6
+-   Begin          req 1 rxreq
+-   End
+`
+	got, flushed := buildTrees(t, GroupRequest, s)
+	var vxids []uint64
+	var levels []int
+	for _, e := range got {
+		e.Walk(func(e *Entry) bool {
+			vxids = append(vxids, e.VXID)
+			levels = append(levels, e.Level)
+			return true
+		})
+	}
+	if want := []uint64{1, 2, 3, 4, 5, 7, 6}; !reflect.DeepEqual(want, vxids) {
+		t.Errorf("building request trees should give VXIDs %v, got %v", want, vxids)
+	}
+	if want := []int{1, 1, 2, 2, 3, 2, 1}; !reflect.DeepEqual(want, levels) {
+		t.Errorf("building request trees should give levels %v, got %v", want, levels)
+	}
+	if len(flushed) != 0 {
+		t.Errorf("building request trees should leave no trees, got %v", flushed)
+	}
+
+	// Without grouping, entries are returned as they are added.
+	got, _ = buildTrees(t, GroupVXID, s)
+	if len(got) != 7 {
+		t.Errorf("building trees without grouping should give 7 entries, got %d", len(got))
+	}
+
+	bad := []*Entry{
+		NewEntry(Request, 2).Add("Begin", "req x rxreq"),
+		NewEntry(Request, 2).Add("Begin", "req 1 rxreq").Add("Link", "bereq"),
+	}
+	for _, e := range bad {
+		if _, err := NewTreeBuilder(GroupRequest).Add(e); err == nil {
+			t.Errorf("adding %v should fail", e)
+		} else {
+			t.Logf("adding %v gives: %v", e, err)
+		}
+	}
+}
+
+// TestTreeBuilderEviction tests that the trees holding the oldest entries are
+// evicted once there are too many entries or the entries are too old.
+func TestTreeBuilderEviction(t *testing.T) {
+	s := `
+*   << BeReq    >> 3
+-   Begin          bereq 2 fetch
+-   Timestamp      Start: 1545037990.000000 0.000000 0.000000
+-   Timestamp      BerespBody: 1545037990.100000 0.100000 0.100000
+-   End
+*   << BeReq    >> 5
+-   Begin          bereq 4 bgfetch
+-   Timestamp      Start: 1545037991.000000 0.000000 0.000000
+-   Timestamp      BerespBody: 1545037991.100000 0.100000 0.100000
+-   End
+*   << Request  >> 6
+-   Begin          req 1 rxreq
+-   Link           bereq 7 fetch
+-   Timestamp      Start: 1545037999.000000 0.000000 0.000000
+-   Timestamp      Resp: 1545037999.100000 0.100000 0.100000
+-   End
+*   << BeReq    >> 8
+-   Begin          bereq 6 fetch
+-   End
+`
+	entries, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("failed to parse entries: %v", err)
+	}
+	vxids := func(entries []*Entry) []uint64 {
+		var vxids []uint64
+		for _, e := range entries {
+			vxids = append(vxids, e.VXID)
+		}
+		return vxids
+	}
+
+	var evicted []*Entry
+	b := NewTreeBuilder(GroupRequest)
+	b.MaxEntries = 2
+	b.OnEvict = func(e *Entry) { evicted = append(evicted, e) }
+	for _, e := range entries {
+		if trees, err := b.Add(e); err != nil || len(trees) != 0 {
+			t.Errorf("adding entry %d should give no trees, got %v (%v)", e.VXID, trees, err)
+		}
+	}
+	if want := []uint64{3, 5}; !reflect.DeepEqual(want, vxids(evicted)) {
+		t.Errorf("evicting beyond 2 entries should evict %v, got %v", want, vxids(evicted))
+	}
+	if b.Len() != 2 {
+		t.Errorf("builder should hold 2 entries, got %d", b.Len())
+	}
+	if want := []uint64{6}; !reflect.DeepEqual(want, vxids(b.Flush())) {
+		t.Errorf("flushing should give %v", want)
+	}
+
+	// The age is measured in the time of the log, request 6 ends 8.9s after
+	// backend request 5.
+	evicted = nil
+	b = NewTreeBuilder(GroupRequest)
+	b.MaxAge = 5 * time.Second
+	b.OnEvict = func(e *Entry) { evicted = append(evicted, e) }
+	for _, e := range entries[:2] {
+		b.Add(e)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("entries within 5s should not be evicted, got %v", vxids(evicted))
+	}
+	b.Add(entries[2])
+	if want := []uint64{3, 5}; !reflect.DeepEqual(want, vxids(evicted)) {
+		t.Errorf("evicting entries older than 5s should evict %v, got %v", want, vxids(evicted))
+	}
+	// Evicted entries no longer wait for their parents.
+	if trees, _ := b.Add(NewEntry(Request, 2).Add("Begin", "req 1 rxreq").Add("Link", "bereq 3 fetch")); len(trees) != 0 {
+		t.Errorf("request 2 should not be completed by evicted backend request 3, got %v", trees)
+	}
+	if want := []uint64{6, 2}; !reflect.DeepEqual(want, vxids(b.Flush())) {
+		t.Errorf("flushing should give %v", want)
+	}
+}
+
+// TestTreeBuilderQueue tests that the builder does not hold on to completed
+// trees behind an old entry which is never resolved.
+func TestTreeBuilderQueue(t *testing.T) {
+	b := NewTreeBuilder(GroupRequest)
+	b.MaxEntries = 100
+	b.Add(NewEntry(BeReq, 1).Add("Begin", "bereq 2 fetch"))
+	for vxid := uint64(10); vxid < 20000; vxid += 2 {
+		b.Add(NewEntry(BeReq, vxid+1).Add("Begin", fmt.Sprintf("bereq %d fetch", vxid)))
+		trees, err := b.Add(NewEntry(Request, vxid).Add("Begin", "req 3 rxreq").Add("Link", fmt.Sprintf("bereq %d fetch", vxid+1)))
+		if err != nil || len(trees) != 1 {
+			t.Fatalf("adding request %d should complete its tree, got %v (%v)", vxid, trees, err)
+		}
+	}
+	if b.Len() != 1 || len(b.queue) > 100 {
+		t.Errorf("builder should hold 1 entry in a short queue, got %d in %d", b.Len(), len(b.queue))
+	}
+}
+
+// TestTreeBuilderPolicy tests that trees are returned by Add according to the
+// flush policy, with incomplete ones flagged.
+func TestTreeBuilderPolicy(t *testing.T) {
+	s := `
+*   << Request  >> 2
+-   Begin          req 1 rxreq
+-   Link           bereq 3 fetch
+-   Timestamp      Start: 1545037990.000000 0.000000 0.000000
+-   Timestamp      Resp: 1545037990.100000 0.100000 0.100000
+-   End
+*   << Request  >> 4
+-   Begin          req 1 rxreq
+-   Timestamp      Start: 1545037999.000000 0.000000 0.000000
+-   Timestamp      Resp: 1545037999.100000 0.100000 0.100000
+-   End
+*   << Session  >> 1
+-   Begin          sess 0 HTTP/1
+-   Link           req 2 rxreq
+-   Link           req 4 rxreq
+-   End
+`
+	entries, err := ParseAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("failed to parse entries: %v", err)
+	}
+	add := func(b *TreeBuilder, e *Entry) []*Entry {
+		trees, err := b.Add(e.Clone())
+		if err != nil {
+			t.Fatalf("adding entry %d should not fail, got: %v", e.VXID, err)
+		}
+		return trees
+	}
+
+	// The session is returned once it ended, without backend request 3.
+	b := NewTreeBuilder(GroupSession)
+	b.Policy = FlushOnRootEnd
+	if trees := add(b, entries[0]); len(trees) != 0 {
+		t.Errorf("adding request 2 should give no trees, got %v", trees)
+	}
+	add(b, entries[1])
+	trees := add(b, entries[2])
+	if len(trees) != 1 || trees[0].VXID != 1 || !trees[0].Incomplete || len(trees[0].Children) != 2 {
+		t.Errorf("adding session 1 should give it incomplete with 2 requests, got %v", trees)
+	}
+	if b.Len() != 0 {
+		t.Errorf("builder should hold no entries, got %d", b.Len())
+	}
+
+	// Request 2 is returned on its own once it is older than 5s.
+	b = NewTreeBuilder(GroupSession)
+	b.Policy = FlushOnEvict
+	b.MaxAge = 5 * time.Second
+	b.OnEvict = func(e *Entry) { t.Errorf("evicted entry %d should be returned", e.VXID) }
+	add(b, entries[0])
+	trees = add(b, entries[1])
+	if len(trees) != 1 || trees[0].VXID != 2 || !trees[0].Incomplete {
+		t.Errorf("adding request 4 should give request 2 incomplete, got %v", trees)
+	}
+
+	// By default, only complete trees are returned.
+	b = NewTreeBuilder(GroupSession)
+	for _, e := range entries {
+		if trees := add(b, e); len(trees) != 0 {
+			t.Errorf("adding entry %d should give no trees, got %v", e.VXID, trees)
+		}
+	}
+	trees = b.Flush()
+	if len(trees) != 1 || trees[0].VXID != 1 || !trees[0].Incomplete {
+		t.Errorf("flushing should give session 1 incomplete, got %v", trees)
+	}
+}
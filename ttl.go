@@ -0,0 +1,103 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// TTL represents a parsed TTL record, which reports the lifetime of an object
+// as computed from the response headers ("RFC"), as set in VCL ("VCL"), or of
+// hit-for-pass ("HFP") and hit-for-miss ("HFM") objects, e.g.:
+//
+//	RFC 120 10 0 1545037998 1545037998 1545037990 0 120 cacheable
+//	VCL 300 10 0 1545037998 cacheable
+//
+// The origin, date, expires and max-age components are only reported by the
+// RFC form. Varnish before 6.0 does not report the cacheability flag, in which
+// case Cacheable is derived from the TTL being positive.
+type TTL struct {
+	Source    string        // Source of the TTL, e.g. "RFC" or "VCL".
+	TTL       time.Duration // Time to live.
+	Grace     time.Duration // Grace period.
+	Keep      time.Duration // Keep period.
+	Reference time.Time     // Reference time the periods are relative to.
+	Origin    time.Time     // Origin time, i.e. now less the Age header (RFC only).
+	Date      time.Time     // Date header, zero if missing (RFC only).
+	Expires   time.Time     // Expires header, zero if missing (RFC only).
+	MaxAge    time.Duration // Max-age from Cache-Control (RFC only).
+	Cacheable bool          // Whether the object is cacheable.
+}
+
+// parseTTL parses the value of a TTL record.
+func parseTTL(v string) (*TTL, error) {
+	fs := strings.Fields(v)
+	if len(fs) < 5 {
+		return nil, errors.Errorf("TTL record %q is malformed", v)
+	}
+	ttl := &TTL{Source: fs[0]}
+	var err error
+	durations := []*time.Duration{&ttl.TTL, &ttl.Grace, &ttl.Keep}
+	for i, d := range durations {
+		if *d, err = parseDuration(fs[1+i]); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse TTL record %q", v)
+		}
+	}
+	if ttl.Reference, err = parseAbsTime(fs[4]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse TTL record %q", v)
+	}
+	rest := fs[5:]
+	if ttl.Source == "RFC" {
+		if len(rest) < 4 {
+			return nil, errors.Errorf("TTL record %q is malformed", v)
+		}
+		for i, t := range []*time.Time{&ttl.Origin, &ttl.Date, &ttl.Expires} {
+			if rest[i] == "0" {
+				continue
+			}
+			if *t, err = parseAbsTime(rest[i]); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse TTL record %q", v)
+			}
+		}
+		if ttl.MaxAge, err = parseDuration(rest[3]); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse TTL record %q", v)
+		}
+		rest = rest[4:]
+	}
+	switch {
+	case len(rest) == 0:
+		ttl.Cacheable = ttl.TTL > 0
+	case len(rest) == 1 && rest[0] == "cacheable":
+		ttl.Cacheable = true
+	case len(rest) == 1 && rest[0] == "uncacheable":
+		ttl.Cacheable = false
+	default:
+		return nil, errors.Errorf("TTL record %q is malformed", v)
+	}
+	return ttl, nil
+}
+
+// TTLs parses and returns all TTL records of the log entry, in the order in
+// which they appear.
+func (e *Entry) TTLs() ([]*TTL, error) {
+	fs := e.Fields["TTL"]
+	ttls := make([]*TTL, 0, len(fs))
+	for _, v := range fs {
+		ttl, err := parseTTL(v)
+		if err != nil {
+			return nil, err
+		}
+		ttls = append(ttls, ttl)
+	}
+	return ttls, nil
+}
+
+// TTL parses and returns the last TTL record of the log entry, which holds the
+// lifetime finally assigned to the object.
+func (e *Entry) TTL() (*TTL, error) {
+	fs, err := e.Field("TTL")
+	if err != nil {
+		return nil, err
+	}
+	return parseTTL(fs[len(fs)-1])
+}
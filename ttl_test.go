@@ -0,0 +1,103 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestTTL tests that TTL records of all forms are parsed correctly and that
+// malformed ones produce errors.
+func TestTTL(t *testing.T) {
+	ref := time.Unix(1545037998, 0).UTC()
+	samples := map[string]*TTL{
+		"RFC 120 10 0 1545037998 1545037998 1545037990 0 120 cacheable": &TTL{
+			Source:    "RFC",
+			TTL:       120 * time.Second,
+			Grace:     10 * time.Second,
+			Reference: ref,
+			Origin:    ref,
+			Date:      time.Unix(1545037990, 0).UTC(),
+			MaxAge:    120 * time.Second,
+			Cacheable: true,
+		},
+		"RFC -1 10 0 1545037998 0 0 0 0 uncacheable": &TTL{
+			Source:    "RFC",
+			TTL:       -time.Second,
+			Grace:     10 * time.Second,
+			Reference: ref,
+		},
+		"VCL 300 10 3600 1545037998 cacheable": &TTL{
+			Source:    "VCL",
+			TTL:       300 * time.Second,
+			Grace:     10 * time.Second,
+			Keep:      time.Hour,
+			Reference: ref,
+			Cacheable: true,
+		},
+		"HFP 120 0 0 1545037998 uncacheable": &TTL{
+			Source:    "HFP",
+			TTL:       120 * time.Second,
+			Reference: ref,
+		},
+		// Varnish before 6.0 reports no cacheability flag.
+		"VCL 300 10 0 1545037998": &TTL{
+			Source:    "VCL",
+			TTL:       300 * time.Second,
+			Grace:     10 * time.Second,
+			Reference: ref,
+			Cacheable: true,
+		},
+		"RFC 120 10 0 1545037998.5 0 1545037990 1545038110 -1": &TTL{
+			Source:    "RFC",
+			TTL:       120 * time.Second,
+			Grace:     10 * time.Second,
+			Reference: time.Unix(1545037998, 500000000).UTC(),
+			Date:      time.Unix(1545037990, 0).UTC(),
+			Expires:   time.Unix(1545038110, 0).UTC(),
+			MaxAge:    -time.Second,
+			Cacheable: true,
+		},
+	}
+	for v, want := range samples {
+		got, err := parseTTL(v)
+		if err != nil {
+			t.Errorf("parsing TTL record %q should not fail, got: %v", v, err)
+			continue
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing TTL record %q should give %v, got %v", v, want, got)
+		}
+	}
+
+	bad := []string{
+		"",
+		"VCL 300 10 0",
+		"VCL 300 10 0 1545037998 maybe",
+		"VCL foo 10 0 1545037998",
+		"RFC 120 10 0 1545037998 0 0 0",
+		"RFC 120 10 0 1545037998 0 0 0 0 cacheable extra",
+		"RFC 120 10 0 1545037998 foo 0 0 0 cacheable",
+	}
+	for _, v := range bad {
+		if _, err := parseTTL(v); err == nil {
+			t.Errorf("parsing TTL record %q should fail", v)
+		} else {
+			t.Logf("parsing TTL record %q gives: %v", v, err)
+		}
+	}
+
+	e := &Entry{Fields: Fields{"TTL": []string{
+		"RFC 120 10 0 1545037998 1545037998 1545037990 0 120 cacheable",
+		"VCL 300 10 0 1545037998 cacheable",
+	}}}
+	if ttls, err := e.TTLs(); err != nil || len(ttls) != 2 || ttls[0].Source != "RFC" {
+		t.Errorf("parsing TTL records should give both in order, got %v (%v)", ttls, err)
+	}
+	if ttl, err := e.TTL(); err != nil || ttl.Source != "VCL" {
+		t.Errorf("the last TTL record should be the VCL one, got %v (%v)", ttl, err)
+	}
+	if _, err := example().TTL(); err == nil {
+		t.Errorf("parsing missing TTL record should fail")
+	}
+}
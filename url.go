@@ -0,0 +1,71 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ParseURL parses the URL of the request selected by o, see URL. The path and
+// query parameters are available as the Path field and the Query method of
+// the result.
+func (e *Entry) ParseURL(o Occurrence) (*url.URL, error) {
+	v, err := e.URL(o)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.ParseRequestURI(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse URL %q", v)
+	}
+	return u, nil
+}
+
+// URLNormalization configures how URLKey normalizes URLs.
+type URLNormalization struct {
+	DropQuery  bool     // Whether to drop the query string entirely.
+	DropParams []string // Query parameters to drop, e.g. "utm_source".
+	CleanPath  bool     // Whether to clean the path with path.Clean.
+	LowerPath  bool     // Whether to lower-case the path.
+	SortValues bool     // Whether to sort values of repeated query parameters.
+}
+
+// URLKey returns the URL of the request selected by o normalized according to
+// n, which is suitable as a key for aggregation of requests by their URL. The
+// query parameters are always sorted by their names and re-encoded, so that
+// e.g. "/?b=1&a=2" and "/?a=2&b=1" give the same key "/?a=2&b=1".
+func (e *Entry) URLKey(o Occurrence, n URLNormalization) (string, error) {
+	u, err := e.ParseURL(o)
+	if err != nil {
+		return "", err
+	}
+	p := u.Path
+	if n.CleanPath && p != "" {
+		p = path.Clean(p)
+	}
+	if n.LowerPath {
+		p = strings.ToLower(p)
+	}
+	k := (&url.URL{Path: p}).EscapedPath()
+	if n.DropQuery {
+		return k, nil
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse query of URL %q", u)
+	}
+	for _, name := range n.DropParams {
+		q.Del(name)
+	}
+	if n.SortValues {
+		for _, vs := range q {
+			slices.Sort(vs)
+		}
+	}
+	if len(q) > 0 {
+		k += "?" + q.Encode()
+	}
+	return k, nil
+}
@@ -0,0 +1,59 @@
+package vslparser
+
+import (
+	"testing"
+)
+
+// TestParseURL tests that the URL of requests is decomposed into its path and
+// query parameters.
+func TestParseURL(t *testing.T) {
+	e := &Entry{Kind: Request, Fields: Fields{"ReqURL": []string{"/foo%20bar?a=1&b=2&a=3"}}}
+	u, err := e.ParseURL(Final)
+	if err != nil {
+		t.Fatalf("parsing URL should not fail, got: %v", err)
+	}
+	q := u.Query()
+	if u.Path != "/foo bar" || len(q["a"]) != 2 || q.Get("b") != "2" {
+		t.Errorf("parsing URL should give path /foo bar and query a=1&a=3&b=2, got %q and %v", u.Path, q)
+	}
+
+	e = &Entry{Kind: Request, Fields: Fields{"ReqURL": []string{"foo"}}}
+	if _, err := e.ParseURL(Final); err == nil {
+		t.Errorf("parsing URL foo should fail")
+	} else {
+		t.Logf("parsing URL foo gives: %v", err)
+	}
+}
+
+// TestURLKey tests that URLs are normalized into aggregation keys.
+func TestURLKey(t *testing.T) {
+	samples := []struct {
+		url  string
+		n    URLNormalization
+		want string
+	}{
+		{"/", URLNormalization{}, "/"},
+		{"/foo?b=1&a=2", URLNormalization{}, "/foo?a=2&b=1"},
+		{"/foo?a=2&a=1", URLNormalization{}, "/foo?a=2&a=1"},
+		{"/foo?a=2&a=1", URLNormalization{SortValues: true}, "/foo?a=1&a=2"},
+		{"/foo?a=2&utm_source=x", URLNormalization{DropParams: []string{"utm_source"}}, "/foo?a=2"},
+		{"/Foo?a=2", URLNormalization{DropQuery: true, LowerPath: true}, "/foo"},
+		{"/a//b/../c/", URLNormalization{CleanPath: true}, "/a/c"},
+		{"/a%20b?x=a%20b", URLNormalization{}, "/a%20b?x=a+b"},
+	}
+	for _, s := range samples {
+		e := &Entry{Kind: BeReq, Fields: Fields{"BereqURL": []string{s.url}}}
+		if got, err := e.URLKey(Final, s.n); err != nil || got != s.want {
+			t.Errorf("key of URL %q with %+v should be %q, got %q (%v)", s.url, s.n, s.want, got, err)
+		}
+	}
+
+	for _, v := range []string{"foo", "/?a=%zz"} {
+		e := &Entry{Kind: BeReq, Fields: Fields{"BereqURL": []string{v}}}
+		if _, err := e.URLKey(Final, URLNormalization{}); err == nil {
+			t.Errorf("key of URL %q should fail", v)
+		} else {
+			t.Logf("key of URL %q gives: %v", v, err)
+		}
+	}
+}
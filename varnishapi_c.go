@@ -0,0 +1,200 @@
+//go:build cgo && varnishapi
+
+package vslparser
+
+/*
+#cgo pkg-config: varnishapi
+#include <stdint.h>
+#include <stdlib.h>
+#include <vapi/vsm.h>
+#include <vapi/vsl.h>
+
+extern int vslparserDispatch(struct VSL_transaction **trans, uintptr_t priv);
+
+static int
+vslparser_dispatch(struct VSL_data *vsl, struct VSL_transaction * const trans[], void *priv)
+{
+	(void)vsl;
+	return (vslparserDispatch((struct VSL_transaction **)trans, (uintptr_t)priv));
+}
+
+static int
+vslparser_vslq_dispatch(struct VSLQ *vslq, uintptr_t priv)
+{
+	return (VSLQ_Dispatch(vslq, vslparser_dispatch, (void *)priv));
+}
+
+static struct VSL_cursor *
+vslparser_cursor(struct VSL_data *vsl, struct vsm *vsm)
+{
+	return (VSL_CursorVSM(vsl, vsm, VSL_COPT_TAIL | VSL_COPT_BATCH));
+}
+
+const char *
+vslparser_tag(const struct VSL_cursor *c)
+{
+	return (VSL_tags[VSL_TAG(c->rec.ptr)]);
+}
+
+const char *
+vslparser_data(const struct VSL_cursor *c)
+{
+	return (VSL_CDATA(c->rec.ptr));
+}
+
+int
+vslparser_len(const struct VSL_cursor *c)
+{
+	return (VSL_LEN(c->rec.ptr));
+}
+
+uint64_t
+vslparser_vxid(const struct VSL_cursor *c)
+{
+	return (VSL_ID(c->rec.ptr));
+}
+*/
+import "C"
+
+import (
+	"github.com/pkg/errors"
+	"io"
+	"runtime/cgo"
+	"time"
+	"unsafe"
+)
+
+// apiGroupings maps groupings to their libvarnishapi counterparts.
+var apiGroupings = map[Grouping]C.enum_VSL_grouping_e{
+	GroupVXID:    C.VSL_g_vxid,
+	GroupRequest: C.VSL_g_request,
+	GroupSession: C.VSL_g_session,
+}
+
+// apiKinds maps the libvarnishapi transaction types to the kinds of entries.
+var apiKinds = map[C.enum_VSL_transaction_e]Kind{
+	C.VSL_t_sess:  Session,
+	C.VSL_t_req:   Request,
+	C.VSL_t_bereq: BeReq,
+	C.VSL_t_raw:   Raw,
+}
+
+// APIReader reads log entries from a running Varnish instance using
+// libvarnishapi, the library varnishlog itself is built on. Cursor management,
+// overrun handling, grouping and queries follow the semantics of varnishlog
+// exactly.
+//
+// The APIReader is only available when built with cgo and the "varnishapi"
+// build tag, and requires libvarnishapi to be installed.
+type APIReader struct {
+	// PollInterval is the interval in which the log is checked for new
+	// records. If zero, DefaultVSMPollInterval is used.
+	PollInterval time.Duration
+
+	vsm    *C.struct_vsm
+	vsl    *C.struct_VSL_data
+	vslq   *C.struct_VSLQ
+	handle cgo.Handle
+	queue  []*Entry
+}
+
+// OpenAPI attaches to the shared memory log of the Varnish instance with the
+// given name or working directory, as accepted by the -n option of
+// varnishlog. The entries are grouped according to grouping, and only those
+// matching query are returned, unless query is empty. See vsl-query(7) for
+// the syntax of queries.
+func OpenAPI(name string, grouping Grouping, query string) (*APIReader, error) {
+	g, ok := apiGroupings[grouping]
+	if !ok {
+		return nil, errors.Errorf("unsupported grouping %d", grouping)
+	}
+	r := &APIReader{
+		vsm: C.VSM_New(),
+	}
+	if name != "" {
+		n := C.CString(name)
+		defer C.free(unsafe.Pointer(n))
+		if C.VSM_Arg(r.vsm, 'n', n) <= 0 {
+			defer r.Close()
+			return nil, errors.Errorf("invalid instance name: %s", C.GoString(C.VSM_Error(r.vsm)))
+		}
+	}
+	if C.VSM_Attach(r.vsm, -1) != 0 {
+		defer r.Close()
+		return nil, errors.Errorf("cannot attach to shared memory: %s", C.GoString(C.VSM_Error(r.vsm)))
+	}
+	r.vsl = C.VSL_New()
+	c := C.vslparser_cursor(r.vsl, r.vsm)
+	if c == nil {
+		defer r.Close()
+		return nil, errors.Errorf("cannot open log cursor: %s", C.GoString(C.VSL_Error(r.vsl)))
+	}
+	var q *C.char
+	if query != "" {
+		q = C.CString(query)
+		defer C.free(unsafe.Pointer(q))
+	}
+	if r.vslq = C.VSLQ_New(r.vsl, &c, g, q); r.vslq == nil {
+		C.VSL_DeleteCursor(c)
+		defer r.Close()
+		return nil, errors.Errorf("cannot create log query: %s", C.GoString(C.VSL_Error(r.vsl)))
+	}
+	r.handle = cgo.NewHandle(r)
+	return r, nil
+}
+
+// Close detaches the reader from the shared memory log and releases all
+// resources.
+func (r *APIReader) Close() error {
+	if r.vslq != nil {
+		C.VSLQ_Delete(&r.vslq)
+	}
+	if r.vsl != nil {
+		C.VSL_Delete(r.vsl)
+		r.vsl = nil
+	}
+	if r.vsm != nil {
+		C.VSM_Destroy(&r.vsm)
+	}
+	if r.handle != 0 {
+		r.handle.Delete()
+		r.handle = 0
+	}
+	return nil
+}
+
+// Next returns the next entry, waiting for it to be logged if necessary. If
+// Varnish overwrote records before they were read, including those of a
+// group being read, ErrOverrun is returned and reading continues with the
+// most recent records. io.EOF is returned when Varnish goes away, and other
+// errors of reading the log are returned as is.
+func (r *APIReader) Next() (*Entry, error) {
+	for len(r.queue) == 0 {
+		switch i := C.vslparser_vslq_dispatch(r.vslq, C.uintptr_t(r.handle)); {
+		case i > 0:
+		case i == 0:
+			interval := r.PollInterval
+			if interval <= 0 {
+				interval = DefaultVSMPollInterval
+			}
+			time.Sleep(interval)
+		case i == -3:
+			// Varnish overwrote records before they were read, start over at
+			// the tail of the log.
+			c := C.vslparser_cursor(r.vsl, r.vsm)
+			if c == nil {
+				return nil, errors.Errorf("cannot reopen log cursor: %s", C.GoString(C.VSL_Error(r.vsl)))
+			}
+			C.VSLQ_SetCursor(r.vslq, &c)
+			return nil, ErrOverrun
+		case i == -1 || i == -2:
+			// End of the log, or Varnish abandoned the shared memory.
+			return nil, io.EOF
+		default:
+			return nil, errors.Errorf("cannot read log: %s", C.GoString(C.VSL_Error(r.vsl)))
+		}
+	}
+	e := r.queue[0]
+	r.queue = r.queue[1:]
+	return e, nil
+}
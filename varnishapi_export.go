@@ -0,0 +1,72 @@
+//go:build cgo && varnishapi
+
+package vslparser
+
+// The preamble of a file with exported functions must not contain
+// definitions, so the dispatch callback lives apart from the C helpers it
+// uses, see varnishapi_c.go.
+
+/*
+#include <stdint.h>
+#include <vapi/vsl.h>
+
+extern const char *vslparser_tag(const struct VSL_cursor *c);
+extern const char *vslparser_data(const struct VSL_cursor *c);
+extern int vslparser_len(const struct VSL_cursor *c);
+extern uint64_t vslparser_vxid(const struct VSL_cursor *c);
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"strings"
+	"unsafe"
+)
+
+// vslparserDispatch is called by VSLQ_Dispatch for every group of
+// transactions, root first. It assembles the transactions into a tree of
+// entries and queues it for the APIReader identified by priv. If reading the
+// records of a transaction fails, e.g. since Varnish overwrote them, the
+// group is dropped and the error of VSL_Next is returned, which stops
+// VSLQ_Dispatch and is returned by it.
+//
+//export vslparserDispatch
+func vslparserDispatch(trans **C.struct_VSL_transaction, priv C.uintptr_t) C.int {
+	r := cgo.Handle(priv).Value().(*APIReader)
+	var root *Entry
+	var parents []*Entry
+	for ; *trans != nil; trans = (**C.struct_VSL_transaction)(unsafe.Add(unsafe.Pointer(trans), unsafe.Sizeof(*trans))) {
+		t := *trans
+		e := newEntry()
+		e.Level = int(t.level)
+		e.Kind = apiKinds[t._type]
+		var i C.int
+		for i = C.VSL_Next(t.c); i == 1; i = C.VSL_Next(t.c) {
+			e.VXID = uint64(C.vslparser_vxid(t.c))
+			tag := C.GoString(C.vslparser_tag(t.c))
+			if tag == "End" {
+				continue
+			}
+			v := C.GoStringN(C.vslparser_data(t.c), C.vslparser_len(t.c))
+			e.add(tag, strings.TrimRight(v, "\x00"))
+		}
+		if i < 0 {
+			return i
+		}
+		if root == nil {
+			root = e
+			parents = []*Entry{e}
+			continue
+		}
+		d := e.Level - root.Level
+		if d < 1 || d > len(parents) {
+			continue
+		}
+		parents[d-1].Children = append(parents[d-1].Children, e)
+		parents = append(parents[:d], e)
+	}
+	if root != nil {
+		r.queue = append(r.queue, root)
+	}
+	return 0
+}
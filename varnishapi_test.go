@@ -0,0 +1,48 @@
+//go:build cgo && varnishapi
+
+package vslparser
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+// TestAPIReader tests that opening a reader fails for unsupported groupings
+// and missing instances, and that requests to the Varnish instance given by
+// VSLPARSER_VARNISH_NAME and VSLPARSER_VARNISH_ADDR, e.g. the one started by
+// CI, are read from its log.
+func TestAPIReader(t *testing.T) {
+	if _, err := OpenAPI("", Grouping(42), ""); err == nil {
+		t.Errorf("opening reader with unsupported grouping should fail")
+	} else {
+		t.Logf("opening reader with unsupported grouping gives: %v", err)
+	}
+	if _, err := OpenAPI(t.TempDir(), GroupVXID, ""); err == nil {
+		t.Errorf("opening reader of missing instance should fail")
+	} else {
+		t.Logf("opening reader of missing instance gives: %v", err)
+	}
+
+	name, addr := os.Getenv("VSLPARSER_VARNISH_NAME"), os.Getenv("VSLPARSER_VARNISH_ADDR")
+	if addr == "" {
+		t.Skip("VSLPARSER_VARNISH_ADDR is not set")
+	}
+	r, err := OpenAPI(name, GroupRequest, `ReqURL eq "/vslparser"`)
+	if err != nil {
+		t.Fatalf("opening reader should not fail, got: %v", err)
+	}
+	defer r.Close()
+	resp, err := http.Get("http://" + addr + "/vslparser")
+	if err != nil {
+		t.Fatalf("requesting Varnish should not fail, got: %v", err)
+	}
+	resp.Body.Close()
+	e, err := r.Next()
+	if err != nil {
+		t.Fatalf("reading entry should not fail, got: %v", err)
+	}
+	if url, err := e.URL(Final); e.Kind != Request || err != nil || url != "/vslparser" {
+		t.Errorf("reading entry should give request of /vslparser, got %v", e)
+	}
+}
@@ -0,0 +1,160 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// DefaultVCLLogDelimiters are the delimiters of keys and values of VCL_Log
+// records recognized by VCLLog by default.
+const DefaultVCLLogDelimiters = ":="
+
+// VCLLog parses the VCL_Log records of the log entry, which are written by
+// std.log() in VCL, as key-value pairs, e.g. "user: 42" or "ab_test=B". The
+// key ends at the first occurrence of any of the bytes in delims, or of
+// DefaultVCLLogDelimiters if delims is empty. White-space around keys and
+// values is removed. Records without a delimiter are ignored.
+//
+// As with the fields of an entry, all values logged with the same key are
+// kept, in the order in which they appear.
+func (e *Entry) VCLLog(delims string) Fields {
+	if delims == "" {
+		delims = DefaultVCLLogDelimiters
+	}
+	fs := Fields{}
+	for _, v := range e.Fields["VCL_Log"] {
+		i := strings.IndexAny(v, delims)
+		if i == -1 {
+			continue
+		}
+		k := strings.TrimSpace(v[:i])
+		if k == "" {
+			continue
+		}
+		fs[k] = append(fs[k], strings.TrimSpace(v[i+1:]))
+	}
+	return fs
+}
+
+// VCLStep is a single step of the path a transaction took through VCL, i.e. a
+// VCL subroutine called and the action it returned.
+type VCLStep struct {
+	Call   string // Name of the subroutine, e.g. "RECV".
+	Return string // Action returned, e.g. "hash", empty if none was logged.
+}
+
+// VCLTrace returns the steps of the path the transaction took through VCL, by
+// pairing each VCL_call record with the VCL_return record following it, e.g.
+// RECV returning hash, followed by HASH returning lookup. Entries without
+// Records are traced by pairing the records by their index.
+func (e *Entry) VCLTrace() []VCLStep {
+	if len(e.Records) > 0 {
+		var steps []VCLStep
+		for _, r := range e.Records {
+			switch {
+			case r.Tag == "VCL_call":
+				steps = append(steps, VCLStep{Call: r.Value})
+			case r.Tag == "VCL_return" && len(steps) > 0 && steps[len(steps)-1].Return == "":
+				steps[len(steps)-1].Return = r.Value
+			}
+		}
+		return steps
+	}
+	calls := e.Fields["VCL_call"]
+	returns := e.Fields["VCL_return"]
+	steps := make([]VCLStep, len(calls))
+	for i, c := range calls {
+		steps[i].Call = c
+		if i < len(returns) {
+			steps[i].Return = returns[i]
+		}
+	}
+	return steps
+}
+
+// VCLUse represents a parsed VCL_use record, which reports the VCL used by a
+// transaction, e.g. "boot", or "tenant_a via l_tenant_a" if the VCL was
+// switched to through a label.
+type VCLUse struct {
+	Name  string // Name of the VCL.
+	Label string // Name of the label the VCL was used through, if any.
+}
+
+// VCLUse parses and returns the last VCL_use record of the log entry, which
+// reports the VCL finally used by the transaction.
+func (e *Entry) VCLUse() (*VCLUse, error) {
+	fs, err := e.Field("VCL_use")
+	if err != nil {
+		return nil, err
+	}
+	v := fs[len(fs)-1]
+	parts := strings.Fields(v)
+	switch {
+	case len(parts) == 1:
+		return &VCLUse{Name: parts[0]}, nil
+	case len(parts) == 3 && parts[1] == "via":
+		return &VCLUse{Name: parts[0], Label: parts[2]}, nil
+	}
+	return nil, errors.Errorf("VCL_use record %q is malformed", v)
+}
+
+// VCLTracePoint represents a parsed VCL_trace record, which reports a point
+// in the VCL source reached by a transaction when tracing is enabled, e.g.
+// "boot 12 1.45.3". Varnish before 6.0 reports neither the name of the VCL
+// nor the index of the source file, e.g. "12 45.3", in which case VCL is
+// empty and Source is zero.
+type VCLTracePoint struct {
+	VCL    string // Name of the VCL.
+	Index  int    // Index of the trace point in the VCL program.
+	Source int    // Index of the source file, zero being the main one.
+	Line   int    // Line in the source file.
+	Pos    int    // Position within the line.
+}
+
+// parseVCLTrace parses the value of a VCL_trace record.
+func parseVCLTrace(v string) (*VCLTracePoint, error) {
+	fs := strings.Fields(v)
+	tp := &VCLTracePoint{}
+	// The location is "source.line.pos", or "line.pos" for old versions.
+	var loc []*int
+	switch len(fs) {
+	case 3:
+		tp.VCL = fs[0]
+		fs = fs[1:]
+		loc = []*int{&tp.Source, &tp.Line, &tp.Pos}
+	case 2:
+		loc = []*int{&tp.Line, &tp.Pos}
+	default:
+		return nil, errors.Errorf("VCL_trace record %q is malformed", v)
+	}
+	parts := strings.Split(fs[1], ".")
+	if len(parts) != len(loc) {
+		return nil, errors.Errorf("VCL_trace record %q is malformed", v)
+	}
+	var err error
+	if tp.Index, err = strconv.Atoi(fs[0]); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse VCL_trace record %q", v)
+	}
+	for i, p := range parts {
+		if *loc[i], err = strconv.Atoi(p); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse VCL_trace record %q", v)
+		}
+	}
+	return tp, nil
+}
+
+// VCLTracePoints parses and returns all VCL_trace records of the log entry, in
+// the order in which they appear.
+func (e *Entry) VCLTracePoints() ([]*VCLTracePoint, error) {
+	fs := e.Fields["VCL_trace"]
+	tps := make([]*VCLTracePoint, 0, len(fs))
+	for _, v := range fs {
+		tp, err := parseVCLTrace(v)
+		if err != nil {
+			return nil, err
+		}
+		tps = append(tps, tp)
+	}
+	return tps, nil
+}
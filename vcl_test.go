@@ -0,0 +1,137 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestVCLLog tests that VCL_Log records are parsed as key-value pairs, with
+// default and custom delimiters.
+func TestVCLLog(t *testing.T) {
+	e := &Entry{Fields: Fields{"VCL_Log": []string{
+		"user: 42",
+		"ab_test=B",
+		"no delimiter here",
+		"url: http://example.com/?a=b",
+		": empty key",
+		"user:  43 ",
+		"tenant|acme",
+	}}}
+	want := Fields{
+		"user":    []string{"42", "43"},
+		"ab_test": []string{"B"},
+		"url":     []string{"http://example.com/?a=b"},
+	}
+	if got := e.VCLLog(""); !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing VCL_Log records should give %v, got %v", want, got)
+	}
+	want = Fields{"tenant": []string{"acme"}}
+	if got := e.VCLLog("|"); !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing VCL_Log records with delimiter \"|\" should give %v, got %v", want, got)
+	}
+	if got := example().VCLLog(""); len(got) != 0 {
+		t.Errorf("entry without VCL_Log records should give no values, got %v", got)
+	}
+}
+
+// TestVCLTrace tests that VCL_call and VCL_return records are paired in order.
+func TestVCLTrace(t *testing.T) {
+	want := []VCLStep{
+		{Call: "RECV", Return: "synth"},
+		{Call: "HASH", Return: "lookup"},
+		{Call: "SYNTH", Return: "deliver"},
+	}
+	if got := example().VCLTrace(); !reflect.DeepEqual(want, got) {
+		t.Errorf("tracing VCL should give %v, got %v", want, got)
+	}
+
+	e := &Entry{Fields: Fields{
+		"VCL_call":   []string{"BACKEND_FETCH", "BACKEND_RESPONSE"},
+		"VCL_return": []string{"fetch"},
+	}}
+	want = []VCLStep{
+		{Call: "BACKEND_FETCH", Return: "fetch"},
+		{Call: "BACKEND_RESPONSE"},
+	}
+	if got := e.VCLTrace(); !reflect.DeepEqual(want, got) {
+		t.Errorf("tracing VCL without the last return should give %v, got %v", want, got)
+	}
+
+	// With records, returns are paired with the call they follow.
+	e = &Entry{Records: Records{
+		{"VCL_call", "RECV"},
+		{"VCL_call", "HASH"},
+		{"VCL_return", "lookup"},
+		{"VCL_return", "deliver"},
+	}}
+	e.Fields = e.Records.Fields()
+	want = []VCLStep{
+		{Call: "RECV"},
+		{Call: "HASH", Return: "lookup"},
+	}
+	if got := e.VCLTrace(); !reflect.DeepEqual(want, got) {
+		t.Errorf("tracing VCL of ordered records should give %v, got %v", want, got)
+	}
+	if got := newEntry().VCLTrace(); len(got) != 0 {
+		t.Errorf("tracing VCL of an empty entry should give no steps, got %v", got)
+	}
+}
+
+// TestVCLUse tests that the VCL used by a transaction is reported, including
+// through labels.
+func TestVCLUse(t *testing.T) {
+	e := &Entry{Fields: Fields{"VCL_use": []string{"boot", "tenant_a via l_tenant_a"}}}
+	want := &VCLUse{Name: "tenant_a", Label: "l_tenant_a"}
+	if got, err := e.VCLUse(); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing VCL_use records should give %v, got %v (%v)", want, got, err)
+	}
+	e = &Entry{Fields: Fields{"VCL_use": []string{"boot"}}}
+	want = &VCLUse{Name: "boot"}
+	if got, err := e.VCLUse(); err != nil || !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing VCL_use record should give %v, got %v (%v)", want, got, err)
+	}
+	for _, v := range []string{"", "tenant_a l_tenant_a", "tenant_a via"} {
+		e := &Entry{Fields: Fields{"VCL_use": []string{v}}}
+		if _, err := e.VCLUse(); err == nil {
+			t.Errorf("parsing VCL_use record %q should fail", v)
+		} else {
+			t.Logf("parsing VCL_use record %q gives: %v", v, err)
+		}
+	}
+	if _, err := example().VCLUse(); err == nil {
+		t.Errorf("parsing missing VCL_use record should fail")
+	}
+}
+
+// TestVCLTracePoints tests that VCL_trace records of all versions are parsed
+// correctly and that malformed ones produce errors.
+func TestVCLTracePoints(t *testing.T) {
+	e := &Entry{Fields: Fields{"VCL_trace": []string{"boot 12 1.45.3", "12 45.3"}}}
+	want := []*VCLTracePoint{
+		&VCLTracePoint{VCL: "boot", Index: 12, Source: 1, Line: 45, Pos: 3},
+		&VCLTracePoint{Index: 12, Line: 45, Pos: 3},
+	}
+	got, err := e.VCLTracePoints()
+	if err != nil {
+		t.Fatalf("parsing VCL_trace records should not fail, got: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("parsing VCL_trace records should give %v, got %v", want, got)
+	}
+
+	bad := []string{
+		"",
+		"12",
+		"boot 12 45.3",
+		"12 1.45.3",
+		"boot x 1.45.3",
+		"boot 12 1.45.x",
+	}
+	for _, v := range bad {
+		if _, err := parseVCLTrace(v); err == nil {
+			t.Errorf("parsing VCL_trace record %q should fail", v)
+		} else {
+			t.Logf("parsing VCL_trace record %q gives: %v", v, err)
+		}
+	}
+}
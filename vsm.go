@@ -0,0 +1,338 @@
+package vslparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Layout of the VSL segment in the shared memory of Varnish, see struct
+// VSL_head in vapi/vsl_int.h of Varnish sources. The head is followed by the
+// log, an array of words divided into vsmSegments segments of equal size,
+// which is written as a ring buffer. The layout of the head is that of a
+// 64-bit host.
+const (
+	vsmHeadMarker    = "VSLHEAD"
+	vsmSegments      = 8
+	vsmSegsizeOffset = 8
+	vsmSegmentNOff   = 16
+	vsmOffsetsOffset = 24
+	vsmLogOffset     = vsmOffsetsOffset + 8*vsmSegments
+	vsmEndMarker     = 254<<24 | 0x454545
+	vsmWrapMarker    = 254<<24 | 0x575757
+	vsmBatchTag      = 255
+)
+
+// DefaultVSMPollInterval is the default interval in which a VSMReader checks
+// for new records once it has read all records written so far.
+const DefaultVSMPollInterval = 10 * time.Millisecond
+
+// DefaultVSMMaxOpen is the default maximum number of transactions a
+// VSMReader holds until their End records are read. It is far more than
+// Varnish usually has in progress, but bounds the memory held for
+// transactions whose End records were lost.
+const DefaultVSMMaxOpen = 100000
+
+// ErrOverrun is returned by VSMReader when Varnish has overwritten the part of
+// the log which was yet to be read. The reader skips to the most recent
+// records and can be used further.
+var ErrOverrun = errors.New("log overrun, records were lost")
+
+// VSMReader reads log records directly from the shared memory log of a
+// running Varnish instance, without running varnishlog. The shared memory is
+// not mapped, the segment file is read instead, so the log is polled for new
+// records.
+type VSMReader struct {
+	// Tags maps the numeric tags of records to their names, see
+	// BinaryReader.
+	Tags []string
+	// PollInterval is the interval in which the log is checked for new
+	// records. If zero, DefaultVSMPollInterval is used.
+	PollInterval time.Duration
+	// MaxOpen is the maximum number of transactions held by NextEntry until
+	// their End records are read, see NextEntry. If zero,
+	// DefaultVSMMaxOpen is used.
+	MaxOpen int
+
+	f       *os.File
+	base    int64  // Offset of the VSL segment in the file.
+	segsize int64  // Size of a log segment in words.
+	ptr     int64  // Index of the next word of the log to be read.
+	seg     uint32 // Number of the segment ptr is in.
+	buf     []byte
+	closed  atomic.Bool
+	grouper vxidGrouper
+}
+
+// vsmSegment returns the path of the file holding the VSL segment of the
+// Varnish instance with the given working directory, and the offset of the
+// segment in the file. The segment is looked up in the index of the shared
+// memory of the child process.
+func vsmSegment(workdir string) (string, int64, error) {
+	dir := filepath.Join(workdir, "_.vsm_child")
+	f, err := os.Open(filepath.Join(dir, "_.index"))
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot open shared memory index")
+	}
+	defer f.Close()
+	// The index lists segments, one per line, as "+ file offset length class
+	// ident", or without the leading "+" in older versions of Varnish.
+	// Segments are removed by "- file offset" lines.
+	found := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fs := strings.Fields(scanner.Text())
+		if len(fs) == 0 || fs[0] == "#" {
+			continue
+		}
+		switch fs[0] {
+		case "-":
+			if len(fs) >= 3 && found == fs[1]+" "+fs[2] {
+				found = ""
+			}
+			continue
+		case "+":
+			fs = fs[1:]
+		}
+		if len(fs) >= 4 && fs[3] == "Log" {
+			found = fs[0] + " " + fs[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, errors.Wrap(err, "cannot read shared memory index")
+	}
+	if found == "" {
+		return "", 0, errors.New("no log segment in shared memory index")
+	}
+	fn, off, _ := strings.Cut(found, " ")
+	o, err := strconv.ParseInt(off, 10, 64)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "cannot parse log segment offset")
+	}
+	return filepath.Join(dir, fn), o, nil
+}
+
+// OpenVSM attaches to the shared memory log of the Varnish instance with the
+// given working directory, e.g. "/var/lib/varnish/hostname". Reading starts
+// at the beginning of the most recent log segment.
+func OpenVSM(workdir string) (*VSMReader, error) {
+	fn, off, err := vsmSegment(workdir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open log segment")
+	}
+	v := &VSMReader{
+		Tags: tagTable,
+		f:    f,
+		base: off,
+	}
+	marker := make([]byte, len(vsmHeadMarker))
+	if _, err := f.ReadAt(marker, off); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "cannot read log segment head")
+	}
+	if string(marker) != vsmHeadMarker {
+		f.Close()
+		return nil, errors.New("log segment head marker not found")
+	}
+	segsize, err := v.read64(vsmSegsizeOffset)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if segsize <= 0 {
+		f.Close()
+		return nil, errors.Errorf("invalid log segment size %d", segsize)
+	}
+	v.segsize = segsize
+	if err := v.seek(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+// Close detaches the reader from the shared memory log. A Next call waiting
+// for new records returns os.ErrClosed.
+func (v *VSMReader) Close() error {
+	v.closed.Store(true)
+	return v.f.Close()
+}
+
+// read32 reads a 32-bit word at the given offset of the log segment.
+func (v *VSMReader) read32(off int64) (uint32, error) {
+	var b [4]byte
+	if _, err := v.f.ReadAt(b[:], v.base+off); err != nil {
+		return 0, errors.Wrap(err, "cannot read log segment")
+	}
+	return binary.NativeEndian.Uint32(b[:]), nil
+}
+
+// read64 reads a 64-bit word at the given offset of the log segment.
+func (v *VSMReader) read64(off int64) (int64, error) {
+	var b [8]byte
+	if _, err := v.f.ReadAt(b[:], v.base+off); err != nil {
+		return 0, errors.Wrap(err, "cannot read log segment")
+	}
+	return int64(binary.NativeEndian.Uint64(b[:])), nil
+}
+
+// word reads the i-th word of the log.
+func (v *VSMReader) word(i int64) (uint32, error) {
+	return v.read32(vsmLogOffset + 4*i)
+}
+
+// seek moves the reader to the beginning of the segment most recently
+// started by Varnish.
+func (v *VSMReader) seek() error {
+	n, err := v.read32(vsmSegmentNOff)
+	if err != nil {
+		return err
+	}
+	ptr, err := v.read64(vsmOffsetsOffset + 8*int64(n%vsmSegments))
+	if err != nil {
+		return err
+	}
+	if ptr < 0 {
+		// Nothing was logged yet.
+		ptr = 0
+	}
+	v.seg = n
+	v.ptr = ptr
+	return nil
+}
+
+// overrun returns whether Varnish could have overwritten the segment which
+// the reader is in.
+func (v *VSMReader) overrun() (bool, error) {
+	n, err := v.read32(vsmSegmentNOff)
+	if err != nil {
+		return false, err
+	}
+	return int32(n-v.seg) >= vsmSegments-1, nil
+}
+
+// move moves the reader to the i-th word of the log, keeping track of the
+// segment it is in.
+func (v *VSMReader) move(i int64) {
+	from, to := v.ptr/v.segsize, i/v.segsize
+	if to < from {
+		to += vsmSegments
+	}
+	v.seg += uint32(to - from)
+	v.ptr = i
+}
+
+// Next reads the next record from the log, waiting for it to be written if
+// necessary. If Varnish overwrote records before they were read, ErrOverrun
+// is returned and reading continues with the most recent records.
+func (v *VSMReader) Next() (*RawRecord, error) {
+	for {
+		if v.closed.Load() {
+			return nil, os.ErrClosed
+		}
+		head, err := v.word(v.ptr)
+		if err != nil {
+			return nil, err
+		}
+		var r *RawRecord
+		switch {
+		case head == vsmEndMarker:
+			interval := v.PollInterval
+			if interval <= 0 {
+				interval = DefaultVSMPollInterval
+			}
+			time.Sleep(interval)
+		case head == vsmWrapMarker:
+			v.move(0)
+		case head>>24 == vsmBatchTag:
+			// Batches of records are read record by record.
+			v.move(v.ptr + 2)
+		default:
+			if r, err = v.record(head); err != nil {
+				return nil, err
+			}
+		}
+		// Whatever was read might have been overwritten in the meantime.
+		if over, err := v.overrun(); err != nil {
+			return nil, err
+		} else if over {
+			if err := v.seek(); err != nil {
+				return nil, err
+			}
+			return nil, ErrOverrun
+		}
+		if r != nil {
+			return r, nil
+		}
+	}
+}
+
+// record reads the record at the current position of the reader, starting
+// with the given header word, and moves past it.
+func (v *VSMReader) record(head uint32) (*RawRecord, error) {
+	id, err := v.word(v.ptr + 1)
+	if err != nil {
+		return nil, err
+	}
+	r, v3 := binaryHeader(v.Tags, head, id)
+	hdr := int64(2)
+	if v3 {
+		lo, err := v.word(v.ptr + 2)
+		if err != nil {
+			return nil, err
+		}
+		r.VXID = r.VXID<<32 | uint64(lo)
+		hdr = 3
+	}
+	n, words := binaryLength(head)
+	if cap(v.buf) < 4*words {
+		v.buf = make([]byte, 4*words)
+	}
+	buf := v.buf[:4*words]
+	if _, err := v.f.ReadAt(buf, v.base+vsmLogOffset+4*(v.ptr+hdr)); err != nil {
+		return nil, errors.Wrap(err, "cannot read log segment")
+	}
+	r.Value = binaryValue(buf[:n])
+	v.move(v.ptr + hdr + int64(words))
+	return r, nil
+}
+
+// NextEntry reads records from the log until a transaction is complete, and
+// returns its entry, grouping the records the same way varnishlog does in its
+// default VXID grouping. Records which belong to no transaction are skipped.
+//
+// Entries of transactions which began before the reader attached to the log
+// have Incomplete set. If Varnish overwrote records before they were read,
+// the open transactions are dropped and ErrOverrun is returned; entries of
+// transactions whose first records were lost have Incomplete set as well.
+// Once more than MaxOpen transactions are open, the entries of the oldest
+// ones are returned with Truncated set.
+func (v *VSMReader) NextEntry() (*Entry, error) {
+	v.grouper.max = v.MaxOpen
+	if v.grouper.max <= 0 {
+		v.grouper.max = DefaultVSMMaxOpen
+	}
+	for {
+		if e := v.grouper.next(); e != nil {
+			return e, nil
+		}
+		r, err := v.Next()
+		if err == ErrOverrun {
+			v.grouper.reset()
+		}
+		if err != nil {
+			return nil, err
+		}
+		v.grouper.add(r)
+	}
+}
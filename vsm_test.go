@@ -0,0 +1,144 @@
+package vslparser
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// vsmLog is a helper which builds the VSL segment of a fake shared memory of
+// Varnish.
+type vsmLog struct {
+	t       *testing.T
+	segsize int64
+	words   []uint32
+}
+
+// put writes a record at the i-th word of the log and returns the index of
+// the word following it.
+func (l *vsmLog) put(i int, tag string, vxid uint32, v string) int {
+	data := append([]byte(v), 0)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	l.words[i] = binaryTag(l.t, tag)<<24 | uint32(len(v)+1)
+	l.words[i+1] = binaryClientMarker | vxid
+	for j := 0; j < len(data); j += 4 {
+		l.words[i+2+j/4] = binary.NativeEndian.Uint32(data[j:])
+	}
+	return i + 2 + len(data)/4
+}
+
+// write writes the segment to the file fn, with the given current segment
+// number and segment offsets.
+func (l *vsmLog) write(fn string, segmentN uint32, offsets []int64) {
+	b := make([]byte, vsmLogOffset+4*len(l.words))
+	copy(b, "VSLHEAD1")
+	binary.NativeEndian.PutUint64(b[vsmSegsizeOffset:], uint64(l.segsize))
+	binary.NativeEndian.PutUint32(b[vsmSegmentNOff:], segmentN)
+	for i, o := range offsets {
+		binary.NativeEndian.PutUint64(b[vsmOffsetsOffset+8*i:], uint64(o))
+	}
+	for i, w := range l.words {
+		binary.NativeEndian.PutUint32(b[vsmLogOffset+4*i:], w)
+	}
+	// Write in place, as Varnish would, so that readers never see the file
+	// truncated.
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		l.t.Error(err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(b, 0); err != nil {
+		l.t.Error(err)
+	}
+}
+
+// TestVSMReader tests that records are read from the shared memory log,
+// including across the wrap-around of the log.
+func TestVSMReader(t *testing.T) {
+	dir := t.TempDir()
+	child := filepath.Join(dir, "_.vsm_child")
+	if err := os.Mkdir(child, 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := "# 1234 1545037998\n+ _.Stat.1 0 1024 Stat \n+ _.Log.1 0 640 Log \n"
+	if err := os.WriteFile(filepath.Join(child, "_.index"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l := &vsmLog{t: t, segsize: 16, words: make([]uint32, 16*vsmSegments)}
+	offsets := []int64{0, -1, -1, -1, -1, -1, -1, 112}
+	i := l.put(112, "Begin", 2, "req 1 rxreq")
+	i = l.put(i, "ReqURL", 2, "/foo")
+	l.words[i] = vsmWrapMarker
+	i = l.put(0, "End", 2, "")
+	l.words[i] = vsmEndMarker
+	fn := filepath.Join(child, "_.Log.1")
+	l.write(fn, 7, offsets)
+
+	v, err := OpenVSM(dir)
+	if err != nil {
+		t.Fatalf("failed to open shared memory log: %v", err)
+	}
+	defer v.Close()
+	v.PollInterval = time.Millisecond
+	e, err := v.NextEntry()
+	if err != nil {
+		t.Fatalf("failed to read entry from shared memory log: %v", err)
+	}
+	want := &Entry{
+		Kind:  Request,
+		VXID:  2,
+		Level: 1,
+		Fields: Fields{
+			"Begin":  []string{"req 1 rxreq"},
+			"ReqURL": []string{"/foo"},
+		},
+		Records: Records{{"Begin", "req 1 rxreq"}, {"ReqURL", "/foo"}},
+	}
+	if !reflect.DeepEqual(want, e) {
+		t.Errorf("reading entry should give %v, got %v", want, e)
+	}
+
+	// Append another record while the reader waits for it.
+	next := i
+	written := make(chan int)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		j := l.put(next, "CLI", 0, "Rd ping")
+		l.words[j] = vsmEndMarker
+		l.write(fn, 8, offsets)
+		written <- j
+	}()
+	r, err := v.Next()
+	if err != nil {
+		t.Fatalf("failed to read record from shared memory log: %v", err)
+	}
+	if r.Tag != "CLI" || r.Value != "Rd ping" {
+		t.Errorf("reading record should give the CLI record, got %v", r)
+	}
+
+	// Varnish moving far ahead of the reader is an overrun, which drops the
+	// transactions being read.
+	j := l.put(<-written, "Begin", 3, "req 1 rxreq")
+	l.words[j] = vsmEndMarker
+	l.write(fn, 8, offsets)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		l.write(fn, 20, offsets)
+	}()
+	if _, err := v.NextEntry(); err != ErrOverrun {
+		t.Errorf("reading overwritten records should give ErrOverrun, got: %v", err)
+	}
+	if len(v.grouper.open) != 0 {
+		t.Errorf("overrun should drop the open transactions, got %d", len(v.grouper.open))
+	}
+
+	if _, err := OpenVSM(t.TempDir()); err == nil {
+		t.Errorf("opening shared memory log of a missing instance should fail")
+	}
+}
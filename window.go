@@ -0,0 +1,26 @@
+package vslparser
+
+import (
+	"time"
+)
+
+// TimeWindow is a Filter selecting the transactions which started within a
+// range of time, e.g. to slice a large capture. The start of transactions is
+// taken from their records, see StartTime, so that a capture selects the
+// same entries whenever it is processed. Entries without a start, e.g. of
+// records which belong to no transaction, are not selected.
+type TimeWindow struct {
+	From time.Time // Start of the range, unbounded if zero.
+	To   time.Time // End of the range, excluded, unbounded if zero.
+}
+
+// Contains returns whether the time t is within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	return (w.From.IsZero() || !t.Before(w.From)) && (w.To.IsZero() || t.Before(w.To))
+}
+
+// Match returns whether the entry e started within the window.
+func (w TimeWindow) Match(e *Entry) bool {
+	start, err := e.StartTime()
+	return err == nil && w.Contains(start)
+}
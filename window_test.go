@@ -0,0 +1,35 @@
+package vslparser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTimeWindow tests that entries are selected by the Start timestamps of
+// transactions and the SessOpen records of sessions, and that either end of
+// a window may be unbounded.
+func TestTimeWindow(t *testing.T) {
+	base := time.Unix(1545037998, 0)
+	w := TimeWindow{From: base, To: base.Add(time.Minute)}
+	samples := map[*Entry]bool{
+		NewEntry(Request, 1).Add("Timestamp", "Start: 1545037998.000000 0.000000 0.000000"):            true,
+		NewEntry(Request, 2).Add("Timestamp", "Start: 1545037997.999999 0.000000 0.000000"):            false,
+		NewEntry(BeReq, 3).Add("Timestamp", "Start: 1545038057.999999 0.000000 0.000000"):              true,
+		NewEntry(BeReq, 4).Add("Timestamp", "Start: 1545038058.000000 0.000000 0.000000"):              false,
+		NewEntry(Session, 5).Add("SessOpen", "127.0.0.1 44876 a0 127.0.0.1 6081 1545038000.000000 17"): true,
+		NewEntry(Request, 6).Add("Timestamp", "Resp: 1545038000.000000 0.000000 0.000000"):             false,
+		NewEntry(Raw, 0).Add("CLI", "Rd ping"):                                                         false,
+	}
+	for e, want := range samples {
+		if got := w.Match(e); got != want {
+			t.Errorf("window should give %v for entry %d, got %v", want, e.VXID, got)
+		}
+	}
+	late := NewEntry(Request, 7).Add("Timestamp", "Start: 1645037998.000000 0.000000 0.000000")
+	if !(TimeWindow{From: base}).Match(late) || (TimeWindow{To: base}).Match(late) {
+		t.Errorf("window without end should select late entry and window without start should not")
+	}
+	if !(TimeWindow{}).Contains(time.Time{}) {
+		t.Errorf("unbounded window should contain all times")
+	}
+}
@@ -0,0 +1,69 @@
+package vslparser
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// XVarnish represents a parsed value of the X-Varnish response header, which
+// Varnish sets to the VXID of the client request and, for cache hits, the
+// VXID of the transaction which fetched the object, e.g. "32770 32768".
+type XVarnish struct {
+	VXID    uint64 // VXID of the client request.
+	ObjVXID uint64 // VXID of the transaction which fetched the object, 0 for misses.
+}
+
+// ParseXVarnish parses the value of an X-Varnish header.
+func ParseXVarnish(v string) (*XVarnish, error) {
+	fs := strings.Fields(v)
+	if len(fs) != 1 && len(fs) != 2 {
+		return nil, errors.Errorf("X-Varnish header %q is malformed", v)
+	}
+	x := &XVarnish{}
+	var err error
+	if x.VXID, err = strconv.ParseUint(fs[0], 10, 64); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse X-Varnish header %q", v)
+	}
+	if len(fs) == 2 {
+		if x.ObjVXID, err = strconv.ParseUint(fs[1], 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse X-Varnish header %q", v)
+		}
+	}
+	return x, nil
+}
+
+// Hit returns whether the response was delivered from the cache.
+func (x *XVarnish) Hit() bool {
+	return x.ObjVXID != 0
+}
+
+// Match returns the entries, including transactions nested in them, which are
+// the client request or the transaction which fetched the object, in the
+// order in which they are visited by Walk.
+func (x *XVarnish) Match(entries []*Entry) []*Entry {
+	var matched []*Entry
+	for _, e := range entries {
+		e.Walk(func(e *Entry) bool {
+			if e.VXID == x.VXID || x.Hit() && e.VXID == x.ObjVXID {
+				matched = append(matched, e)
+			}
+			return true
+		})
+	}
+	return matched
+}
+
+// XVarnish parses and returns the final X-Varnish header of the response to a
+// client request.
+func (e *Entry) XVarnish() (*XVarnish, error) {
+	h, err := e.ReplayHeaders("Resp")
+	if err != nil {
+		return nil, err
+	}
+	v := h.Final.Get("X-Varnish")
+	if v == "" {
+		return nil, errors.New("entry has no X-Varnish response header")
+	}
+	return ParseXVarnish(v)
+}
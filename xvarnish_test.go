@@ -0,0 +1,55 @@
+package vslparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseXVarnish tests that X-Varnish headers of hits and misses are
+// parsed and that malformed ones produce errors.
+func TestParseXVarnish(t *testing.T) {
+	samples := map[string]*XVarnish{
+		"32770":         &XVarnish{VXID: 32770},
+		" 32770 32768 ": &XVarnish{VXID: 32770, ObjVXID: 32768},
+	}
+	for v, want := range samples {
+		if got, err := ParseXVarnish(v); err != nil || !reflect.DeepEqual(want, got) {
+			t.Errorf("parsing X-Varnish header %q should give %v, got %v (%v)", v, want, got, err)
+		}
+	}
+	for _, v := range []string{"", "1 2 3", "x", "1 -2"} {
+		if _, err := ParseXVarnish(v); err == nil {
+			t.Errorf("parsing X-Varnish header %q should fail", v)
+		} else {
+			t.Logf("parsing X-Varnish header %q gives: %v", v, err)
+		}
+	}
+
+	x, err := example().XVarnish()
+	if err != nil || x.VXID != 29236596 || x.Hit() {
+		t.Errorf("X-Varnish header of example should be a miss of 29236596, got %v (%v)", x, err)
+	}
+	if _, err := (&Entry{}).XVarnish(); err == nil {
+		t.Errorf("X-Varnish header of an empty entry should fail")
+	}
+}
+
+// TestXVarnishMatch tests that the client request and the transaction which
+// fetched the object are matched, including nested transactions.
+func TestXVarnishMatch(t *testing.T) {
+	bereq := &Entry{Kind: BeReq, VXID: 3}
+	entries := []*Entry{
+		&Entry{Kind: Request, VXID: 2, Children: []*Entry{bereq}},
+		&Entry{Kind: Request, VXID: 4},
+		&Entry{Kind: Request, VXID: 5},
+	}
+	if got := (&XVarnish{VXID: 5, ObjVXID: 3}).Match(entries); !reflect.DeepEqual([]*Entry{bereq, entries[2]}, got) {
+		t.Errorf("matching hit of 3 by 5 should give entries 3 and 5, got %v", got)
+	}
+	if got := (&XVarnish{VXID: 4}).Match(entries); !reflect.DeepEqual([]*Entry{entries[1]}, got) {
+		t.Errorf("matching miss by 4 should give entry 4, got %v", got)
+	}
+	if got := (&XVarnish{VXID: 7}).Match(entries); got != nil {
+		t.Errorf("matching miss by 7 should give no entries, got %v", got)
+	}
+}